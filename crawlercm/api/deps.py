@@ -1,6 +1,28 @@
-from fastapi import Request, HTTPException, status
+from pathlib import Path
+from typing import Callable, Dict, Iterable, List, Optional, Tuple
+
+from fastapi import Depends, Request, HTTPException, Security, status
+from fastapi.security import APIKeyHeader, HTTPAuthorizationCredentials, HTTPBearer
 import aiohttp
 
+from core.api_keys import Action, InvalidApiKey, Principal, authenticate
+from core.config import settings
+from core.utils.parsers import portal_now
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.runner_schemas import RunConfig
+from services.export.destinations import FILE_DESTINATION, destination_of
+from services.export.naming import is_template, render_template, template_values
+from services.report_registry import get_report
+
+# Security schemes of the API keys, declared in the OpenAPI document so
+# generated clients send them. Either one is enough.
+API_KEY_HEADER = APIKeyHeader(
+    name="X-API-Key",
+    auto_error=False,
+    description="API key of `API_KEYS`, required when the server has keys.",
+)
+BEARER = HTTPBearer(auto_error=False, description="API key of `API_KEYS`, as a bearer token.")
+
 
 def get_authenticated_client(request: Request) -> aiohttp.ClientSession:
     """
@@ -32,3 +54,163 @@ def get_authenticated_client(request: Request) -> aiohttp.ClientSession:
             detail="No authenticated client session available.",
         )
     return request.app.state.http_client
+
+
+def get_principal(
+    request: Request,
+    api_key: Optional[str] = Security(API_KEY_HEADER),
+    bearer: Optional[HTTPAuthorizationCredentials] = Security(BEARER),
+) -> Principal:
+    """
+    Dependency function authenticating the API key of a request.
+
+    The key is read from the `X-API-Key` header, or from an
+    `Authorization: Bearer` header, and the caller is kept in
+    `request.state.principal` (see `core.api_keys`).
+
+    Raises:
+        HTTPException: If `API_KEYS` is set and the key is missing or
+            unknown (401).
+
+    Args:
+        request (Request): The FastAPI request object.
+        api_key (Optional[str]): Key of the `X-API-Key` header.
+        bearer (Optional[HTTPAuthorizationCredentials]): Bearer token of
+            the `Authorization` header.
+
+    Returns:
+        Principal: The caller and what its key grants.
+    """
+    secret = api_key or (bearer.credentials if bearer is not None else None)
+    try:
+        principal = authenticate(secret)
+    except InvalidApiKey as e:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail=str(e),
+            headers={"WWW-Authenticate": "Bearer"},
+        )
+    request.state.principal = principal
+    return principal
+
+
+def check_access(principal: Principal, action: Action, reports: Iterable[str] = ()) -> None:
+    """
+    Check that a caller may perform an action on some reports.
+
+    Raises:
+        HTTPException: If the key of the caller does not grant them (403).
+    """
+    try:
+        principal.check(action, reports)
+    except PermissionError as e:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail=str(e))
+
+
+def require(action: Action, report: Optional[str] = None) -> Callable[..., Principal]:
+    """
+    Dependency factory requiring an API key that grants an action.
+
+    The report is the one given, else the `report` path parameter of the
+    route, with dashes as underscores. Reading with `fresh=true` requires
+    `refresh`.
+
+    Args:
+        action (Action): Action of the route.
+        report (Optional[str], optional): Report of the route, for routes
+            of a single report.
+
+    Returns:
+        Callable[..., Principal]: The dependency, returning the caller.
+    """
+
+    def dependency(request: Request, principal: Principal = Depends(get_principal)) -> Principal:
+        name = report or request.path_params.get("report")
+        required = action
+        if action == "read" and request.query_params.get("fresh", "").lower() in ("1", "true"):
+            required = "refresh"
+        check_access(principal, required, [name.replace("-", "_")] if name else [])
+        return principal
+
+    return dependency
+
+
+
+def _outside_export_dir(path: str) -> bool:
+    root = Path(settings.API_EXPORT_DIR).resolve()
+    return not Path(path).resolve().is_relative_to(root)
+
+
+def _files_of(config: RunConfig) -> Tuple[List[str], bool]:
+    """
+    Files a run configuration writes, with their placeholders filled as the
+    run would fill them, and whether it delivers anywhere the server does
+    not name itself.
+    """
+    samples: Dict[str, Dataset] = {}
+    files: List[str] = []
+    deliveries = bool(config.bundles or config.manifest)
+    for job in config.reports:
+        definition = get_report(job.report)
+        filters = definition.parse_filters(job.filters).model_dump(mode="json")
+        samples[job.report] = sample = Dataset(
+            metadata=DatasetMetadata(
+                report=definition.name, fetched_at=portal_now(), filters=filters
+            )
+        )
+        deliveries = deliveries or job.email is not None
+        for destination in job.destinations:
+            target = destination if isinstance(destination, str) else destination.target
+            if destination_of(target) is FILE_DESTINATION:
+                deliveries = True
+                files.append(render_template(target, sample) if is_template(target) else target)
+            elif "://" in target:
+                deliveries = True
+    for bundle in config.bundles:
+        names = [name for name in bundle.reports or list(samples) if name in samples]
+        values = template_values(samples[names[0]]) if names else {}
+        try:
+            files.append(bundle.target.format_map({**values, "report": bundle.name}))
+        except (KeyError, IndexError) as e:
+            raise ValueError(f"Invalid template {bundle.target!r}: unknown placeholder {e}")
+    if config.manifest:
+        manifest = config.manifest.format(run_started_at=portal_now())
+        if Path(manifest).name != manifest or manifest in (".", ".."):
+            raise ValueError(f"Manifest {config.manifest!r} is not a file name")
+    return files, deliveries
+
+
+def check_run_config(principal: Principal, config: RunConfig) -> None:
+    """
+    Check that a caller may run a batch run configuration sent to the API.
+
+    Its reports need `refresh`. Destinations named by the server settings
+    (`postgres`, `s3?format=csv`, plugin names...) need nothing more; file
+    paths, URLs, bundles, manifests and e-mails set by the caller need
+    `admin`, as they write wherever the server can reach. Files and
+    bundles must also be written inside `API_EXPORT_DIR`.
+
+    Args:
+        principal (Principal): The caller.
+        config (RunConfig): The run configuration of the request.
+
+    Raises:
+        HTTPException: If the configuration has an unknown report, invalid
+            filters or an invalid template (400), is not granted by the key
+            of the caller, or writes outside `API_EXPORT_DIR` (403).
+    """
+    check_access(principal, "refresh", [job.report for job in config.reports])
+    try:
+        files, deliveries = _files_of(config)
+    except KeyError as e:
+        raise HTTPException(status_code=400, detail=str(e).strip("'\""))
+    except (IndexError, ValueError) as e:
+        raise HTTPException(status_code=400, detail=f"Invalid batch run configuration: {e}")
+    if deliveries:
+        check_access(principal, "admin")
+    outside = [path for path in files if _outside_export_dir(path)]
+    if outside:
+        raise HTTPException(
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail=f"Files must be written inside {settings.API_EXPORT_DIR}: {', '.join(outside)}",
+        )
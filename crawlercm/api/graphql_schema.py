@@ -0,0 +1,289 @@
+"""
+GraphQL schema of the enriched sales items, for the dashboard.
+
+The dashboard asks for exactly the fields it shows, in one query, instead
+of joining the reports itself:
+
+    {
+      items(code: "PRD-001") {
+        code product stock unitPrice
+        priceHistory(limit: 10) { at price }
+        orders { customer productionOrder stage pendingMaterials { material pending } }
+      }
+    }
+
+Items are the products of the pending sales, one per code, joined with
+their production stage and pending materials by the filtered sales report
+(see `services.report_registry`), which is fetched with its dependencies
+through the batch runner and cached as the REST API
+(`REPORT_CACHE_TTL_SECONDS`). Price histories come from the stored
+snapshots of `pending_sales` (see `core.snapshot_store`), oldest first.
+With `API_KEYS` set, items need a key granting both reports, and `fresh`
+a `refresh` key (see `core.api_keys`).
+
+Requires strawberry, from the `graphql` extra; the schema is served at
+`/api/graphql` by `api.routes.graphql_router`.
+"""
+
+import asyncio
+from collections import defaultdict
+from datetime import date, datetime
+from typing import Any, Dict, List, Optional
+
+import strawberry
+from strawberry.types import Info
+
+from core.api_keys import Action, Principal
+from core.cache import report_cache
+from core.snapshot_store import snapshot_store
+from schemas.dataset_schemas import Dataset
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.report_registry import REPORTS, ReportContext
+from services.runner import run_reports
+
+# Reports of the items: the sales with their stock, and their join with the
+# production orders and pending materials.
+SALES_REPORT = "pending_sales"
+ITEMS_REPORT = "filtered_sales_report"
+
+
+@strawberry.type
+class PricePoint:
+    """
+    Unit price of an item in a stored run of the sales report.
+    """
+
+    at: datetime
+    price: float
+
+
+@strawberry.type
+class PendingMaterial:
+    """
+    A material still missing for a production order.
+    """
+
+    code: str
+    material: str
+    quantity: float
+    pending: float
+    unit: str
+    status: str
+    expected: Optional[date]
+
+
+@strawberry.type
+class SalesOrder:
+    """
+    A pending sales order line of an item, with its production stage.
+    """
+
+    customer: Optional[str]
+    order: str
+    production_order: str
+    due: Optional[date]
+    pending_quantity: int
+    unit_price: float
+    total: float
+    stage: str
+    pending_materials: List[PendingMaterial]
+
+
+@strawberry.type
+class Item:
+    """
+    A product of the pending sales, with its stock and orders.
+    """
+
+    code: str
+    product: str
+    stock: Optional[int]
+    unit_price: float
+    pending_quantity: int
+    orders: List[SalesOrder]
+
+    @strawberry.field(description="Unit prices of the last stored runs, oldest first.")
+    async def price_history(self, info: Info, limit: int = 30) -> List[PricePoint]:
+        if limit <= 0:
+            return []
+        return (await price_history(info.context, self.code, limit))[-limit:]
+
+
+@strawberry.type
+class ReportInfo:
+    """
+    A report of the registry.
+    """
+
+    name: str
+    description: str
+    filters: List[str]
+    depends_on: List[str]
+
+
+def _pending_material(row: Any) -> PendingMaterial:
+    return PendingMaterial(
+        code=row.codigo,
+        material=row.material,
+        quantity=row.quantidade,
+        pending=row.pendente,
+        unit=str(row.unidade),
+        status=row.situacao,
+        expected=row.previsao_mp,
+    )
+
+
+def build_items(sales: List[Any], enriched: List[Any]) -> List[Item]:
+    """
+    Items of the enriched sales lines, by product code, in order of code.
+
+    Args:
+        sales (List[Any]): Rows of the pending sales, for the customers and stock.
+        enriched (List[Any]): Rows of the filtered sales report.
+
+    Returns:
+        List[Item]: One item per product code.
+    """
+    customers = {(row.op, row.codigo): row.cliente for row in sales}
+    stock = {row.codigo: row.estoque for row in sales}
+    lines: Dict[str, List[Any]] = defaultdict(list)
+    for row in enriched:
+        lines[row.codigo].append(row)
+    items = []
+    for code in sorted(lines):
+        rows = lines[code]
+        items.append(
+            Item(
+                code=code,
+                product=rows[0].produto,
+                stock=stock.get(code),
+                unit_price=float(rows[-1].valor_unitario),
+                pending_quantity=sum(row.qtde_pendente for row in rows),
+                orders=[
+                    SalesOrder(
+                        customer=customers.get((row.op, code)),
+                        order=row.pedido_cliente,
+                        production_order=row.op,
+                        due=row.previsao,
+                        pending_quantity=row.qtde_pendente,
+                        unit_price=float(row.valor_unitario),
+                        total=float(row.valor_total),
+                        stage=row.etapa,
+                        pending_materials=[
+                            _pending_material(material) for material in row.materiais_pendentes
+                        ],
+                    )
+                    for row in rows
+                ],
+            )
+        )
+    return items
+
+
+def _load_prices(limit: int) -> Dict[str, List[PricePoint]]:
+    prices: Dict[str, List[PricePoint]] = defaultdict(list)
+    for info in snapshot_store.list(SALES_REPORT)[-limit:]:
+        snapshot = snapshot_store.load(SALES_REPORT, info.id)
+        seen = set()
+        for row in snapshot.rows:
+            if row.get("codigo") in seen or row.get("valor_unitario") is None:
+                continue
+            seen.add(row["codigo"])
+            price = PricePoint(at=snapshot.created_at, price=float(row["valor_unitario"]))
+            prices[row["codigo"]].append(price)
+    return prices
+
+
+async def price_history(context: Dict[str, Any], code: str, limit: int) -> List[PricePoint]:
+    """
+    Unit prices of a product in the last `limit` stored snapshots of the
+    sales report.
+
+    Snapshots are loaded in a worker thread, once per query and limit, in
+    `context`, for every item of it.
+    """
+    key = ("prices", limit)
+    if key not in context:
+        context[key] = asyncio.ensure_future(asyncio.to_thread(_load_prices, limit))
+    return (await context[key]).get(code, [])
+
+
+def _principal(info: Info) -> Principal:
+    # Authenticated by the dependency of the route (see `main`).
+    return info.context["request"].state.principal
+
+
+def _check(info: Info, action: Action, reports: List[str]) -> None:
+    _principal(info).check(action, reports)
+
+
+async def _datasets(info: Info, filters: Dict[str, Any], fresh: bool) -> Dict[str, Dataset]:
+    state = info.context["request"].app.state
+
+    async def fetch() -> Dict[str, Dataset]:
+        context = ReportContext(client=state.http_client, csrf_token=state.csrf_token)
+        results: Dict[str, Dataset] = {}
+        job = ReportJob(report=ITEMS_REPORT, filters=filters)
+        summary = await run_reports(context, RunConfig(reports=[job]), results=results)
+        failed = next((status for status in summary.results if status.status == "failed"), None)
+        if failed is not None:
+            raise RuntimeError(f"{failed.report} failed: {failed.error}")
+        return results
+
+    key = (ITEMS_REPORT, "graphql", tuple(sorted(filters.items())))
+    if fresh:
+        report_cache.invalidate(key)
+    return await report_cache.get_or_fetch(key, fetch)
+
+
+@strawberry.type
+class Query:
+    """
+    Queries of the schema.
+    """
+
+    @strawberry.field(description="Registered reports, with their filters and dependencies.")
+    def reports(self, info: Info) -> List[ReportInfo]:
+        return [
+            ReportInfo(
+                name=definition.name,
+                description=definition.description,
+                filters=list(definition.filters_model.model_fields),
+                depends_on=definition.depends_on,
+            )
+            for definition in REPORTS.values()
+            if _principal(info).sees(definition.name)
+        ]
+
+    @strawberry.field(description="Products of the pending sales, of a code or customer if given.")
+    async def items(
+        self,
+        info: Info,
+        code: Optional[str] = None,
+        customer: Optional[str] = None,
+        init_date: Optional[date] = None,
+        end_date: Optional[date] = None,
+        fresh: bool = False,
+    ) -> List[Item]:
+        filters = {
+            name: value.isoformat()
+            for name, value in (("init_date", init_date), ("end_date", end_date))
+            if value is not None
+        }
+        _check(info, "refresh" if fresh else "read", [SALES_REPORT, ITEMS_REPORT])
+        datasets = await _datasets(info, filters, fresh)
+        items = build_items(datasets[SALES_REPORT].rows, datasets[ITEMS_REPORT].rows)
+        if code is not None:
+            items = [item for item in items if item.code == code]
+        if customer is not None:
+            wanted = customer.casefold()
+            for item in items:
+                item.orders = [
+                    order for order in item.orders if wanted in (order.customer or "").casefold()
+                ]
+                item.pending_quantity = sum(order.pending_quantity for order in item.orders)
+            items = [item for item in items if item.orders]
+        return items
+
+
+schema = strawberry.Schema(query=Query)
@@ -0,0 +1,246 @@
+"""
+gRPC server of the reports of CM, for the internal services preferring
+typed clients and streaming over the REST API.
+
+The service is defined by `protos/lanx/v1/reports.proto`, from which
+clients generate their stubs. The server loads the same file at runtime
+(`grpc.protos_and_services`), so no generated code is kept in the repo:
+
+    ListReports   → the registered reports and their filters
+    GetReport     → a report whole, through the batch runner, cached as
+                    the REST API (`REPORT_CACHE_TTL_SECONDS`)
+    StreamReport  → the rows of a report page by page, as CM returns them
+
+Failures of CM are returned as `UNAVAILABLE`, unknown reports as
+`NOT_FOUND` and invalid filters as `INVALID_ARGUMENT`. With `API_KEYS`
+set, calls send their key in the `x-api-key` metadata (see
+`core.api_keys`): missing or unknown keys are `UNAUTHENTICATED`, and keys
+not granting the report, or `refresh` for `fresh` and streams,
+`PERMISSION_DENIED`. Requires the
+`grpc` extra (grpcio and grpcio-tools).
+"""
+
+import asyncio
+import sys
+from pathlib import Path
+from typing import Any, AsyncIterator, Dict, List, Optional, Tuple
+
+import aiohttp
+from pydantic import BaseModel, ValidationError
+
+from core.api_keys import Action, InvalidApiKey, Principal, authenticate, bearer_key
+from core.cache import report_cache
+from core.errors import LoginError, error_kind
+from core.logger import logger, mask_secrets
+from core.utils.table_mapping import CellParseError, PageParseError, RowParseError
+from schemas.dataset_schemas import Dataset
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.export.json_export import row_json
+from services.report_registry import (
+    REPORTS,
+    ReportContext,
+    ReportDefinition,
+    get_report,
+    report_pages,
+)
+from services.runner import run_reports
+
+PROTO_DIR = Path(__file__).resolve().parent.parent / "protos"
+PROTO_FILE = "lanx/v1/reports.proto"
+
+# Errors of CM while streaming the pages of a report.
+STREAM_ERRORS = (
+    LoginError,
+    aiohttp.ClientError,
+    asyncio.TimeoutError,
+    CellParseError,
+    PageParseError,
+    RowParseError,
+)
+
+
+def grpc_modules() -> Tuple[Any, Any, Any]:
+    """
+    The grpc module and the messages and services of the proto file.
+
+    Raises:
+        RuntimeError: If grpcio or grpcio-tools is not installed.
+    """
+    try:
+        import grpc
+        import grpc_tools  # noqa: F401 (needed by protos_and_services)
+    except ImportError as e:
+        raise RuntimeError("The gRPC server requires grpcio; install the 'grpc' extra") from e
+    if str(PROTO_DIR) not in sys.path:
+        sys.path.append(str(PROTO_DIR))
+    protos, services = grpc.protos_and_services(PROTO_FILE)
+    return grpc, protos, services
+
+
+class ReportService:
+    """
+    Implementation of the `lanx.v1.ReportService` of the proto file.
+
+    Args:
+        session (aiohttp.ClientSession): Authenticated session of CM.
+        csrf_token (str): CSRF token of the session.
+        grpc (Any): The grpc module.
+        protos (Any): Messages of the proto file.
+    """
+
+    def __init__(self, session: aiohttp.ClientSession, csrf_token: str, grpc: Any, protos: Any):
+        self.session = session
+        self.csrf_token = csrf_token
+        self.grpc = grpc
+        self.protos = protos
+
+    def _rows(self, rows: List[Any]) -> List[Any]:
+        from google.protobuf.struct_pb2 import Struct
+
+        structs = []
+        for row in rows:
+            struct = Struct()
+            struct.update(row_json(row))
+            structs.append(struct)
+        return structs
+
+    async def _principal(self, context: Any) -> Principal:
+        metadata = dict(context.invocation_metadata() or ())
+        secret = metadata.get("x-api-key") or bearer_key(metadata.get("authorization"))
+        try:
+            return authenticate(secret)
+        except InvalidApiKey as e:
+            await context.abort(self.grpc.StatusCode.UNAUTHENTICATED, str(e))
+
+    async def _request(
+        self, request: Any, context: Any, action: Action
+    ) -> Tuple[ReportDefinition, Dict[str, Any]]:
+        principal = await self._principal(context)
+        try:
+            definition = get_report(request.report.replace("-", "_"))
+        except KeyError:
+            await context.abort(self.grpc.StatusCode.NOT_FOUND, f"Unknown report: {request.report}")
+        try:
+            principal.check(action, [definition.name])
+        except PermissionError as e:
+            await context.abort(self.grpc.StatusCode.PERMISSION_DENIED, str(e))
+        try:
+            # Validated filters, as the REST API keys the report cache on them.
+            filters = definition.parse_filters(dict(request.filters)).model_dump(mode="json")
+        except ValidationError as e:
+            await context.abort(
+                self.grpc.StatusCode.INVALID_ARGUMENT,
+                f"Invalid filters of {definition.name}: {e}",
+            )
+        return definition, filters
+
+    async def _run(self, jobs: List[ReportJob], context: Any) -> Dict[str, Dataset]:
+        report_context = ReportContext(client=self.session, csrf_token=self.csrf_token)
+        results: Dict[str, Dataset] = {}
+        summary = await run_reports(report_context, RunConfig(reports=jobs), results=results)
+        failed = next((status for status in summary.results if status.status == "failed"), None)
+        if failed is not None:
+            logger.error(f"Error fetching {failed.report}: {failed.error}")
+            await context.abort(
+                self._status_code(failed.error_kind),
+                mask_secrets(f"{failed.report} failed: {failed.error}"),
+            )
+        return results
+
+    def _status_code(self, kind: Optional[str]) -> Any:
+        # Failures of CM are UNAVAILABLE; anything else is INTERNAL.
+        if kind in ("auth", "portal", "parse"):
+            return self.grpc.StatusCode.UNAVAILABLE
+        return self.grpc.StatusCode.INTERNAL
+
+    async def ListReports(self, request: Any, context: Any) -> Any:
+        principal = await self._principal(context)
+        return self.protos.ListReportsResponse(
+            reports=[
+                self.protos.ReportInfo(
+                    name=definition.name,
+                    description=definition.description,
+                    filters=list(definition.filters_model.model_fields),
+                    depends_on=definition.depends_on,
+                    key_fields=definition.key_fields,
+                )
+                for definition in REPORTS.values()
+                if principal.sees(definition.name)
+            ]
+        )
+
+    async def GetReport(self, request: Any, context: Any) -> Any:
+        action: Action = "refresh" if request.fresh else "read"
+        definition, filters = await self._request(request, context, action)
+
+        async def fetch() -> Dataset:
+            job = ReportJob(report=definition.name, filters=filters)
+            return (await self._run([job], context))[definition.name]
+
+        # Shared with the REST API, so both serve the same cached fetch.
+        key = (definition.name, "dataset", tuple(sorted(filters.items())))
+        if request.fresh:
+            report_cache.invalidate(key)
+        dataset = await report_cache.get_or_fetch(key, fetch)
+        metadata = dataset.metadata
+        message = self.protos.Dataset(
+            metadata=self.protos.DatasetMetadata(
+                report=metadata.report,
+                source_url=metadata.source_url or "",
+                page_count=metadata.page_count,
+                row_count=metadata.row_count,
+                elapsed_seconds=metadata.elapsed_seconds,
+                parse_errors=len(metadata.parse_errors),
+            ),
+            rows=self._rows(dataset.rows),
+        )
+        message.metadata.fetched_at.FromDatetime(metadata.fetched_at)
+        message.metadata.filters.update(metadata.filters)
+        return message
+
+    async def StreamReport(self, request: Any, context: Any) -> AsyncIterator[Any]:
+        # Streams always scrape CM, bypassing the cache.
+        definition, filters = await self._request(request, context, "refresh")
+        deps: Dict[str, Dataset] = {}
+        if definition.depends_on:
+            jobs = [ReportJob(report=name, filters=filters) for name in definition.depends_on]
+            deps = await self._run(jobs, context)
+        report_context = ReportContext(client=self.session, csrf_token=self.csrf_token)
+        parsed: BaseModel = definition.parse_filters(filters)
+        page = 0
+        try:
+            async for rows in report_pages(definition, report_context, parsed, deps):
+                page += 1
+                yield self.protos.ReportPage(page=page, rows=self._rows(rows))
+        except STREAM_ERRORS as e:
+            logger.error(f"Error streaming {definition.name} after {page} pages: {e}")
+            await context.abort(
+                self._status_code(error_kind(e)), mask_secrets(f"{definition.name} failed: {e}")
+            )
+
+
+async def serve_grpc(address: str, session: aiohttp.ClientSession, csrf_token: str) -> None:
+    """
+    Serve the `ReportService` until cancelled.
+
+    Args:
+        address (str): Address to listen on, as host:port.
+        session (aiohttp.ClientSession): Authenticated session of CM.
+        csrf_token (str): CSRF token of the session.
+
+    Raises:
+        RuntimeError: If grpcio is not installed or the address cannot be bound.
+    """
+    grpc, protos, services = grpc_modules()
+    server = grpc.aio.server()
+    services.add_ReportServiceServicer_to_server(
+        ReportService(session, csrf_token, grpc, protos), server
+    )
+    if not server.add_insecure_port(address):
+        raise RuntimeError(f"Cannot listen on {address}")
+    await server.start()
+    logger.info(f"gRPC server listening on {address}.")
+    try:
+        await server.wait_for_termination()
+    finally:
+        await server.stop(grace=5)
@@ -0,0 +1,121 @@
+"""
+OpenAPI 3 document of the REST API, served at `/openapi.json`.
+
+FastAPI documents the annotated routes; this adds what the generic routes
+cannot tell, so other teams generate typed clients instead of reading the
+code:
+
+- one operation per report of the registry (see
+  `services.report_registry`), `GET /api/v1/reports/<report>`, with the
+  filters of the report as query parameters and its rows typed by its
+  `row_model`, e.g. `get_pending_orders_dataset` returning `PendingOrdersDataset`;
+- the version of the scraper (see `core.build_info`).
+
+Operations are named after their handlers (`list_jobs`, `submit_job`...),
+and the `X-API-Key` and bearer schemes of `API_KEYS` are declared on the
+protected operations. `lanx openapi` writes the same document to a file,
+for client generation in CI.
+"""
+
+import copy
+from typing import Any, Dict
+
+from fastapi import FastAPI
+from fastapi.openapi.utils import get_openapi
+from fastapi.routing import APIRoute
+from pydantic.json_schema import models_json_schema
+
+from services.report_registry import REPORTS, ReportDefinition
+
+REF_TEMPLATE = "#/components/schemas/{model}"
+
+# Path of the generic report route, whose operation the report operations copy.
+REPORT_PATH = "/api/v1/reports/{report}"
+
+
+def operation_id(route: APIRoute) -> str:
+    """
+    Operation id of a route: the name of its handler, unique in the API.
+    """
+    return route.name
+
+
+def _report_operation(
+    definition: ReportDefinition, generic: Dict[str, Any], schemas: Dict[str, Any]
+) -> Dict[str, Any]:
+    operation = copy.deepcopy(generic)
+    operation["operationId"] = f"get_{definition.name}_dataset"
+    operation["summary"] = definition.description
+    operation["description"] = (
+        f"Fetches the {definition.name} report, by the batch runner with its dependencies."
+    )
+    filters = definition.filters_model.model_json_schema(ref_template=REF_TEMPLATE)
+    required = set(filters.get("required", []))
+    parameters = [
+        {
+            "name": name,
+            "in": "query",
+            "required": name in required,
+            "description": schema.get("description", ""),
+            "schema": schema,
+        }
+        for name, schema in filters.get("properties", {}).items()
+    ]
+    parameters += [
+        parameter for parameter in generic.get("parameters", []) if parameter["in"] == "query"
+    ]
+    operation["parameters"] = parameters
+    if definition.row_model is not None:
+        model = definition.row_model.__name__
+        name = f"{model.removesuffix('Item')}Dataset"
+        schemas[name] = {
+            "title": name,
+            "type": "object",
+            "properties": {
+                "metadata": {"$ref": REF_TEMPLATE.format(model="DatasetMetadata")},
+                "rows": {"type": "array", "items": {"$ref": REF_TEMPLATE.format(model=model)}},
+            },
+            "required": ["metadata"],
+        }
+        content = operation["responses"]["200"]["content"]["application/json"]
+        content["schema"] = {"$ref": REF_TEMPLATE.format(model=name)}
+    return operation
+
+
+def build_openapi(app: FastAPI) -> Dict[str, Any]:
+    """
+    OpenAPI document of the app, with an operation per registered report.
+
+    The document is built once and kept in `app.openapi_schema`, as FastAPI
+    does.
+
+    Args:
+        app (FastAPI): The API.
+
+    Returns:
+        Dict[str, Any]: The OpenAPI 3 document.
+    """
+    if app.openapi_schema:
+        return app.openapi_schema
+    document = get_openapi(
+        title=app.title,
+        version=app.version,
+        description=app.description,
+        routes=app.routes,
+    )
+    generic = document.get("paths", {}).get(REPORT_PATH, {}).get("get")
+    if generic is not None:
+        schemas = document.setdefault("components", {}).setdefault("schemas", {})
+        models = [
+            (definition.row_model, "serialization")
+            for definition in REPORTS.values()
+            if definition.row_model is not None
+        ]
+        _, definitions = models_json_schema(models, ref_template=REF_TEMPLATE)
+        for name, schema in definitions.get("$defs", {}).items():
+            schemas.setdefault(name, schema)
+        for definition in REPORTS.values():
+            path = REPORT_PATH.replace("{report}", definition.name)
+            document["paths"][path] = {"get": _report_operation(definition, generic, schemas)}
+    app.openapi_schema = document
+    return document
@@ -0,0 +1,67 @@
+"""
+Routes for purchasing alerts computed from stored snapshots.
+
+Endpoints:
+    - /alerts/price_changes/{report} → Price changes between the last two runs of a report.
+"""
+
+from typing import List, Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query
+
+from api import deps
+from core.logger import logger
+from core.snapshot_store import snapshot_store
+from schemas.alert_schemas import PriceChange
+from services.alerts import latest_price_changes
+from services.report_registry import get_report
+
+router = APIRouter()
+
+
+@router.get(
+    "/alerts/price_changes/{report}",
+    response_model=List[PriceChange],
+    dependencies=[Depends(deps.require("read"))],
+)
+def get_price_changes(
+    report: str,
+    threshold_pct: float = 5.0,
+    key: Optional[List[str]] = Query(None),
+    price_field: Optional[str] = None,
+) -> List[PriceChange]:
+    """
+    Lists the rows whose price moved more than `threshold_pct` between the
+    last two stored snapshots of a report.
+
+    Args:
+        report (str): Report name.
+        threshold_pct (float, optional): Minimum absolute percentage change. Defaults to 5.
+        key (Optional[List[str]], optional): Fields identifying a material.
+            Defaults to the key fields of the report.
+        price_field (Optional[str], optional): Field holding the price.
+            Defaults to the price field of the report.
+
+    Returns:
+        List[PriceChange]: Price changes above the threshold.
+
+    Raises:
+        HTTPException: If the report is unknown (404), has no price field
+            and none is given (400), or the snapshots cannot be compared with
+            the given key (400).
+    """
+    try:
+        definition = get_report(report)
+    except KeyError as e:
+        raise HTTPException(status_code=404, detail=str(e).strip("'\""))
+    key = key or definition.key_fields
+    price_field = price_field or definition.price_field
+    if price_field is None:
+        raise HTTPException(
+            status_code=400, detail=f"{report} has no price field; give one with price_field"
+        )
+    try:
+        return latest_price_changes(snapshot_store, report, threshold_pct, key, price_field)
+    except (KeyError, ValueError) as e:
+        logger.error(f"Error computing price changes for {report}: {e}")
+        raise HTTPException(status_code=400, detail=f"Error computing price changes: {e}")
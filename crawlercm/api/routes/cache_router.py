@@ -0,0 +1,62 @@
+"""
+Routes for the in-process report cache.
+
+Reports are cached for `REPORT_CACHE_TTL_SECONDS`, or their own TTL in
+`REPORT_CACHE_TTLS` (see `core.cache`). When CM is known to have changed,
+such as after a new order is entered, the cached rows of a report are
+dropped here instead of waiting for them to expire. Reports built on the
+invalidated one (`ReportDefinition.depends_on`) are dropped with it.
+
+Endpoints:
+    - POST /v1/cache/invalidate/{report} → Drops the cached rows of a report.
+"""
+
+from typing import Any, Dict, List
+
+from fastapi import APIRouter, Depends, HTTPException
+
+from api import deps
+from core.cache import report_cache
+from core.logger import logger
+from services.report_registry import REPORTS, get_report
+
+router = APIRouter()
+
+
+def _dependents(name: str) -> List[str]:
+    names = [name]
+    for current in names:
+        names.extend(
+            definition.name
+            for definition in REPORTS.values()
+            if current in definition.depends_on and definition.name not in names
+        )
+    return names
+
+
+@router.post(
+    "/v1/cache/invalidate/{report}",
+    response_model=Dict[str, Any],
+    dependencies=[Depends(deps.require("refresh"))],
+)
+def invalidate_report(report: str) -> Dict[str, Any]:
+    """
+    Drops the cached rows of a report and of the reports built on it.
+
+    Args:
+        report (str): Report name, with underscores or dashes.
+
+    Returns:
+        Dict[str, Any]: Invalidated reports and number of entries removed.
+
+    Raises:
+        HTTPException: If the report is unknown (404).
+    """
+    try:
+        definition = get_report(report.replace("-", "_"))
+    except KeyError as e:
+        raise HTTPException(status_code=404, detail=str(e).strip("'\""))
+    reports = _dependents(definition.name)
+    removed = report_cache.invalidate_reports(reports)
+    logger.info(f"Invalidated {removed} cached entries of {', '.join(reports)}.")
+    return {"reports": reports, "removed": removed}
@@ -0,0 +1,138 @@
+"""
+Routes serving the registered reports as JSON, for internal apps.
+
+Any report of the registry (see `services.report_registry`) is fetched
+through the batch runner, with its dependencies, deduplication and
+validation, and returned with the provenance of its rows. Query
+parameters are the filters of the report, and report names may use
+dashes, as in the `lanx` CLI:
+
+    GET /api/v1/reports/pending-orders?init_date=2025-01-01
+
+Results are served from the in-process report cache when
+`REPORT_CACHE_TTL_SECONDS` or the TTL of the report in `REPORT_CACHE_TTLS`
+is set; `fresh=true` scrapes CM again.
+
+Endpoints:
+    - /v1/reports → Lists the registered reports and their filters.
+    - /v1/reports/{report} → Fetches a report as a dataset.
+"""
+
+from typing import Any, Dict, List
+
+import aiohttp
+from fastapi import APIRouter, Depends, HTTPException, Request
+from pydantic import ValidationError
+
+from api import deps
+from core.api_keys import Principal
+from core.cache import report_cache
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.report_registry import REPORTS, ReportContext, get_report
+from services.runner import run_reports
+
+router = APIRouter()
+
+# HTTP status of the failed reports, by error kind: failures of CM are
+# reported as a bad gateway, anything else as an internal error.
+ERROR_STATUS = {"auth": 502, "portal": 502, "parse": 502, "snapshot": 500, "error": 500}
+
+# Detail of the failed reports, by error kind; the error itself is only
+# logged, since the exceptions of CM hold its URLs.
+ERROR_DETAIL = {
+    "auth": "the CM login was rejected",
+    "portal": "CM could not be reached",
+    "parse": "the CM page could not be read",
+    "snapshot": "the previous snapshot could not be loaded",
+    "error": "internal error",
+}
+
+
+@router.get("/v1/reports", response_model=List[Dict[str, Any]])
+def list_reports(principal: Principal = Depends(deps.require("read"))) -> List[Dict[str, Any]]:
+    """
+    Lists the registered reports, with their filters and dependencies.
+
+    Args:
+        principal (Principal): Caller, whose API key grants the reports listed.
+
+    Returns:
+        List[Dict[str, Any]]: Name, description, filters and dependencies
+            of every report.
+    """
+    return [
+        {
+            "name": definition.name,
+            "description": definition.description,
+            "filters": list(definition.filters_model.model_fields),
+            "depends_on": definition.depends_on,
+        }
+        for definition in REPORTS.values()
+        if principal.sees(definition.name)
+    ]
+
+
+@router.get(
+    "/v1/reports/{report}",
+    response_model=Dataset,
+    dependencies=[Depends(deps.require("read"))],
+)
+async def get_report_dataset(
+    report: str,
+    request: Request,
+    fresh: bool = False,
+    client: aiohttp.ClientSession = Depends(deps.get_authenticated_client),
+) -> Dataset:
+    """
+    Fetches a report, with the filters given as query parameters.
+
+    Args:
+        report (str): Report name, with underscores or dashes.
+        request (Request): The FastAPI request object. Used to access the
+            filters and app state data (e.g., CSRF token).
+        fresh (bool, optional): Scrape CM even if the report is cached.
+            Defaults to False.
+        client (aiohttp.ClientSession): An authenticated HTTP client injected via dependency.
+
+    Returns:
+        Dataset: The report rows and their metadata.
+
+    Raises:
+        HTTPException: If the API key does not grant the report, or
+            `refresh` with `fresh` (403), the report is unknown (404), its
+            filters are invalid (422) or it could not be fetched (502 for
+            failures of CM, 500 otherwise).
+    """
+    try:
+        definition = get_report(report.replace("-", "_"))
+    except KeyError as e:
+        raise HTTPException(status_code=404, detail=str(e).strip("'\""))
+    query = {key: value for key, value in request.query_params.items() if key != "fresh"}
+    try:
+        # Parameters that are not filters of the report are dropped, so they
+        # cannot add entries to the cache.
+        filters = definition.parse_filters(query).model_dump(mode="json")
+    except ValidationError as e:
+        raise HTTPException(status_code=422, detail=f"Invalid filters of {definition.name}: {e}")
+
+    async def fetch() -> Dataset:
+        context = ReportContext(client=client, csrf_token=request.app.state.csrf_token)
+        results: Dict[str, Dataset] = {}
+        job = ReportJob(report=definition.name, filters=filters)
+        summary = await run_reports(context, RunConfig(reports=[job]), results=results)
+        if definition.name not in results:
+            failed = next(status for status in summary.results if status.status == "failed")
+            logger.error(f"Error fetching {definition.name}: {failed.error}")
+            kind = failed.error_kind or "error"
+            raise HTTPException(
+                status_code=ERROR_STATUS[kind],
+                detail=f"{failed.report} failed: {ERROR_DETAIL[kind]}",
+            )
+        return results[definition.name]
+
+    key = (definition.name, "dataset", tuple(sorted(filters.items())))
+    if fresh:
+        report_cache.invalidate(key)
+    return await report_cache.get_or_fetch(key, fetch)
@@ -0,0 +1,35 @@
+"""
+Route of the GraphQL schema of the dashboard (see `api.graphql_schema`).
+
+Endpoints:
+    - /graphql → Queries the enriched sales items, with GraphiQL on GET.
+
+Without the `graphql` extra, the route answers 501 with how to enable it,
+so the rest of the API serves as usual.
+"""
+
+from fastapi import APIRouter, HTTPException
+
+try:
+    from strawberry.fastapi import GraphQLRouter
+except ImportError:
+    GraphQLRouter = None
+
+if GraphQLRouter is not None:
+    from api.graphql_schema import schema
+
+    router = GraphQLRouter(schema, path="/graphql")
+else:
+    router = APIRouter()
+
+    @router.api_route("/graphql", methods=["GET", "POST"])
+    def graphql_unavailable() -> None:
+        """
+        Explains that the GraphQL schema needs the `graphql` extra.
+
+        Raises:
+            HTTPException: Always, with status 501.
+        """
+        raise HTTPException(
+            status_code=501, detail="GraphQL requires strawberry; install the 'graphql' extra"
+        )
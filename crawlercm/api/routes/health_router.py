@@ -0,0 +1,86 @@
+"""
+Liveness and readiness probes, for the container orchestrator and monitoring.
+
+`/healthz` only tells the process answers requests, so a hung process is
+restarted. `/readyz` also checks what the reports depend on, answering
+503 with the failed checks when any fails:
+
+- `portal`: CM still accepts the login of the session of the app;
+- `destinations`: the destinations of `REPORT_DEFAULTS`, `SCHEDULES` and
+  `PIPELINES` are configured and their hosts can be connected to, as
+  checked by `lanx config validate` (see `cli.config_command`).
+
+Endpoints:
+    - /healthz → The process is alive.
+    - /readyz → The CM session is valid and the destinations are reachable.
+"""
+
+import asyncio
+from typing import Any, Dict
+
+import aiohttp
+from fastapi import APIRouter, Request, Response
+
+from cli.config_command import CONNECT_TIMEOUT_SECONDS, ConfigValidator
+from core.logger import logger
+from core.session_manager import session_is_valid
+
+router = APIRouter()
+
+
+async def _portal_error(request: Request) -> str:
+    client = getattr(request.app.state, "http_client", None)
+    if client is None or client.closed:
+        return "no CM session"
+    try:
+        valid = await asyncio.wait_for(session_is_valid(client), CONNECT_TIMEOUT_SECONDS)
+    except asyncio.TimeoutError:
+        return "CM timed out"
+    except aiohttp.ClientError as e:
+        return f"cannot reach CM: {e}"
+    return "" if valid else "CM rejected the session"
+
+
+@router.get("/healthz")
+def healthz() -> Dict[str, str]:
+    """
+    Liveness probe.
+
+    Returns:
+        Dict[str, str]: The status of the process.
+    """
+    return {"status": "ok"}
+
+
+@router.get("/readyz")
+async def readyz(request: Request, response: Response) -> Dict[str, Any]:
+    """
+    Readiness probe.
+
+    Args:
+        request (Request): The FastAPI request object. Used to access the
+            CM session of the app.
+        response (Response): Response whose status is set to 503 when a
+            check fails.
+
+    Returns:
+        Dict[str, Any]: The status and the problems of every check, empty
+            when it passed.
+    """
+    validator = ConfigValidator()
+    await validator.check_report_defaults()
+    await validator.check_schedules()
+    await validator.check_pipelines()
+    portal = await _portal_error(request)
+    checks = {
+        "portal": [portal] if portal else [],
+        "destinations": [
+            f"{problem.location}: {problem.message}" for problem in validator.problems
+        ],
+    }
+    ready = not any(checks.values())
+    if not ready:
+        response.status_code = 503
+        problems = [problem for found in checks.values() for problem in found]
+        logger.warning(f"Not ready: {'; '.join(problems)}")
+    return {"status": "ready" if ready else "unavailable", "checks": checks}
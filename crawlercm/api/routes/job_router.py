@@ -0,0 +1,135 @@
+"""
+Routes for the background scrape jobs.
+
+Batch runs submitted here are queued and executed by the workers of the
+job queue (see `services.job_queue`), so slow scrapes do not depend on
+the lifetime of an HTTP request; the job is then polled until it finishes.
+
+Endpoints:
+    - POST /jobs → Queues a batch run and returns its job.
+    - GET /jobs → Lists the jobs, newest first, optionally by status.
+    - GET /jobs/{job_id} → Returns a job, with its summary once finished.
+    - DELETE /jobs/{job_id} → Cancels a job that has not started.
+"""
+
+from typing import List, Optional
+
+from fastapi import APIRouter, Depends, HTTPException
+
+from api import deps
+from core.api_keys import Principal
+from schemas.job_schemas import JobStatus, ScrapeJob
+from schemas.runner_schemas import RunConfig
+from services.job_queue import job_queue
+
+router = APIRouter()
+
+
+@router.post("/jobs", response_model=ScrapeJob, status_code=202)
+def submit_job(
+    config: RunConfig, principal: Principal = Depends(deps.require("refresh"))
+) -> ScrapeJob:
+    """
+    Queues a batch run.
+
+    Args:
+        config (RunConfig): Reports, filters and destinations to execute.
+        principal (Principal): Caller, whose API key must grant `refresh` and the
+            reports, and `admin` for the files, URLs and e-mails of the
+            configuration (see `deps.check_run_config`).
+
+    Returns:
+        ScrapeJob: The queued job.
+
+    Raises:
+        HTTPException: If the configuration references unknown reports (400),
+            or is not granted to the caller (403).
+    """
+    deps.check_run_config(principal, config)
+    return job_queue.submit(config)
+
+
+@router.get("/jobs", response_model=List[ScrapeJob])
+def list_jobs(
+    status: Optional[JobStatus] = None,
+    limit: int = 100,
+    principal: Principal = Depends(deps.require("read")),
+) -> List[ScrapeJob]:
+    """
+    Lists the jobs, newest first.
+
+    Args:
+        status (Optional[JobStatus], optional): Only jobs with this status.
+        limit (int, optional): Maximum number of jobs. Defaults to 100.
+        principal (Principal): Caller; only jobs of reports its API key
+            grants are listed.
+
+    Returns:
+        List[ScrapeJob]: The jobs.
+    """
+    jobs = [
+        job
+        for job in reversed(job_queue.store.list())
+        if status in (None, job.status)
+        and all(principal.sees(entry.report) for entry in job.config.reports)
+    ]
+    return jobs[:limit]
+
+
+@router.get("/jobs/{job_id}", response_model=ScrapeJob)
+def get_job(job_id: str, principal: Principal = Depends(deps.require("read"))) -> ScrapeJob:
+    """
+    Returns a job.
+
+    Args:
+        job_id (str): Job identifier.
+        principal (Principal): Caller, whose API key must grant the reports of the job.
+
+    Returns:
+        ScrapeJob: The job, with its summary once finished.
+
+    Raises:
+        HTTPException: If the job identifier is invalid (400), its reports
+            are not granted (403) or the job does not exist (404).
+    """
+    try:
+        job = job_queue.store.load(job_id)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    deps.check_access(principal, "read", [entry.report for entry in job.config.reports])
+    return job
+
+
+@router.delete("/jobs/{job_id}", response_model=ScrapeJob)
+def cancel_job(job_id: str, principal: Principal = Depends(deps.require("refresh"))) -> ScrapeJob:
+    """
+    Cancels a job that has not started.
+
+    Args:
+        job_id (str): Job identifier.
+        principal (Principal): Caller, whose API key must grant `refresh`
+            and the reports of the job.
+
+    Returns:
+        ScrapeJob: The cancelled job.
+
+    Raises:
+        HTTPException: If the job identifier is invalid (400), its reports
+            are not granted (403), the job does not exist (404) or it is no
+            longer queued (409).
+    """
+    try:
+        reports = [entry.report for entry in job_queue.store.load(job_id).config.reports]
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    deps.check_access(principal, "refresh", reports)
+    try:
+        return job_queue.cancel(job_id)
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except ValueError as e:
+        raise HTTPException(status_code=409, detail=str(e))
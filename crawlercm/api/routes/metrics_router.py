@@ -0,0 +1,32 @@
+"""
+Route exposing the Prometheus metrics of the API (see `core.metrics`).
+
+Scraped by Prometheus so Grafana can alert when CM slows down, logins
+fail or the layout of a report changes and its cells stop parsing:
+
+    scrape_configs:
+      - job_name: lanx
+        static_configs: [{targets: ["lanx:8000"]}]
+
+Endpoints:
+    - /metrics → The metrics in the Prometheus text format.
+"""
+
+from fastapi import APIRouter, Response
+
+from core.metrics import registry
+
+router = APIRouter()
+
+CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+
+@router.get("/metrics", response_class=Response)
+def metrics() -> Response:
+    """
+    Renders the metrics of the process.
+
+    Returns:
+        Response: The metrics in the Prometheus text format.
+    """
+    return Response(content=registry.render(), media_type=CONTENT_TYPE)
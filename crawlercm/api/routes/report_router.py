@@ -4,7 +4,8 @@ Routes for retrieving reports data via scraping.
 This module defines endpoints that trigger asynchronous scraping
 processes to fetch sales, production, and material-related reports
 from external systems. Each route manages its own date range defaults,
-formatting, and exception handling.
+formatting, and exception handling. Results are served from the
+in-process report cache when `REPORT_CACHE_TTL_SECONDS` is set.
 
 Endpoints:
     - /pending_sales → Fetches sales report data.
@@ -13,7 +14,7 @@ Endpoints:
 """
 
 import aiohttp
-from typing import List, Optional
+from typing import List, Optional, Tuple
 from datetime import date, timedelta
 
 from fastapi import APIRouter, Depends, Request, HTTPException, Response
@@ -31,16 +32,38 @@ from services.scrape_reports import (
     scrape_prod_pending_orders,
     scrape_sales_pending_orders,
 )
+from core.cache import report_cache
 from core.utils.format_excel import format_data_for_excel
+from core.utils.parsers import portal_today
 from core.logger import logger
 from core.config import settings
 
 router = APIRouter()
-init_date_str = (date.today() - timedelta(days=15)).strftime("%d/%m/%Y")
-end_date_str = (date.today() + timedelta(days=90)).strftime("%d/%m/%Y")
 
 
-@router.get("/pending_sales", response_model=List[SalesReportItem])
+def _date_range(init_date: Optional[date], end_date: Optional[date]) -> Tuple[str, str]:
+    """
+    Format the date range of a request as the DD/MM/YYYY strings expected by
+    CM. Missing dates default to 15 days ago and 90 days from today, as of
+    the request.
+
+    Args:
+        init_date (Optional[date]): Start date of the request, if any.
+        end_date (Optional[date]): End date of the request, if any.
+
+    Returns:
+        Tuple[str, str]: Formatted start and end dates.
+    """
+    init_date = init_date or portal_today() - timedelta(days=15)
+    end_date = end_date or portal_today() + timedelta(days=90)
+    return init_date.strftime("%d/%m/%Y"), end_date.strftime("%d/%m/%Y")
+
+
+@router.get(
+    "/pending_sales",
+    response_model=List[SalesReportItem],
+    dependencies=[Depends(deps.require("read", "pending_sales"))],
+)
 async def get_sales_pending_orders(
     client: aiohttp.ClientSession = Depends(deps.get_authenticated_client),
     init_date: Optional[date] = None,
@@ -66,19 +89,14 @@ async def get_sales_pending_orders(
         HTTPException: If any error occurs during the scraping process.
     """
     try:
-        if init_date is None:
-            init_date = init_date_str
-        else:
-            init_date = init_date.strftime("%d/%m/%Y")
-
-        if end_date is None:
-            end_date = end_date_str
-        else:
-            end_date = end_date.strftime("%d/%m/%Y")
+        init_date, end_date = _date_range(init_date, end_date)
 
         logger.info("Fetching sales pending orders...")
-        report_data = await scrape_sales_pending_orders(
-            client, settings.SALES_PENDING_ORDER_URL, init_date_str, end_date_str
+        report_data = await report_cache.get_or_fetch(
+            ("pending_sales", init_date, end_date),
+            lambda: scrape_sales_pending_orders(
+                client, settings.SALES_PENDING_ORDER_URL, init_date, end_date
+            ),
         )
         if not report_data:
             raise HTTPException(
@@ -91,7 +109,11 @@ async def get_sales_pending_orders(
         raise HTTPException(status_code=500, detail=f"Error fetching sales pending orders: {e}")
 
 
-@router.get("/pending_orders", response_model=List[PendingOrdersItem])
+@router.get(
+    "/pending_orders",
+    response_model=List[PendingOrdersItem],
+    dependencies=[Depends(deps.require("read", "pending_orders"))],
+)
 async def get_prod_pending_orders(
     request: Request,
     client: aiohttp.ClientSession = Depends(deps.get_authenticated_client),
@@ -123,24 +145,19 @@ async def get_prod_pending_orders(
     """
 
     try:
-        if init_date is None:
-            init_date = init_date_str
-        else:
-            init_date = init_date.strftime("%d/%m/%Y")
-
-        if end_date is None:
-            end_date = end_date_str
-        else:
-            end_date = end_date.strftime("%d/%m/%Y")
+        init_date, end_date = _date_range(init_date, end_date)
 
         logger.info("Fetching production pending orders...")
         csrf_token = request.app.state.csrf_token
-        report_data = await scrape_prod_pending_orders(
-            client,
-            settings.PROD_PENDING_ORDER_URL,
-            init_date_str,
-            end_date_str,
-            csrf_token,
+        report_data = await report_cache.get_or_fetch(
+            ("pending_orders", init_date, end_date),
+            lambda: scrape_prod_pending_orders(
+                client,
+                settings.PROD_PENDING_ORDER_URL,
+                init_date,
+                end_date,
+                csrf_token,
+            ),
         )
 
         if not report_data:
@@ -155,7 +172,11 @@ async def get_prod_pending_orders(
         raise HTTPException(status_code=500, detail=f"Error fetching production pending orders: {e}")
 
 
-@router.get("/pending_materials", response_model=List[PendingMaterialsItem])
+@router.get(
+    "/pending_materials",
+    response_model=List[PendingMaterialsItem],
+    dependencies=[Depends(deps.require("read", "pending_materials"))],
+)
 async def get_pending_materials(
     client: aiohttp.ClientSession = Depends(deps.get_authenticated_client),
 ) -> List[PendingMaterialsItem]:
@@ -177,8 +198,9 @@ async def get_pending_materials(
     """
     try:
         logger.info("Fetching pending materials...")
-        report_data = await scrape_pending_materials(
-            client, settings.PENDING_MATERIALS_URL
+        report_data = await report_cache.get_or_fetch(
+            ("pending_materials",),
+            lambda: scrape_pending_materials(client, settings.PENDING_MATERIALS_URL),
         )
 
         if not report_data:
@@ -191,7 +213,11 @@ async def get_pending_materials(
         raise HTTPException(status_code=500, detail=f"Error fetching pending materials: {e}")
 
 
-@router.get("/filtered_sales_report", response_model=List[FilteredSalesReportItem])
+@router.get(
+    "/filtered_sales_report",
+    response_model=List[FilteredSalesReportItem],
+    dependencies=[Depends(deps.require("read", "filtered_sales_report"))],
+)
 async def get_filtered_sales_report(
     request: Request,
     client: aiohttp.ClientSession = Depends(deps.get_authenticated_client), 
@@ -218,15 +244,7 @@ async def get_filtered_sales_report(
         HTTPException: If any error occurs during the scraping process.
     """
     try:
-        if init_date is None:
-            init_date = init_date_str
-        else:
-            init_date = init_date.strftime("%d/%m/%Y")
-
-        if end_date is None:
-            end_date = end_date_str
-        else:
-            end_date = end_date.strftime("%d/%m/%Y")
+        init_date, end_date = _date_range(init_date, end_date)
 
         logger.info("Fetching combining sales reports...")
         urls = {
@@ -236,8 +254,11 @@ async def get_filtered_sales_report(
         }
         csrf_token = request.app.state.csrf_token
 
-        report_data = await get_combined_report_data(
-            client, urls, init_date, end_date, csrf_token
+        report_data = await report_cache.get_or_fetch(
+            ("filtered_sales_report", init_date, end_date),
+            lambda: get_combined_report_data(
+                client, urls, init_date, end_date, csrf_token
+            ),
         )
         logger.info("Sales filtered report fetched successfully!")
         return report_data
@@ -245,7 +266,11 @@ async def get_filtered_sales_report(
         logger.error(f"Error fetching sales filtered report: {e}")
         raise HTTPException(status_code=500, detail=f"Error fetching sales filtered report: {e}")
     
-@router.get("/filtered_sales_report/export", tags=["Consolidated Reports"])
+@router.get(
+    "/filtered_sales_report/export",
+    tags=["Consolidated Reports"],
+    dependencies=[Depends(deps.require("read", "filtered_sales_report"))],
+)
 async def export_filtered_sales_report(
     request: Request,
     client: aiohttp.ClientSession = Depends(deps.get_authenticated_client),
@@ -271,15 +296,7 @@ async def export_filtered_sales_report(
     Raises:
         HTTPException: If an error occurs during the scraping or file generation process.
     """
-    if init_date is None:
-        init_date = init_date_str
-    else:
-        init_date = init_date.strftime("%d/%m/%Y")
-
-    if end_date is None:
-        end_date = end_date_str
-    else:
-        end_date = end_date.strftime("%d/%m/%Y")
+    init_date, end_date = _date_range(init_date, end_date)
     
     try:
         urls = {
@@ -289,12 +306,15 @@ async def export_filtered_sales_report(
         }
         csrf_token = request.app.state.csrf_token
         
-        report_data = await get_combined_report_data(
-            client, urls, init_date_str, end_date_str, csrf_token
+        report_data = await report_cache.get_or_fetch(
+            ("filtered_sales_report", init_date, end_date),
+            lambda: get_combined_report_data(
+                client, urls, init_date, end_date, csrf_token
+            ),
         )
         logger.info("Getting excel bytes for filtered sales report...")
         excel_bytes = format_data_for_excel(report_data)
-        file_name = f"relatorio_carteira_{date.today().strftime('%Y-%m-%d')}.xlsx"
+        file_name = f"relatorio_carteira_{portal_today().strftime('%Y-%m-%d')}.xlsx"
         media_type = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
         headers = {
             'Content-Disposition': f'attachment; filename="{file_name}"'
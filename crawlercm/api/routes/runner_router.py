@@ -0,0 +1,63 @@
+"""
+Routes for executing batch runs of reports.
+
+Endpoints:
+    - /run → Executes a configured list of reports and returns the run summary.
+"""
+
+import aiohttp
+from fastapi import APIRouter, Depends, HTTPException, Request
+
+from api import deps
+from core.api_keys import Principal
+from core.logger import logger
+from core.snapshot_store import snapshot_store
+from schemas.runner_schemas import RunConfig, RunSummary
+from services.progress import ProgressLog
+from services.report_registry import ReportContext
+from services.runner import run_reports
+
+router = APIRouter()
+
+
+@router.post("/run", response_model=RunSummary)
+async def run_batch(
+    config: RunConfig,
+    request: Request,
+    client: aiohttp.ClientSession = Depends(deps.get_authenticated_client),
+    principal: Principal = Depends(deps.require("refresh")),
+) -> RunSummary:
+    """
+    Executes a batch of reports.
+
+    Reports are executed respecting their dependencies and delivered to the
+    configured destinations, and every successful report run is stored as a
+    snapshot. The progress of long fetches is logged periodically. A
+    failure in one report does not abort the
+    others; the status of each one is returned in the summary.
+
+    Args:
+        config (RunConfig): Reports, filters and destinations to execute.
+        request (Request): The FastAPI request object. Used to access app state data (e.g., CSRF token).
+        client (aiohttp.ClientSession): An authenticated HTTP client injected via dependency.
+        principal (Principal): Caller, whose API key must grant `refresh` and the
+            reports, and `admin` for the files, URLs and e-mails of the
+            configuration (see `deps.check_run_config`).
+
+    Returns:
+        RunSummary: The consolidated run summary.
+
+    Raises:
+        HTTPException: If the configuration references unknown reports or circular
+            dependencies (400), or is not granted to the caller (403).
+    """
+    deps.check_run_config(principal, config)
+    try:
+        with ProgressLog() as progress:
+            context = ReportContext(
+                client=client, csrf_token=request.app.state.csrf_token, progress=progress
+            )
+            return await run_reports(context, config, snapshot_store)
+    except (KeyError, ValueError) as e:
+        logger.error(f"Invalid batch run configuration: {e}")
+        raise HTTPException(status_code=400, detail=f"Invalid batch run configuration: {e}")
@@ -0,0 +1,119 @@
+"""
+Routes for browsing stored report snapshots.
+
+Endpoints:
+    - /snapshots → Lists the reports that have stored snapshots.
+    - /snapshots/{report} → Lists the stored snapshots of a report.
+    - /snapshots/{report}/{snapshot_id} → Loads a stored snapshot.
+    - /snapshots/{report}/{snapshot_id}/rows → Pages through the rows of a snapshot.
+"""
+
+from typing import Any, Dict, List
+
+from fastapi import APIRouter, Depends, HTTPException
+
+from api import deps
+from core.api_keys import Principal
+from core.snapshot_store import snapshot_store
+from core.utils.pagination import PagedDataset
+from schemas.page_schemas import Page
+from schemas.snapshot_schemas import Snapshot, SnapshotInfo
+
+router = APIRouter()
+
+
+@router.get("/snapshots", response_model=List[str])
+def list_snapshot_reports(principal: Principal = Depends(deps.require("read"))) -> List[str]:
+    """
+    Lists the reports that have at least one stored snapshot.
+
+    Args:
+        principal (Principal): Caller, whose API key grants the reports listed.
+
+    Returns:
+        List[str]: Report names.
+    """
+    return [report for report in snapshot_store.reports() if principal.sees(report)]
+
+
+@router.get(
+    "/snapshots/{report}",
+    response_model=List[SnapshotInfo],
+    dependencies=[Depends(deps.require("read"))],
+)
+def list_snapshots(report: str) -> List[SnapshotInfo]:
+    """
+    Lists the stored snapshots of a report, oldest first.
+
+    Args:
+        report (str): Report name.
+
+    Returns:
+        List[SnapshotInfo]: Stored snapshots.
+
+    Raises:
+        HTTPException: If the report name is invalid.
+    """
+    try:
+        return snapshot_store.list(report)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+
+@router.get(
+    "/snapshots/{report}/{snapshot_id}",
+    response_model=Snapshot,
+    dependencies=[Depends(deps.require("read"))],
+)
+def get_snapshot(report: str, snapshot_id: str) -> Snapshot:
+    """
+    Loads a stored snapshot.
+
+    Args:
+        report (str): Report name.
+        snapshot_id (str): Snapshot identifier, as returned by the listing.
+
+    Returns:
+        Snapshot: The stored report run.
+
+    Raises:
+        HTTPException: If the report name is invalid or the snapshot does not exist.
+    """
+    try:
+        return snapshot_store.load(report, snapshot_id)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+
+
+@router.get(
+    "/snapshots/{report}/{snapshot_id}/rows",
+    response_model=Page[Dict[str, Any]],
+    dependencies=[Depends(deps.require("read"))],
+)
+def get_snapshot_rows(
+    report: str, snapshot_id: str, page: int = 1, size: int = 100
+) -> Page[Dict[str, Any]]:
+    """
+    Returns one page of the rows of a stored snapshot.
+
+    Args:
+        report (str): Report name.
+        snapshot_id (str): Snapshot identifier, as returned by the listing.
+        page (int, optional): Page number, starting at 1. Defaults to 1.
+        size (int, optional): Rows per page. Defaults to 100.
+
+    Returns:
+        Page[Dict[str, Any]]: The requested page of rows.
+
+    Raises:
+        HTTPException: If the paging parameters are invalid or the snapshot does not exist.
+    """
+    try:
+        snapshot = snapshot_store.load(report, snapshot_id)
+        return PagedDataset.from_rows(snapshot.rows).page(page, size)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
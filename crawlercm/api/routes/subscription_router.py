@@ -0,0 +1,119 @@
+"""
+Routes for the webhook subscriptions to report changes.
+
+External systems register an endpoint for a report, a filter of its rows
+and the kinds of change they want; the scheduled refreshes then POST them
+the changes they detect (see `services.subscriptions`). Secrets are never
+returned. With `API_KEYS` set, the routes need an `admin` key (see
+`core.api_keys`).
+
+Endpoints:
+    - POST /v1/subscriptions → Subscribes an endpoint to the changes of a report.
+    - GET /v1/subscriptions → Lists the subscriptions, optionally of a report.
+    - GET /v1/subscriptions/{subscription_id} → Returns a subscription.
+    - DELETE /v1/subscriptions/{subscription_id} → Removes a subscription.
+"""
+
+from typing import List, Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Response
+
+from api import deps
+from core.api_keys import Principal
+from core.subscription_store import subscription_store
+from schemas.subscription_schemas import Subscription, SubscriptionRequest
+from services.report_registry import get_report
+
+router = APIRouter(dependencies=[Depends(deps.require("admin"))])
+
+
+@router.post(
+    "/v1/subscriptions",
+    response_model=Subscription,
+    response_model_exclude={"secret"},
+    status_code=201,
+)
+def create_subscription(
+    request: SubscriptionRequest, principal: Principal = Depends(deps.get_principal)
+) -> Subscription:
+    """
+    Subscribes an endpoint to the changes of a report.
+
+    Args:
+        request (SubscriptionRequest): Endpoint, report, filter and kinds of change.
+        principal (Principal): Caller, whose API key must grant the report.
+
+    Returns:
+        Subscription: The subscription, with its identifier.
+
+    Raises:
+        HTTPException: If the report is unknown (400) or not granted (403).
+    """
+    try:
+        request.report = get_report(request.report.replace("-", "_")).name
+    except KeyError as e:
+        raise HTTPException(status_code=400, detail=str(e).strip("'\""))
+    deps.check_access(principal, "admin", [request.report])
+    return subscription_store.add(request)
+
+
+@router.get(
+    "/v1/subscriptions", response_model=List[Subscription], response_model_exclude={"secret"}
+)
+def list_subscriptions(report: Optional[str] = None) -> List[Subscription]:
+    """
+    Lists the subscriptions, oldest first.
+
+    Args:
+        report (Optional[str], optional): Only the subscriptions of this report.
+
+    Returns:
+        List[Subscription]: The subscriptions.
+    """
+    return subscription_store.list(report.replace("-", "_") if report else None)
+
+
+@router.get(
+    "/v1/subscriptions/{subscription_id}",
+    response_model=Subscription,
+    response_model_exclude={"secret"},
+)
+def get_subscription(subscription_id: str) -> Subscription:
+    """
+    Returns a subscription.
+
+    Args:
+        subscription_id (str): Subscription identifier.
+
+    Returns:
+        Subscription: The subscription.
+
+    Raises:
+        HTTPException: If the identifier is invalid or the subscription does not exist.
+    """
+    try:
+        return subscription_store.load(subscription_id)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+
+
+@router.delete("/v1/subscriptions/{subscription_id}", status_code=204)
+def delete_subscription(subscription_id: str) -> Response:
+    """
+    Removes a subscription.
+
+    Args:
+        subscription_id (str): Subscription identifier.
+
+    Raises:
+        HTTPException: If the identifier is invalid or the subscription does not exist.
+    """
+    try:
+        subscription_store.delete(subscription_id)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except FileNotFoundError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    return Response(status_code=204)
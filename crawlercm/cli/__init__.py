@@ -0,0 +1,25 @@
+"""
+Command line interface of the scraper.
+
+The `lanx` command runs the registered reports outside the API, for
+one-off pulls on a laptop and for the scheduled jobs of the automation
+server:
+
+    lanx init
+    lanx report pending-orders --filter init_date=2025-01-01 --output orders.csv
+    lanx report pending-materials --format csv > materials.csv
+    lanx reports list
+    lanx run --pipeline nightly
+    lanx login --account plant2
+    lanx tui pending-materials
+    lanx diff snapshot:pending_materials/latest~1 snapshot:pending_materials/latest
+    lanx watch pending-materials --interval 5m --on-change notify:slack
+    lanx config validate
+    lanx debug fetch-raw pending-orders
+    lanx serve-scheduler
+    lanx serve --port 8090
+    lanx serve-grpc --port 50051
+    lanx openapi --output openapi.json
+    source <(lanx completion bash)
+    lanx version
+"""
@@ -0,0 +1,9 @@
+"""
+Entry point of `python -m cli`.
+"""
+
+import sys
+
+from cli.app import main
+
+sys.exit(main())
@@ -0,0 +1,190 @@
+"""
+The `lanx` command.
+
+Builds the command line parser from the command modules of the package
+and runs the selected command. Logs go to stderr, so the output of a
+command can be piped:
+
+    lanx report pending-orders --format csv | head
+
+`--config` names a YAML config file (see `core.config`), read before any
+setting is used, and `--profile` one of its profiles; options given on the
+command line take precedence over the environment, which takes precedence
+over the config file, except for the settings of the profile:
+
+    lanx --profile homolog run --pipeline nightly
+
+Only warnings and errors are logged unless `--verbose` (INFO) or `--debug`
+(DEBUG, with every portal request) is given; `--log-format json` writes
+one JSON object per record, for the logs of scheduled runs. `--quiet`, for
+cron jobs, only prints errors and a single summary line, while the log
+file still receives the logs of `LOG_LEVEL`:
+
+    0 6 * * * lanx --quiet run --pipeline nightly
+
+Failures exit with stable codes by kind (see `cli.errors`), and
+`--error-format json` prints the error as a JSON object, for wrapper
+scripts and monitoring.
+"""
+
+import argparse
+import asyncio
+import logging
+import os
+import sys
+from typing import List, Optional, Sequence
+
+import aiohttp
+
+from cli.errors import CommandError, report_error
+from core.logger import configure_logging, log_to_stderr, set_console_level
+
+
+def _config_option(parser: argparse.ArgumentParser) -> None:
+    parser.add_argument(
+        "--config",
+        metavar="PATH",
+        help="YAML config file. Defaults to the LANX_CONFIG environment variable.",
+    )
+    parser.add_argument(
+        "--profile",
+        metavar="NAME",
+        help="Profile of the config file, such as homolog. Defaults to the LANX_PROFILE "
+        "environment variable.",
+    )
+
+
+def _logging_options(parser: argparse.ArgumentParser) -> None:
+    levels = parser.add_mutually_exclusive_group()
+    levels.add_argument(
+        "-v",
+        "--verbose",
+        dest="log_level",
+        action="store_const",
+        const=logging.INFO,
+        help="Log progress (INFO).",
+    )
+    levels.add_argument(
+        "--debug",
+        dest="log_level",
+        action="store_const",
+        const=logging.DEBUG,
+        help="Log everything, including every portal request (DEBUG).",
+    )
+    levels.add_argument(
+        "-q",
+        "--quiet",
+        action="store_true",
+        help="Print only errors and a summary line, e.g. for cron; the log file keeps "
+        "LOG_LEVEL.",
+    )
+    parser.add_argument(
+        "--log-format",
+        choices=["text", "json"],
+        help="Format of the logs. Defaults to LOG_FORMAT.",
+    )
+    parser.add_argument(
+        "--error-format",
+        choices=["text", "json"],
+        default="text",
+        help="Format of the error printed when the command fails.",
+    )
+
+
+def build_parser() -> argparse.ArgumentParser:
+    """
+    Parser of the `lanx` command line, with every subcommand.
+    """
+    from cli.completion_command import add_completion_commands
+    from cli.config_command import add_config_commands
+    from cli.debug_command import add_debug_commands
+    from cli.diff_command import add_diff_commands
+    from cli.init_command import add_init_commands
+    from cli.login_command import add_login_commands
+    from cli.openapi_command import add_openapi_commands
+    from cli.report_command import add_report_commands
+    from cli.reports_command import add_reports_commands
+    from cli.run_command import add_run_commands
+    from cli.scheduler_command import add_scheduler_commands
+    from cli.serve_command import add_serve_commands
+    from cli.tui_command import add_tui_commands
+    from cli.version_command import add_version_commands
+    from cli.watch_command import add_watch_commands
+
+    parser = argparse.ArgumentParser(
+        prog="lanx", description="Scrape and export the reports of CM."
+    )
+    _config_option(parser)
+    _logging_options(parser)
+    subparsers = parser.add_subparsers(dest="command", metavar="COMMAND", required=True)
+    add_init_commands(subparsers)
+    add_report_commands(subparsers)
+    add_reports_commands(subparsers)
+    add_run_commands(subparsers)
+    add_login_commands(subparsers)
+    add_diff_commands(subparsers)
+    add_watch_commands(subparsers)
+    add_tui_commands(subparsers)
+    add_scheduler_commands(subparsers)
+    add_serve_commands(subparsers)
+    add_openapi_commands(subparsers)
+    add_config_commands(subparsers)
+    add_debug_commands(subparsers)
+    add_completion_commands(subparsers)
+    add_version_commands(subparsers)
+    return parser
+
+
+def main(argv: Optional[Sequence[str]] = None) -> int:
+    """
+    Run the `lanx` command.
+
+    Args:
+        argv (Optional[Sequence[str]], optional): Arguments, without the
+            program name. Defaults to the arguments of the process.
+
+    Returns:
+        int: Exit status of the command, one of `cli.errors.EXIT_CODES`.
+    """
+    arguments: List[str] = list(sys.argv[1:] if argv is None else argv)
+    # The settings are read when the command modules are imported, so the
+    # config file and the logging are set up before anything else is parsed.
+    preparser = argparse.ArgumentParser(prog="lanx", add_help=False)
+    _config_option(preparser)
+    _logging_options(preparser)
+    options, arguments = preparser.parse_known_args(arguments)
+    config_file = options.config
+    if config_file:
+        os.environ["LANX_CONFIG"] = config_file
+    if options.profile:
+        os.environ["LANX_PROFILE"] = options.profile
+
+    log_to_stderr()
+    try:
+        # First import of the settings, so they are read with the config file.
+        from core.config import reload_settings, settings
+    except (ValueError, RuntimeError, IOError) as e:
+        return report_error(CommandError("error", f"Invalid settings: {e}"), options.error_format)
+    try:
+        from core.tracing import configure_tracing
+        from services.export.registry import load_plugins
+
+        if config_file or options.profile:
+            reload_settings()
+        log_format = options.log_format or settings.LOG_FORMAT
+        if options.quiet:
+            configure_logging(settings.LOG_LEVEL, log_format)
+            set_console_level(logging.ERROR)
+        else:
+            configure_logging(options.log_level or logging.WARNING, log_format)
+        load_plugins()
+        configure_tracing()
+        args = build_parser().parse_args(arguments)
+        args.quiet = options.quiet
+        return asyncio.run(args.handler(args))
+    except (
+        CommandError, KeyError, ValueError, IOError, RuntimeError, aiohttp.ClientError
+    ) as e:
+        return report_error(e, options.error_format)
+    except KeyboardInterrupt:
+        return 130
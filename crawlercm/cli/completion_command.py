@@ -0,0 +1,198 @@
+"""
+`lanx completion` command.
+
+Prints the shell completion script of `lanx` for bash, zsh or PowerShell:
+
+    source <(lanx completion bash)                    # ~/.bashrc
+    source <(lanx completion zsh)                     # ~/.zshrc, after compinit
+    lanx completion powershell | Out-String | Invoke-Expression   # $PROFILE
+
+The scripts complete through the hidden `lanx __complete` command, so
+completions follow the installed version: subcommands, options and their
+choices, and values read from the settings (with the `--config` file and
+`--profile` of the command line, if any), such as the report names, the
+profiles of `--profile`, the `ACCOUNTS` of `--account`, the `SCHEDULES`
+of `--job`, the `PIPELINES` of `--pipeline`, the `NOTIFY_WEBHOOKS` of
+`--on-change` and the filters of `--filter`.
+Values without completions (paths) fall back to file completion.
+"""
+
+import argparse
+import os
+from typing import List, Optional
+
+BASH_SCRIPT = """\
+_lanx() {
+    local IFS=$'\\n'
+    COMPREPLY=($(lanx __complete "--current=${COMP_WORDS[COMP_CWORD]}" -- \\
+        "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null))
+}
+complete -o default -F _lanx lanx
+"""
+
+ZSH_SCRIPT = """\
+#compdef lanx
+_lanx() {
+    local -a candidates
+    candidates=("${(@f)$(lanx __complete "--current=${words[CURRENT]}" -- \\
+        "${(@)words[2,CURRENT-1]}" 2>/dev/null)}")
+    if [[ -n "${candidates[1]}" ]]; then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+compdef _lanx lanx
+"""
+
+POWERSHELL_SCRIPT = """\
+Register-ArgumentCompleter -Native -CommandName lanx -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 |
+        Where-Object { $_.Extent.EndOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() })
+    lanx __complete "--current=$wordToComplete" -- @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+"""
+
+SCRIPTS = {"bash": BASH_SCRIPT, "zsh": ZSH_SCRIPT, "powershell": POWERSHELL_SCRIPT}
+
+
+def add_completion_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `completion` command and the hidden `__complete` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "completion",
+        help="Print the shell completion script.",
+        description="Print the completion script of lanx for a shell.",
+    )
+    parser.add_argument("shell", choices=sorted(SCRIPTS))
+    parser.set_defaults(handler=print_script)
+    # Without help, so it is not listed in the help of lanx.
+    complete_parser = subparsers.add_parser("__complete")
+    complete_parser.add_argument("--current", default="")
+    complete_parser.add_argument("words", nargs="*")
+    complete_parser.set_defaults(handler=print_completions)
+
+
+def _subcommands(parser: argparse.ArgumentParser) -> Optional[argparse._SubParsersAction]:
+    return next(
+        (action for action in parser._actions if isinstance(action, argparse._SubParsersAction)),
+        None,
+    )
+
+
+def _option(parser: argparse.ArgumentParser, flag: str) -> Optional[argparse.Action]:
+    return parser._option_string_actions.get(flag)
+
+
+def _values(parser: argparse.ArgumentParser, action: argparse.Action) -> List[str]:
+    from core.config import settings
+
+    if action.choices is not None:
+        return [str(choice) for choice in action.choices]
+    if action.dest == "profile":
+        return list(settings.PROFILES)
+    if action.dest == "account":
+        return list(settings.ACCOUNTS)
+    if action.dest == "jobs":
+        return list(settings.SCHEDULES)
+    if action.dest == "pipeline":
+        return list(settings.PIPELINES)
+    if action.dest == "actions":
+        return [f"notify:{channel}" for channel in settings.NOTIFY_WEBHOOKS]
+    definition = parser._defaults.get("definition")
+    if action.dest == "filters" and definition is not None:
+        return [f"{name}=" for name in definition.filters_model.model_fields]
+    return []
+
+
+def completions(words: List[str], current: str = "") -> List[str]:
+    """
+    Completions of a `lanx` command line.
+
+    Args:
+        words (List[str]): Words of the command line before the one being
+            completed, without the program name.
+        current (str, optional): Word being completed, possibly empty.
+
+    Returns:
+        List[str]: Candidates starting with `current`; empty when the word
+        takes a value without completions, such as a path.
+    """
+    from cli.app import build_parser
+
+    variables = {"--config": "LANX_CONFIG", "--profile": "LANX_PROFILE"}
+    given = {
+        variable: words[words.index(flag) + 1]
+        for flag, variable in variables.items()
+        if flag in words[:-1]
+    }
+    if given:
+        os.environ.update(given)
+        from core.config import reload_settings
+
+        reload_settings()
+    parser = build_parser()
+    expecting: Optional[argparse.Action] = None
+    for word in words:
+        if expecting is not None:
+            expecting = None
+        elif word.startswith("-"):
+            action = _option(parser, word.split("=", 1)[0])
+            if action is not None and action.nargs != 0 and "=" not in word:
+                expecting = action
+        else:
+            subcommands = _subcommands(parser)
+            if subcommands is not None and word in subcommands.choices:
+                parser = subcommands.choices[word]
+    if expecting is not None:
+        candidates = _values(parser, expecting)
+    elif current.startswith("-"):
+        candidates = [
+            flag
+            for action in parser._actions
+            if action.help != argparse.SUPPRESS
+            for flag in action.option_strings
+        ]
+    else:
+        subcommands = _subcommands(parser)
+        if subcommands is None:
+            candidates = [
+                str(choice)
+                for action in parser._actions
+                if not action.option_strings and action.choices is not None
+                for choice in action.choices
+            ]
+        else:
+            # Aliases share the parser of their command and are not offered.
+            seen: List[argparse.ArgumentParser] = []
+            candidates = []
+            for name, subparser in subcommands.choices.items():
+                if not name.startswith("__") and subparser not in seen:
+                    seen.append(subparser)
+                    candidates.append(name)
+    return [candidate for candidate in candidates if candidate.startswith(current)]
+
+
+async def print_script(args: argparse.Namespace) -> int:
+    """
+    Print the completion script of a shell.
+    """
+    print(SCRIPTS[args.shell], end="")
+    return 0
+
+
+async def print_completions(args: argparse.Namespace) -> int:
+    """
+    Print the completions of a command line, one per line.
+    """
+    for candidate in completions(args.words, args.current):
+        print(candidate)
+    return 0
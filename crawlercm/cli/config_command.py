@@ -0,0 +1,467 @@
+"""
+`lanx config validate` command.
+
+Checks the settings before the nightly run depends on them, printing one
+line per problem with where it is configured:
+
+    $ lanx config validate
+    accounts.plant2: The password of account plant2 is not set in CM_PLANT2_PASSWORD
+    pipelines.nightly: Unknown report: pending_stock
+    report_defaults.pending_orders.destinations[0]: cannot connect to db.local:5432: timed out
+    Checked 2 accounts, 3 report defaults, 1 schedule and 1 pipeline: 3 problems.
+
+The checks cover:
+
+- the reports, filters, formats and accounts referenced by
+  `REPORT_DEFAULTS`, `SCHEDULES` (with their cron expression) and `PIPELINES`;
+- the credentials of `ACCOUNTS`, whose passwords must resolve; `--login`
+  also logs in to CM with every account;
+- the secrets of `API_KEYS`, which must resolve;
+- every destination: the settings it needs (e.g. `POSTGRES_DSN` for
+  `postgres`), the extra installing its driver, writable directories for
+  files and, unless `--offline`, a connection to its host, as to CM and
+  the chat channels of `NOTIFY_WEBHOOKS`.
+
+Destinations registered by plugins are not checked. The command exits
+with 1 when a problem is found.
+"""
+
+import argparse
+import asyncio
+import importlib.util
+import json
+import os
+from dataclasses import asdict, dataclass
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple, Union
+from urllib.parse import urlparse
+
+import aiohttp
+
+from cli.report_command import account_credentials, output_destination
+from cli.run_command import pipeline_config
+from cli.scheduler_command import schedule_config
+from core.config import settings
+from core.session_manager import login, new_session
+from core.utils.cron import CronExpression
+from schemas.runner_schemas import DestinationConfig, RunConfig
+from services.export.files import EXPORTERS
+from services.export.registry import destination_for
+from services.report_registry import get_report
+
+# Seconds to wait for a connection to a host.
+CONNECT_TIMEOUT_SECONDS = 5.0
+
+# Settings needed by every destination of a kind.
+REQUIRED_SETTINGS: Dict[str, Tuple[str, ...]] = {
+    "gdrive": ("GOOGLE_SERVICE_ACCOUNT_FILE",),
+    "azure": ("AZURE_STORAGE_ACCOUNT_URL",),
+    "influxdb": ("INFLUXDB_URL", "INFLUXDB_TOKEN", "INFLUXDB_ORG"),
+    "kafka": ("KAFKA_BOOTSTRAP_SERVERS",),
+}
+
+# Further settings needed by the destinations without a URL (e.g. `s3` or
+# `s3?format=csv`), which read their target from the settings.
+NAMED_SETTINGS: Dict[str, Tuple[str, ...]] = {
+    "postgres": ("POSTGRES_DSN",),
+    "mysql": ("MYSQL_DSN",),
+    "gdrive": ("GOOGLE_DRIVE_FOLDER_ID",),
+    "s3": ("S3_BUCKET",),
+    "azure": ("AZURE_STORAGE_CONTAINER",),
+    "bigquery": ("BIGQUERY_PROJECT", "BIGQUERY_DATASET"),
+    "influxdb": ("INFLUXDB_BUCKET",),
+    "amqp": ("AMQP_URL",),
+    "mqtt": ("MQTT_HOST",),
+    "webhook": ("WEBHOOK_URL",),
+}
+
+# Module and extra of the driver of every destination with one.
+DRIVERS: Dict[str, Tuple[str, str]] = {
+    "postgres": ("psycopg", "postgres"),
+    "mysql": ("pymysql", "mysql"),
+    "sheets": ("gspread", "sheets"),
+    "gdrive": ("googleapiclient", "drive"),
+    "s3": ("boto3", "s3"),
+    "azure": ("azure.storage.blob", "azure"),
+    "bigquery": ("google.cloud.bigquery", "bigquery"),
+    "kafka": ("confluent_kafka", "kafka"),
+    "amqp": ("pika", "amqp"),
+    "mqtt": ("paho.mqtt", "mqtt"),
+    "sftp": ("paramiko", "sftp"),
+}
+
+DEFAULT_PORTS = {
+    "postgres": 5432,
+    "postgresql": 5432,
+    "mysql": 3306,
+    "amqp": 5672,
+    "amqps": 5671,
+    "mqtt": 1883,
+    "mqtts": 8883,
+    "sftp": 22,
+    "ftp": 21,
+    "ftps": 21,
+    "http": 80,
+    "https": 443,
+}
+
+# Destination kinds by URL scheme, when the scheme differs from the kind.
+SCHEME_KINDS = {
+    "postgresql": "postgres",
+    "gsheets": "sheets",
+    "amqps": "amqp",
+    "mqtts": "mqtt",
+    "ftp": "sftp",
+    "ftps": "sftp",
+    "http": "webhook",
+    "https": "webhook",
+}
+
+# Kinds whose URL names a server; the others name a bucket, topic, table...
+# on the server of the settings.
+SERVER_URLS = ("postgres", "mysql", "amqp", "mqtt", "sftp", "webhook")
+
+
+@dataclass
+class ConfigProblem:
+    """
+    A problem of the settings.
+    """
+
+    location: str
+    message: str
+
+
+def destination_kind(target: str) -> str:
+    """
+    Kind of a destination, as told apart by `services.export.destinations`:
+    `postgres`, `s3`, `webhook`, ..., or `file` for file paths.
+    """
+    if target.startswith("https://api.powerbi.com/"):
+        return "powerbi"
+    if "://" in target:
+        scheme = target.split("://", 1)[0]
+        return SCHEME_KINDS.get(scheme, scheme)
+    name = target.split("?", 1)[0]
+    named = (*NAMED_SETTINGS, "powerbi")
+    return name if name in named else "file"
+
+
+def _url_address(url: str, default_port: Optional[int] = None) -> Optional[Tuple[str, int]]:
+    parsed = urlparse(url)
+    port = parsed.port or DEFAULT_PORTS.get(parsed.scheme, default_port)
+    return (parsed.hostname, port) if parsed.hostname and port else None
+
+
+def destination_address(target: str) -> Optional[Tuple[str, int]]:
+    """
+    Host and port a destination connects to, when known from its URL or the settings.
+    """
+    kind = destination_kind(target)
+    if kind in SERVER_URLS and urlparse(target).netloc:
+        return _url_address(target, settings.MQTT_PORT if kind == "mqtt" else None)
+    if kind == "mqtt":
+        return (settings.MQTT_HOST, settings.MQTT_PORT) if settings.MQTT_HOST else None
+    if kind == "kafka":
+        server = (settings.KAFKA_BOOTSTRAP_SERVERS or "").split(",")[0].strip()
+        host, _, port = server.rpartition(":")
+        return (host, int(port)) if host and port.isdigit() else None
+    url = {
+        "postgres": settings.POSTGRES_DSN,
+        "mysql": settings.MYSQL_DSN,
+        "amqp": settings.AMQP_URL,
+        "influxdb": settings.INFLUXDB_URL,
+        "webhook": settings.WEBHOOK_URL,
+    }.get(kind)
+    return _url_address(url) if url else None
+
+
+async def connection_error(host: str, port: int) -> Optional[str]:
+    """
+    Why a host cannot be connected to, or None when it can.
+    """
+    try:
+        _, writer = await asyncio.wait_for(
+            asyncio.open_connection(host, port), CONNECT_TIMEOUT_SECONDS
+        )
+    except asyncio.TimeoutError:
+        return f"cannot connect to {host}:{port}: timed out"
+    except OSError as e:
+        return f"cannot connect to {host}:{port}: {e.strerror or e}"
+    writer.close()
+    return None
+
+
+def _writable_directory(directory: Path) -> Optional[str]:
+    while not directory.exists() and directory != directory.parent:
+        directory = directory.parent
+    if not directory.is_dir() or not os.access(directory, os.W_OK | os.X_OK):
+        return f"cannot write to {directory}"
+    return None
+
+
+class ConfigValidator:
+    """
+    Checks of the settings, collecting their problems.
+
+    Args:
+        network (bool, optional): Whether to connect to CM and to the hosts
+            of the destinations. Defaults to True.
+        check_logins (bool, optional): Whether to log in to CM with every
+            account. Defaults to False.
+    """
+
+    def __init__(self, network: bool = True, check_logins: bool = False):
+        self.network = network
+        self.check_logins = check_logins
+        self.problems: List[ConfigProblem] = []
+        self._connections: Dict[Tuple[str, int], Optional[str]] = {}
+
+    def problem(self, location: str, message: Union[str, BaseException]) -> None:
+        """
+        Record a problem; exceptions are recorded by their message.
+        """
+        self.problems.append(ConfigProblem(location, str(message).strip("'\"")))
+
+    async def _connect(self, location: str, address: Optional[Tuple[str, int]]) -> None:
+        if not self.network or address is None:
+            return
+        if address not in self._connections:
+            self._connections[address] = await connection_error(*address)
+        if self._connections[address] is not None:
+            self.problem(location, self._connections[address])
+
+    async def check_destination(
+        self, location: str, destination: Union[str, DestinationConfig, Dict[str, Any]]
+    ) -> None:
+        """
+        Check the settings, driver, directory and host of a destination.
+        """
+        if isinstance(destination, dict):
+            try:
+                destination = DestinationConfig.model_validate(destination)
+            except ValueError as e:
+                self.problem(location, e)
+                return
+        target = destination if isinstance(destination, str) else destination.target
+        if destination_for(target) is not None:
+            return
+        kind = destination_kind(target)
+        if kind == "file":
+            # The directory of the path, up to its first template field.
+            error = _writable_directory(Path(target.split("{", 1)[0] + "x").parent)
+            if error is not None:
+                self.problem(location, error)
+            return
+        if kind in DRIVERS:
+            module, extra = DRIVERS[kind]
+            try:
+                found = importlib.util.find_spec(module) is not None
+            except ModuleNotFoundError:
+                found = False
+            if not found:
+                self.problem(location, f"{target} needs {module}; install the '{extra}' extra")
+        required = REQUIRED_SETTINGS.get(kind, ())
+        if not urlparse(target).netloc:
+            required += NAMED_SETTINGS.get(kind, ())
+        missing = [name for name in required if not getattr(settings, name)]
+        if missing:
+            self.problem(location, f"{target} needs {', '.join(missing)}")
+            return
+        await self._connect(location, destination_address(target))
+
+    def check_account(self, location: str, account: Optional[str]) -> None:
+        """
+        Check that an account is configured and its password resolves.
+        """
+        try:
+            account_credentials(account)
+        except ValueError as e:
+            self.problem(location, e)
+
+    async def check_run(self, location: str, config: RunConfig) -> None:
+        """
+        Check the reports, filters and destinations of a batch run.
+        """
+        for index, job in enumerate(config.reports):
+            job_location = f"{location}.reports[{index}]"
+            try:
+                definition = get_report(job.report)
+                definition.parse_filters(job.filters)
+                definition.dedup_policy(job.dedup)
+            except (KeyError, ValueError) as e:
+                self.problem(job_location, e)
+            for position, destination in enumerate(job.destinations):
+                destination_location = f"{job_location}.destinations[{position}]"
+                await self.check_destination(destination_location, destination)
+            if job.email is not None and not settings.SMTP_HOST:
+                self.problem(f"{job_location}.email", "e-mails need SMTP_HOST")
+        for index, bundle in enumerate(config.bundles):
+            await self.check_destination(f"{location}.bundles[{index}]", bundle.target)
+
+    def check_api_keys(self) -> None:
+        """
+        Check that the secret of every key of `API_KEYS` resolves.
+        """
+        for name, key in settings.API_KEYS.items():
+            location = f"api_keys.{name}"
+            if key.key_env and not os.environ.get(key.key_env):
+                self.problem(location, f"The secret of API key {name} is not set in {key.key_env}")
+            elif not key.key_env and not key.key:
+                self.problem(location, f"API key {name} has no key or key_env")
+
+    async def check_report_defaults(self) -> None:
+        """
+        Check the filters, account, format and destinations of `REPORT_DEFAULTS`.
+        """
+        for name, defaults in settings.REPORT_DEFAULTS.items():
+            location = f"report_defaults.{name}"
+            try:
+                get_report(name).parse_filters(defaults.filters)
+            except (KeyError, ValueError) as e:
+                self.problem(location, e)
+            if defaults.account is not None:
+                self.check_account(f"{location}.account", defaults.account)
+            if defaults.format is not None and defaults.format not in EXPORTERS:
+                self.problem(f"{location}.format", f"Unknown export format {defaults.format}")
+            for index, destination in enumerate(defaults.destinations):
+                await self.check_destination(f"{location}.destinations[{index}]", destination)
+            if defaults.output is not None and defaults.output != "-":
+                output = output_destination(defaults.output, defaults.format) or defaults.output
+                await self.check_destination(f"{location}.output", output)
+
+    async def check_schedules(self) -> None:
+        """
+        Check the cron expression, account and run of `SCHEDULES`.
+        """
+        for name, schedule in settings.SCHEDULES.items():
+            location = f"schedules.{name}"
+            try:
+                CronExpression.parse(schedule.cron)
+            except ValueError as e:
+                self.problem(f"{location}.cron", e)
+            if schedule.account is not None:
+                self.check_account(f"{location}.account", schedule.account)
+            try:
+                config = schedule_config(name, schedule)
+            except (KeyError, ValueError) as e:
+                self.problem(location, e)
+                continue
+            if schedule.run is not None:
+                await self.check_run(f"{location}.run", config)
+
+    async def check_pipelines(self) -> None:
+        """
+        Check the runs of `PIPELINES`.
+        """
+        for name in settings.PIPELINES:
+            location = f"pipelines.{name}"
+            try:
+                config = pipeline_config(name)
+            except (KeyError, ValueError) as e:
+                self.problem(location, e)
+                continue
+            await self.check_run(location, config)
+
+    async def check_portal(self) -> None:
+        """
+        Check the accounts and that CM can be reached, logging in with every
+        account when `check_logins`.
+        """
+        for account in settings.ACCOUNTS:
+            self.check_account(f"accounts.{account}", account)
+        problems = len(self.problems)
+        await self._connect("login_url", destination_address(settings.LOGIN_URL))
+        if not self.check_logins or len(self.problems) > problems:
+            return
+        accounts: List[Optional[str]] = [None, *settings.ACCOUNTS]
+        for account in accounts:
+            try:
+                username, password = account_credentials(account)
+            except ValueError:
+                continue
+            try:
+                async with new_session() as session:
+                    await login(session, username, password)
+            except (aiohttp.ClientError, IOError) as e:
+                self.problem(f"accounts.{account}" if account else "username", e)
+
+    async def validate(self) -> List[ConfigProblem]:
+        """
+        Run every check.
+
+        Returns:
+            List[ConfigProblem]: The problems found, in the order of the checks.
+        """
+        await self.check_portal()
+        self.check_api_keys()
+        error = _writable_directory(Path(settings.SNAPSHOT_DIR))
+        if error is not None:
+            self.problem("snapshot_dir", error)
+        await self.check_report_defaults()
+        await self.check_schedules()
+        await self.check_pipelines()
+        for channel, url in settings.NOTIFY_WEBHOOKS.items():
+            await self._connect(f"notify_webhooks.{channel}", destination_address(url))
+        return self.problems
+
+
+def add_config_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `config` command and its `validate` subcommand.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    config_parser = subparsers.add_parser("config", help="Check the settings.")
+    commands = config_parser.add_subparsers(dest="config_command", metavar="COMMAND", required=True)
+    parser = commands.add_parser(
+        "validate",
+        help="Check reports, accounts and destinations of the settings.",
+        description="Check the reports, accounts and destinations of the settings, and that "
+        "CM and the destinations can be reached.",
+    )
+    parser.add_argument(
+        "--offline", action="store_true", help="Do not connect to CM or to any destination."
+    )
+    parser.add_argument(
+        "--login", action="store_true", help="Also log in to CM with every account."
+    )
+    parser.add_argument("--json", action="store_true", help="Print the problems as JSON.")
+    parser.set_defaults(handler=validate_config)
+
+
+def _counted(count: int, noun: str) -> str:
+    return f"{count} {noun}{'' if count == 1 else 's'}"
+
+
+async def validate_config(args: argparse.Namespace) -> int:
+    """
+    Check the settings and print their problems.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status; 1 when a problem was found.
+    """
+    problems = await ConfigValidator(not args.offline, args.login).validate()
+    if args.json:
+        print(json.dumps([asdict(problem) for problem in problems], indent=2))
+        return 1 if problems else 0
+    if not args.quiet:
+        for problem in problems:
+            print(f"{problem.location}: {problem.message}")
+    checked = ", ".join(
+        [
+            _counted(len(settings.ACCOUNTS), "account"),
+            _counted(len(settings.REPORT_DEFAULTS), "report default"),
+            _counted(len(settings.SCHEDULES), "schedule"),
+        ]
+    )
+    checked += f" and {_counted(len(settings.PIPELINES), 'pipeline')}"
+    if settings.PROFILE is not None:
+        checked += f" of profile {settings.PROFILE}"
+    found = _counted(len(problems), "problem") if problems else "no problems"
+    print(f"Checked {checked}: {found}.")
+    return 1 if problems else 0
@@ -0,0 +1,109 @@
+"""
+`lanx debug` commands, for troubleshooting the scraping of CM.
+
+`lanx debug fetch-raw REPORT` saves the raw responses of the portal for a
+report, every page as received, with a manifest of the requests (see
+`core.response_recorder`):
+
+    $ lanx debug fetch-raw pending-orders --filter init_date=2025-01-01
+    Saved 1 response of pending_orders to tmp/raw/pending_orders/20250307-103500.
+
+The report is fetched as by `lanx report`, with the reports it depends
+on, but its rows are neither written nor delivered. The responses are
+saved before they are parsed, so they are kept when parsing fails, which
+is reported, with its exit code (see `cli.errors`), once they are saved.
+"""
+
+import argparse
+from datetime import datetime
+from pathlib import Path
+
+from cli.errors import CommandError
+from cli.report_command import account_credentials, parse_filters
+from core.config import ReportDefaults, settings
+from core.logger import TMP_DIR
+from core.response_recorder import MANIFEST_FILE, RecordingSession
+from core.session_manager import authenticated_session
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.report_registry import REPORTS, ReportContext, get_report
+from services.runner import run_reports
+
+RAW_DIR = TMP_DIR / "raw"
+
+
+def add_debug_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `debug` command and its subcommands.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser("debug", help="Troubleshoot the scraping of CM.")
+    commands = parser.add_subparsers(dest="debug_command", metavar="COMMAND", required=True)
+    fetch_parser = commands.add_parser(
+        "fetch-raw",
+        help="Save the raw responses of CM for a report, without parsing them.",
+        description="Fetch a report and save every response of CM as received, with a "
+        f"{MANIFEST_FILE} manifest, for bug reports and parser fixtures.",
+    )
+    fetch_parser.add_argument(
+        "report",
+        type=lambda name: name.replace("-", "_"),
+        choices=sorted(REPORTS),
+        metavar="REPORT",
+        help="Report to fetch.",
+    )
+    fetch_parser.add_argument(
+        "--filter",
+        dest="filters",
+        action="append",
+        default=[],
+        metavar="KEY=VALUE",
+        help="Report filter; repeat for several filters.",
+    )
+    fetch_parser.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    fetch_parser.add_argument(
+        "--output",
+        type=Path,
+        metavar="DIR",
+        help=f"Directory receiving the responses. Defaults to {RAW_DIR}/REPORT/TIMESTAMP.",
+    )
+    fetch_parser.set_defaults(handler=fetch_raw)
+
+
+async def fetch_raw(args: argparse.Namespace) -> int:
+    """
+    Save the raw responses of CM for a report.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the filters or the account are invalid.
+        CommandError: If the report or a report it depends on could not be
+            fetched or parsed, once the responses are saved.
+    """
+    definition = get_report(args.report)
+    defaults = settings.REPORT_DEFAULTS.get(definition.name, ReportDefaults())
+    filters = {**defaults.filters, **parse_filters(args.filters)}
+    definition.parse_filters(filters)
+    directory = args.output or RAW_DIR / definition.name / f"{datetime.now():%Y%m%d-%H%M%S}"
+    job = ReportJob(report=definition.name, filters=filters, timeout=defaults.timeout)
+    credentials = account_credentials(args.account or defaults.account)
+    async with authenticated_session(*credentials) as (session, csrf_token):
+        recorder = RecordingSession(session, directory)
+        context = ReportContext(client=recorder, csrf_token=csrf_token)
+        summary = await run_reports(context, RunConfig(reports=[job], dry_run=True))
+    count = len(recorder.responses)
+    print(f"Saved {count} response{'' if count == 1 else 's'} of {definition.name} to {directory}.")
+    failed = next((status for status in summary.results if status.status == "failed"), None)
+    if failed is not None:
+        raise CommandError(
+            failed.error_kind or "error", f"{failed.report} failed: {failed.error}", failed.report
+        )
+    return 0
@@ -0,0 +1,196 @@
+"""
+`lanx diff` command.
+
+Compares two runs of a report with the diff engine of
+`services.report_diff`, printing the rows added, removed and changed:
+
+    $ lanx diff old.json new.json --key code,supplier
+    + code=MP-09 supplier=ACME
+    - code=MP-02 supplier=Cobre Sul
+    ~ code=MP-07 supplier=ACME: unit_price 10.5 → 11.2
+    1 added, 1 removed, 1 changed
+
+Each side is an export file (.json, .ndjson or .csv, possibly gzipped,
+such as the files of a batch run) or a stored snapshot, given as
+`snapshot:<report>/<id>`, `snapshot:<report>/latest` or
+`snapshot:<report>/latest~1` for the run before the latest. Field names
+are compared by their stable English names (see `core.utils.field_names`),
+so snapshots and export files can be compared with each other. The key
+defaults to the key fields of the report, when the files name it.
+
+`--json` prints the diff as JSON and `--exit-code` exits with 1 when the
+runs differ, for scripts; `--quiet` only prints the closing count.
+"""
+
+import argparse
+import csv
+import gzip
+import io
+import json
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+
+from core.snapshot_store import snapshot_store
+from core.utils.field_names import english_name
+from core.utils.records import field_name
+from schemas.diff_schemas import ReportDiff
+from services.report_diff import diff
+from services.report_registry import REPORTS
+
+SNAPSHOT_PREFIX = "snapshot:"
+
+
+def add_diff_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `diff` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "diff",
+        help="Compare two snapshots or export files of a report.",
+        description="Print the rows added, removed and changed between two runs of a report.",
+    )
+    parser.add_argument("old", help="Old run: an export file or snapshot:<report>/<id>.")
+    parser.add_argument("new", help="New run: an export file or snapshot:<report>/<id>.")
+    parser.add_argument(
+        "--key",
+        metavar="FIELDS",
+        help="Comma separated fields identifying a row. Defaults to the key of the report.",
+    )
+    parser.add_argument(
+        "--ignore",
+        metavar="FIELDS",
+        default="",
+        help="Comma separated fields not compared, such as timestamps.",
+    )
+    parser.add_argument("--json", action="store_true", help="Print the diff as JSON.")
+    parser.add_argument(
+        "--exit-code", action="store_true", help="Exit with 1 when the runs differ."
+    )
+    parser.set_defaults(handler=run_diff)
+
+
+def _english(row: Dict[str, Any]) -> Dict[str, Any]:
+    return {english_name(field_name(name)): value for name, value in row.items()}
+
+
+def _read_file(path: Path) -> Tuple[List[Dict[str, Any]], Optional[str]]:
+    content = path.read_bytes()
+    name = path.name
+    if name.endswith(".gz"):
+        content, name = gzip.decompress(content), name[: -len(".gz")]
+    text = content.decode("utf-8-sig")
+    if name.endswith(".ndjson"):
+        return [json.loads(line) for line in text.splitlines() if line.strip()], None
+    if name.endswith(".csv"):
+        try:
+            dialect: Any = csv.Sniffer().sniff(text.split("\n", 1)[0], delimiters=",;\t|")
+        except csv.Error:
+            dialect = csv.excel
+        return list(csv.DictReader(io.StringIO(text), dialect=dialect)), None
+    if name.endswith(".json"):
+        data = json.loads(text)
+        if isinstance(data, list):
+            return data, None
+        if isinstance(data, dict) and isinstance(data.get("rows"), list):
+            report = data.get("report") or (data.get("metadata") or {}).get("report")
+            return data["rows"], report
+    raise ValueError(f"Cannot read rows from {path}; use a .json, .ndjson or .csv file")
+
+
+def read_rows(source: str) -> Tuple[List[Dict[str, Any]], Optional[str]]:
+    """
+    Rows of a run, by their English field names, and its report, if known.
+
+    Args:
+        source (str): Export file path, or `snapshot:<report>/<id>` with an
+            id, `latest` or `latest~N`.
+
+    Raises:
+        ValueError: If the source cannot be read or the snapshot does not exist.
+        FileNotFoundError: If the file does not exist.
+    """
+    if not source.startswith(SNAPSHOT_PREFIX):
+        rows, report = _read_file(Path(source))
+        return [_english(row) for row in rows], report
+    report, _, snapshot_id = source[len(SNAPSHOT_PREFIX):].partition("/")
+    if snapshot_id == "latest" or snapshot_id.startswith("latest~"):
+        offset = int(snapshot_id.partition("~")[2] or 0)
+        snapshot = snapshot_store.latest(report, offset)
+        if snapshot is None:
+            raise ValueError(f"No snapshot {snapshot_id} of {report}")
+    else:
+        snapshot = snapshot_store.load(report, snapshot_id)
+    return [_english(row) for row in snapshot.rows], report
+
+
+def _key_text(key: Dict[str, Any]) -> str:
+    return " ".join(f"{name}={value}" for name, value in key.items())
+
+
+def diff_text(result: ReportDiff) -> str:
+    """
+    Human readable diff, one line per row and a closing count.
+    """
+    keys = result.key_fields
+    lines = [f"+ {_key_text({name: row.get(name) for name in keys})}" for row in result.added]
+    lines.extend(
+        f"- {_key_text({name: row.get(name) for name in keys})}" for row in result.removed
+    )
+    for change in result.changed:
+        fields = ", ".join(
+            f"{name} {change.old.get(name)} → {change.new.get(name)}" for name in change.fields
+        )
+        lines.append(f"~ {_key_text(change.key)}: {fields}")
+    lines.extend(f"! {_key_text(key)}: duplicated key" for key in result.duplicated)
+    summary = (
+        f"{len(result.added)} added, {len(result.removed)} removed, "
+        f"{len(result.changed)} changed"
+    )
+    if result.duplicated:
+        summary += f", {len(result.duplicated)} duplicated keys"
+    lines.append(summary)
+    return "\n".join(lines)
+
+
+async def run_diff(args: argparse.Namespace) -> int:
+    """
+    Compare two runs of a report and print the differences.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status; 1 with `--exit-code` when the runs differ.
+
+    Raises:
+        ValueError: If a run cannot be read, no key is known or a row has
+            no key field.
+    """
+    old_rows, old_report = read_rows(args.old)
+    new_rows, new_report = read_rows(args.new)
+    if args.key:
+        key_fields = args.key.split(",")
+    else:
+        definition = REPORTS.get(new_report or old_report or "")
+        if definition is None or not definition.key_fields:
+            raise ValueError("The key of the rows is unknown; give it with --key")
+        key_fields = definition.key_fields
+    try:
+        result = diff(
+            old_rows,
+            new_rows,
+            [english_name(field_name(name.strip())) for name in key_fields],
+            [english_name(field_name(name.strip())) for name in args.ignore.split(",") if name],
+        )
+    except KeyError as e:
+        raise ValueError(f"Rows without the key field {e}; give the key with --key") from e
+    if args.json:
+        print(result.model_dump_json(indent=2))
+    elif args.quiet:
+        print(diff_text(result).splitlines()[-1])
+    else:
+        print(diff_text(result))
+    return 1 if args.exit_code and not result.is_empty else 0
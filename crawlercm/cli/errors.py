@@ -0,0 +1,87 @@
+"""
+Exit codes and error output of the `lanx` command.
+
+The exit codes are stable, so wrapper scripts and monitoring can react to
+the kind of failure (see `core.errors`):
+
+    0    success
+    1    any other error, such as invalid filters, a failed destination or
+         a previous snapshot that could not be loaded (snapshot)
+    2    invalid command line (argparse prints the usage)
+    3    CM rejected the login (auth)
+    4    CM could not be reached or answered with an error (portal)
+    5    a page of CM could not be parsed (parse)
+    6    partial success: the report was delivered to some of its destinations
+    130  interrupted
+
+Errors are printed to stderr as `lanx: <message>`, or with
+`--error-format json` as a single JSON object:
+
+    {"error": "auth", "exit_code": 3, "message": "Login failed: ...", "report": null}
+"""
+
+import json
+import sys
+from typing import Literal, Optional
+
+ErrorCode = Literal["auth", "portal", "parse", "snapshot", "partial", "error"]
+
+EXIT_CODES = {
+    "error": 1,
+    "snapshot": 1,
+    "usage": 2,
+    "auth": 3,
+    "portal": 4,
+    "parse": 5,
+    "partial": 6,
+    "interrupted": 130,
+}
+
+
+class CommandError(Exception):
+    """
+    Failure of a command, exiting with the code of its kind.
+
+    Args:
+        kind (ErrorCode): Kind of the failure, a key of `EXIT_CODES`.
+        message (str): Error message.
+        report (Optional[str], optional): Report that failed, if any.
+    """
+
+    def __init__(self, kind: ErrorCode, message: str, report: Optional[str] = None):
+        super().__init__(message)
+        self.kind = kind
+        self.report = report
+
+
+def report_error(error: BaseException, error_format: str = "text") -> int:
+    """
+    Print an error to stderr and return its exit code.
+
+    Args:
+        error (BaseException): The error; a `CommandError` gives its kind,
+            other errors are classified with `core.errors.error_kind`.
+        error_format (str, optional): `text` or `json`. Defaults to `text`.
+
+    Returns:
+        int: The exit code of the error.
+    """
+    if isinstance(error, CommandError):
+        kind, report = error.kind, error.report
+    else:
+        # Imported here: `core.errors` reads the settings, which `lanx` only
+        # loads once `--config` and `--profile` are known.
+        from core.errors import error_kind
+
+        kind, report = error_kind(error), None
+    if error_format == "json":
+        content = {
+            "error": kind,
+            "exit_code": EXIT_CODES[kind],
+            "message": str(error),
+            "report": report,
+        }
+        print(json.dumps(content, ensure_ascii=False), file=sys.stderr)
+    else:
+        print(f"lanx: {error}", file=sys.stderr)
+    return EXIT_CODES[kind]
@@ -0,0 +1,128 @@
+"""
+Command line flags of report filters.
+
+Every field of the filter model of a report (`ReportDefinition.filters_model`)
+becomes a flag of its `lanx report` subcommand, named after the field with
+dashes (`init_date` → `--init-date`) and documented with the field
+description, so ad-hoc pulls need neither code nor `--filter` pairs:
+
+    lanx report pending-orders --from 2025-01-01 --to 2025-03-31
+
+Boolean fields take `--name` / `--no-name`, list fields are repeated, and
+fields limited to a set of values (`Literal`, `Enum`) only accept those.
+Values are validated by the filter model, as the filters of a batch run.
+Common filters have short aliases, such as `--from` and `--to` for date
+ranges.
+"""
+
+import argparse
+import types
+from datetime import date, datetime
+from enum import Enum
+from typing import Any, Dict, List, Literal, Type, Union, get_args, get_origin
+
+from pydantic import BaseModel
+from pydantic.fields import FieldInfo
+
+FLAG_ALIASES: Dict[str, List[str]] = {
+    "init_date": ["--from"],
+    "end_date": ["--to"],
+}
+
+_DEST_PREFIX = "filter_"
+
+
+def _unwrap_optional(annotation: Any) -> Any:
+    if get_origin(annotation) in (Union, types.UnionType):
+        args = [arg for arg in get_args(annotation) if arg is not type(None)]
+        if len(args) == 1:
+            return args[0]
+    return annotation
+
+
+def _flag_options(name: str, field: FieldInfo) -> Dict[str, Any]:
+    annotation = _unwrap_optional(field.annotation)
+    options: Dict[str, Any] = {"help": field.description, "dest": f"{_DEST_PREFIX}{name}"}
+    if get_origin(annotation) in (list, List):
+        options["action"] = "append"
+        annotation = _unwrap_optional(get_args(annotation)[0]) if get_args(annotation) else str
+    if annotation is bool:
+        options["action"] = argparse.BooleanOptionalAction
+    elif get_origin(annotation) is Literal:
+        options["choices"] = [str(value) for value in get_args(annotation)]
+    elif isinstance(annotation, type) and issubclass(annotation, Enum):
+        options["choices"] = [str(member.value) for member in annotation]
+    elif annotation is datetime:
+        options["metavar"] = "YYYY-MM-DDTHH:MM"
+    elif annotation is date:
+        options["metavar"] = "YYYY-MM-DD"
+    else:
+        options["metavar"] = name.upper()
+    return options
+
+
+def type_name(annotation: Any) -> str:
+    """
+    Readable type of a filter field, e.g. "date", "list of str" or "one of a, b".
+    """
+    annotation = _unwrap_optional(annotation)
+    if get_origin(annotation) in (list, List):
+        item = get_args(annotation)
+        return f"list of {type_name(item[0]) if item else 'str'}"
+    if get_origin(annotation) is Literal:
+        return f"one of {', '.join(str(value) for value in get_args(annotation))}"
+    if isinstance(annotation, type) and issubclass(annotation, Enum):
+        return f"one of {', '.join(str(member.value) for member in annotation)}"
+    return getattr(annotation, "__name__", str(annotation))
+
+
+def filter_flag(name: str) -> str:
+    """
+    Flag of a filter field, e.g. `--init-date` for `init_date`.
+    """
+    return f"--{name.replace('_', '-')}"
+
+
+def add_filter_flags(
+    parser: argparse.ArgumentParser, filters_model: Type[BaseModel]
+) -> List[str]:
+    """
+    Add a flag per field of a filter model.
+
+    Fields whose flag is already taken by an option of the command (e.g. a
+    filter named `format`) get no flag; they are still given with `--filter`.
+
+    Args:
+        parser (argparse.ArgumentParser): Parser of the report subcommand.
+        filters_model (Type[BaseModel]): Filter model of the report.
+
+    Returns:
+        List[str]: The fields that received a flag.
+    """
+    taken = {flag for action in parser._actions for flag in action.option_strings}
+    group = parser.add_argument_group("filters")
+    fields = []
+    for name, field in filters_model.model_fields.items():
+        flag = filter_flag(name)
+        if flag in taken:
+            continue
+        flags = [flag, *(alias for alias in FLAG_ALIASES.get(name, []) if alias not in taken)]
+        group.add_argument(*flags, **_flag_options(name, field))
+        taken.update(flags)
+        fields.append(name)
+    return fields
+
+
+def flag_filters(args: argparse.Namespace, fields: List[str]) -> Dict[str, Any]:
+    """
+    Filters given as flags.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+        fields (List[str]): Fields with a flag, from `add_filter_flags`.
+
+    Returns:
+        Dict[str, Any]: The value of every flag given, by field name.
+    """
+    values = {name: getattr(args, f"{_DEST_PREFIX}{name}", None) for name in fields}
+    return {name: value for name, value in values.items() if value is not None}
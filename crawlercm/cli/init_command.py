@@ -0,0 +1,208 @@
+"""
+`lanx init` command.
+
+Writes a commented starter config file for a new plant, asking for the
+CM portal URLs, the CM user, one report and one destination of it, and
+optionally a schedule:
+
+    $ lanx init
+    Login URL of CM [https://cm.lanx.local/login]:
+    ...
+    Report (pending_materials, pending_orders, pending_sales, ...) [pending_orders]:
+    Destination of pending_orders [exports/{report}_{date:%Y-%m-%d}.csv]: postgres
+    Wrote lanx.yaml. Set LANX_PASSWORD, then check it with:
+        lanx --config lanx.yaml config validate
+
+Answers default to the current settings, when set. The password is not
+written: it is read from the environment (`LANX_PASSWORD`), as secrets
+should be. `--yes` takes every default without asking, e.g. for scripts.
+"""
+
+import argparse
+import json
+from datetime import date
+from pathlib import Path
+from typing import Callable, List, Optional, Tuple
+
+from core.config import settings
+from core.utils.cron import CronExpression
+from services.report_registry import REPORTS
+
+DEFAULT_DESTINATION = "exports/{report}_{date:%Y-%m-%d}.csv"
+DEFAULT_CRON = "0 6 * * mon-fri"
+
+# Settings of the portal asked for, with their question.
+PORTAL_SETTINGS: List[Tuple[str, str]] = [
+    ("LOGIN_URL", "Login URL of CM"),
+    ("HOME_URL", "Home page URL of CM"),
+    ("SALES_PENDING_ORDER_URL", "URL of the pending sales report"),
+    ("PROD_PENDING_ORDER_URL", "URL of the pending production orders report"),
+    ("PENDING_MATERIALS_URL", "URL of the pending materials report"),
+]
+
+
+def add_init_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `init` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "init",
+        help="Write a starter config file.",
+        description="Ask for the portal, the CM user, a report and a destination, and write "
+        "a commented config file with them.",
+    )
+    parser.add_argument(
+        "--output",
+        type=Path,
+        default=Path("lanx.yaml"),
+        metavar="PATH",
+        help="Config file to write. Defaults to lanx.yaml.",
+    )
+    parser.add_argument(
+        "--force", action="store_true", help="Overwrite the config file if it exists."
+    )
+    parser.add_argument(
+        "-y", "--yes", action="store_true", help="Take every default without asking."
+    )
+    parser.set_defaults(handler=init_config)
+
+
+def _yaml(value: str) -> str:
+    # JSON strings are YAML strings, quoted and escaped.
+    return json.dumps(value, ensure_ascii=False)
+
+
+def ask(
+    question: str,
+    default: Optional[str] = None,
+    check: Optional[Callable[[str], None]] = None,
+    assume_default: bool = False,
+) -> str:
+    """
+    Ask a question until its answer is valid.
+
+    Args:
+        question (str): Question, without its default.
+        default (Optional[str], optional): Answer of an empty reply. An
+            answer is required without it.
+        check (Optional[Callable[[str], None]], optional): Raises
+            ValueError, with the reason, for invalid answers.
+        assume_default (bool, optional): Whether to take the default without
+            asking, when there is one. Defaults to False.
+
+    Returns:
+        str: The answer.
+
+    Raises:
+        ValueError: If the input ends before an answer, or the default is
+            invalid with `assume_default`.
+    """
+    prompt = f"{question} [{default}]: " if default else f"{question}: "
+    while True:
+        try:
+            reply = default if assume_default and default else input(prompt).strip()
+        except EOFError:
+            raise ValueError(f"No answer to: {question}") from None
+        answer = reply or default
+        if not answer:
+            print("An answer is required.")
+            continue
+        try:
+            if check is not None:
+                check(answer)
+        except ValueError as e:
+            if assume_default:
+                raise
+            print(e)
+            continue
+        return answer
+
+
+def _check_report(name: str) -> None:
+    if name.replace("-", "_") not in REPORTS:
+        raise ValueError(f"Unknown report {name}; use one of {', '.join(sorted(REPORTS))}")
+
+
+def _check_cron(expression: str) -> None:
+    if expression != "-":
+        CronExpression.parse(expression)
+
+
+def config_text(
+    portal: List[Tuple[str, str]],
+    username: str,
+    report: str,
+    destination: str,
+    cron: Optional[str],
+) -> str:
+    """
+    Commented config file of the answers of `lanx init`.
+    """
+    definition = REPORTS[report]
+    lines = [
+        f"# Config file of lanx, written by `lanx init` on {date.today():%Y-%m-%d}.",
+        "# Settings are named as in crawlercm/core/config.py, in any case; variables of",
+        "# the environment take precedence over them (e.g. LANX_S3_BUCKET).",
+        "",
+        "# CM portal.",
+        *(f"{name.lower()}: {_yaml(value)}" for name, value in portal),
+        "",
+        "# CM user of the reports. Its password is read from the environment:",
+        "#   export LANX_PASSWORD=...",
+        f"username: {_yaml(username)}",
+        "",
+        "# Defaults of `lanx report` and of the schedules of the reports. Destinations",
+        "# are file paths (CSV, XLSX, JSON... by their suffix), postgres, s3://bucket/prefix,",
+        "# webhook...; `lanx reports list` describes them.",
+        "report_defaults:",
+        f"  {report}:",
+    ]
+    filters = list(definition.filters_model.model_fields)
+    if filters:
+        lines.append(f"    # Filters: {', '.join(filters)}, e.g. {{{filters[0]}: 2025-01-01}}.")
+    lines += ["    filters: {}", f"    destinations: [{_yaml(destination)}]", ""]
+    schedule = f"  {report}: {{cron: {_yaml(cron or DEFAULT_CRON)}, report: {report}}}"
+    lines += [
+        "# Runs of `lanx serve-scheduler`; cron fields: minute hour day month weekday.",
+        "schedules:" if cron else "# schedules:",
+        schedule if cron else f"#{schedule}",
+    ]
+    return "\n".join(lines) + "\n"
+
+
+async def init_config(args: argparse.Namespace) -> int:
+    """
+    Ask for the settings of a plant and write its config file.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the config file exists and `--force` is not given,
+            or a default is invalid with `--yes`.
+    """
+    if args.output.exists() and not args.force:
+        raise ValueError(f"{args.output} exists; give --force to overwrite it")
+    yes = args.yes
+    portal = [
+        (name, ask(question, getattr(settings, name) or None, assume_default=yes))
+        for name, question in PORTAL_SETTINGS
+    ]
+    username = ask("CM user", settings.USERNAME or None, assume_default=yes)
+    names = ", ".join(sorted(REPORTS))
+    report = ask(f"Report ({names})", "pending_orders", _check_report, yes).replace("-", "_")
+    destination = ask(f"Destination of {report}", DEFAULT_DESTINATION, assume_default=yes)
+    cron = ask("Schedule, as a cron expression, or - for none", DEFAULT_CRON, _check_cron, yes)
+
+    text = config_text(portal, username, report, destination, None if cron == "-" else cron)
+    args.output.parent.mkdir(parents=True, exist_ok=True)
+    args.output.write_text(text, encoding="utf-8")
+    print(f"Wrote {args.output}. Set LANX_PASSWORD, then check it with:")
+    print(f"    lanx --config {args.output} config validate")
+    return 0
@@ -0,0 +1,77 @@
+"""
+`lanx login` command.
+
+Logs in to CM with an account, checks that the portal accepted it and
+caches the session (see `core.session_cache`), printing who is logged in:
+
+    $ lanx login --account plant2
+    Logged in to cm.lanx.local as integracao (account plant2).
+    Session cached in tmp/sessions/integracao.json until 2025-03-01 10:30.
+
+Credentials can so be checked apart from report runs, and the runs that
+follow reuse the session while `SESSION_CACHE_TTL_SECONDS` allows.
+"""
+
+import argparse
+from datetime import datetime, timedelta
+from urllib.parse import urlparse
+
+from cli.report_command import account_credentials
+from core.config import settings
+from core.errors import LoginError
+from core.session_cache import save_session
+from core.session_manager import login, new_session, session_is_valid
+
+
+def add_login_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `login` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "login",
+        help="Log in to CM and cache the session.",
+        description="Log in to CM, check that the login was accepted and cache the session.",
+    )
+    parser.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    parser.set_defaults(handler=run_login)
+
+
+async def run_login(args: argparse.Namespace) -> int:
+    """
+    Log in with an account and print its identity.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the account is not configured.
+        LoginError: If CM rejects the login.
+        aiohttp.ClientError: If CM cannot be reached.
+    """
+    username, password = account_credentials(args.account)
+    username = username or settings.USERNAME
+    async with new_session() as session:
+        csrf_token = await login(session, username, password)
+        if not await session_is_valid(session):
+            raise LoginError("Login failed: CM did not keep the session")
+        path = save_session(session, csrf_token, username)
+    account = f" (account {args.account})" if args.account else ""
+    print(f"Logged in to {urlparse(settings.LOGIN_URL).hostname} as {username}{account}.")
+    if args.quiet:
+        return 0
+    if settings.SESSION_CACHE_TTL_SECONDS > 0:
+        expires = datetime.now() + timedelta(seconds=settings.SESSION_CACHE_TTL_SECONDS)
+        print(f"Session cached in {path} until {expires:%Y-%m-%d %H:%M}.")
+    else:
+        print(
+            f"Session cached in {path}; set SESSION_CACHE_TTL_SECONDS to reuse it in report runs."
+        )
+    return 0
@@ -0,0 +1,65 @@
+"""
+`lanx openapi` command.
+
+Prints the OpenAPI 3 document of the HTTP API (see `api.openapi`), the
+same one `lanx serve` serves at `/openapi.json`, without starting the
+server or logging in to CM, so CI can generate clients from it:
+
+    $ lanx openapi --output openapi.json
+    Wrote the OpenAPI document of 38 operations to openapi.json.
+"""
+
+import argparse
+import json
+from pathlib import Path
+
+from core.logger import log_to_stderr
+
+
+def add_openapi_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `openapi` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "openapi",
+        help="Print the OpenAPI document of the HTTP API.",
+        description="Print the OpenAPI 3 document served by `lanx serve` at /openapi.json, "
+        "with an operation per report.",
+    )
+    parser.add_argument(
+        "--output",
+        type=Path,
+        metavar="FILE",
+        help="File receiving the document. Defaults to stdout.",
+    )
+    parser.set_defaults(handler=print_openapi)
+
+
+async def print_openapi(args: argparse.Namespace) -> int:
+    """
+    Print the OpenAPI document of the API, or write it to `--output`.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+    """
+    from api.openapi import build_openapi
+    from main import app
+
+    # Importing the app configures the logging again; stdout is the document.
+    log_to_stderr()
+    document = build_openapi(app)
+    text = json.dumps(document, indent=2, ensure_ascii=False)
+    if args.output is None:
+        print(text)
+        return 0
+    args.output.write_text(text + "\n", encoding="utf-8")
+    if not args.quiet:
+        operations = sum(len(item) for item in document["paths"].values())
+        print(f"Wrote the OpenAPI document of {operations} operations to {args.output}.")
+    return 0
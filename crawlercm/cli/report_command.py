@@ -0,0 +1,339 @@
+"""
+`lanx report` command.
+
+Adds one subcommand per registered report (`lanx report pending-orders`,
+`lanx report pending-materials`, ...), named after the report with
+dashes, all sharing the same options:
+
+    --account NAME      CM account of `ACCOUNTS`; defaults to USERNAME/PASSWORD
+    --filter KEY=VALUE  report filter, repeatable (e.g. init_date=2025-01-01)
+    --<filter>          a flag per filter of the report (see `cli.filter_flags`)
+    --format NAME       export format of the rows (table, json, csv, xlsx, ...)
+    --output TARGET     "-" for stdout (the default), a file path or a destination
+    --dry-run           fetch the report, but only print where it would be delivered
+    --timeout SECONDS   fail the report when fetching it takes longer
+    --no-progress       no progress bar on stderr while the report is fetched
+
+Destinations given to `--output` are any destination of a batch run (see
+`services.runner`): a database, an upload URL such as
+`s3://bucket/prefix`, `sftp://host/dir` or `sheets://<id>/<tab>`, or a
+destination registered by a plugin, so one-off exports need no config
+edits. File paths are written with `--format` when given, or by the batch
+runner, with the format of their suffix, placeholders and compression.
+
+Options not given fall back to the `REPORT_DEFAULTS` of the report in the
+settings, whose destinations also receive the rows. Reports run through
+the batch runner (see `services.runner`), so their dependencies,
+deduplication and validation apply as in a batch run. While the report is
+fetched, a progress bar is shown when stderr is a terminal, and progress is
+logged periodically otherwise (see `services.progress`), also without `-v`.
+"""
+
+import argparse
+import os
+import sys
+from contextlib import nullcontext
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+from urllib.parse import parse_qs, urlparse
+
+from cli.errors import CommandError
+from cli.filter_flags import add_filter_flags, flag_filters
+from core.config import ReportDefaults, settings
+from core.logger import logger
+from core.session_manager import authenticated_session
+from schemas.dataset_schemas import Dataset
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.export.destinations import UPLOAD_DESTINATIONS
+from services.export.files import EXPORTERS, exporter_for, format_of
+from services.export.naming import is_template, render_template, unique_path
+from services.export.registry import destination_for
+from services.progress import progress_for
+from services.report_registry import REPORTS, ReportContext, ReportDefinition
+from services.runner import run_reports
+
+DESTINATION_NAMES = ("postgres", "mysql", *UPLOAD_DESTINATIONS)
+FILE_UPLOAD_SCHEMES = ("gdrive", "s3", "azure", "sftp", "ftp", "ftps")
+
+
+def command_name(report: str) -> str:
+    """
+    Subcommand of a report, e.g. `pending-orders` for `pending_orders`.
+    """
+    return report.replace("_", "-")
+
+
+def timeout_seconds(value: str) -> float:
+    """
+    Seconds of a `--timeout` option.
+
+    Raises:
+        argparse.ArgumentTypeError: If the value is not a positive number.
+    """
+    try:
+        seconds = float(value)
+    except ValueError:
+        seconds = 0
+    if not seconds > 0:
+        raise argparse.ArgumentTypeError(f"invalid timeout {value!r}; use a number of seconds")
+    return seconds
+
+
+def _report_options() -> argparse.ArgumentParser:
+    options = argparse.ArgumentParser(add_help=False)
+    options.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    options.add_argument(
+        "--filter",
+        dest="filters",
+        action="append",
+        default=[],
+        metavar="KEY=VALUE",
+        help="Report filter; repeat for several filters.",
+    )
+    options.add_argument(
+        "--format",
+        choices=sorted(EXPORTERS),
+        help="Export format. Defaults to the suffix of --output; on stdout, to a table "
+        "on a terminal and json otherwise.",
+    )
+    options.add_argument(
+        "--output",
+        metavar="TARGET",
+        help="'-' for stdout, a file path or a destination such as s3://bucket/prefix, "
+        "sftp://host/dir or sheets://<spreadsheet id>/<tab>. Defaults to stdout.",
+    )
+    options.add_argument(
+        "--dry-run",
+        action="store_true",
+        help="Log in and fetch the report, but write, upload and e-mail nothing; print "
+        "what would be delivered where.",
+    )
+    options.add_argument(
+        "--timeout",
+        type=timeout_seconds,
+        metavar="SECONDS",
+        help="Fail the report when fetching it takes longer. Defaults to the timeout of "
+        "REPORT_DEFAULTS; no timeout when unset.",
+    )
+    options.add_argument(
+        "--no-progress",
+        dest="progress",
+        action="store_false",
+        help="Do not show the progress of the fetch on stderr.",
+    )
+    return options
+
+
+def add_report_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `report` command and its subcommand per registered report.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    report_parser = subparsers.add_parser("report", help="Fetch a report.")
+    reports = report_parser.add_subparsers(dest="report", metavar="REPORT", required=True)
+    options = _report_options()
+    for definition in REPORTS.values():
+        name = command_name(definition.name)
+        parser = reports.add_parser(
+            name,
+            aliases=[definition.name] if name != definition.name else [],
+            parents=[options],
+            help=definition.description,
+            description=definition.description,
+        )
+        parser.set_defaults(
+            handler=run_report,
+            definition=definition,
+            filter_fields=add_filter_flags(parser, definition.filters_model),
+        )
+
+
+def parse_filters(pairs: List[str]) -> Dict[str, str]:
+    """
+    Filters given as KEY=VALUE pairs.
+
+    Raises:
+        ValueError: If a pair has no `=`.
+    """
+    filters = {}
+    for pair in pairs:
+        key, separator, value = pair.partition("=")
+        if not separator or not key:
+            raise ValueError(f"Invalid filter {pair!r}; use KEY=VALUE")
+        filters[key.strip()] = value.strip()
+    return filters
+
+
+def account_credentials(account: Optional[str]) -> Tuple[Optional[str], Optional[str]]:
+    """
+    User and password of a configured CM account.
+
+    Accounts reference their password by the environment variable holding
+    it (`password_env`), so config files need not contain it.
+
+    Args:
+        account (Optional[str]): Account name, or None for USERNAME/PASSWORD.
+
+    Raises:
+        ValueError: If the account is not configured, or the environment
+            variable of its password is not set.
+    """
+    if account is None:
+        return None, None
+    if account not in settings.ACCOUNTS:
+        raise ValueError(f"Unknown account {account}; configure it in ACCOUNTS")
+    credentials = settings.ACCOUNTS[account]
+    password = credentials.get("password")
+    if "password_env" in credentials:
+        password = os.environ.get(credentials["password_env"])
+        if password is None:
+            raise ValueError(
+                f"The password of account {account} is not set in {credentials['password_env']}"
+            )
+    return credentials.get("username"), password
+
+
+def output_destination(output: str, file_format: Optional[str]) -> Optional[str]:
+    """
+    Batch run destination of an `--output` target.
+
+    Args:
+        output (str): `--output` target.
+        file_format (Optional[str]): Export format, if set; file uploads
+            receive it as their `?format=`, other destinations ignore it.
+
+    Returns:
+        Optional[str]: The destination, or None when the CLI writes the
+        output itself (stdout, and file paths with a format).
+    """
+    if output == "-":
+        return None
+    name = output.split("://", 1)[0] if "://" in output else output.split("?", 1)[0]
+    registered = destination_for(output) is not None
+    if "://" not in output and name not in DESTINATION_NAMES and not registered:
+        return None if file_format else output
+    if file_format is None:
+        return output
+    if name not in FILE_UPLOAD_SCHEMES or registered:
+        logger.warning(f"--format {file_format} ignored: {name} destinations have no file format")
+        return output
+    url = urlparse(output)
+    if "format" in parse_qs(url.query):
+        return output
+    query = f"{url.query}&format={file_format}" if url.query else f"format={file_format}"
+    return url._replace(query=query).geturl()
+
+
+def write_output(dataset: Dataset, output: str, file_format: Optional[str]) -> str:
+    """
+    Write the rows of a report to a file or to stdout.
+
+    Args:
+        dataset (Dataset): Report rows and metadata.
+        output (str): File path, possibly with run placeholders, or "-" for stdout.
+        file_format (Optional[str]): Export format. Defaults to the suffix of
+            the file, or json. On stdout, defaults to a table when stdout is
+            a terminal and to json otherwise (e.g. piped to another command).
+
+    Returns:
+        str: The file written, with its placeholders rendered, or "stdout".
+    """
+    if output == "-":
+        file_format = file_format or ("table" if sys.stdout.isatty() else "json")
+        exporter_for(file_format).write(dataset, sys.stdout.buffer)
+        sys.stdout.flush()
+        return "stdout"
+    path = Path(output)
+    if is_template(output):
+        path = unique_path(Path(render_template(output, dataset)))
+    path.parent.mkdir(parents=True, exist_ok=True)
+    exporter_for(file_format or format_of(path) or "json").export(dataset, path)
+    return str(path)
+
+
+async def run_report(args: argparse.Namespace) -> int:
+    """
+    Fetch the report of a `lanx report` subcommand and write its rows.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the filters or the account are invalid.
+        CommandError: If the report failed, with the kind of the failure;
+            `partial` when it was delivered to some of its destinations.
+    """
+    definition: ReportDefinition = args.definition
+    defaults = settings.REPORT_DEFAULTS.get(definition.name, ReportDefaults())
+    output = args.output or defaults.output or "-"
+    file_format = args.format or defaults.format
+    destination = output_destination(output, file_format)
+    job = ReportJob(
+        report=definition.name,
+        filters={
+            **defaults.filters,
+            **parse_filters(args.filters),
+            **flag_filters(args, args.filter_fields),
+        },
+        destinations=[*defaults.destinations, *([destination] if destination else [])],
+        timeout=args.timeout or defaults.timeout,
+    )
+    definition.parse_filters(job.filters)
+    username, password = account_credentials(args.account or defaults.account)
+    results: Dict[str, Dataset] = {}
+    progress = progress_for(sys.stderr) if args.progress and not args.quiet else nullcontext()
+    async with authenticated_session(username, password) as (session, csrf_token):
+        with progress as shown:
+            context = ReportContext(client=session, csrf_token=csrf_token, progress=shown)
+            config = RunConfig(reports=[job], dry_run=args.dry_run)
+            summary = await run_reports(context, config, results=results)
+    status = next(result for result in summary.results if result.report == definition.name)
+    if status.status == "skipped":
+        failed = next(result for result in summary.results if result.status == "failed")
+        raise CommandError(
+            failed.error_kind or "error",
+            f"{definition.name} skipped: {failed.report} failed: {failed.error}",
+            definition.name,
+        )
+    if definition.name not in results:
+        raise CommandError(
+            status.error_kind or "error",
+            f"{definition.name} failed: {status.error}",
+            definition.name,
+        )
+    targets = list(status.destinations)
+    if args.dry_run:
+        if output == "-":
+            targets.append("stdout")
+        elif destination is None:
+            dataset = results[definition.name]
+            targets.append(render_template(output, dataset) if is_template(output) else output)
+        if not args.quiet:
+            for target in targets:
+                print(f"{definition.name}: would deliver {status.row_count} rows to {target}")
+    elif destination is None:
+        targets.append(write_output(results[definition.name], output, file_format))
+    if args.quiet:
+        # The summary line goes to stderr when the rows went to stdout.
+        print(
+            f"{definition.name}: {'would deliver' if args.dry_run else 'delivered'} "
+            f"{status.row_count} rows to {', '.join(targets) or 'no destination'} "
+            f"in {status.duration_seconds:.1f}s.",
+            file=sys.stderr if "stdout" in targets and not args.dry_run else sys.stdout,
+        )
+    if status.status != "success":
+        # Fetched, but a destination or the e-mail failed.
+        delivered = status.destinations or destination is None
+        raise CommandError(
+            "partial" if delivered else status.error_kind or "error",
+            f"{definition.name} failed: {status.error}",
+            definition.name,
+        )
+    return 0
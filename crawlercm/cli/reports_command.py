@@ -0,0 +1,143 @@
+"""
+`lanx reports list` command.
+
+Prints every registered report with its description, the reports it
+depends on and its filters (flag, type, default and description), then
+the destinations reports can be delivered to, including those registered
+by plugins, so operators can find what a report takes without reading
+its source:
+
+    pending-orders  Pending production orders.
+      filters:
+        --init-date, --from  date  Start date for the search range.
+    ...
+
+`--json` prints the same as JSON, for scripts.
+"""
+
+import argparse
+import json
+from typing import Any, Dict, List
+
+from pydantic_core import PydanticUndefined
+
+from cli.filter_flags import FLAG_ALIASES, filter_flag, type_name
+from cli.report_command import command_name
+from services.export.destinations import DESTINATION_FORMS
+from services.export.files import EXPORTERS
+from services.export.registry import DESTINATIONS
+from services.report_registry import REPORTS, ReportDefinition
+
+
+def add_reports_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `reports` command and its subcommands.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser("reports", help="Describe the registered reports.")
+    commands = parser.add_subparsers(dest="reports_command", metavar="COMMAND", required=True)
+    list_parser = commands.add_parser(
+        "list",
+        help="List the reports, their filters and the destinations.",
+        description="List every registered report, its filters and the destinations "
+        "reports can be delivered to.",
+    )
+    list_parser.add_argument("--json", action="store_true", help="Print JSON.")
+    list_parser.set_defaults(handler=list_reports)
+
+
+def report_description(definition: ReportDefinition) -> Dict[str, Any]:
+    """
+    Description of a report and its filters.
+    """
+    filters = []
+    for name, field in definition.filters_model.model_fields.items():
+        default = None if field.default is PydanticUndefined else field.default
+        filters.append(
+            {
+                "name": name,
+                "flags": [filter_flag(name), *FLAG_ALIASES.get(name, [])],
+                "type": type_name(field.annotation),
+                "required": field.is_required(),
+                "default": default,
+                "description": field.description,
+            }
+        )
+    return {
+        "name": definition.name,
+        "command": command_name(definition.name),
+        "description": definition.description,
+        "depends_on": definition.depends_on,
+        "streamable": definition.fetch_pages is not None,
+        "filters": filters,
+    }
+
+
+def destination_descriptions() -> Dict[str, str]:
+    """
+    Forms of every destination, built-in and registered by plugins.
+    """
+    forms = dict(DESTINATION_FORMS)
+    forms["file"] = f"{forms['file']}; formats: {', '.join(sorted(EXPORTERS))}"
+    for name, destination in sorted(DESTINATIONS.items()):
+        forms[name] = (destination.__doc__ or "").strip().split("\n")[0] or "plugin destination"
+    return forms
+
+
+def _text(reports: List[Dict[str, Any]], destinations: Dict[str, str]) -> str:
+    lines = []
+    for report in reports:
+        lines.append(f"{report['command']}  {report['description']}")
+        if report["depends_on"]:
+            lines.append(f"  depends on: {', '.join(report['depends_on'])}")
+        if report["streamable"]:
+            lines.append("  streamable: yes")
+        lines.append("  filters:" if report["filters"] else "  filters: none")
+        rows = [
+            (
+                ", ".join(item["flags"]),
+                item["type"],
+                " ".join(
+                    part
+                    for part in (
+                        item["description"] or "",
+                        "(required)" if item["required"] else "",
+                        f"(default: {item['default']})" if item["default"] is not None else "",
+                    )
+                    if part
+                ),
+            )
+            for item in report["filters"]
+        ]
+        flag_width = max((len(flags) for flags, _, _ in rows), default=0)
+        type_width = max((len(kind) for _, kind, _ in rows), default=0)
+        for flags, kind, text in rows:
+            line = f"    {flags.ljust(flag_width)}  {kind.ljust(type_width)}  {text}"
+            lines.append(line.rstrip())
+        lines.append("")
+    lines.append("destinations:")
+    width = max(len(name) for name in destinations)
+    lines.extend(f"  {name.ljust(width)}  {form}" for name, form in destinations.items())
+    return "\n".join(lines)
+
+
+async def list_reports(args: argparse.Namespace) -> int:
+    """
+    Print the registered reports, their filters and the destinations.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+    """
+    reports = [report_description(definition) for definition in REPORTS.values()]
+    destinations = destination_descriptions()
+    if args.json:
+        content = {"reports": reports, "destinations": destinations}
+        print(json.dumps(content, ensure_ascii=False, indent=2, default=str))
+    else:
+        print(_text(reports, destinations))
+    return 0
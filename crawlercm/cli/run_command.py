@@ -0,0 +1,193 @@
+"""
+`lanx run` command.
+
+Runs a pipeline of `PIPELINES`, a named batch run configuration
+(`RunConfig`) with its reports and destinations, and prints the status of
+every report:
+
+    $ lanx run --pipeline nightly
+    REPORT             STATUS   ROWS   TIME  DESTINATIONS / ERROR
+    -----------------  -------  -----  ----  ------------------------------
+    pending_orders     success    120  3.2s  postgres
+    pending_materials  failed       0  1.0s  s3://lanx-exports/materials: timed out
+    1/2 reports succeeded in 4.5s.
+
+Reports run as in a scheduled run, through the batch runner with the
+snapshot store (see `services.runner`), at most `max_parallel` of the
+pipeline (or `--parallel`) at once. `--timeout` sets the timeout of the
+reports of the pipeline without one of their own. The command fails with the `partial`
+exit code when some of the reports failed (see `cli.errors`). With
+`--quiet`, only the closing line is printed, prefixed by the pipeline.
+"""
+
+import argparse
+import sys
+from contextlib import nullcontext
+from typing import List, Sequence
+
+from cli.errors import CommandError
+from cli.report_command import account_credentials, timeout_seconds
+from core.config import settings
+from core.session_manager import authenticated_session
+from core.snapshot_store import snapshot_store
+from schemas.runner_schemas import ReportRunStatus, RunConfig, RunSummary
+from services.progress import progress_for
+from services.report_registry import ReportContext, get_report
+from services.runner import run_reports
+
+
+def add_run_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `run` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "run",
+        help="Run a configured pipeline of reports.",
+        description="Run every report of a pipeline of PIPELINES and print their status.",
+    )
+    parser.add_argument(
+        "--pipeline", required=True, metavar="NAME", help="Pipeline, as configured in PIPELINES."
+    )
+    parser.add_argument(
+        "--parallel",
+        type=int,
+        metavar="N",
+        help="Maximum number of reports fetched at once. Defaults to the max_parallel of "
+        "the pipeline.",
+    )
+    parser.add_argument(
+        "--timeout",
+        type=timeout_seconds,
+        metavar="SECONDS",
+        help="Fail the reports whose fetch takes longer, unless they have a timeout of their "
+        "own. Defaults to the timeout of the pipeline.",
+    )
+    parser.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    parser.add_argument(
+        "--dry-run",
+        action="store_true",
+        help="Log in and fetch the reports, but write, upload and e-mail nothing.",
+    )
+    parser.add_argument("--json", action="store_true", help="Print the run summary as JSON.")
+    parser.add_argument(
+        "--no-progress",
+        dest="progress",
+        action="store_false",
+        help="Do not show the progress of the fetches on stderr.",
+    )
+    parser.set_defaults(handler=run_pipeline)
+
+
+def pipeline_config(name: str) -> RunConfig:
+    """
+    Batch run configuration of a pipeline.
+
+    Raises:
+        ValueError: If the pipeline is not configured, or not a valid `RunConfig`.
+        KeyError: If the pipeline references an unknown report.
+    """
+    if name not in settings.PIPELINES:
+        raise ValueError(f"Unknown pipeline {name}; configure it in PIPELINES")
+    config = RunConfig.model_validate(settings.PIPELINES[name])
+    for job in config.reports:
+        get_report(job.report)
+    return config
+
+
+def _outcome(status: ReportRunStatus) -> str:
+    if status.status == "success":
+        return ", ".join(status.destinations) or "-"
+    return status.error or "-"
+
+
+def summary_table(statuses: Sequence[ReportRunStatus]) -> str:
+    """
+    Aligned text table with the status, rows, time and destinations of every report.
+    """
+    header = ["REPORT", "STATUS", "ROWS", "TIME", "DESTINATIONS / ERROR"]
+    rows = [
+        [
+            status.report,
+            status.status,
+            f"{status.row_count:,}",
+            f"{status.duration_seconds:.1f}s",
+            _outcome(status),
+        ]
+        for status in statuses
+    ]
+    widths = [max([len(title), *(len(row[i]) for row in rows)]) for i, title in enumerate(header)]
+    # Rows and times are aligned to the right.
+    right = [False, False, True, True, False]
+
+    def line(values: List[str]) -> str:
+        cells = [
+            value.rjust(width) if aligned else value.ljust(width)
+            for value, width, aligned in zip(values, widths, right)
+        ]
+        return "  ".join(cells).rstrip()
+
+    lines = [line(header), line(["-" * width for width in widths])]
+    lines.extend(line(row) for row in rows)
+    return "\n".join(lines)
+
+
+def summary_line(summary: RunSummary) -> str:
+    """
+    Closing line of a run, e.g. "2/3 reports succeeded in 12.4s."
+    """
+    succeeded = sum(status.status == "success" for status in summary.results)
+    elapsed = (summary.finished_at - summary.started_at).total_seconds()
+    dry_run = " (dry run)" if summary.dry_run else ""
+    return f"{succeeded}/{len(summary.results)} reports succeeded in {elapsed:.1f}s{dry_run}."
+
+
+async def run_pipeline(args: argparse.Namespace) -> int:
+    """
+    Run a pipeline and print the status of its reports.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the pipeline or the account are invalid.
+        CommandError: If a report failed; `partial` when others succeeded.
+    """
+    config = pipeline_config(args.pipeline)
+    update = {"dry_run": args.dry_run or config.dry_run}
+    if args.parallel is not None:
+        if args.parallel < 1:
+            raise ValueError("--parallel must be at least 1")
+        update["max_parallel"] = args.parallel
+    if args.timeout is not None:
+        update["timeout"] = args.timeout
+    config = config.model_copy(update=update)
+    username, password = account_credentials(args.account)
+    progress = progress_for(sys.stderr) if args.progress and not args.quiet else nullcontext()
+    async with authenticated_session(username, password) as (session, csrf_token):
+        with progress as shown:
+            context = ReportContext(client=session, csrf_token=csrf_token, progress=shown)
+            summary = await run_reports(context, config, snapshot_store)
+    if args.json:
+        print(summary.model_dump_json(indent=2))
+    elif args.quiet:
+        print(f"{args.pipeline}: {summary_line(summary)}")
+    else:
+        print(summary_table(summary.results))
+        print(summary_line(summary))
+    failed = [status for status in summary.results if status.status != "success"]
+    if not failed:
+        return 0
+    names = ", ".join(status.report for status in failed)
+    message = f"{len(failed)} of {len(summary.results)} reports not delivered: {names}"
+    if len(failed) < len(summary.results):
+        raise CommandError("partial", message)
+    first = next((status for status in failed if status.status == "failed"), failed[0])
+    raise CommandError(first.error_kind or "error", message, first.report)
@@ -0,0 +1,193 @@
+"""
+`lanx serve-scheduler` command.
+
+Runs the `SCHEDULES` of the settings in-process until interrupted, in
+place of one Task Scheduler entry per report. Each schedule has a cron
+expression, in the `PORTAL_TIMEZONE`, and either a report, fetched with
+its `REPORT_DEFAULTS`, or a whole batch run configuration:
+
+    schedules:
+      morning_orders:
+        cron: "0 7 * * mon-fri"
+        report: pending_orders
+      weekly_pipeline:
+        cron: "30 6 * * mon"
+        account: plant2
+        run:
+          reports: [{report: pending_materials, destinations: [postgres]}]
+          bundles: [{target: "semanal_{date:%Y-%m-%d}.xlsx"}]
+
+Every run logs in again, so sessions never expire between runs, and stores
+its snapshots as the batch runs of the API. Runs are skipped while the
+previous run of the same schedule is still running (see
+`services.scheduler`); each schedule also logs to its own file of
+`SCHEDULE_LOG_DIR`. Unlike other commands, the scheduler logs its
+progress (INFO) without `--verbose`.
+
+Reports with a `timeout`, in their `REPORT_DEFAULTS` or their batch run,
+fail once fetching them takes longer, so a hung report cannot delay the
+rest of a run; `--timeout` sets it for the reports with none. Once a run
+stored its snapshots, the webhook subscriptions of its reports receive
+their changes (see `services.subscriptions`).
+"""
+
+import argparse
+import logging
+from pathlib import Path
+from typing import List, Optional
+
+from cli.report_command import account_credentials, timeout_seconds
+from core.config import ReportDefaults, Schedule, settings
+from core.logger import logger
+from core.session_manager import authenticated_session
+from core.snapshot_store import snapshot_store
+from core.utils.cron import CronExpression
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.progress import ProgressLog
+from services.report_registry import ReportContext, get_report
+from services.runner import run_reports
+from services.scheduler import ScheduledJob, Scheduler
+from services.subscriptions import notify_subscribers
+
+
+def add_scheduler_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `serve-scheduler` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "serve-scheduler",
+        help="Run the configured schedules until interrupted.",
+        description="Run the SCHEDULES of the settings at the times of their cron "
+        "expressions, until interrupted.",
+    )
+    parser.add_argument(
+        "--job",
+        dest="jobs",
+        action="append",
+        metavar="NAME",
+        help="Only run this schedule; repeat for several schedules.",
+    )
+    parser.add_argument(
+        "--list",
+        action="store_true",
+        help="Print the next run of every schedule and exit.",
+    )
+    parser.add_argument(
+        "--dry-run",
+        action="store_true",
+        help="Fetch the reports at their times, but write, upload and e-mail nothing.",
+    )
+    parser.add_argument(
+        "--timeout",
+        type=timeout_seconds,
+        metavar="SECONDS",
+        help="Fail the reports whose fetch takes longer, unless they have a timeout of their "
+        "own. Defaults to the timeout of the run of each schedule.",
+    )
+    parser.set_defaults(handler=serve_scheduler)
+
+
+def schedule_config(name: str, schedule: Schedule) -> RunConfig:
+    """
+    Batch run configuration of a schedule.
+
+    Raises:
+        KeyError: If the schedule references an unknown report.
+        ValueError: If the schedule has both or neither a report and a run.
+    """
+    if (schedule.report is None) == (schedule.run is None):
+        raise ValueError(f"Schedule {name} must have either a report or a run")
+    if schedule.run is not None:
+        config = RunConfig.model_validate(schedule.run)
+    else:
+        defaults = settings.REPORT_DEFAULTS.get(schedule.report, ReportDefaults())
+        job = ReportJob(
+            report=schedule.report,
+            filters=defaults.filters,
+            destinations=defaults.destinations,
+            timeout=defaults.timeout,
+        )
+        config = RunConfig(reports=[job])
+    for job in config.reports:
+        get_report(job.report)
+    return config
+
+
+def scheduled_job(
+    name: str, schedule: Schedule, dry_run: bool = False, timeout: Optional[float] = None
+) -> ScheduledJob:
+    """
+    Job of the scheduler running a schedule.
+
+    Args:
+        name (str): Schedule name.
+        schedule (Schedule): Schedule of the settings.
+        dry_run (bool, optional): Whether the runs write nothing. Defaults to False.
+        timeout (Optional[float], optional): Timeout of the reports without
+            one of their own, replacing the one of the run when given.
+
+    Raises:
+        KeyError: If the schedule references an unknown report.
+        ValueError: If the schedule or its cron expression is invalid.
+    """
+    cron = CronExpression.parse(schedule.cron)
+    config = schedule_config(name, schedule)
+    update = {"dry_run": dry_run, **({"timeout": timeout} if timeout else {})}
+    config = config.model_copy(update=update)
+    account = schedule.account
+    if account is None and schedule.report is not None:
+        account = settings.REPORT_DEFAULTS.get(schedule.report, ReportDefaults()).account
+    username, password = account_credentials(account)
+
+    async def run() -> None:
+        async with authenticated_session(username, password) as (session, csrf_token):
+            with ProgressLog() as progress:
+                context = ReportContext(client=session, csrf_token=csrf_token, progress=progress)
+                summary = await run_reports(context, config, snapshot_store)
+        await notify_subscribers(summary)
+        failed = [result.report for result in summary.results if result.status != "success"]
+        if failed:
+            raise RuntimeError(f"Reports not delivered: {', '.join(failed)}")
+
+    return ScheduledJob(name=name, cron=cron, run=run)
+
+
+async def serve_scheduler(args: argparse.Namespace) -> int:
+    """
+    Run the configured schedules until interrupted.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        KeyError: If a schedule is not configured or references an unknown report.
+        ValueError: If a schedule is invalid.
+    """
+    names: List[str] = args.jobs or list(settings.SCHEDULES)
+    unknown = [name for name in names if name not in settings.SCHEDULES]
+    if unknown:
+        raise KeyError(f"Unknown schedules {', '.join(unknown)}; configure them in SCHEDULES")
+    scheduler = Scheduler(
+        jobs=[
+            scheduled_job(name, settings.SCHEDULES[name], args.dry_run, args.timeout)
+            for name in names
+        ],
+        timezone=settings.PORTAL_TIMEZONE,
+        log_dir=Path(settings.SCHEDULE_LOG_DIR),
+    )
+    if args.list:
+        for when, job in scheduler.next_runs():
+            print(f"{job.name}\t{job.cron.expression}\t{when:%Y-%m-%d %H:%M %Z}")
+        return 0
+    root = logging.getLogger()
+    if root.level > logging.INFO:
+        root.setLevel(logging.INFO)
+    logger.info(f"Scheduler started with {len(scheduler.jobs)} schedules.")
+    await scheduler.serve()
+    return 0
@@ -0,0 +1,122 @@
+"""
+`lanx serve` command.
+
+Starts the HTTP API of the scraper (see `main`), so internal apps get the
+reports as JSON instead of embedding the scraping themselves:
+
+    $ lanx serve --port 8090
+    Serving the reports on http://127.0.0.1:8090/api/v1/reports
+    $ curl 'http://127.0.0.1:8090/api/v1/reports/pending-orders?init_date=2025-01-01'
+
+Every registered report is served by `GET /api/v1/reports/{report}`, with
+its filters as query parameters (see `api.routes.dataset_router`), along
+with the other routes of the API. The server logs in to CM once, as
+USERNAME, when it starts, and serves until interrupted. It needs uvicorn,
+from the `serve` extra.
+
+`lanx serve-grpc` serves the same reports over gRPC instead, for typed
+clients and streaming (see `api.grpc_server` and
+`protos/lanx/v1/reports.proto`), logged in as `--account`:
+
+    $ lanx serve-grpc --port 50051
+    Serving the reports over gRPC on 127.0.0.1:50051
+"""
+
+import argparse
+
+from cli.report_command import account_credentials
+from core.session_manager import authenticated_session
+
+
+def add_serve_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `serve` and `serve-grpc` commands.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "serve",
+        help="Serve the reports as JSON over HTTP until interrupted.",
+        description="Start the HTTP API, serving every report at /api/v1/reports/REPORT "
+        "with its filters as query parameters.",
+    )
+    parser.add_argument(
+        "--host",
+        default="127.0.0.1",
+        help="Address to listen on; 0.0.0.0 for every interface. Defaults to 127.0.0.1.",
+    )
+    parser.add_argument(
+        "--port", type=int, default=8000, help="Port to listen on. Defaults to 8000."
+    )
+    parser.set_defaults(handler=serve)
+
+    grpc_parser = subparsers.add_parser(
+        "serve-grpc",
+        help="Serve the reports over gRPC until interrupted.",
+        description="Start the gRPC ReportService of protos/lanx/v1/reports.proto, listing, "
+        "fetching and streaming every report.",
+    )
+    grpc_parser.add_argument(
+        "--host",
+        default="127.0.0.1",
+        help="Address to listen on; 0.0.0.0 for every interface. Defaults to 127.0.0.1.",
+    )
+    grpc_parser.add_argument(
+        "--port", type=int, default=50051, help="Port to listen on. Defaults to 50051."
+    )
+    grpc_parser.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    grpc_parser.set_defaults(handler=serve_grpc)
+
+
+async def serve(args: argparse.Namespace) -> int:
+    """
+    Serve the HTTP API until interrupted.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        RuntimeError: If uvicorn is not installed.
+    """
+    try:
+        import uvicorn
+    except ImportError as e:
+        raise RuntimeError("lanx serve requires uvicorn; install the 'serve' extra") from e
+    from main import app
+
+    # The logging of the API, configured by `core.logger`, is kept.
+    server = uvicorn.Server(uvicorn.Config(app, host=args.host, port=args.port, log_config=None))
+    print(f"Serving the reports on http://{args.host}:{args.port}/api/v1/reports")
+    await server.serve()
+    return 0
+
+
+async def serve_grpc(args: argparse.Namespace) -> int:
+    """
+    Serve the reports over gRPC until interrupted.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the account is invalid.
+        RuntimeError: If grpcio is not installed or the port cannot be bound.
+    """
+    from api.grpc_server import grpc_modules, serve_grpc as serve_reports
+
+    # Fails before logging in when grpcio is missing.
+    grpc_modules()
+    username, password = account_credentials(args.account)
+    async with authenticated_session(username, password) as (session, csrf_token):
+        print(f"Serving the reports over gRPC on {args.host}:{args.port}")
+        await serve_reports(f"{args.host}:{args.port}", session, csrf_token)
+    return 0
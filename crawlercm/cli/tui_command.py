@@ -0,0 +1,93 @@
+"""
+`lanx tui` command.
+
+Browses the reports of CM in the terminal, for quick lookups without a
+spreadsheet:
+
+1. pick a report, unless given as argument;
+2. edit its filters, starting from its `REPORT_DEFAULTS` and `--filter`
+   options (Enter edits the selected filter, `f` fetches the report);
+3. browse its rows: the arrows and PgUp/PgDn scroll and select a column,
+   `/` keeps the rows containing a text, `s` sorts by the selected column
+   (again for descending order), `e` exports the rows as shown, searched
+   and sorted, to a file whose suffix gives its format, `b` goes back to
+   the filters and `q` quits.
+
+Values are shown as in the `table` format (see `services.export.table_export`).
+The whole session uses one CM login, and the console logs are hidden
+while the screen is shown (they still go to the log file). The screens
+are in `cli.tui_screen`, on `curses`; where it is missing (Windows
+without `windows-curses`), only `lanx tui` fails.
+"""
+
+import argparse
+
+from cli.errors import CommandError
+from cli.report_command import account_credentials, parse_filters
+from core.session_manager import authenticated_session
+from services.report_registry import REPORTS, ReportContext, get_report
+
+
+def add_tui_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `tui` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "tui",
+        help="Browse, search, sort and export reports in the terminal.",
+        description="Pick a report, set its filters, then browse, search, sort and export "
+        "its rows in the terminal.",
+    )
+    parser.add_argument(
+        "report",
+        nargs="?",
+        type=lambda name: name.replace("-", "_"),
+        choices=sorted(REPORTS),
+        metavar="REPORT",
+        help="Report to open. Defaults to picking one.",
+    )
+    parser.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    parser.add_argument(
+        "--filter",
+        dest="filters",
+        action="append",
+        default=[],
+        metavar="KEY=VALUE",
+        help="Initial report filter; repeat for several filters.",
+    )
+    parser.set_defaults(handler=run_tui)
+
+
+async def run_tui(args: argparse.Namespace) -> int:
+    """
+    Browse reports until quit.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the account is invalid.
+        CommandError: If `curses` is missing.
+    """
+    try:
+        import curses  # noqa: F401
+    except ImportError as e:
+        raise CommandError("error", "lanx tui needs curses; on Windows, install windows-curses") from e
+    from cli.tui_screen import browse_reports
+
+    credentials = account_credentials(args.account)
+    initial = parse_filters(args.filters)
+    definition = get_report(args.report) if args.report else None
+    if definition is not None:
+        definition.parse_filters(initial)
+    async with authenticated_session(*credentials) as (session, csrf_token):
+        context = ReportContext(client=session, csrf_token=csrf_token)
+        return await browse_reports(context, definition, initial, args.quiet)
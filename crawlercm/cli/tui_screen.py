@@ -0,0 +1,448 @@
+"""
+Screens of `lanx tui`, on `curses`.
+
+Kept apart from `cli.tui_command` so that `lanx` runs where `curses` is
+missing (Windows without `windows-curses`): this module is imported only
+when `lanx tui` runs.
+"""
+
+import curses
+import logging
+from contextlib import contextmanager
+from dataclasses import dataclass, field
+from decimal import Decimal
+from typing import Any, Dict, Iterator, List, Optional, Sequence, Tuple, Union
+
+from pydantic import BaseModel, ValidationError
+
+from cli.report_command import write_output
+from core.config import ReportDefaults, settings
+from core.logger import logger, set_console_level
+from core.utils.sorting import sort_rows
+from schemas.dataset_schemas import Dataset
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.export.columns import display_values, select_columns
+from services.report_registry import REPORTS, ReportContext, ReportDefinition
+from services.runner import run_reports
+
+# Maximum width of a column of the table, in characters.
+MAX_COLUMN_WIDTH = 30
+
+ESCAPE = "\x1b"
+ENTER_KEYS = ("\n", "\r", curses.KEY_ENTER)
+BACKSPACE_KEYS = ("\b", "\x7f", curses.KEY_BACKSPACE)
+NAVIGATION_KEYS = (
+    curses.KEY_UP,
+    curses.KEY_DOWN,
+    curses.KEY_PPAGE,
+    curses.KEY_NPAGE,
+    curses.KEY_HOME,
+    curses.KEY_END,
+)
+
+TABLE_HELP = "↑↓ rows  ←→ column  / search  s sort  e export  b filters  q quit"
+FILTERS_HELP = "↑↓ select  Enter edit  f fetch  q reports"
+REPORTS_HELP = "↑↓ select  Enter open  q quit"
+
+Key = Union[str, int]
+
+
+@dataclass
+class TableView:
+    """
+    Rows of a dataset as browsed: searched, sorted and formatted for display.
+
+    Attributes:
+        dataset (Dataset): Report rows.
+        search (str): Text the rows shown contain, in any of their values,
+            ignoring case; every row is shown when empty.
+        sort_field (Optional[str]): Field the rows are sorted by, if any.
+        descending (bool): Whether the rows are sorted in descending order.
+    """
+
+    dataset: Dataset
+    search: str = ""
+    sort_field: Optional[str] = None
+    descending: bool = False
+    fields: List[str] = field(init=False)
+    headers: List[str] = field(init=False)
+    numeric: List[bool] = field(init=False)
+    _texts: Dict[int, List[str]] = field(init=False)
+
+    def __post_init__(self):
+        layout = select_columns(self.dataset, language="pt-BR")
+        self.fields = [name for name, _ in layout]
+        self.headers = [title for _, title in layout]
+        self.numeric = [
+            any(
+                isinstance(value, (int, float, Decimal)) and not isinstance(value, bool)
+                for value in self.dataset.column(name)
+            )
+            for name in self.fields
+        ]
+        self._texts = {id(row): display_values(row, self.fields) for row in self.dataset.rows}
+
+    def sort_by(self, name: str) -> None:
+        """
+        Sort by a field, ascending, or descending when already sorted by it.
+        """
+        self.descending = self.sort_field == name and not self.descending
+        self.sort_field = name
+
+    def rows(self) -> List[Any]:
+        """
+        Rows shown, in order.
+        """
+        needle = self.search.casefold()
+        rows = [
+            row
+            for row in self.dataset.rows
+            if not needle or any(needle in text.casefold() for text in self._texts[id(row)])
+        ]
+        if self.sort_field is not None:
+            rows = sort_rows(rows, [f"{'-' if self.descending else ''}{self.sort_field}"])
+        return rows
+
+    def texts(self, row: Any) -> List[str]:
+        """
+        Display texts of the fields of a row.
+        """
+        return self._texts[id(row)]
+
+    def view(self) -> Dataset:
+        """
+        Dataset with the rows shown, in order, for exports.
+        """
+        rows = self.rows()
+        metadata = self.dataset.metadata.model_copy(update={"row_count": len(rows)})
+        return self.dataset.model_copy(update={"metadata": metadata, "rows": rows})
+
+
+def _cut(text: str, width: int) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= width else f"{text[: width - 1]}…"
+
+
+def _filter_values(definition: ReportDefinition, texts: Dict[str, str]) -> BaseModel:
+    """
+    Validated filters of the texts of the filter form, as `--filter` pairs;
+    empty texts leave their filter unset.
+    """
+    return definition.parse_filters({name: text for name, text in texts.items() if text.strip()})
+
+
+@contextmanager
+def _terminal(quiet: bool) -> Iterator["curses.window"]:
+    """
+    Full screen mode of the terminal, as `curses.wrapper`, with the console logs hidden.
+    """
+    set_console_level(logging.CRITICAL + 1)
+    screen = curses.initscr()
+    try:
+        curses.noecho()
+        curses.cbreak()
+        screen.keypad(True)
+        try:
+            curses.curs_set(0)
+        except curses.error:
+            pass
+        yield screen
+    finally:
+        screen.keypad(False)
+        curses.nocbreak()
+        curses.echo()
+        curses.endwin()
+        set_console_level(logging.ERROR if quiet else logging.NOTSET)
+
+
+def _put(screen: "curses.window", y: int, x: int, text: str, attributes: int = 0) -> None:
+    height, width = screen.getmaxyx()
+    # The bottom right cell cannot be written without an error.
+    length = width - x - (1 if y == height - 1 else 0)
+    if 0 <= y < height and length > 0:
+        screen.addnstr(y, x, text, length, attributes)
+
+
+def _status(screen: "curses.window", text: str) -> None:
+    height, _ = screen.getmaxyx()
+    screen.move(height - 1, 0)
+    screen.clrtoeol()
+    _put(screen, height - 1, 0, text, curses.A_DIM)
+
+
+def _prompt(screen: "curses.window", label: str, initial: str = "") -> Optional[str]:
+    """
+    Read a line of text on the bottom line of the screen.
+
+    Returns:
+        Optional[str]: The text, or None when cancelled with Escape.
+    """
+    text = initial
+    try:
+        curses.curs_set(1)
+    except curses.error:
+        pass
+    try:
+        while True:
+            height, _ = screen.getmaxyx()
+            screen.move(height - 1, 0)
+            screen.clrtoeol()
+            _put(screen, height - 1, 0, f"{label}{text}")
+            key = screen.get_wch()
+            if key in ENTER_KEYS:
+                return text
+            if key == ESCAPE:
+                return None
+            if key in BACKSPACE_KEYS:
+                text = text[:-1]
+            elif isinstance(key, str) and key.isprintable():
+                text += key
+    finally:
+        try:
+            curses.curs_set(0)
+        except curses.error:
+            pass
+
+
+def _move(key: Key, selected: int, count: int, page: int) -> int:
+    """
+    Selected line after a navigation key.
+    """
+    steps = dict(zip(NAVIGATION_KEYS, (-1, 1, -page, page, -count, count)))
+    return max(0, min(count - 1, selected + steps.get(key, 0)))
+
+
+def _menu(
+    screen: "curses.window",
+    title: str,
+    lines: Sequence[str],
+    help_text: str,
+    selected: int = 0,
+    message: str = "",
+) -> Tuple[Key, int]:
+    """
+    Show a list of lines with one selected, until a key other than a navigation key.
+
+    Returns:
+        Tuple[Key, int]: The key pressed and the selected line.
+    """
+    while True:
+        height, _ = screen.getmaxyx()
+        page = max(1, height - 4)
+        top = max(0, selected - page + 1)
+        screen.erase()
+        _put(screen, 0, 0, title, curses.A_BOLD)
+        for y, index in enumerate(range(top, min(len(lines), top + page)), start=2):
+            _put(screen, y, 0, lines[index], curses.A_REVERSE if index == selected else 0)
+        _status(screen, message or help_text)
+        key = screen.get_wch()
+        if key in NAVIGATION_KEYS:
+            selected = _move(key, selected, len(lines), page)
+        elif key != curses.KEY_RESIZE:
+            return key, selected
+        message = ""
+
+
+def _pick_report(screen: "curses.window", current: Optional[str]) -> Optional[ReportDefinition]:
+    names = sorted(REPORTS)
+    width = max(len(name) for name in names)
+    lines = [f"{name.ljust(width)}  {REPORTS[name].description}" for name in names]
+    selected = names.index(current) if current in names else 0
+    while True:
+        key, selected = _menu(screen, "Reports", lines, REPORTS_HELP, selected)
+        if key in ENTER_KEYS:
+            return REPORTS[names[selected]]
+        if key in ("q", ESCAPE):
+            return None
+
+
+def _edit_filters(
+    screen: "curses.window", definition: ReportDefinition, texts: Dict[str, str]
+) -> Optional[BaseModel]:
+    """
+    Form of the filters of a report, editing `texts` in place.
+
+    Returns:
+        Optional[BaseModel]: The validated filters to fetch the report with,
+        or None to pick another report.
+    """
+    fields = definition.filters_model.model_fields
+    names = list(fields)
+    width = max([len(name) for name in names] or [0])
+    selected, message = 0, ""
+    while True:
+        lines = [
+            f"{name.ljust(width)}  {texts.get(name) or '-':<24}  {fields[name].description or ''}"
+            for name in names
+        ] or ["(no filters)"]
+        title = f"{definition.name}: filters"
+        key, selected = _menu(screen, title, lines, FILTERS_HELP, selected, message)
+        message = ""
+        if key in ENTER_KEYS and names:
+            name = names[selected]
+            text = _prompt(screen, f"{name}: ", texts.get(name, ""))
+            if text is not None:
+                texts[name] = text
+        elif key == "f":
+            try:
+                return _filter_values(definition, texts)
+            except ValidationError as e:
+                error = e.errors()[0]
+                message = f"{'.'.join(map(str, error['loc']))}: {error['msg']}"
+            except ValueError as e:
+                message = str(e)
+        elif key in ("q", ESCAPE):
+            return None
+
+
+def _column_widths(view: TableView, rows: Sequence[Any]) -> List[int]:
+    widths = [len(header) for header in view.headers]
+    for row in rows:
+        for index, text in enumerate(view.texts(row)):
+            widths[index] = max(widths[index], len(text))
+    return [min(width, MAX_COLUMN_WIDTH) for width in widths]
+
+
+def _export(screen: "curses.window", view: TableView) -> str:
+    path = _prompt(screen, "Export to (file.csv, .xlsx, .json...): ")
+    if not path:
+        return ""
+    try:
+        written = write_output(view.view(), path, None)
+    except (KeyError, ValueError, OSError, RuntimeError) as e:
+        return f"Export failed: {e}"
+    return f"Exported {len(view.rows())} rows to {written}."
+
+
+def _browse(screen: "curses.window", view: TableView) -> bool:
+    """
+    Table of the rows of a report.
+
+    Returns:
+        bool: True to go back to the filters, False to quit.
+    """
+    widths = _column_widths(view, view.dataset.rows)
+    column, selected, first_column, message = 0, 0, 0, ""
+    rows = view.rows()
+    while True:
+        height, screen_width = screen.getmaxyx()
+        page = max(1, height - 5)
+        top = max(0, selected - page + 1)
+        # Scroll the columns so the selected one is on screen.
+        first_column = min(first_column, column)
+        while first_column < column and (
+            sum(width + 2 for width in widths[first_column : column + 1]) > screen_width
+        ):
+            first_column += 1
+        screen.erase()
+        sort = ""
+        if view.sort_field is not None:
+            sort = f"  sorted by {view.sort_field} {'↓' if view.descending else '↑'}"
+        search = f"  search: {view.search}" if view.search else ""
+        count = len(view.dataset.rows)
+        title = f"{view.dataset.metadata.report}: {len(rows)}/{count} rows{search}{sort}"
+        _put(screen, 0, 0, title, curses.A_BOLD)
+
+        def cells(values: Sequence[str], y: int, attributes: int = 0) -> None:
+            x = 0
+            for index in range(first_column, len(values)):
+                if x >= screen_width:
+                    break
+                width = widths[index]
+                text = _cut(values[index], width)
+                text = text.rjust(width) if view.numeric[index] else text.ljust(width)
+                highlight = curses.A_UNDERLINE if index == column and y == 1 else 0
+                _put(screen, y, x, text, attributes | highlight)
+                x += width + 2
+
+        cells(view.headers, 1, curses.A_BOLD)
+        cells(["─" * width for width in widths], 2)
+        for y, index in enumerate(range(top, min(len(rows), top + page)), start=3):
+            cells(view.texts(rows[index]), y, curses.A_REVERSE if index == selected else 0)
+        _status(screen, message or TABLE_HELP)
+        message = ""
+
+        key = screen.get_wch()
+        if key == curses.KEY_LEFT:
+            column = max(0, column - 1)
+        elif key == curses.KEY_RIGHT:
+            column = min(len(view.fields) - 1, column + 1)
+        elif key == "/":
+            search = _prompt(screen, "Search: ", view.search)
+            if search is not None:
+                view.search = search
+                rows, selected = view.rows(), 0
+        elif key == "s" and view.fields:
+            view.sort_by(view.fields[column])
+            rows, selected = view.rows(), 0
+        elif key == "e":
+            message = _export(screen, view)
+        elif key == "b":
+            return True
+        elif key in ("q", ESCAPE):
+            return False
+        elif rows:
+            selected = _move(key, selected, len(rows), page)
+
+
+async def _fetch(
+    context: ReportContext, definition: ReportDefinition, filters: BaseModel
+) -> Dataset:
+    job = ReportJob(report=definition.name, filters=filters.model_dump(exclude_unset=True))
+    results: Dict[str, Dataset] = {}
+    summary = await run_reports(context, RunConfig(reports=[job]), results=results)
+    if definition.name not in results:
+        status = next(result for result in summary.results if result.status == "failed")
+        raise RuntimeError(f"{status.report} failed: {status.error}")
+    return results[definition.name]
+
+
+async def browse_reports(
+    context: ReportContext,
+    definition: Optional[ReportDefinition],
+    initial: Dict[str, Any],
+    quiet: bool,
+) -> int:
+    """
+    Browse reports until quit.
+
+    Args:
+        context (ReportContext): Authenticated CM session.
+        definition (Optional[ReportDefinition]): Report to open first. Defaults to picking one.
+        initial (Dict[str, Any]): Initial filters, from `--filter` options.
+        quiet (bool): Whether the console logs are already hidden.
+
+    Returns:
+        int: Exit status.
+    """
+    texts: Dict[str, Dict[str, str]] = {}
+    with _terminal(quiet) as screen:
+        while True:
+            if definition is None:
+                definition = _pick_report(screen, None)
+                if definition is None:
+                    return 0
+            if definition.name not in texts:
+                defaults = settings.REPORT_DEFAULTS.get(definition.name, ReportDefaults())
+                values = {**defaults.filters, **initial}
+                texts[definition.name] = {
+                    name: "" if values.get(name) is None else str(values[name])
+                    for name in definition.filters_model.model_fields
+                }
+            filters = _edit_filters(screen, definition, texts[definition.name])
+            if filters is None:
+                definition = _pick_report(screen, definition.name)
+                if definition is None:
+                    return 0
+                continue
+            _status(screen, f"Fetching {definition.name}...")
+            screen.refresh()
+            try:
+                dataset = await _fetch(context, definition, filters)
+            except Exception as e:
+                logger.error(f"Fetching {definition.name} failed: {e}")
+                _status(screen, f"Fetching {definition.name} failed: {e} (press a key)")
+                screen.get_wch()
+                continue
+            if not _browse(screen, TableView(dataset)):
+                return 0
@@ -0,0 +1,70 @@
+"""
+`lanx version` command.
+
+Prints the version of the scraper and the build it runs (see
+`core.build_info`), to be quoted in support tickets:
+
+    $ lanx version
+    lanx 0.1.0
+    commit:     9d674a8c1e0b...
+    built:      2025-03-01T10:00:00
+    python:     3.12.7
+    platform:   Linux-6.1-x86_64
+
+`--json` prints the same as JSON, and `--stamp` records the commit and
+date of the build in `build_info.json`, for build and deploy steps.
+"""
+
+import argparse
+import json
+
+from core.build_info import build_info, stamp_build_info
+
+
+def add_version_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `version` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "version",
+        help="Print the version and build of lanx.",
+        description="Print the version, git commit, build date and Python version of lanx.",
+    )
+    parser.add_argument("--json", action="store_true", help="Print the build as JSON.")
+    parser.add_argument(
+        "--stamp",
+        action="store_true",
+        help="Record the git commit and the date of the build in build_info.json.",
+    )
+    parser.set_defaults(handler=print_version)
+
+
+async def print_version(args: argparse.Namespace) -> int:
+    """
+    Print the version and build metadata, stamping them first with `--stamp`.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        RuntimeError: If the build is stamped outside a git checkout.
+    """
+    if args.stamp:
+        path = stamp_build_info()
+        if not args.quiet:
+            print(f"Stamped the build in {path}.")
+    info = build_info()
+    if args.json:
+        print(json.dumps(info, indent=2))
+        return 0
+    print(f"lanx {info['version']}")
+    labels = {"commit": "commit", "build_date": "built", "python": "python", "platform": "platform"}
+    for key, label in labels.items():
+        print(f"{label + ':':<11} {info[key] or 'unknown'}")
+    return 0
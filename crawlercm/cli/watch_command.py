@@ -0,0 +1,249 @@
+"""
+`lanx watch` command.
+
+Fetches a report at an interval and, whenever its rows changed since the
+previous fetch, prints the diff (see `cli.diff_command`) and runs the
+`--on-change` actions:
+
+    $ lanx watch pending-materials --interval 5m --on-change notify:slack
+    Watching pending_materials every 5m: 412 rows.
+    2025-03-07 10:35 pending_materials changed
+    ~ code=MP-07 supplier=ACME: unit_price 10.5 → 11.2
+    0 added, 0 removed, 1 changed
+
+Actions are `notify:<channel>`, posting the diff to a chat channel of
+`NOTIFY_WEBHOOKS` (see `services.notify.chat`), or any destination of a
+batch run (a file path, `s3://bucket/prefix`, `webhook`, ...), which
+receives the rows of the changed report. The first fetch is the baseline
+and triggers nothing; failed fetches after it are logged and retried at
+the next interval. Rows are identified by the key of the report, or
+`--key`.
+"""
+
+import argparse
+import asyncio
+import re
+import sys
+from datetime import datetime
+from typing import Dict, List, Optional, Tuple
+
+from cli.diff_command import diff_text
+from cli.errors import CommandError
+from cli.report_command import account_credentials, parse_filters
+from core.config import ReportDefaults, settings
+from core.logger import logger
+from core.session_manager import authenticated_session
+from schemas.dataset_schemas import Dataset
+from schemas.diff_schemas import ReportDiff
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.notify.chat import post_chat_message
+from services.report_diff import diff
+from services.report_registry import REPORTS, ReportContext, ReportDefinition, get_report
+from services.runner import deliver, run_reports
+
+NOTIFY_PREFIX = "notify:"
+# Rows of the diff included in chat notifications.
+MESSAGE_ROWS = 10
+
+_INTERVAL_PATTERN = re.compile(r"(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?")
+
+
+def parse_interval(text: str) -> float:
+    """
+    Seconds of an interval such as `90`, `30s`, `5m` or `1h30m`.
+
+    Raises:
+        argparse.ArgumentTypeError: If the interval is invalid or not positive.
+    """
+    match = _INTERVAL_PATTERN.fullmatch(text)
+    if text.isdigit():
+        seconds = int(text)
+    elif text and match:
+        hours, minutes, secs = (int(value or 0) for value in match.groups())
+        seconds = hours * 3600 + minutes * 60 + secs
+    else:
+        raise argparse.ArgumentTypeError(f"invalid interval {text!r}; use e.g. 30s, 5m or 1h")
+    if seconds <= 0:
+        raise argparse.ArgumentTypeError("the interval must be positive")
+    return float(seconds)
+
+
+def interval_text(seconds: float) -> str:
+    """
+    Interval in the form of `parse_interval`, e.g. `1h30m`.
+    """
+    minutes, secs = divmod(int(seconds), 60)
+    hours, minutes = divmod(minutes, 60)
+    parts = [(hours, "h"), (minutes, "m"), (secs, "s")]
+    return "".join(f"{value}{unit}" for value, unit in parts if value) or "0s"
+
+
+def add_watch_commands(subparsers: argparse._SubParsersAction) -> None:
+    """
+    Add the `watch` command.
+
+    Args:
+        subparsers (argparse._SubParsersAction): Subcommands of `lanx`.
+    """
+    parser = subparsers.add_parser(
+        "watch",
+        help="Fetch a report at an interval and act when it changes.",
+        description="Fetch a report at an interval; when its rows change, print the diff "
+        "and run the --on-change actions.",
+    )
+    parser.add_argument(
+        "report",
+        type=lambda name: name.replace("-", "_"),
+        choices=sorted(REPORTS),
+        metavar="REPORT",
+        help="Report to watch.",
+    )
+    parser.add_argument(
+        "--interval",
+        type=parse_interval,
+        default=parse_interval("5m"),
+        metavar="DURATION",
+        help="Time between two fetches, such as 30s, 5m or 1h. Defaults to 5m.",
+    )
+    parser.add_argument(
+        "--on-change",
+        dest="actions",
+        action="append",
+        default=[],
+        metavar="ACTION",
+        help="notify:<channel of NOTIFY_WEBHOOKS> or a destination receiving the changed "
+        "rows; repeat for several actions.",
+    )
+    parser.add_argument(
+        "--account", help="CM account, as configured in ACCOUNTS. Defaults to USERNAME."
+    )
+    parser.add_argument(
+        "--filter",
+        dest="filters",
+        action="append",
+        default=[],
+        metavar="KEY=VALUE",
+        help="Report filter; repeat for several filters.",
+    )
+    parser.add_argument(
+        "--key",
+        metavar="FIELDS",
+        help="Comma separated fields identifying a row. Defaults to the key of the report.",
+    )
+    parser.add_argument(
+        "--ignore",
+        metavar="FIELDS",
+        default="",
+        help="Comma separated fields not compared, such as timestamps.",
+    )
+    parser.add_argument("--json", action="store_true", help="Print every diff as a JSON line.")
+    parser.set_defaults(handler=run_watch)
+
+
+def change_message(report: str, result: ReportDiff) -> str:
+    """
+    Chat message of a change, with the first `MESSAGE_ROWS` rows of its diff.
+    """
+    lines = diff_text(result).splitlines()
+    rows = lines[:-1]
+    message = [f"{report} changed: {lines[-1]}", *rows[:MESSAGE_ROWS]]
+    if len(rows) > MESSAGE_ROWS:
+        message.append(f"… and {len(rows) - MESSAGE_ROWS} more")
+    return "\n".join(message)
+
+
+def run_action(action: str, dataset: Dataset, result: ReportDiff) -> None:
+    """
+    Run an `--on-change` action for a changed report.
+
+    Args:
+        action (str): `notify:<channel>` or a destination of a batch run.
+        dataset (Dataset): Rows of the report as just fetched.
+        result (ReportDiff): Changes since the previous fetch.
+    """
+    if action.startswith(NOTIFY_PREFIX):
+        channel = action[len(NOTIFY_PREFIX):]
+        post_chat_message(channel, change_message(dataset.metadata.report, result))
+    else:
+        deliver(dataset, action)
+
+
+async def _fetch(
+    definition: ReportDefinition, job: ReportJob, credentials: Tuple[Optional[str], ...]
+) -> Dataset:
+    results: Dict[str, Dataset] = {}
+    async with authenticated_session(*credentials) as (session, csrf_token):
+        context = ReportContext(client=session, csrf_token=csrf_token)
+        summary = await run_reports(context, RunConfig(reports=[job]), results=results)
+    if definition.name not in results:
+        status = next(result for result in summary.results if result.status == "failed")
+        raise CommandError(
+            status.error_kind or "error",
+            f"{status.report} failed: {status.error}",
+            definition.name,
+        )
+    return results[definition.name]
+
+
+async def run_watch(args: argparse.Namespace) -> int:
+    """
+    Watch a report until interrupted.
+
+    Args:
+        args (argparse.Namespace): Parsed command line.
+
+    Returns:
+        int: Exit status.
+
+    Raises:
+        ValueError: If the filters or the account are invalid, or the key of
+            the rows is unknown.
+        KeyError: If an action names an unknown notification channel.
+        CommandError: If the first fetch fails.
+    """
+    definition = get_report(args.report)
+    defaults = settings.REPORT_DEFAULTS.get(definition.name, ReportDefaults())
+    job = ReportJob(
+        report=definition.name,
+        filters={**defaults.filters, **parse_filters(args.filters)},
+        timeout=defaults.timeout,
+    )
+    definition.parse_filters(job.filters)
+    key_fields: List[str] = args.key.split(",") if args.key else definition.key_fields
+    if not key_fields:
+        raise ValueError("The key of the rows is unknown; give it with --key")
+    ignore = [name.strip() for name in args.ignore.split(",") if name.strip()]
+    for action in args.actions:
+        channel = action[len(NOTIFY_PREFIX):]
+        if action.startswith(NOTIFY_PREFIX) and channel not in settings.NOTIFY_WEBHOOKS:
+            raise KeyError(f"Unknown notification channel: {channel}; configure NOTIFY_WEBHOOKS")
+    credentials = account_credentials(args.account or defaults.account)
+
+    previous = await _fetch(definition, job, credentials)
+    interval = interval_text(args.interval)
+    print(
+        f"Watching {definition.name} every {interval}: {len(previous.rows)} rows.",
+        file=sys.stderr,
+    )
+    while True:
+        await asyncio.sleep(args.interval)
+        try:
+            dataset = await _fetch(definition, job, credentials)
+        except Exception as e:
+            logger.error(f"Fetching {definition.name} failed; retrying in {interval}: {e}")
+            continue
+        result = diff(previous.rows, dataset.rows, [key.strip() for key in key_fields], ignore)
+        previous = dataset
+        if result.is_empty:
+            continue
+        if args.json:
+            print(result.model_dump_json())
+        else:
+            print(f"{datetime.now():%Y-%m-%d %H:%M} {definition.name} changed")
+            print(diff_text(result))
+        sys.stdout.flush()
+        for action in args.actions:
+            try:
+                run_action(action, dataset, result)
+            except Exception as e:
+                logger.error(f"Action {action} of {definition.name} failed: {e}")
@@ -0,0 +1,133 @@
+"""
+API keys of the REST and gRPC servers.
+
+Stock and cost data should not be visible to every internal consumer, so
+with `API_KEYS` set, every request must send one of the keys, in the
+`X-API-Key` header (or `Authorization: Bearer <key>`; the `x-api-key`
+metadata on gRPC). Each key grants some reports and actions:
+
+    api_keys:
+      dashboard: {key_env: LANX_DASHBOARD_KEY, reports: [filtered_sales_report]}
+      purchasing:
+        key_env: LANX_PURCHASING_KEY
+        reports: [pending_materials, pending_orders]
+        actions: [refresh]
+      ops: {key_env: LANX_OPS_KEY, actions: [admin]}
+
+- `read`: fetch reports, snapshots, alerts and jobs, served from the
+  report cache when cached;
+- `refresh`: also scrape CM on demand (`fresh`, runs, jobs, streams and
+  cache invalidation);
+- `admin`: everything, including the webhook subscriptions and the runs
+  and jobs whose request sets its own files, URLs, bundles or e-mails
+  (destinations named by the settings, such as `postgres`, only need
+  `refresh`). Files are kept inside `API_EXPORT_DIR` even for `admin`.
+
+Keys reference their secret by the environment variable holding it
+(`key_env`), as the passwords of `ACCOUNTS`, or give it as `key`. Reports
+default to every report (`*`) and actions to `read`. Without `API_KEYS`
+the servers are open, as before; the health probes and `/metrics` are
+always open.
+"""
+
+import hmac
+import os
+from dataclasses import dataclass, field
+from typing import Iterable, List, Literal, Optional
+
+from core.config import settings
+
+Action = Literal["read", "refresh", "admin"]
+
+# Actions granted by each action of a key.
+GRANTS = {"read": {"read"}, "refresh": {"read", "refresh"}, "admin": {"read", "refresh", "admin"}}
+
+
+class InvalidApiKey(Exception):
+    """
+    A request sent no API key, or an unknown one.
+    """
+
+
+@dataclass
+class Principal:
+    """
+    Caller of the servers, by its API key.
+
+    Attributes:
+        name (str): Name of the key in `API_KEYS`.
+        reports (List[str]): Reports granted, or `*` for every report.
+        actions (List[Action]): Actions granted.
+    """
+
+    name: str
+    reports: List[str] = field(default_factory=lambda: ["*"])
+    actions: List[Action] = field(default_factory=lambda: ["admin"])
+
+    def can(self, action: Action) -> bool:
+        """
+        Whether the key grants an action.
+        """
+        return any(action in GRANTS[granted] for granted in self.actions)
+
+    def sees(self, report: str) -> bool:
+        """
+        Whether the key grants a report.
+        """
+        return "*" in self.reports or report in self.reports
+
+    def check(self, action: Action, reports: Iterable[str] = ()) -> None:
+        """
+        Check that the key grants an action on some reports.
+
+        Raises:
+            PermissionError: If the action or a report is not granted.
+        """
+        if not self.can(action):
+            raise PermissionError(f"API key {self.name} cannot {action}")
+        denied = [report for report in reports if not self.sees(report)]
+        if denied:
+            raise PermissionError(f"API key {self.name} cannot access {', '.join(denied)}")
+
+
+# Caller when `API_KEYS` is not set: everything is allowed.
+ANONYMOUS = Principal("anonymous")
+
+
+def _secret(name: str) -> Optional[str]:
+    key = settings.API_KEYS[name]
+    return os.environ.get(key.key_env) if key.key_env else key.key
+
+
+def authenticate(secret: Optional[str]) -> Principal:
+    """
+    The caller sending an API key.
+
+    Args:
+        secret (Optional[str]): Key sent with the request, if any.
+
+    Returns:
+        Principal: The key and what it grants; `ANONYMOUS` without `API_KEYS`.
+
+    Raises:
+        InvalidApiKey: If `API_KEYS` is set and the key is missing or unknown.
+    """
+    if not settings.API_KEYS:
+        return ANONYMOUS
+    if not secret:
+        raise InvalidApiKey("Missing API key; send it in the X-API-Key header")
+    for name, key in settings.API_KEYS.items():
+        expected = _secret(name)
+        if expected and hmac.compare_digest(expected.encode(), secret.encode()):
+            return Principal(name, list(key.reports), list(key.actions))
+    raise InvalidApiKey("Invalid API key")
+
+
+def bearer_key(authorization: Optional[str]) -> Optional[str]:
+    """
+    Key of an `Authorization: Bearer <key>` header, if it is one.
+    """
+    scheme, _, credentials = (authorization or "").partition(" ")
+    if scheme.lower() != "bearer":
+        return None
+    return credentials.strip() or None
@@ -0,0 +1,106 @@
+"""
+Version and build metadata of the scraper.
+
+Deployed builds record the git commit they were built from and the build
+date in `build_info.json`, next to `pyproject.toml`, with
+`lanx version --stamp` (run by the build or deploy step), since installed
+copies have no git checkout. Image builds may set the
+`LANX_BUILD_COMMIT` and `LANX_BUILD_DATE` environment variables instead,
+e.g. from build arguments. Without either, the commit is read from the git
+checkout, if any, so support tickets always name the exact build:
+
+    {"version": "0.1.0", "commit": "9d674a8...", "build_date": "2025-03-01T10:00:00",
+     "python": "3.12.7", "platform": "Linux-6.1-x86_64"}
+"""
+
+import json
+import os
+import platform
+import subprocess
+import tomllib
+from datetime import datetime
+from importlib import metadata
+from pathlib import Path
+from typing import Dict, Optional
+
+PROJECT_DIR = Path(__file__).resolve().parent.parent
+BUILD_INFO_FILE = PROJECT_DIR / "build_info.json"
+
+
+def version() -> str:
+    """
+    Version of the scraper, from the installed package or `pyproject.toml`.
+    """
+    try:
+        return metadata.version("crawlercm")
+    except metadata.PackageNotFoundError:
+        pass
+    try:
+        with open(PROJECT_DIR / "pyproject.toml", "rb") as file:
+            return tomllib.load(file)["project"]["version"]
+    except (OSError, KeyError, tomllib.TOMLDecodeError):
+        return "unknown"
+
+
+def _git(*args: str) -> Optional[str]:
+    try:
+        result = subprocess.run(
+            ["git", *args], cwd=PROJECT_DIR, capture_output=True, text=True, timeout=5
+        )
+    except (OSError, subprocess.SubprocessError):
+        return None
+    if result.returncode != 0:
+        return None
+    return result.stdout.strip() or None
+
+
+def git_commit() -> Optional[str]:
+    """
+    Commit of the git checkout of the scraper, suffixed by `-dirty` when it
+    has uncommitted changes, or None outside a checkout.
+    """
+    commit = _git("rev-parse", "HEAD")
+    if commit is not None and _git("status", "--porcelain", "--untracked-files=no"):
+        commit = f"{commit}-dirty"
+    return commit
+
+
+def build_info() -> Dict[str, Optional[str]]:
+    """
+    Version, commit, build date, Python version and platform of the scraper.
+
+    The commit and build date come from the environment, then from
+    `build_info.json`, then from the git checkout (without a build date).
+    """
+    stamped: Dict[str, str] = {}
+    if BUILD_INFO_FILE.is_file():
+        try:
+            stamped = json.loads(BUILD_INFO_FILE.read_text(encoding="utf-8"))
+        except ValueError:
+            stamped = {}
+    commit = os.environ.get("LANX_BUILD_COMMIT") or stamped.get("commit")
+    return {
+        "version": version(),
+        "commit": commit or git_commit(),
+        "build_date": os.environ.get("LANX_BUILD_DATE") or stamped.get("build_date"),
+        "python": platform.python_version(),
+        "platform": platform.platform(terse=True),
+    }
+
+
+def stamp_build_info() -> Path:
+    """
+    Record the commit of the git checkout and the current date in `build_info.json`.
+
+    Returns:
+        Path: The file written.
+
+    Raises:
+        RuntimeError: If the scraper is not in a git checkout.
+    """
+    commit = git_commit()
+    if commit is None:
+        raise RuntimeError(f"{PROJECT_DIR} is not a git checkout; cannot stamp the build")
+    content = {"commit": commit, "build_date": datetime.now().isoformat(timespec="seconds")}
+    BUILD_INFO_FILE.write_text(json.dumps(content, indent=2) + "\n", encoding="utf-8")
+    return BUILD_INFO_FILE
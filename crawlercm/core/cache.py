@@ -0,0 +1,180 @@
+"""
+In-process TTL cache for report results.
+
+Repeated requests for the same report and filters within the configured
+TTL are answered from memory instead of scraping CM again. Concurrent
+requests for the same key wait for a single fetch. The cache is disabled
+when `REPORT_CACHE_TTL_SECONDS` is 0; `REPORT_CACHE_TTLS` sets the TTL of
+single reports, e.g. a short one for prices and none for a report that
+must always be fresh:
+
+    REPORT_CACHE_TTL_SECONDS: 300
+    REPORT_CACHE_TTLS: {pending_materials: 60, pending_orders: 0}
+
+Keys are tuples starting with the report name, so every entry of a report
+can be invalidated at once (see `invalidate_reports`).
+"""
+
+import asyncio
+import time
+from typing import Any, Awaitable, Callable, Collection, Dict, Hashable, Optional, Tuple
+
+from core.config import settings
+from core.logger import logger
+from core.metrics import cache_requests
+from schemas.dataset_schemas import Dataset
+
+
+def _is_empty(value: Any) -> bool:
+    """
+    Whether a fetched value has no rows: a dataset without rows, an empty
+    list or dict, or None.
+    """
+    if value is None:
+        return True
+    if isinstance(value, Dataset):
+        return not value.rows
+    if isinstance(value, (list, dict)):
+        return len(value) == 0
+    return False
+
+
+class TTLCache:
+    """
+    Asynchronous cache whose entries expire after a fixed time to live.
+
+    Args:
+        ttl_seconds (float): Time to live of each entry. 0 disables caching.
+        report_ttls (Optional[Dict[str, float]], optional): Time to live of
+            the entries of single reports, by report name, the first item of
+            their key.
+    """
+
+    def __init__(self, ttl_seconds: float, report_ttls: Optional[Dict[str, float]] = None):
+        self.ttl_seconds = ttl_seconds
+        self.report_ttls = report_ttls or {}
+        self._entries: Dict[Hashable, Tuple[float, Any]] = {}
+        self._locks: Dict[Hashable, asyncio.Lock] = {}
+        self._lock_users: Dict[Hashable, int] = {}
+
+    @property
+    def enabled(self) -> bool:
+        return self.ttl_seconds > 0 or any(ttl > 0 for ttl in self.report_ttls.values())
+
+    def ttl_of(self, key: Hashable) -> float:
+        """
+        Time to live of the entry of a key, by the report it starts with.
+        """
+        report = key[0] if isinstance(key, tuple) and key else key
+        return self.report_ttls.get(report, self.ttl_seconds)
+
+    def get(self, key: Hashable) -> Optional[Any]:
+        """
+        Return a cached value if it has not expired.
+
+        Args:
+            key (Hashable): Cache key.
+
+        Returns:
+            Optional[Any]: The cached value, or None if absent or expired.
+        """
+        entry = self._entries.get(key)
+        if entry is None:
+            return None
+        expires_at, value = entry
+        if expires_at <= time.monotonic():
+            del self._entries[key]
+            return None
+        return value
+
+    def set(self, key: Hashable, value: Any) -> None:
+        """
+        Store a value for the configured TTL.
+
+        Expired entries are evicted first, so keys that are never requested
+        again do not hold their values forever.
+
+        Args:
+            key (Hashable): Cache key.
+            value (Any): Value to cache.
+        """
+        now = time.monotonic()
+        expired = [key for key, (expires_at, _) in self._entries.items() if expires_at <= now]
+        for expired_key in expired:
+            del self._entries[expired_key]
+        ttl = self.ttl_of(key)
+        if ttl > 0:
+            self._entries[key] = (now + ttl, value)
+
+    def invalidate(self, key: Optional[Hashable] = None) -> None:
+        """
+        Remove one entry, or every entry when no key is given.
+
+        Args:
+            key (Optional[Hashable]): Cache key to remove.
+        """
+        if key is None:
+            self._entries.clear()
+        else:
+            self._entries.pop(key, None)
+
+    def invalidate_reports(self, reports: Collection[str]) -> int:
+        """
+        Remove every entry of some reports.
+
+        Args:
+            reports (Collection[str]): Report names.
+
+        Returns:
+            int: Number of entries removed.
+        """
+        keys = [
+            key for key in self._entries if isinstance(key, tuple) and key and key[0] in reports
+        ]
+        for key in keys:
+            del self._entries[key]
+        return len(keys)
+
+    async def get_or_fetch(self, key: Hashable, fetch: Callable[[], Awaitable[Any]]) -> Any:
+        """
+        Return the cached value for a key, fetching and caching it when missing.
+
+        Empty results are not cached, so a report without rows is scraped
+        again by the next request. The lock of the key is dropped once no
+        request holds it or waits for it.
+
+        Args:
+            key (Hashable): Cache key, usually the report name and its filters.
+            fetch (Callable[[], Awaitable[Any]]): Coroutine factory producing the value.
+
+        Returns:
+            Any: The cached or freshly fetched value.
+        """
+        if self.ttl_of(key) <= 0:
+            return await fetch()
+
+        lock = self._locks.setdefault(key, asyncio.Lock())
+        self._lock_users[key] = self._lock_users.get(key, 0) + 1
+        try:
+            async with lock:
+                value = self.get(key)
+                if value is not None:
+                    logger.info(f"Cache hit for {key}")
+                    cache_requests.inc(result="hit")
+                    return value
+                cache_requests.inc(result="miss")
+                value = await fetch()
+                if not _is_empty(value):
+                    self.set(key, value)
+                return value
+        finally:
+            # Counted rather than checked with `locked()`: a waiter woken by
+            # the release does not hold the lock yet, and a new request must
+            # still queue behind it instead of fetching in parallel.
+            self._lock_users[key] -= 1
+            if not self._lock_users[key]:
+                del self._lock_users[key]
+                del self._locks[key]
+
+
+report_cache = TTLCache(settings.REPORT_CACHE_TTL_SECONDS, settings.REPORT_CACHE_TTLS)
@@ -1,4 +1,187 @@
-from pydantic_settings import BaseSettings
+"""
+Settings of the scraper.
+
+Settings are read, from the highest precedence to the lowest, from the
+environment, the `.env` file and the YAML config file named by
+`LANX_CONFIG` (or the `--config` option of the `lanx` CLI), so the same
+config file serves dev laptops and the automation server, with secrets
+and host-specific values overridden by environment variables. Keys of the
+config file are the setting names, in any case:
+
+    login_url: https://cm.lanx.local/login
+    accounts:
+      plant2: {username: integracao, password_env: CM_PLANT2_PASSWORD}
+    portal_max_connections: 4
+    portal_request_interval_seconds: 0.5
+    report_defaults:
+      pending_orders:
+        filters: {init_date: 2025-01-01}
+        format: csv
+        destinations: [postgres]
+        timeout: 600
+    schedules:
+      morning_orders: {cron: "0 7 * * mon-fri", report: pending_orders}
+    pipelines:
+      nightly:
+        max_parallel: 2
+        reports:
+          - {report: pending_orders, destinations: [postgres]}
+          - {report: pending_materials, destinations: ["s3://lanx-exports/materials"]}
+
+One config file may hold several profiles, such as the sandbox tenant and
+production, selected by `LANX_PROFILE` (or the `--profile` option). The
+settings of a profile replace the same settings at the top of the file
+whole, rather than merging with them, so a profile setting its own
+`report_defaults` or `pipelines` never inherits a production destination:
+
+    login_url: https://cm.lanx.local/login
+    pipelines:
+      nightly: {reports: [{report: pending_orders, destinations: [postgres]}]}
+    profiles:
+      homolog:
+        login_url: https://cm-homolog.lanx.local/login
+        postgres_dsn: postgresql://lanx@db-homolog.lanx.local/lanx
+        pipelines:
+          nightly: {reports: [{report: pending_orders, destinations: [tmp/nightly.csv]}]}
+
+The settings of the selected profile take precedence over the `.env` file
+and the variables without prefix, which usually hold the production
+credentials, so a profile never logs in to or writes to production by
+mistake; only `LANX_` variables still override them.
+
+Every setting can also be given by a `LANX_` variable, e.g. `LANX_S3_BUCKET`,
+which takes precedence over the variable without prefix, for containers
+sharing the environment with other programs. Settings holding lists or
+mappings take JSON, and their entries can be set one by one with `__`
+between the keys, merged with the entries of the config file:
+
+    LANX_NOTIFY_WEBHOOKS='{"slack": "https://hooks.slack.com/services/T000/B000/XXXX"}'
+    LANX_REPORT_DEFAULTS__PENDING_ORDERS__FORMAT=csv
+    LANX_ACCOUNTS__PLANT2__PASSWORD_ENV=CM_PLANT2_PASSWORD
+"""
+
+import os
+from pathlib import Path
+from typing import Any, Dict, List, Literal, Optional, Tuple, Union
+
+from pydantic import BaseModel
+from pydantic_settings import (
+    BaseSettings,
+    EnvSettingsSource,
+    InitSettingsSource,
+    PydanticBaseSettingsSource,
+)
+
+from schemas.replenish_schemas import MaterialMinimum
+
+ENV_PREFIX = "LANX_"
+CONFIG_FILE_VARIABLE = "LANX_CONFIG"
+PROFILE_VARIABLE = "LANX_PROFILE"
+
+
+class ReportDefaults(BaseModel):
+    """
+    Defaults of a report in the `lanx` CLI, overridden by its options.
+    """
+
+    account: Optional[str] = None
+    filters: Dict[str, Any] = {}
+    format: Optional[str] = None
+    output: Optional[str] = None
+    destinations: List[Union[str, Dict[str, Any]]] = []
+    timeout: Optional[float] = None
+
+
+class Schedule(BaseModel):
+    """
+    A scheduled run of `lanx serve-scheduler`: a report, with its
+    `REPORT_DEFAULTS`, or a batch run configuration (`RunConfig`).
+    """
+
+    cron: str
+    report: Optional[str] = None
+    run: Optional[Dict[str, Any]] = None
+    account: Optional[str] = None
+
+
+class ApiKey(BaseModel):
+    """
+    A key of the REST and gRPC servers, with the reports and actions it
+    grants (see `core.api_keys`).
+    """
+
+    key: Optional[str] = None
+    key_env: Optional[str] = None
+    reports: List[str] = ["*"]
+    actions: List[Literal["read", "refresh", "admin"]] = ["read"]
+
+
+def _read_mapping(path: Union[str, Path]) -> Dict[str, Any]:
+    """
+    Content of a YAML config file, by upper case setting name.
+
+    Raises:
+        RuntimeError: If PyYAML is not installed.
+        ValueError: If the file is not a mapping of settings.
+    """
+    try:
+        import yaml
+    except ImportError as e:
+        raise RuntimeError("Config files require PyYAML; install the 'config' extra") from e
+    content = yaml.safe_load(Path(path).read_text(encoding="utf-8")) or {}
+    if not isinstance(content, dict):
+        raise ValueError(f"Config file {path} must map setting names to values")
+    return {str(name).upper(): value for name, value in content.items()}
+
+
+def read_profile(path: Union[str, Path], profile: str) -> Dict[str, Any]:
+    """
+    Settings of a profile of a YAML config file, by their upper case name.
+
+    Args:
+        path (Union[str, Path]): Config file.
+        profile (str): Profile of the file.
+
+    Returns:
+        Dict[str, Any]: The settings of the profile, with its name in `PROFILE`.
+
+    Raises:
+        RuntimeError: If PyYAML is not installed.
+        ValueError: If the profile is not in the file or is not a mapping.
+    """
+    profiles = _read_mapping(path).get("PROFILES") or {}
+    if not isinstance(profiles, dict) or profile not in profiles:
+        names = ", ".join(profiles) if isinstance(profiles, dict) and profiles else "none"
+        raise ValueError(f"Unknown profile {profile} in {path}; its profiles are: {names}")
+    overrides = profiles[profile] or {}
+    if not isinstance(overrides, dict):
+        raise ValueError(f"Profile {profile} of {path} must map setting names to values")
+    values = {str(name).upper(): value for name, value in overrides.items()}
+    values["PROFILE"] = profile
+    return values
+
+
+def read_config_file(path: Union[str, Path], profile: Optional[str] = None) -> Dict[str, Any]:
+    """
+    Settings of a YAML config file, by their upper case name.
+
+    Args:
+        path (Union[str, Path]): Config file.
+        profile (Optional[str], optional): Profile of the file whose
+            settings replace those at its top. Defaults to none.
+
+    Returns:
+        Dict[str, Any]: The settings of the file, with the profile in `PROFILE`.
+
+    Raises:
+        RuntimeError: If PyYAML is not installed.
+        ValueError: If the file is not a mapping of settings, or the profile
+            is not in the file.
+    """
+    values = _read_mapping(path)
+    if profile is not None:
+        values.update(read_profile(path, profile))
+    return values
 
 
 class Settings(BaseSettings):
@@ -9,9 +192,138 @@ class Settings(BaseSettings):
     PENDING_MATERIALS_URL: str
     USERNAME: str
     PASSWORD: str
+    ACCOUNTS: Dict[str, Dict[str, str]] = {}
+    API_KEYS: Dict[str, ApiKey] = {}
+    LOG_LEVEL: str = "INFO"
+    LOG_FORMAT: str = "text"
+    PORTAL_MAX_CONNECTIONS: int = 0
+    PORTAL_REQUEST_INTERVAL_SECONDS: float = 0.0
+    PROGRESS_LOG_INTERVAL_SECONDS: float = 30.0
+    SESSION_CACHE_DIR: str = "tmp/sessions"
+    SESSION_CACHE_TTL_SECONDS: int = 0
+    REPORT_DEFAULTS: Dict[str, ReportDefaults] = {}
+    SCHEDULES: Dict[str, Schedule] = {}
+    PIPELINES: Dict[str, Dict[str, Any]] = {}
+    SCHEDULE_LOG_DIR: str = "tmp/logs/schedules"
+    SNAPSHOT_DIR: str = "tmp/snapshots"
+    JOB_DIR: str = "tmp/jobs"
+    JOB_WORKERS: int = 1
+    JOB_START_INTERVAL_SECONDS: float = 0.0
+    SUBSCRIPTION_DIR: str = "tmp/subscriptions"
+    API_EXPORT_DIR: str = "tmp/exports"
+    REPORT_CACHE_TTL_SECONDS: int = 0
+    REPORT_CACHE_TTLS: Dict[str, float] = {}
+    OTLP_ENDPOINT: Optional[str] = None
+    OTLP_HEADERS: Dict[str, str] = {}
+    OTEL_SERVICE_NAME: str = "lanx"
+    PORTAL_TIMEZONE: str = "America/Sao_Paulo"
+    MATERIAL_CODE_PREFIXES: List[str] = []
+    STRICT_PARSING: bool = False
+    POSTGRES_DSN: Optional[str] = None
+    POSTGRES_SCHEMA: str = "staging"
+    MYSQL_DSN: Optional[str] = None
+    GOOGLE_SERVICE_ACCOUNT_FILE: Optional[str] = None
+    GOOGLE_DRIVE_FOLDER_ID: Optional[str] = None
+    S3_BUCKET: Optional[str] = None
+    S3_PREFIX: str = "{report}/{fetched_at:%Y/%m/%d}/"
+    S3_ENDPOINT_URL: Optional[str] = None
+    S3_SSE: Optional[str] = None
+    S3_KMS_KEY_ID: Optional[str] = None
+    S3_ARCHIVE_SNAPSHOTS: bool = False
+    SMTP_HOST: Optional[str] = None
+    SMTP_PORT: int = 587
+    SMTP_SECURITY: str = "starttls"
+    SMTP_USERNAME: Optional[str] = None
+    SMTP_PASSWORD: Optional[str] = None
+    SMTP_SENDER: Optional[str] = None
+    POWERBI_TENANT_ID: Optional[str] = None
+    POWERBI_CLIENT_ID: Optional[str] = None
+    POWERBI_CLIENT_SECRET: Optional[str] = None
+    POWERBI_GROUP_ID: Optional[str] = None
+    POWERBI_DATASET_ID: Optional[str] = None
+    POWERBI_PUSH_URL: Optional[str] = None
+    AZURE_STORAGE_ACCOUNT_URL: Optional[str] = None
+    AZURE_STORAGE_CONTAINER: Optional[str] = None
+    AZURE_STORAGE_PREFIX: str = "{report}/{fetched_at:%Y/%m/%d}/"
+    AZURE_STORAGE_SAS_TOKEN: Optional[str] = None
+    BIGQUERY_PROJECT: Optional[str] = None
+    BIGQUERY_DATASET: Optional[str] = None
+    BIGQUERY_LOCATION: Optional[str] = None
+    INFLUXDB_URL: Optional[str] = None
+    INFLUXDB_TOKEN: Optional[str] = None
+    INFLUXDB_ORG: Optional[str] = None
+    INFLUXDB_BUCKET: Optional[str] = None
+    KAFKA_BOOTSTRAP_SERVERS: Optional[str] = None
+    KAFKA_TOPIC: str = "lanx.{report}"
+    KAFKA_PRODUCER_CONFIG: Dict[str, str] = {}
+    KAFKA_FLUSH_TIMEOUT_SECONDS: float = 30.0
+    AMQP_URL: Optional[str] = None
+    AMQP_EXCHANGE: str = "lanx.reports"
+    AMQP_ROUTING_KEY: str = "lanx.{report}"
+    MQTT_HOST: Optional[str] = None
+    MQTT_PORT: int = 1883
+    MQTT_TLS: bool = False
+    MQTT_USERNAME: Optional[str] = None
+    MQTT_PASSWORD: Optional[str] = None
+    MQTT_TOPIC: str = "lanx/{report}/{key}"
+    MQTT_QOS: int = 1
+    MQTT_RETAIN: bool = True
+    SFTP_KEY_FILE: Optional[str] = None
+    SFTP_KEY_PASSPHRASE: Optional[str] = None
+    SFTP_KNOWN_HOSTS: Optional[str] = None
+    FTP_PASSWORD: Optional[str] = None
+    UPLOAD_RETRIES: int = 3
+    UPLOAD_RETRY_DELAY_SECONDS: float = 5.0
+    WEBHOOK_URL: Optional[str] = None
+    WEBHOOK_SECRET: Optional[str] = None
+    WEBHOOK_CHUNK_SIZE: int = 0
+    NOTIFY_WEBHOOKS: Dict[str, str] = {}
+    EXPORT_PLUGINS: List[str] = []
+    CURRENCY_RATES: Dict[str, float] = {}
+    MATERIAL_MINIMUMS: List[MaterialMinimum] = []
+    PTAX_URL: str = "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata"
+    PROFILES: Dict[str, Dict[str, Any]] = {}
+    PROFILE: Optional[str] = None
 
     class Config:
         env_file = ".env"
 
+    @classmethod
+    def settings_customise_sources(
+        cls,
+        settings_cls: type[BaseSettings],
+        init_settings: PydanticBaseSettingsSource,
+        env_settings: PydanticBaseSettingsSource,
+        dotenv_settings: PydanticBaseSettingsSource,
+        file_secret_settings: PydanticBaseSettingsSource,
+    ) -> Tuple[PydanticBaseSettingsSource, ...]:
+        prefixed_env_settings = EnvSettingsSource(
+            settings_cls, env_prefix=ENV_PREFIX, env_nested_delimiter="__"
+        )
+        sources: Tuple[PydanticBaseSettingsSource, ...] = (init_settings, prefixed_env_settings)
+        config_file = os.environ.get(CONFIG_FILE_VARIABLE)
+        profile = os.environ.get(PROFILE_VARIABLE) or None
+        if profile and not config_file:
+            raise ValueError(f"Profile {profile} needs a config file; set {CONFIG_FILE_VARIABLE}")
+        if profile:
+            # Above the .env file, which holds the credentials of production.
+            sources += (InitSettingsSource(settings_cls, read_profile(config_file, profile)),)
+        sources += (env_settings, dotenv_settings)
+        if config_file:
+            sources += (InitSettingsSource(settings_cls, read_config_file(config_file)),)
+        return sources + (file_secret_settings,)
+
 
 settings = Settings()
+
+
+def reload_settings() -> None:
+    """
+    Read the settings again, e.g. once `LANX_CONFIG` names a config file or
+    `LANX_PROFILE` a profile.
+
+    Every module shares the same `settings` object, which is updated in place.
+    """
+    fresh = Settings()
+    for name in Settings.model_fields:
+        setattr(settings, name, getattr(fresh, name))
@@ -0,0 +1,47 @@
+"""
+Kinds of the errors of a report run.
+
+Failures are classified so batch summaries, the API and the exit codes of
+the `lanx` CLI tell them apart without parsing messages:
+
+- `auth`: CM rejected the login (`LoginError`, HTTP 401/403).
+- `portal`: CM could not be reached, timed out or answered with an error.
+- `parse`: a page of CM could not be parsed into report rows.
+- `snapshot`: the previous snapshot of a report could not be loaded, so
+  its delta destinations were skipped (set by `services.runner`).
+- `error`: anything else, such as invalid filters or a failed destination.
+"""
+
+import asyncio
+
+import aiohttp
+
+from core.utils.table_mapping import CellParseError, PageParseError, RowParseError
+from schemas.runner_schemas import ErrorKind
+
+
+class LoginError(IOError):
+    """
+    CM rejected a login, or the session of a login.
+    """
+
+
+def error_kind(error: BaseException) -> ErrorKind:
+    """
+    Kind of an error raised while logging in or fetching a report.
+
+    Args:
+        error (BaseException): The error.
+
+    Returns:
+        ErrorKind: `auth`, `portal`, `parse` or `error`.
+    """
+    if isinstance(error, LoginError):
+        return "auth"
+    if isinstance(error, aiohttp.ClientResponseError) and error.status in (401, 403):
+        return "auth"
+    if isinstance(error, (aiohttp.ClientError, asyncio.TimeoutError)):
+        return "portal"
+    if isinstance(error, (CellParseError, PageParseError, RowParseError)):
+        return "parse"
+    return "error"
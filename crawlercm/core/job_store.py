@@ -0,0 +1,82 @@
+"""
+Local store of the background scrape jobs.
+
+Every job of the job queue (see `services.job_queue`) is kept as a JSON
+file, `<base_dir>/<id>.json`, rewritten on every change of status, so jobs
+survive restarts of the API and can be listed with their outcome.
+"""
+
+import os
+import re
+from pathlib import Path
+from typing import List
+
+from core.config import settings
+from schemas.job_schemas import ScrapeJob
+
+_JOB_ID = re.compile(r"^[0-9a-f]{32}$")
+
+
+class JobStore:
+    """
+    Directory of job records.
+
+    Args:
+        base_dir (str | Path): Directory where the jobs are kept.
+    """
+
+    def __init__(self, base_dir: str | Path):
+        self.base_dir = Path(base_dir)
+
+    def path(self, job_id: str) -> Path:
+        """
+        File of a job.
+
+        Raises:
+            ValueError: If the job identifier is invalid.
+        """
+        if not _JOB_ID.match(job_id):
+            raise ValueError(f"Invalid job id: {job_id}")
+        return self.base_dir / f"{job_id}.json"
+
+    def save(self, job: ScrapeJob) -> None:
+        """
+        Write a job, replacing its previous record at once.
+
+        Args:
+            job (ScrapeJob): The job.
+        """
+        path = self.path(job.id)
+        self.base_dir.mkdir(parents=True, exist_ok=True)
+        # Written aside, then moved, so readers never see a partial record.
+        partial = path.with_suffix(".tmp")
+        partial.write_text(job.model_dump_json(indent=2), encoding="utf-8")
+        os.replace(partial, path)
+
+    def load(self, job_id: str) -> ScrapeJob:
+        """
+        Read a job.
+
+        Raises:
+            ValueError: If the job identifier is invalid.
+            FileNotFoundError: If the job does not exist.
+        """
+        path = self.path(job_id)
+        if not path.is_file():
+            raise FileNotFoundError(f"Job {job_id} not found")
+        return ScrapeJob.model_validate_json(path.read_text(encoding="utf-8"))
+
+    def list(self) -> List[ScrapeJob]:
+        """
+        Every job, oldest first.
+        """
+        if not self.base_dir.is_dir():
+            return []
+        jobs = [
+            ScrapeJob.model_validate_json(path.read_text(encoding="utf-8"))
+            for path in self.base_dir.glob("*.json")
+        ]
+        return sorted(jobs, key=lambda job: job.created_at)
+
+
+job_store = JobStore(settings.JOB_DIR)
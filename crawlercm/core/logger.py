@@ -1,7 +1,24 @@
+"""
+Logging of the application.
+
+Logs go to `tmp/logs/app.log` and to the console, as text or, for log
+collectors, as one JSON object per line (`LOG_FORMAT=json`):
+
+    {"time": "2025-03-01T10:00:00", "level": "INFO", "logger": "app", "message": "..."}
+
+Session data (CSRF tokens, cookies, passwords) is masked in every record
+and traceback, including the URLs and errors of aiohttp, whose query
+strings carry the CSRF token of some reports.
+"""
+
+import json
 import logging
+import re
+from datetime import datetime
 from logging.handlers import RotatingFileHandler
 import sys
 from pathlib import Path
+from typing import Union
 
 TMP_DIR = Path("tmp")
 TMP_DIR.mkdir(exist_ok=True)
@@ -11,9 +28,77 @@ LOG_DIR.mkdir(exist_ok=True)
 
 LOG_FILE = LOG_DIR / "app.log"
 
+TEXT_FORMAT = "%(asctime)s [%(levelname)s] [%(name)s]: %(message)s"
+
+SECRET_PATTERN = re.compile(
+    r"(?i)(YII_CSRF_TOKEN|csrf_token|PHPSESSID|password|cookie|authorization)"
+    r"(['\"]?\s*[:=]\s*['\"]?(?:(?:Bearer|Basic)\s+)?)([^\s'\";&,)]+)"
+)
+
+
+def mask_secrets(text: str) -> str:
+    """
+    Mask the values of session data (CSRF tokens, cookies, passwords) in a text.
+
+    Args:
+        text (str): Text, e.g. an error message holding a portal URL.
+
+    Returns:
+        str: The text with those values replaced by `***`.
+    """
+    return SECRET_PATTERN.sub(r"\1\2***", text)
+
+
+class RedactingFilter(logging.Filter):
+    """
+    Mask the values of session data in the message of a record.
+    """
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        message = record.getMessage()
+        redacted = mask_secrets(message)
+        if redacted != message:
+            record.msg, record.args = redacted, ()
+        return True
+
+
+class RedactingFormatter(logging.Formatter):
+    """
+    Text formatter masking session data in the tracebacks of records too
+    (e.g. of `logger.exception`), which `RedactingFilter` does not see.
+    """
+
+    def formatException(self, ei) -> str:
+        return mask_secrets(super().formatException(ei))
+
+    def formatStack(self, stack_info: str) -> str:
+        return mask_secrets(super().formatStack(stack_info))
+
+
+class JsonFormatter(logging.Formatter):
+    """
+    One JSON object per record, with its time, level, logger and message,
+    and the `fields` given as `extra` (e.g. the counts of progress logs).
+    """
+
+    def format(self, record: logging.LogRecord) -> str:
+        entry = {
+            "time": datetime.fromtimestamp(record.created).isoformat(timespec="seconds"),
+            "level": record.levelname,
+            "logger": record.name,
+            "message": record.getMessage(),
+        }
+        entry.update(getattr(record, "fields", {}))
+        if record.exc_info:
+            entry["exception"] = mask_secrets(self.formatException(record.exc_info))
+        if record.stack_info:
+            entry["stack"] = mask_secrets(self.formatStack(record.stack_info))
+        return json.dumps(entry, ensure_ascii=False, default=str)
+
+
 logging.basicConfig(
     level=logging.INFO,
-    format="%(asctime)s [%(levelname)s] [%(name)s]: %(message)s",
+    format=TEXT_FORMAT,
     handlers=[
         RotatingFileHandler(
             LOG_FILE, maxBytes=5_000_000, backupCount=5, encoding="utf-8"
@@ -21,8 +106,53 @@ logging.basicConfig(
         logging.StreamHandler(sys.stdout),
     ],
 )
+for _handler in logging.getLogger().handlers:
+    _handler.addFilter(RedactingFilter())
+    _handler.setFormatter(RedactingFormatter(TEXT_FORMAT))
 logger = logging.getLogger("app")
 logging.getLogger("watchfiles").setLevel(logging.WARNING)
 logging.getLogger("uvicorn").setLevel(logging.WARNING)
 logging.getLogger("uvicorn.error").setLevel(logging.WARNING)
 logging.getLogger("uvicorn.access").setLevel(logging.WARNING)
+
+
+def log_to_stderr() -> None:
+    """
+    Send the console logs to stderr, keeping stdout for command output
+    (e.g. reports written to stdout by the `lanx` CLI).
+    """
+    for handler in logging.getLogger().handlers:
+        if type(handler) is logging.StreamHandler:
+            handler.setStream(sys.stderr)
+
+
+def set_console_level(level: Union[int, str]) -> None:
+    """
+    Set the minimum level of the console logs only, so the log file keeps
+    the level of `configure_logging` (e.g. for the `--quiet` option of the
+    `lanx` CLI).
+    """
+    for handler in logging.getLogger().handlers:
+        if type(handler) is logging.StreamHandler:
+            handler.setLevel(level)
+
+
+def configure_logging(level: Union[int, str] = logging.INFO, log_format: str = "text") -> None:
+    """
+    Set the level and format of the logs.
+
+    Args:
+        level (Union[int, str], optional): Minimum level, e.g. "DEBUG" or
+            `logging.WARNING`. Defaults to INFO.
+        log_format (str, optional): "text" or "json". Defaults to "text".
+
+    Raises:
+        ValueError: If the level or the format is unknown.
+    """
+    if log_format not in ("text", "json"):
+        raise ValueError(f"Unknown log format {log_format}; use text or json")
+    root = logging.getLogger()
+    root.setLevel(level.upper() if isinstance(level, str) else level)
+    formatter = JsonFormatter() if log_format == "json" else RedactingFormatter(TEXT_FORMAT)
+    for handler in root.handlers:
+        handler.setFormatter(formatter)
@@ -0,0 +1,266 @@
+"""
+Prometheus metrics of the scrapes, served at `/metrics`.
+
+The metrics are kept in process and rendered in the Prometheus text
+format, so the API needs no client library:
+
+- `lanx_report_duration_seconds{report,status}`: time spent fetching every
+  report run, as a histogram;
+- `lanx_report_rows_total{report}` and `lanx_report_parse_errors_total{report}`:
+  rows delivered and cells CM returned that could not be parsed, a sign the
+  layout of a page changed;
+- `lanx_report_runs_total{report,status,error_kind}`: report runs by outcome;
+- `lanx_cache_requests_total{result}`: lookups of the report cache, `hit` or
+  `miss`, whose hit rate is
+
+      rate(lanx_cache_requests_total{result="hit"}[5m])
+        / rate(lanx_cache_requests_total[5m])
+
+- `lanx_job_queue_depth`: background jobs waiting for a worker;
+- `lanx_login_failures_total{error_kind}`: failed logins to CM, `auth` when
+  CM rejected the user or `portal` when it could not be reached.
+"""
+
+import threading
+from typing import Callable, Dict, Iterable, List, Optional, Sequence, Tuple
+
+LabelValues = Tuple[str, ...]
+
+# Buckets of the scrape durations, in seconds: reports take from seconds to
+# several minutes.
+DURATION_BUCKETS = (1.0, 5.0, 10.0, 30.0, 60.0, 120.0, 300.0, 600.0, 1800.0)
+
+
+def _escape(value: str) -> str:
+    return value.replace("\\", "\\\\").replace("\n", "\\n").replace('"', '\\"')
+
+
+def _labels(names: Sequence[str], values: Sequence[str], extra: str = "") -> str:
+    pairs = [f'{name}="{_escape(value)}"' for name, value in zip(names, values)]
+    if extra:
+        pairs.append(extra)
+    return "{" + ",".join(pairs) + "}" if pairs else ""
+
+
+def _number(value: float) -> str:
+    if value == float("inf"):
+        return "+Inf"
+    return str(int(value)) if float(value).is_integer() else repr(float(value))
+
+
+class Metric:
+    """
+    Base of the metrics: a name, a help text and label names.
+
+    Args:
+        name (str): Metric name.
+        help (str): Description of the metric.
+        labels (Sequence[str], optional): Label names.
+    """
+
+    kind = "untyped"
+
+    def __init__(self, name: str, help: str, labels: Sequence[str] = ()):
+        self.name = name
+        self.help = help
+        self.label_names = tuple(labels)
+        self._lock = threading.Lock()
+
+    def _key(self, labels: Dict[str, str]) -> LabelValues:
+        if set(labels) != set(self.label_names):
+            raise ValueError(f"{self.name} needs the labels {', '.join(self.label_names)}")
+        return tuple(str(labels[name]) for name in self.label_names)
+
+    def samples(self) -> Iterable[str]:
+        """
+        Lines of the samples of the metric.
+        """
+        return []
+
+    def render(self) -> str:
+        """
+        The metric in the Prometheus text format.
+        """
+        lines = [f"# HELP {self.name} {self.help}", f"# TYPE {self.name} {self.kind}"]
+        lines.extend(self.samples())
+        return "\n".join(lines) + "\n"
+
+
+class Counter(Metric):
+    """
+    A value that only increases, by labels.
+    """
+
+    kind = "counter"
+
+    def __init__(self, name: str, help: str, labels: Sequence[str] = ()):
+        super().__init__(name, help, labels)
+        self._values: Dict[LabelValues, float] = {}
+
+    def inc(self, amount: float = 1.0, **labels: str) -> None:
+        """
+        Increase the value of some labels.
+        """
+        key = self._key(labels)
+        with self._lock:
+            self._values[key] = self._values.get(key, 0.0) + amount
+
+    def samples(self) -> Iterable[str]:
+        with self._lock:
+            values = sorted(self._values.items())
+        for key, value in values:
+            yield f"{self.name}{_labels(self.label_names, key)} {_number(value)}"
+
+
+class Gauge(Metric):
+    """
+    A value read when the metrics are rendered.
+
+    Args:
+        name (str): Metric name.
+        help (str): Description of the metric.
+        function (Callable[[], float]): Returns the current value.
+    """
+
+    kind = "gauge"
+
+    def __init__(self, name: str, help: str, function: Callable[[], float]):
+        super().__init__(name, help)
+        self.function = function
+
+    def samples(self) -> Iterable[str]:
+        yield f"{self.name} {_number(self.function())}"
+
+
+class Histogram(Metric):
+    """
+    Distribution of observed values in cumulative buckets, by labels.
+
+    Args:
+        name (str): Metric name.
+        help (str): Description of the metric.
+        labels (Sequence[str], optional): Label names.
+        buckets (Sequence[float], optional): Upper bounds of the buckets.
+    """
+
+    kind = "histogram"
+
+    def __init__(
+        self,
+        name: str,
+        help: str,
+        labels: Sequence[str] = (),
+        buckets: Sequence[float] = DURATION_BUCKETS,
+    ):
+        super().__init__(name, help, labels)
+        self.buckets = tuple(sorted(buckets)) + (float("inf"),)
+        self._values: Dict[LabelValues, Tuple[List[int], float]] = {}
+
+    def observe(self, value: float, **labels: str) -> None:
+        """
+        Record a value for some labels.
+        """
+        key = self._key(labels)
+        with self._lock:
+            counts, total = self._values.get(key, ([0] * len(self.buckets), 0.0))
+            for index, bound in enumerate(self.buckets):
+                if value <= bound:
+                    counts[index] += 1
+            self._values[key] = (counts, total + value)
+
+    def samples(self) -> Iterable[str]:
+        with self._lock:
+            values = sorted(
+                (key, (list(counts), total)) for key, (counts, total) in self._values.items()
+            )
+        for key, (counts, total) in values:
+            for bound, count in zip(self.buckets, counts):
+                labels = _labels(self.label_names, key, f'le="{_number(bound)}"')
+                yield f"{self.name}_bucket{labels} {count}"
+            labels = _labels(self.label_names, key)
+            yield f"{self.name}_sum{labels} {_number(total)}"
+            yield f"{self.name}_count{labels} {counts[-1]}"
+
+
+class Registry:
+    """
+    The metrics of the process, rendered together.
+    """
+
+    def __init__(self):
+        self._metrics: Dict[str, Metric] = {}
+
+    def register(self, metric: Metric) -> Metric:
+        """
+        Add a metric, or return the metric already registered with its name.
+        """
+        return self._metrics.setdefault(metric.name, metric)
+
+    def render(self) -> str:
+        """
+        Every metric in the Prometheus text format.
+        """
+        return "".join(metric.render() for metric in self._metrics.values())
+
+
+registry = Registry()
+
+report_duration = registry.register(
+    Histogram(
+        "lanx_report_duration_seconds",
+        "Time spent fetching a report run.",
+        ("report", "status"),
+    )
+)
+report_rows = registry.register(
+    Counter("lanx_report_rows_total", "Rows delivered by the report runs.", ("report",))
+)
+report_parse_errors = registry.register(
+    Counter(
+        "lanx_report_parse_errors_total",
+        "Cells of CM that could not be parsed.",
+        ("report",),
+    )
+)
+report_runs = registry.register(
+    Counter(
+        "lanx_report_runs_total",
+        "Report runs by status and error kind.",
+        ("report", "status", "error_kind"),
+    )
+)
+cache_requests = registry.register(
+    Counter(
+        "lanx_cache_requests_total", "Lookups of the report cache, hit or miss.", ("result",)
+    )
+)
+login_failures = registry.register(
+    Counter("lanx_login_failures_total", "Failed logins to CM.", ("error_kind",))
+)
+
+
+def register_gauge(name: str, help: str, function: Callable[[], float]) -> None:
+    """
+    Register a gauge read from a function, such as the depth of a queue.
+    """
+    registry.register(Gauge(name, help, function))
+
+
+def record_report_run(
+    report: str,
+    status: str,
+    duration_seconds: float,
+    row_count: int,
+    parse_errors: int,
+    error_kind: Optional[str] = None,
+) -> None:
+    """
+    Record the outcome of a report run; skipped runs have no duration.
+    """
+    if status != "skipped":
+        report_duration.observe(duration_seconds, report=report, status=status)
+    report_runs.inc(report=report, status=status, error_kind=error_kind or "")
+    if row_count:
+        report_rows.inc(row_count, report=report)
+    if parse_errors:
+        report_parse_errors.inc(parse_errors, report=report)
@@ -0,0 +1,116 @@
+"""
+Recording of the raw responses of CM.
+
+`RecordingSession` wraps an authenticated session and saves the body of
+every portal response to a directory, as received and before any parsing,
+with a `responses.json` manifest of the requests:
+
+    tmp/raw/pending_orders/20250307-103500/
+        001-post-relatorio-pendencias-producao.html
+        responses.json
+
+so layout changes of the portal can be reported with the pages that broke
+the parser, and parser fixtures regenerated from them. Session data in the
+recorded URLs (e.g. the CSRF token of some reports) is masked as in the
+logs; the bodies are saved unchanged.
+"""
+
+import json
+import mimetypes
+import re
+from contextlib import asynccontextmanager
+from dataclasses import asdict, dataclass
+from datetime import datetime
+from pathlib import Path
+from typing import Any, AsyncIterator, List
+
+import aiohttp
+from yarl import URL
+
+from core.logger import SECRET_PATTERN
+
+MANIFEST_FILE = "responses.json"
+
+# Extensions of the content types whose guess by `mimetypes` is unhelpful.
+EXTENSIONS = {"text/html": ".html", "application/json": ".json", "text/plain": ".txt"}
+
+
+@dataclass
+class RecordedResponse:
+    """
+    A response saved by a `RecordingSession`.
+    """
+
+    file: str
+    method: str
+    url: str
+    status: int
+    content_type: str
+    size: int
+    received_at: str
+
+
+def _file_name(number: int, method: str, url: URL, content_type: str) -> str:
+    name = re.sub(r"[^\w-]+", "-", url.path.strip("/").split("/")[-1]).strip("-")
+    extension = EXTENSIONS.get(content_type) or mimetypes.guess_extension(content_type) or ".bin"
+    return f"{number:03d}-{method.lower()}-{name or 'index'}{extension}"
+
+
+class RecordingSession:
+    """
+    Session saving the body of every response to a directory.
+
+    Everything but `get`, `post` and `request` goes to the wrapped session,
+    so it can stand in for it, e.g. as the client of a `ReportContext`.
+
+    Args:
+        session (aiohttp.ClientSession): Session making the requests.
+        directory (Path): Directory receiving the responses; created if missing.
+    """
+
+    def __init__(self, session: aiohttp.ClientSession, directory: Path):
+        self._session = session
+        self.directory = directory
+        self.responses: List[RecordedResponse] = []
+
+    def __getattr__(self, name: str) -> Any:
+        return getattr(self._session, name)
+
+    def get(self, url: str, **kwargs: Any):
+        return self.request("GET", url, **kwargs)
+
+    def post(self, url: str, **kwargs: Any):
+        return self.request("POST", url, **kwargs)
+
+    @asynccontextmanager
+    async def request(
+        self, method: str, url: str, **kwargs: Any
+    ) -> AsyncIterator[aiohttp.ClientResponse]:
+        """
+        Make a request, as `aiohttp.ClientSession.request`, saving its response body.
+        """
+        async with self._session.request(method, url, **kwargs) as response:
+            # The body is kept by the response, so it can still be read by the caller.
+            body = await response.read()
+            self._save(method, response, body)
+            yield response
+
+    def _save(self, method: str, response: aiohttp.ClientResponse, body: bytes) -> None:
+        self.directory.mkdir(parents=True, exist_ok=True)
+        file = _file_name(len(self.responses) + 1, method, response.url, response.content_type)
+        (self.directory / file).write_bytes(body)
+        self.responses.append(
+            RecordedResponse(
+                file=file,
+                method=method,
+                url=SECRET_PATTERN.sub(r"\1\2***", str(response.url)),
+                status=response.status,
+                content_type=response.content_type,
+                size=len(body),
+                received_at=datetime.now().isoformat(timespec="seconds"),
+            )
+        )
+        manifest = [asdict(recorded) for recorded in self.responses]
+        (self.directory / MANIFEST_FILE).write_text(
+            json.dumps(manifest, indent=2) + "\n", encoding="utf-8"
+        )
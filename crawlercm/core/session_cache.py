@@ -0,0 +1,93 @@
+"""
+Cache of authenticated CM sessions.
+
+The cookies and CSRF token of a login are kept in `SESSION_CACHE_DIR`,
+one JSON file per CM user readable only by its owner, so consecutive
+runs of the `lanx` CLI can skip the login while the session lasts
+(`SESSION_CACHE_TTL_SECONDS`). `lanx login` warms the cache; sessions the
+portal no longer accepts are discarded and replaced by a new login.
+"""
+
+import json
+import os
+import re
+from datetime import datetime, timedelta
+from pathlib import Path
+from typing import Optional
+from urllib.parse import urlparse
+
+import aiohttp
+from yarl import URL
+
+from core.config import settings
+from core.logger import logger
+
+
+def cache_path(username: str) -> Path:
+    """
+    Cache file of the sessions of a CM user.
+    """
+    return Path(settings.SESSION_CACHE_DIR) / f"{re.sub(r'[^\w.-]', '_', username)}.json"
+
+
+def save_session(session: aiohttp.ClientSession, csrf_token: str, username: str) -> Path:
+    """
+    Store the cookies and CSRF token of an authenticated session.
+
+    Args:
+        session (aiohttp.ClientSession): Authenticated session.
+        csrf_token (str): CSRF token of the login.
+        username (str): CM user of the session.
+
+    Returns:
+        Path: The cache file.
+    """
+    path = cache_path(username)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    content = {
+        "username": username,
+        "saved_at": datetime.now().isoformat(),
+        "csrf_token": csrf_token,
+        "cookies": {cookie.key: cookie.value for cookie in session.cookie_jar},
+    }
+    fd = os.open(path, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o600)
+    with os.fdopen(fd, "w", encoding="utf-8") as file:
+        json.dump(content, file)
+    return path
+
+
+def load_session(session: aiohttp.ClientSession, username: str) -> Optional[str]:
+    """
+    Restore the cached cookies of a CM user into a session.
+
+    Args:
+        session (aiohttp.ClientSession): New session receiving the cookies.
+        username (str): CM user.
+
+    Returns:
+        Optional[str]: The CSRF token of the cached session, or None when
+        no session is cached or it is older than `SESSION_CACHE_TTL_SECONDS`.
+    """
+    path = cache_path(username)
+    if settings.SESSION_CACHE_TTL_SECONDS <= 0 or not path.is_file():
+        return None
+    try:
+        content = json.loads(path.read_text(encoding="utf-8"))
+        saved_at = datetime.fromisoformat(content["saved_at"])
+    except (ValueError, KeyError) as e:
+        logger.warning(f"Ignoring the invalid session cache {path}: {e}")
+        return None
+    if datetime.now() - saved_at > timedelta(seconds=settings.SESSION_CACHE_TTL_SECONDS):
+        return None
+    url = urlparse(settings.LOGIN_URL)
+    session.cookie_jar.update_cookies(
+        content["cookies"], response_url=URL(f"{url.scheme}://{url.netloc}/")
+    )
+    return content["csrf_token"]
+
+
+def clear_session(username: str) -> None:
+    """
+    Discard the cached session of a CM user, e.g. once the portal rejects it.
+    """
+    cache_path(username).unlink(missing_ok=True)
@@ -1,10 +1,208 @@
+import asyncio
+import logging
 import sys
+import time
 import aiohttp
 from contextlib import asynccontextmanager
+from typing import Any, AsyncIterator, Optional, Tuple
 from fastapi import FastAPI
 from bs4 import BeautifulSoup
 from core.config import settings
+from core.errors import LoginError, error_kind
 from core.logger import logger
+from core.metrics import login_failures
+from core.tracing import request_tracing, span
+from core.session_cache import clear_session, load_session, save_session
+
+
+class RequestThrottle:
+    """
+    Spacing of the requests of a session, so scraping does not overload CM.
+
+    Args:
+        interval (float): Minimum seconds between the start of two requests.
+    """
+
+    def __init__(self, interval: float):
+        self.interval = interval
+        self._next_request = 0.0
+        self._lock = asyncio.Lock()
+
+    async def wait(self, *_: Any) -> None:
+        """
+        Wait until the next request may start; an aiohttp request start hook.
+        """
+        async with self._lock:
+            delay = self._next_request - time.monotonic()
+            if delay > 0:
+                await asyncio.sleep(delay)
+            self._next_request = time.monotonic() + self.interval
+
+
+async def _log_request(
+    session: aiohttp.ClientSession,
+    context: Any,
+    params: aiohttp.TraceRequestEndParams,
+) -> None:
+    logger.debug(f"{params.method} {params.url} -> HTTP {params.response.status}")
+
+
+def new_session() -> aiohttp.ClientSession:
+    """
+    Session for CM requests, within the configured rate limits.
+
+    At most `PORTAL_MAX_CONNECTIONS` connections are open at once (the
+    aiohttp default of 100 when 0), and requests start at least
+    `PORTAL_REQUEST_INTERVAL_SECONDS` apart.
+    With DEBUG logs, every request is logged with its status, and with
+    tracing enabled it is traced as a span (see `core.tracing`).
+
+    Returns:
+        aiohttp.ClientSession: A new, unauthenticated session.
+    """
+    trace_configs = []
+    if settings.PORTAL_REQUEST_INTERVAL_SECONDS > 0:
+        trace_config = aiohttp.TraceConfig()
+        trace_config.on_request_start.append(
+            RequestThrottle(settings.PORTAL_REQUEST_INTERVAL_SECONDS).wait
+        )
+        trace_configs.append(trace_config)
+    if logger.isEnabledFor(logging.DEBUG):
+        trace_config = aiohttp.TraceConfig()
+        trace_config.on_request_end.append(_log_request)
+        trace_configs.append(trace_config)
+    tracing = request_tracing()
+    if tracing is not None:
+        trace_configs.append(tracing)
+    connector = aiohttp.TCPConnector(limit=settings.PORTAL_MAX_CONNECTIONS or 100)
+    return aiohttp.ClientSession(connector=connector, trace_configs=trace_configs)
+
+
+async def login(
+    session: aiohttp.ClientSession,
+    username: Optional[str] = None,
+    password: Optional[str] = None,
+) -> str:
+    """
+    Authenticate a session with CM.
+
+    Args:
+        session (aiohttp.ClientSession): Session receiving the login cookies.
+        username (Optional[str], optional): CM user. Defaults to `USERNAME`.
+        password (Optional[str], optional): CM password. Defaults to `PASSWORD`.
+
+    Returns:
+        str: The CSRF token of the login page, required by some reports.
+
+    Raises:
+        aiohttp.ClientError: If CM cannot be reached.
+        IOError: If the login page has no CSRF token.
+        LoginError: If CM rejects the login.
+    """
+    try:
+        with span("login", username=username or settings.USERNAME):
+            return await _login(session, username, password)
+    except (aiohttp.ClientError, IOError) as e:
+        login_failures.inc(error_kind=error_kind(e))
+        raise
+
+
+async def _login(
+    session: aiohttp.ClientSession, username: Optional[str], password: Optional[str]
+) -> str:
+    # Step 1: Get CSRF Token
+    logger.info(f"Accessing {settings.LOGIN_URL} to get CSRF token...")
+    async with session.get(settings.LOGIN_URL) as response:
+        response.raise_for_status()
+        html_content = await response.text()
+
+    soup = BeautifulSoup(html_content, "html.parser")
+    csrf_input = soup.find("input", {"name": "YII_CSRF_TOKEN"})
+
+    if not csrf_input or "value" not in csrf_input.attrs:
+        raise IOError("Could not find CSRF token input on login page.")
+
+    csrf_token = csrf_input["value"]
+    logger.info("CSRF token extracted successfully!")
+
+    # Step 2: Perform login
+    login_payload = {
+        "YII_CSRF_TOKEN": csrf_token,
+        "LoginForm[username]": username or settings.USERNAME,
+        "LoginForm[password]": password or settings.PASSWORD,
+        "LoginForm[codigoConexao]": "3.1~13,3^17,7",
+        "yt0": "Entrar",
+    }
+
+    logger.info("Sending login request...")
+    async with session.post(settings.LOGIN_URL, data=login_payload) as response:
+        response.raise_for_status()
+        if response.status != 200:
+            raise LoginError(f"Login failed: HTTP {response.status}")
+        # CM answers rejected credentials with the login form again.
+        if _is_login_page(await response.text()):
+            raise LoginError("Login failed: CM rejected the user or password")
+
+    logger.debug(f"Session cookies: {', '.join(cookie.key for cookie in session.cookie_jar)}")
+    logger.info("✅ Login successful! Scraper ready.")
+    return csrf_token
+
+
+def _is_login_page(html: str) -> bool:
+    soup = BeautifulSoup(html, "html.parser")
+    return soup.find("input", {"name": "LoginForm[password]"}) is not None
+
+
+async def session_is_valid(session: aiohttp.ClientSession) -> bool:
+    """
+    Whether CM still accepts the login of a session, e.g. of a cached session.
+
+    Raises:
+        aiohttp.ClientError: If CM cannot be reached.
+    """
+    async with session.get(settings.HOME_URL) as response:
+        response.raise_for_status()
+        return not _is_login_page(await response.text())
+
+
+@asynccontextmanager
+async def authenticated_session(
+    username: Optional[str] = None, password: Optional[str] = None
+) -> AsyncIterator[Tuple[aiohttp.ClientSession, str]]:
+    """
+    Session authenticated with CM for the duration of a block, outside the API.
+
+    With `SESSION_CACHE_TTL_SECONDS` set, a session cached by a recent
+    login of the same user is reused while CM accepts it (see
+    `core.session_cache`), and new logins are cached.
+
+    Args:
+        username (Optional[str], optional): CM user. Defaults to `USERNAME`.
+        password (Optional[str], optional): CM password. Defaults to `PASSWORD`.
+
+    Yields:
+        Tuple[aiohttp.ClientSession, str]: The session and its CSRF token.
+
+    Raises:
+        aiohttp.ClientError: If CM cannot be reached.
+        IOError: If the login page has no CSRF token.
+        LoginError: If CM rejects the login.
+    """
+    username = username or settings.USERNAME
+    async with new_session() as session:
+        csrf_token = load_session(session, username)
+        if csrf_token is not None and not await session_is_valid(session):
+            logger.info("The cached session expired; logging in again.")
+            clear_session(username)
+            session.cookie_jar.clear()
+            csrf_token = None
+        if csrf_token is None:
+            csrf_token = await login(session, username, password)
+            if settings.SESSION_CACHE_TTL_SECONDS > 0:
+                save_session(session, csrf_token, username)
+        else:
+            logger.info(f"Reusing the cached session of {username}.")
+        yield session, csrf_token
 
 
 @asynccontextmanager
@@ -19,42 +217,10 @@ async def lifespan(app: FastAPI):
       - Closes session on shutdown
     """
     logger.info("Initializing aiohttp session...")
-    session = aiohttp.ClientSession()
+    session = new_session()
 
     try:
-        # Step 1: Get CSRF Token
-        logger.info(f"Accessing {settings.LOGIN_URL} to get CSRF token...")
-        async with session.get(settings.LOGIN_URL) as response:
-            response.raise_for_status()
-            html_content = await response.text()
-
-        soup = BeautifulSoup(html_content, "html.parser")
-        csrf_input = soup.find("input", {"name": "YII_CSRF_TOKEN"})
-
-        if not csrf_input or "value" not in csrf_input.attrs:
-            raise IOError("Could not find CSRF token input on login page.")
-
-        csrf_token = csrf_input["value"]
-        logger.info("CSRF token extracted successfully!")
-
-        app.state.csrf_token = csrf_token
-
-        # Step 2: Perform login
-        login_payload = {
-            "YII_CSRF_TOKEN": csrf_token,
-            "LoginForm[username]": settings.USERNAME,
-            "LoginForm[password]": settings.PASSWORD,
-            "LoginForm[codigoConexao]": "3.1~13,3^17,7",
-            "yt0": "Entrar",
-        }
-
-        logger.info("Sending login request...")
-        async with session.post(settings.LOGIN_URL, data=login_payload) as response:
-            response.raise_for_status()
-            if response.status != 200:
-                raise IOError(f"Login failed: HTTP {response.status}")
-
-        logger.info("✅ Login successful! Scraper ready.")
+        app.state.csrf_token = await login(session)
         app.state.http_client = session
 
         # Yield control to app runtime
@@ -0,0 +1,277 @@
+"""
+Local store of report snapshots.
+
+Every report run can be persisted as a gzip-compressed JSON file under
+`<base_dir>/<report>/<timestamp>.json.gz`. The store lists the runs of a
+report and loads any of them back, which is the base for diffs and trend
+analysis between runs.
+
+Snapshots are stamped with the row schema version of their report. When a
+report model adds or renames fields, bump its version by registering a
+migration from the previous one:
+
+    @register_migration("pending_sales", from_version=1)
+    def _rename_valor(row):
+        row["valor_unitario"] = row.pop("valor", None)
+        return row
+
+Loading a snapshot applies, row by row, every migration between its
+version and the current one, so runs stored by older releases keep
+loading.
+"""
+
+import gzip
+import re
+from datetime import datetime
+from pathlib import Path
+from typing import Any, Callable, Dict, List, Optional, Sequence, Tuple
+
+from pydantic import BaseModel
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.snapshot_schemas import Snapshot, SnapshotInfo
+
+_ID_FORMAT = "%Y%m%dT%H%M%S%f"
+_SUFFIX = ".json.gz"
+_REPORT_NAME = re.compile(r"^[A-Za-z0-9_\-]+$")
+
+RowMigration = Callable[[Dict[str, Any]], Dict[str, Any]]
+
+MIGRATIONS: Dict[Tuple[str, int], RowMigration] = {}
+
+
+def register_migration(report: str, from_version: int) -> Callable[[RowMigration], RowMigration]:
+    """
+    Register a migration of snapshot rows from one schema version to the next.
+
+    Args:
+        report (str): Report name.
+        from_version (int): Version migrated from; rows end up in
+            `from_version + 1`.
+
+    Returns:
+        Callable[[RowMigration], RowMigration]: Decorator registering the function.
+    """
+
+    def decorator(migration: RowMigration) -> RowMigration:
+        MIGRATIONS[(report, from_version)] = migration
+        return migration
+
+    return decorator
+
+
+class SnapshotStore:
+    """
+    Filesystem-backed store of compressed report snapshots.
+
+    Args:
+        base_dir (str | Path): Directory where snapshots are kept.
+        migrations (Optional[Dict[Tuple[str, int], RowMigration]]): Row
+            migrations by report and source version. Defaults to the ones
+            registered with `register_migration`.
+    """
+
+    def __init__(
+        self,
+        base_dir: str | Path,
+        migrations: Optional[Dict[Tuple[str, int], RowMigration]] = None,
+    ):
+        self.base_dir = Path(base_dir)
+        self.migrations = MIGRATIONS if migrations is None else migrations
+
+    def schema_version(self, report: str) -> int:
+        """
+        Current row schema version of a report.
+
+        Args:
+            report (str): Report name.
+
+        Returns:
+            int: 1 plus the number of migrations registered for the report.
+        """
+        versions = [version for name, version in self.migrations if name == report]
+        return max(versions, default=0) + 1
+
+    def _migrate(self, snapshot: Snapshot) -> Snapshot:
+        """
+        Bring the rows of a snapshot to the current schema version.
+
+        Args:
+            snapshot (Snapshot): Snapshot as stored.
+
+        Returns:
+            Snapshot: The snapshot with migrated rows.
+
+        Raises:
+            ValueError: If a migration between the stored and the current
+                version is missing.
+        """
+        current = self.schema_version(snapshot.report)
+        if snapshot.schema_version > current:
+            logger.warning(
+                f"Snapshot {snapshot.id} of {snapshot.report} has schema version "
+                f"{snapshot.schema_version}, newer than {current}; rows are returned as stored."
+            )
+            return snapshot
+        rows = snapshot.rows
+        for version in range(snapshot.schema_version, current):
+            migration = self.migrations.get((snapshot.report, version))
+            if migration is None:
+                raise ValueError(
+                    f"No migration of {snapshot.report} rows from schema version {version}"
+                )
+            rows = [migration(dict(row)) for row in rows]
+        return snapshot.model_copy(update={"rows": rows, "schema_version": current})
+
+    def _report_dir(self, report: str) -> Path:
+        if not _REPORT_NAME.match(report):
+            raise ValueError(f"Invalid report name: {report}")
+        return self.base_dir / report
+
+    def save(
+        self,
+        report: str,
+        rows: Sequence[BaseModel | Dict[str, Any]],
+        filters: Optional[Dict[str, Any]] = None,
+        metadata: Optional[DatasetMetadata] = None,
+    ) -> SnapshotInfo:
+        """
+        Persist a report run.
+
+        Args:
+            report (str): Report name.
+            rows (Sequence[BaseModel | Dict[str, Any]]): Report rows.
+            filters (Optional[Dict[str, Any]]): Filters used in the run.
+            metadata (Optional[DatasetMetadata]): Provenance of the rows.
+
+        Returns:
+            SnapshotInfo: Identification of the stored snapshot.
+        """
+        created_at = datetime.now()
+        snapshot = Snapshot(
+            id=created_at.strftime(_ID_FORMAT),
+            report=report,
+            created_at=created_at,
+            schema_version=self.schema_version(report),
+            filters=filters or {},
+            metadata=metadata,
+            rows=[
+                row.model_dump(mode="json") if isinstance(row, BaseModel) else dict(row)
+                for row in rows
+            ],
+        )
+        report_dir = self._report_dir(report)
+        report_dir.mkdir(parents=True, exist_ok=True)
+        path = report_dir / f"{snapshot.id}{_SUFFIX}"
+        path.write_bytes(gzip.compress(snapshot.model_dump_json().encode("utf-8")))
+        logger.info(f"Snapshot {snapshot.id} of {report} stored with {len(rows)} rows.")
+        return self._info(report, path)
+
+    def save_dataset(self, dataset: Dataset) -> SnapshotInfo:
+        """
+        Persist a fetched dataset, keeping its metadata envelope.
+
+        Args:
+            dataset (Dataset): Report rows and metadata.
+
+        Returns:
+            SnapshotInfo: Identification of the stored snapshot.
+        """
+        metadata = dataset.metadata
+        return self.save(metadata.report, dataset.rows, metadata.filters, metadata)
+
+    def list(self, report: str) -> List[SnapshotInfo]:
+        """
+        List the stored snapshots of a report, oldest first.
+
+        Args:
+            report (str): Report name.
+
+        Returns:
+            List[SnapshotInfo]: Stored snapshots.
+        """
+        report_dir = self._report_dir(report)
+        if not report_dir.is_dir():
+            return []
+        return [self._info(report, path) for path in sorted(report_dir.glob(f"*{_SUFFIX}"))]
+
+    def reports(self) -> List[str]:
+        """
+        List the reports that have at least one stored snapshot.
+
+        Returns:
+            List[str]: Report names.
+        """
+        if not self.base_dir.is_dir():
+            return []
+        return sorted(
+            path.name for path in self.base_dir.iterdir() if path.is_dir() and any(path.glob(f"*{_SUFFIX}"))
+        )
+
+    def path(self, report: str, snapshot_id: str) -> Path:
+        """
+        File where a stored snapshot is kept (gzip-compressed JSON).
+
+        Args:
+            report (str): Report name.
+            snapshot_id (str): Snapshot identifier.
+
+        Returns:
+            Path: The snapshot file.
+
+        Raises:
+            FileNotFoundError: If the snapshot does not exist.
+        """
+        path = self._report_dir(report) / f"{snapshot_id}{_SUFFIX}"
+        if not re.match(r"^\d+T\d+$", snapshot_id) or not path.is_file():
+            raise FileNotFoundError(f"Snapshot {snapshot_id} of {report} not found.")
+        return path
+
+    def load(self, report: str, snapshot_id: str) -> Snapshot:
+        """
+        Load a stored snapshot, migrating its rows to the current schema version.
+
+        Args:
+            report (str): Report name.
+            snapshot_id (str): Snapshot identifier.
+
+        Returns:
+            Snapshot: The stored report run.
+
+        Raises:
+            FileNotFoundError: If the snapshot does not exist.
+            ValueError: If the rows cannot be migrated to the current schema version.
+        """
+        path = self.path(report, snapshot_id)
+        snapshot = Snapshot.model_validate_json(gzip.decompress(path.read_bytes()))
+        return self._migrate(snapshot)
+
+    def latest(self, report: str, offset: int = 0) -> Optional[Snapshot]:
+        """
+        Load one of the most recent snapshots of a report.
+
+        Args:
+            report (str): Report name.
+            offset (int, optional): 0 for the latest snapshot, 1 for the one before it, and so on.
+
+        Returns:
+            Optional[Snapshot]: The snapshot, or None if there are not enough snapshots.
+        """
+        snapshots = self.list(report)
+        if offset >= len(snapshots):
+            return None
+        return self.load(report, snapshots[-1 - offset].id)
+
+    def _info(self, report: str, path: Path) -> SnapshotInfo:
+        snapshot_id = path.name[: -len(_SUFFIX)]
+        return SnapshotInfo(
+            id=snapshot_id,
+            report=report,
+            created_at=datetime.strptime(snapshot_id, _ID_FORMAT),
+            size_bytes=path.stat().st_size,
+        )
+
+
+snapshot_store = SnapshotStore(settings.SNAPSHOT_DIR)
@@ -0,0 +1,108 @@
+"""
+Local store of the webhook subscriptions to report changes.
+
+Every subscription (see `schemas.subscription_schemas`) is kept as a JSON
+file, `<base_dir>/<id>.json`, so subscriptions made through the API are
+seen by the scheduler, which runs in its own process and notifies them
+(see `services.subscriptions`).
+"""
+
+import os
+import re
+import uuid
+from datetime import datetime
+from pathlib import Path
+from typing import List, Optional
+
+from core.config import settings
+from schemas.subscription_schemas import Subscription, SubscriptionRequest
+
+_SUBSCRIPTION_ID = re.compile(r"^[0-9a-f]{32}$")
+
+
+class SubscriptionStore:
+    """
+    Directory of subscriptions.
+
+    Args:
+        base_dir (str | Path): Directory where the subscriptions are kept.
+    """
+
+    def __init__(self, base_dir: str | Path):
+        self.base_dir = Path(base_dir)
+
+    def path(self, subscription_id: str) -> Path:
+        """
+        File of a subscription.
+
+        Raises:
+            ValueError: If the subscription identifier is invalid.
+        """
+        if not _SUBSCRIPTION_ID.match(subscription_id):
+            raise ValueError(f"Invalid subscription id: {subscription_id}")
+        return self.base_dir / f"{subscription_id}.json"
+
+    def add(self, request: SubscriptionRequest) -> Subscription:
+        """
+        Store a new subscription.
+
+        Args:
+            request (SubscriptionRequest): Endpoint, report, filter and events.
+
+        Returns:
+            Subscription: The stored subscription, with its identifier.
+        """
+        subscription = Subscription(
+            **request.model_dump(), id=uuid.uuid4().hex, created_at=datetime.now()
+        )
+        path = self.path(subscription.id)
+        self.base_dir.mkdir(parents=True, exist_ok=True)
+        # Written aside, then moved, so readers never see a partial record.
+        partial = path.with_suffix(".tmp")
+        partial.write_text(subscription.model_dump_json(indent=2), encoding="utf-8")
+        os.replace(partial, path)
+        return subscription
+
+    def load(self, subscription_id: str) -> Subscription:
+        """
+        Read a subscription.
+
+        Raises:
+            ValueError: If the subscription identifier is invalid.
+            FileNotFoundError: If the subscription does not exist.
+        """
+        path = self.path(subscription_id)
+        if not path.is_file():
+            raise FileNotFoundError(f"Subscription {subscription_id} not found")
+        return Subscription.model_validate_json(path.read_text(encoding="utf-8"))
+
+    def delete(self, subscription_id: str) -> None:
+        """
+        Remove a subscription.
+
+        Raises:
+            ValueError: If the subscription identifier is invalid.
+            FileNotFoundError: If the subscription does not exist.
+        """
+        path = self.path(subscription_id)
+        if not path.is_file():
+            raise FileNotFoundError(f"Subscription {subscription_id} not found")
+        path.unlink()
+
+    def list(self, report: Optional[str] = None) -> List[Subscription]:
+        """
+        Every subscription, oldest first, or those of a report.
+        """
+        if not self.base_dir.is_dir():
+            return []
+        subscriptions = [
+            Subscription.model_validate_json(path.read_text(encoding="utf-8"))
+            for path in self.base_dir.glob("*.json")
+        ]
+        return sorted(
+            (item for item in subscriptions if report in (None, item.report)),
+            key=lambda item: item.created_at,
+        )
+
+
+subscription_store = SubscriptionStore(settings.SUBSCRIPTION_DIR)
@@ -0,0 +1,159 @@
+"""
+OpenTelemetry traces of the scrapes, exported over OTLP.
+
+With `OTLP_ENDPOINT` set, e.g. `http://otel-collector:4318`, every report
+run is traced from the login to its last destination:
+
+    report pending_orders
+    ├── login
+    │   ├── GET /login
+    │   └── POST /login
+    ├── GET /relatorio/pedidosPendentes     (one span per request to CM)
+    ├── parse
+    └── write postgres                       (one span per destination)
+
+Spans are sent in batches over OTLP/HTTP, with the `OTLP_HEADERS` (e.g.
+the API key of a hosted collector), as the `OTEL_SERVICE_NAME` service.
+Without `OTLP_ENDPOINT`, `span` does nothing. Request URLs and errors are
+masked as the logs, so CSRF tokens of the query never reach the collector.
+Requires the OpenTelemetry SDK, from the `otel` extra.
+"""
+
+import traceback
+from contextlib import contextmanager
+from typing import Any, Iterator, Optional
+from urllib.parse import urlparse
+
+import aiohttp
+
+from core.config import settings
+from core.logger import SECRET_PATTERN, logger
+
+_tracer: Optional[Any] = None
+
+
+class _NoSpan:
+    """
+    Span of the traces when tracing is disabled.
+    """
+
+    def set_attribute(self, key: str, value: Any) -> None:
+        pass
+
+
+def configure_tracing() -> None:
+    """
+    Start exporting the spans to `OTLP_ENDPOINT`; nothing when it is unset.
+
+    Raises:
+        RuntimeError: If the OpenTelemetry SDK is not installed.
+    """
+    global _tracer
+    if not settings.OTLP_ENDPOINT or _tracer is not None:
+        return
+    try:
+        from opentelemetry import trace
+        from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+        from opentelemetry.sdk.resources import Resource
+        from opentelemetry.sdk.trace import TracerProvider
+        from opentelemetry.sdk.trace.export import BatchSpanProcessor
+    except ImportError as e:
+        raise RuntimeError(
+            "Tracing needs the OpenTelemetry SDK; install the 'otel' extra"
+        ) from e
+    endpoint = settings.OTLP_ENDPOINT.rstrip("/")
+    if not endpoint.endswith("/v1/traces"):
+        endpoint += "/v1/traces"
+    resource = Resource.create({"service.name": settings.OTEL_SERVICE_NAME})
+    provider = TracerProvider(resource=resource)
+    provider.add_span_processor(
+        BatchSpanProcessor(OTLPSpanExporter(endpoint=endpoint, headers=settings.OTLP_HEADERS))
+    )
+    trace.set_tracer_provider(provider)
+    _tracer = trace.get_tracer("lanx")
+    logger.info(f"Exporting traces to {endpoint}.")
+
+
+@contextmanager
+def span(name: str, **attributes: Any) -> Iterator[Any]:
+    """
+    Trace a block as a span, child of the current span.
+
+    Errors raised by the block are recorded on the span and raised again.
+
+    Args:
+        name (str): Span name.
+        **attributes (Any): Attributes of the span, e.g. `report`.
+
+    Yields:
+        Any: The span, to set further attributes on.
+    """
+    if _tracer is None:
+        yield _NoSpan()
+        return
+    with _tracer.start_as_current_span(
+        name, attributes={f"lanx.{key}": value for key, value in attributes.items()}
+    ) as current:
+        yield current
+
+
+def _masked(text: str) -> str:
+    return SECRET_PATTERN.sub(r"\1\2***", text)
+
+
+async def _request_start(
+    session: aiohttp.ClientSession,
+    context: Any,
+    params: aiohttp.TraceRequestStartParams,
+) -> None:
+    context.span = _tracer.start_span(
+        f"{params.method} {urlparse(str(params.url)).path or '/'}",
+        attributes={"http.request.method": params.method, "url.full": _masked(str(params.url))},
+    )
+
+
+async def _request_end(
+    session: aiohttp.ClientSession,
+    context: Any,
+    params: aiohttp.TraceRequestEndParams,
+) -> None:
+    context.span.set_attribute("http.response.status_code", params.response.status)
+    context.span.end()
+
+
+async def _request_exception(
+    session: aiohttp.ClientSession,
+    context: Any,
+    params: aiohttp.TraceRequestExceptionParams,
+) -> None:
+    from opentelemetry.trace import Status, StatusCode
+
+    error = params.exception
+    message = _masked(str(error))
+    context.span.record_exception(
+        error,
+        attributes={
+            "exception.message": message,
+            "exception.stacktrace": _masked(
+                "".join(traceback.format_exception(type(error), error, error.__traceback__))
+            ),
+        },
+    )
+    context.span.set_status(Status(StatusCode.ERROR, message))
+    context.span.end()
+
+
+def request_tracing() -> Optional[aiohttp.TraceConfig]:
+    """
+    Hooks tracing every request of a session as a span, when tracing is enabled.
+
+    Returns:
+        Optional[aiohttp.TraceConfig]: The hooks, or None when tracing is disabled.
+    """
+    if _tracer is None:
+        return None
+    trace_config = aiohttp.TraceConfig()
+    trace_config.on_request_start.append(_request_start)
+    trace_config.on_request_end.append(_request_end)
+    trace_config.on_request_exception.append(_request_exception)
+    return trace_config
@@ -0,0 +1,95 @@
+"""
+Canonical forms of supplier and material identifiers.
+
+The same material or supplier is often written differently across CM
+reports (extra spaces, lowercase, internal prefixes, "LTDA" vs "Ltda.").
+These helpers produce one canonical form so joins between datasets match.
+Internal prefixes stripped from material codes are configured in
+`MATERIAL_CODE_PREFIXES`.
+"""
+
+import re
+import unicodedata
+from typing import Iterable, Optional
+
+from core.config import settings
+
+_COMPANY_SUFFIXES = {"LTDA", "ME", "EPP", "EIRELI", "SA", "S/A", "S.A", "CIA"}
+
+
+def normalize_key(value: Optional[str]) -> str:
+    """
+    Trim, uppercase and collapse inner whitespace.
+
+    Args:
+        value (Optional[str]): Identifier.
+
+    Returns:
+        str: Normalized identifier, empty for None.
+    """
+    if value is None:
+        return ""
+    return " ".join(str(value).split()).upper()
+
+
+def _strip_accents(value: str) -> str:
+    decomposed = unicodedata.normalize("NFKD", value)
+    return "".join(char for char in decomposed if not unicodedata.combining(char))
+
+
+def canonical_material_code(value: Optional[str], prefixes: Optional[Iterable[str]] = None) -> str:
+    """
+    Canonical form of a material or product code.
+
+    Args:
+        value (Optional[str]): Code as rendered by the report.
+        prefixes (Optional[Iterable[str]]): Internal prefixes to strip.
+            Defaults to `MATERIAL_CODE_PREFIXES`.
+
+    Returns:
+        str: Trimmed, uppercased code without internal prefixes.
+    """
+    code = normalize_key(value)
+    for prefix in prefixes if prefixes is not None else settings.MATERIAL_CODE_PREFIXES:
+        prefix = normalize_key(prefix)
+        if prefix and code.startswith(prefix) and len(code) > len(prefix):
+            code = code[len(prefix) :].lstrip(" -_.")
+            break
+    return code
+
+
+def canonical_supplier_name(value: Optional[str]) -> str:
+    """
+    Canonical form of a supplier or customer name.
+
+    Accents, punctuation and company-type suffixes (LTDA, S/A, ME, EPP...)
+    are removed, so "Cabos Brasil Ltda." and "CABOS BRASIL" match.
+
+    Args:
+        value (Optional[str]): Name as rendered by the report.
+
+    Returns:
+        str: Canonical name.
+    """
+    name = _strip_accents(normalize_key(value))
+    tokens = [token for token in re.split(r"[\s,\-]+", name) if token]
+    while tokens and (tokens[-1].rstrip(".") in _COMPANY_SUFFIXES or tokens[-1] == "&"):
+        tokens.pop()
+    return " ".join(re.sub(r"[^\w&/]", "", token) for token in tokens).strip()
+
+
+def canonical_supplier_code(value: Optional[str]) -> str:
+    """
+    Canonical form of a supplier code. CNPJs are reduced to their digits.
+
+    Args:
+        value (Optional[str]): Supplier code or CNPJ.
+
+    Returns:
+        str: Canonical code.
+    """
+    code = normalize_key(value)
+    digits = re.sub(r"\D", "", code)
+    if len(digits) == 14 and re.fullmatch(r"[\d./\-\s]+", code):
+        return digits
+    return code
@@ -0,0 +1,161 @@
+"""
+Cron expressions of scheduled runs.
+
+Expressions have the five fields of crontab (minute, hour, day of month,
+month, day of week), each a `*`, a value, a range (`1-5`), a step (`*/15`,
+`8-18/2`) or a list of those (`0,30`). Months and weekdays also take
+their English names (`jan`, `mon-fri`), Sunday is 0 or 7, and the usual
+shortcuts are accepted:
+
+    0 7 * * mon-fri     07:00 on weekdays
+    */30 8-18 * * *     every half hour during business hours
+    @daily              00:00 every day
+
+As in crontab, when both the day of month and the day of week are
+restricted, a day matching either runs.
+"""
+
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from typing import Dict, FrozenSet, List, Tuple
+
+SHORTCUTS: Dict[str, str] = {
+    "@yearly": "0 0 1 1 *",
+    "@annually": "0 0 1 1 *",
+    "@monthly": "0 0 1 * *",
+    "@weekly": "0 0 * * 0",
+    "@daily": "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@hourly": "0 * * * *",
+}
+
+_MONTHS = ["jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"]
+_WEEKDAYS = ["sun", "mon", "tue", "wed", "thu", "fri", "sat"]
+
+# Name, lowest and highest value and value names of every field.
+_FIELDS: List[Tuple[str, int, int, Dict[str, int]]] = [
+    ("minute", 0, 59, {}),
+    ("hour", 0, 23, {}),
+    ("day of month", 1, 31, {}),
+    ("month", 1, 12, {name: i for i, name in enumerate(_MONTHS, start=1)}),
+    ("day of week", 0, 7, {name: i for i, name in enumerate(_WEEKDAYS)}),
+]
+
+# Runs are searched up to this far ahead (e.g. "0 0 30 2 *" never runs).
+_SEARCH_LIMIT = timedelta(days=5 * 366)
+
+
+def _value(text: str, field: str, low: int, high: int, names: Dict[str, int]) -> int:
+    value = names.get(text.lower()) if not text.isdigit() else int(text)
+    if value is None or not low <= value <= high:
+        raise ValueError(f"Invalid {field} {text!r}; use {low}-{high}")
+    return value
+
+
+def _parse_field(
+    text: str, field: str, low: int, high: int, names: Dict[str, int]
+) -> FrozenSet[int]:
+    values = set()
+    for part in text.split(","):
+        expression, _, step_text = part.partition("/")
+        step = int(step_text) if step_text.isdigit() else 0
+        if step_text and step < 1:
+            raise ValueError(f"Invalid step {step_text!r} in {field} {text!r}")
+        if expression == "*":
+            start, end = low, high
+        elif "-" in expression:
+            first, _, last = expression.partition("-")
+            start = _value(first, field, low, high, names)
+            end = _value(last, field, low, high, names)
+            if start > end:
+                raise ValueError(f"Invalid range {expression!r} in {field} {text!r}")
+        else:
+            start = end = _value(expression, field, low, high, names)
+            if step:
+                end = high
+        values.update(range(start, end + 1, step or 1))
+    return frozenset(values)
+
+
+@dataclass(frozen=True)
+class CronExpression:
+    """
+    A parsed cron expression. Build it with `CronExpression.parse`.
+    """
+
+    expression: str
+    minutes: FrozenSet[int]
+    hours: FrozenSet[int]
+    days: FrozenSet[int]
+    months: FrozenSet[int]
+    weekdays: FrozenSet[int]
+    any_day: bool
+    any_weekday: bool
+
+    @classmethod
+    def parse(cls, expression: str) -> "CronExpression":
+        """
+        Parse a cron expression.
+
+        Raises:
+            ValueError: If the expression is invalid.
+        """
+        fields = SHORTCUTS.get(expression.strip().lower(), expression).split()
+        if len(fields) != 5:
+            raise ValueError(
+                f"Invalid cron expression {expression!r}; use minute hour day month weekday"
+            )
+        minutes, hours, days, months, weekdays = (
+            _parse_field(text, *field) for text, field in zip(fields, _FIELDS)
+        )
+        return cls(
+            expression=expression,
+            minutes=minutes,
+            hours=hours,
+            days=days,
+            months=months,
+            weekdays=frozenset(day % 7 for day in weekdays),
+            any_day=fields[2] == "*",
+            any_weekday=fields[4] == "*",
+        )
+
+    def matches_day(self, moment: datetime) -> bool:
+        """
+        Whether the expression runs on the day of a moment.
+        """
+        day = moment.day in self.days
+        weekday = (moment.weekday() + 1) % 7 in self.weekdays
+        if self.any_day or self.any_weekday:
+            return day and weekday
+        return day or weekday
+
+    def next_after(self, moment: datetime) -> datetime:
+        """
+        First run after a moment, in the time zone of the moment.
+
+        Args:
+            moment (datetime): Start of the search; a run at exactly this
+                moment is not returned.
+
+        Returns:
+            datetime: The next run, at second 0.
+
+        Raises:
+            ValueError: If the expression never runs (e.g. on February 30).
+        """
+        candidate = moment.replace(second=0, microsecond=0) + timedelta(minutes=1)
+        limit = moment + _SEARCH_LIMIT
+        while candidate <= limit:
+            if candidate.month not in self.months:
+                month = candidate.month % 12 + 1
+                year = candidate.year + (candidate.month == 12)
+                candidate = candidate.replace(year=year, month=month, day=1, hour=0, minute=0)
+            elif not self.matches_day(candidate):
+                candidate = (candidate + timedelta(days=1)).replace(hour=0, minute=0)
+            elif candidate.hour not in self.hours:
+                candidate = (candidate + timedelta(hours=1)).replace(minute=0)
+            elif candidate.minute not in self.minutes:
+                candidate += timedelta(minutes=1)
+            else:
+                return candidate
+        raise ValueError(f"Cron expression {self.expression!r} never runs")
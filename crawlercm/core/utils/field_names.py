@@ -0,0 +1,158 @@
+"""
+Stable external names of report fields.
+
+Report models keep the Portuguese field names used across the services,
+but everything serialized to the outside (API responses, JSON and CSV
+files) uses the English snake_case names declared here, so the output
+does not change when the portal renames a column header. Models opt in
+with `STABLE_NAMES`:
+
+    class SalesReportItem(ReportRow):
+        model_config = STABLE_NAMES
+
+Serialize with `model_dump(by_alias=True)` (FastAPI does it by default) to
+get the English names. Validation accepts both the English and the
+Portuguese names, so previously stored rows keep loading. `PT_BR_LABELS`
+maps the English names to the pt-BR headers shown in spreadsheets.
+"""
+
+from typing import Dict, Type
+
+from pydantic import AliasChoices, AliasGenerator, BaseModel, ConfigDict
+
+FIELD_NAMES: Dict[str, str] = {
+    "cliente": "customer",
+    "negociacao": "deal",
+    "tipo_servico": "service_type",
+    "emissao_pv": "sales_order_issued_on",
+    "pedido_cliente": "customer_order",
+    "op": "production_order",
+    "numero_projeto": "project_number",
+    "codigo": "code",
+    "produto": "product",
+    "previsao": "expected_on",
+    "qtde_pendente": "pending_quantity",
+    "estoque": "stock",
+    "valor_unitario": "unit_price",
+    "ipi": "ipi_pct",
+    "valor_total": "total_price",
+    "custo_estrutura": "structure_cost",
+    "lucratividade_rs": "profit",
+    "lucratividade_percentual": "profit_pct",
+    "condicao_pagamento": "payment_terms",
+    "criacao": "created_on",
+    "prazo": "due_on",
+    "quantidade": "quantity",
+    "peso": "weight",
+    "etapa": "stage",
+    "servico": "service",
+    "material": "material",
+    "sub_produto": "sub_product",
+    "previsao_op": "production_order_expected_on",
+    "pendente": "pending",
+    "unidade": "unit",
+    "situacao": "status",
+    "previsao_mp": "material_expected_on",
+    "materiais_pendentes": "pending_materials",
+    "minimo": "minimum",
+    "lote_minimo": "minimum_order_quantity",
+    "lote_multiplo": "order_multiple",
+    "fornecedor_preferido": "preferred_supplier",
+    "deficit": "deficit",
+    "qtde_sugerida": "suggested_quantity",
+    "cadastro": "master_data",
+    "precos": "prices",
+    "ausente_em": "missing_from",
+    "tipo_alteracao": "change_type",
+}
+
+PT_BR_LABELS: Dict[str, str] = {
+    "customer": "Cliente",
+    "deal": "Negociação",
+    "service_type": "Tipo de Serviço",
+    "sales_order_issued_on": "Emissão do PV",
+    "customer_order": "Pedido do Cliente",
+    "production_order": "Ordem de Produção (OP)",
+    "project_number": "Nº do Projeto",
+    "code": "Código",
+    "product": "Descrição do Produto",
+    "expected_on": "Previsão de Entrega",
+    "pending_quantity": "Qtde. Pendente",
+    "stock": "Estoque",
+    "unit_price": "Valor Unitário (R$)",
+    "ipi_pct": "IPI (%)",
+    "total_price": "Valor Total (R$)",
+    "structure_cost": "Custo Estrutura (R$)",
+    "profit": "Lucratividade (R$)",
+    "profit_pct": "Lucratividade (%)",
+    "payment_terms": "Condição de Pagamento",
+    "created_on": "Criação",
+    "due_on": "Prazo",
+    "quantity": "Quantidade",
+    "weight": "Peso",
+    "stage": "Etapa Atual",
+    "service": "Serviço",
+    "material": "Material",
+    "sub_product": "Subproduto",
+    "production_order_expected_on": "Previsão da OP",
+    "pending": "Pendente",
+    "unit": "Unidade",
+    "status": "Situação",
+    "material_expected_on": "Previsão do Material",
+    "pending_materials": "Materiais Pendentes",
+    "minimum": "Estoque Mínimo",
+    "minimum_order_quantity": "Lote Mínimo",
+    "order_multiple": "Lote Múltiplo",
+    "preferred_supplier": "Fornecedor Preferido",
+    "deficit": "Déficit",
+    "suggested_quantity": "Qtde. Sugerida",
+    "master_data": "Cadastro",
+    "prices": "Preços",
+    "missing_from": "Ausente Em",
+    "change_type": "Tipo de Alteração",
+}
+
+
+def english_name(field_name: str) -> str:
+    """
+    Stable English name of a field.
+
+    Args:
+        field_name (str): Model field name.
+
+    Returns:
+        str: The English snake_case name, or the field name itself when it
+        has no entry in `FIELD_NAMES`.
+    """
+    return FIELD_NAMES.get(field_name, field_name)
+
+
+def _validation_alias(field_name: str) -> AliasChoices:
+    return AliasChoices(english_name(field_name), field_name)
+
+
+STABLE_NAMES = ConfigDict(
+    alias_generator=AliasGenerator(
+        serialization_alias=english_name, validation_alias=_validation_alias
+    ),
+    populate_by_name=True,
+)
+
+
+def field_labels(model: Type[BaseModel], pt_br: bool = False) -> Dict[str, str]:
+    """
+    External names of the fields of a model, used as CSV/spreadsheet headers.
+
+    Args:
+        model (Type[BaseModel]): Model class.
+        pt_br (bool, optional): Return the pt-BR labels instead of the
+            English names. Defaults to False.
+
+    Returns:
+        Dict[str, str]: External name indexed by field name, in field order.
+    """
+    labels = {}
+    for name, info in model.model_fields.items():
+        external = info.serialization_alias or name
+        labels[name] = PT_BR_LABELS.get(external, external) if pt_br else external
+    return labels
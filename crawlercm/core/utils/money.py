@@ -0,0 +1,74 @@
+"""
+Decimal-safe money type for prices and costs.
+
+`Money` is a `Decimal` annotated for Pydantic: values are validated from
+numbers or pt-BR strings ("R$ 1.234,56") without going through float, and
+are serialized as JSON numbers so API consumers keep receiving numeric
+prices. Arithmetic between `Money` values is exact.
+"""
+
+from decimal import ROUND_HALF_UP, Decimal
+from typing import Annotated, Any, Iterable
+
+from pydantic import BeforeValidator, PlainSerializer
+
+from core.utils.parsers import parse_money
+
+CENTS = Decimal("0.01")
+
+
+def _to_decimal(value: Any) -> Any:
+    """
+    Convert raw values into Decimal before Pydantic validation.
+    """
+    if isinstance(value, str):
+        return parse_money(value)
+    if isinstance(value, float):
+        return Decimal(repr(value))
+    return value
+
+
+Money = Annotated[
+    Decimal,
+    BeforeValidator(_to_decimal),
+    PlainSerializer(float, return_type=float, when_used="json"),
+]
+
+
+def round_money(value: Decimal) -> Decimal:
+    """
+    Round an amount to cents, half up, as done in invoices.
+
+    Args:
+        value (Decimal): Amount to round.
+
+    Returns:
+        Decimal: The amount rounded to two decimal places.
+    """
+    return value.quantize(CENTS, rounding=ROUND_HALF_UP)
+
+
+def to_cents(value: Decimal) -> int:
+    """
+    Convert an amount into integer cents.
+
+    Args:
+        value (Decimal): Amount in BRL.
+
+    Returns:
+        int: The amount in cents, rounded half up.
+    """
+    return int(round_money(value) * 100)
+
+
+def sum_money(values: Iterable[Decimal]) -> Decimal:
+    """
+    Sum amounts exactly.
+
+    Args:
+        values (Iterable[Decimal]): Amounts to sum.
+
+    Returns:
+        Decimal: The total.
+    """
+    return sum(values, Decimal("0"))
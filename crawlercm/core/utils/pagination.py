@@ -0,0 +1,125 @@
+"""
+Paged access over fetched datasets.
+
+`PagedDataset` exposes rows page by page through `page(n, size)`. Rows can
+come from an in-memory sequence or from a loader that materializes only the
+requested slice, so consumers such as dashboards do not need to hold a full
+report in memory. `iter_pages(size)` reads the whole dataset page by page,
+e.g. to export it with the page writers of `services.export.exporter`.
+"""
+
+from collections import OrderedDict
+from typing import Callable, Generic, Iterator, Optional, Sequence, TypeVar
+
+from schemas.page_schemas import Page
+
+T = TypeVar("T")
+
+Loader = Callable[[int, int], Sequence[T]]
+
+
+class PagedDataset(Generic[T]):
+    """
+    Dataset whose rows are materialized lazily, one page at a time.
+
+    Args:
+        loader (Loader): Function receiving an offset and a limit and
+            returning the rows of that slice.
+        total_items (Optional[int]): Total number of rows, when known.
+        max_cached_pages (int): Number of materialized pages kept in memory.
+    """
+
+    def __init__(
+        self,
+        loader: Loader,
+        total_items: Optional[int] = None,
+        max_cached_pages: int = 8,
+    ):
+        self._loader = loader
+        self.total_items = total_items
+        self._max_cached_pages = max_cached_pages
+        self._pages: "OrderedDict[tuple[int, int], Sequence[T]]" = OrderedDict()
+
+    @classmethod
+    def from_rows(cls, rows: Sequence[T]) -> "PagedDataset[T]":
+        """
+        Build a paged view over rows already in memory.
+
+        Args:
+            rows (Sequence[T]): Dataset rows.
+
+        Returns:
+            PagedDataset[T]: Paged view over the rows.
+        """
+        return cls(lambda offset, limit: rows[offset : offset + limit], total_items=len(rows))
+
+    def _load(self, offset: int, limit: int) -> Sequence[T]:
+        key = (offset, limit)
+        if key in self._pages:
+            self._pages.move_to_end(key)
+            return self._pages[key]
+        rows = self._loader(offset, limit)
+        self._pages[key] = rows
+        if len(self._pages) > self._max_cached_pages:
+            self._pages.popitem(last=False)
+        return rows
+
+    def page(self, n: int, size: int) -> Page[T]:
+        """
+        Return one page of the dataset.
+
+        When the total number of rows is unknown, one extra row is requested
+        from the loader to find out whether a next page exists.
+
+        Args:
+            n (int): Page number, starting at 1.
+            size (int): Maximum number of rows per page.
+
+        Returns:
+            Page[T]: The requested page, empty if past the end of the dataset.
+
+        Raises:
+            ValueError: If the page number or size are not positive.
+        """
+        if n < 1 or size < 1:
+            raise ValueError("Page number and size must be positive.")
+
+        offset = (n - 1) * size
+        if self.total_items is not None:
+            items = list(self._load(offset, size)) if offset < self.total_items else []
+            has_next = offset + size < self.total_items
+        else:
+            rows = list(self._load(offset, size + 1))
+            items, has_next = rows[:size], len(rows) > size
+
+        return Page[T](
+            page=n, size=size, items=items, total_items=self.total_items, has_next=has_next
+        )
+
+    def iter_pages(self, size: int) -> Iterator[Sequence[T]]:
+        """
+        Iterate over the rows of the dataset, one page at a time.
+
+        Pages are read straight from the loader, bypassing the page cache,
+        so only the current page is held in memory.
+
+        Args:
+            size (int): Maximum number of rows per page.
+
+        Yields:
+            Sequence[T]: The rows of every non-empty page, in order.
+
+        Raises:
+            ValueError: If the page size is not positive.
+        """
+        if size < 1:
+            raise ValueError("Page size must be positive.")
+        offset = 0
+        while self.total_items is None or offset < self.total_items:
+            rows = self._loader(offset, size)
+            if not rows:
+                return
+            yield rows
+            if len(rows) < size:
+                return
+            offset += size
@@ -0,0 +1,278 @@
+"""
+Parsing helpers for Brazilian (pt-BR) formats.
+
+CM renders numbers as `1.234,56`, currency as `R$ 1.234,56`, percentages
+as `12,5%`, dates as `DD/MM/YYYY`, timestamps as `DD/MM/YYYY HH:MM` and
+flags as "Sim"/"Não".
+These helpers turn the raw cell text into typed values and are shared by
+every scraper, so numeric and temporal fields never live as raw strings in
+the report models. Timestamps are interpreted in the portal timezone
+(`PORTAL_TIMEZONE`, America/Sao_Paulo by default).
+"""
+
+from datetime import date, datetime
+from decimal import Decimal, InvalidOperation
+from enum import Enum
+from typing import Callable, Optional, Type, TypeVar, Union
+from zoneinfo import ZoneInfo
+
+from core.config import settings
+
+DATE_FORMATS = ["%d/%m/%Y", "%d/%m/%y"]
+DATETIME_FORMATS = ["%d/%m/%Y %H:%M:%S", "%d/%m/%Y %H:%M", "%d/%m/%y %H:%M:%S", "%d/%m/%y %H:%M"]
+PORTAL_TZ = ZoneInfo(settings.PORTAL_TIMEZONE)
+
+E = TypeVar("E", bound=Enum)
+
+
+def _normalize_number(value: str) -> Optional[str]:
+    """
+    Rewrite a pt-BR numeric string using "." as the only decimal separator.
+
+    Values such as "1.300" are ambiguous; a dot followed by exactly three
+    digits is treated as a thousands separator.
+
+    Args:
+        value (str): Numeric string.
+
+    Returns:
+        Optional[str]: The normalized string, or None for empty values.
+    """
+    if not isinstance(value, str):
+        return None
+
+    cleaned_value = value.strip()
+    if not cleaned_value:
+        return None
+
+    if "," in cleaned_value:
+        cleaned_value = cleaned_value.replace(".", "").replace(",", ".")
+
+    elif "." in cleaned_value:
+        parts = cleaned_value.split(".")
+        if len(parts[-1]) == 3 and len(parts) > 1:
+            cleaned_value = "".join(parts)
+        else:
+            cleaned_value = "".join(parts[:-1]) + "." + parts[-1]
+
+    return cleaned_value
+
+
+def parse_decimal(value: str) -> float:
+    """
+    Converte uma string para float, lidando com formatos brasileiros (ex: "1.300,00")
+    e aplicando uma heurística para valores ambíguos como "1.300".
+
+    Args:
+        value (str): A string numérica a ser convertida.
+
+    Returns:
+        float: O valor float convertido. Retorna 0.0 se a conversão falhar.
+    """
+    cleaned_value = _normalize_number(value)
+    if cleaned_value is None:
+        return 0.0
+
+    try:
+        return float(cleaned_value)
+    except (ValueError, TypeError):
+        return 0.0
+
+
+def parse_int(value: str) -> int:
+    """
+    Parse a string into an integer using `parse_decimal`.
+
+    Args:
+        value (str): Numeric string.
+
+    Returns:
+        int: Parsed integer value.
+    """
+    return int(parse_decimal(value))
+
+
+def parse_currency(value: str) -> float:
+    """
+    Parse a BRL amount such as "R$ 1.234,56" or "-R$ 10,00".
+
+    Args:
+        value (str): Currency string, with or without the "R$" symbol.
+
+    Returns:
+        float: Parsed amount. Returns 0.0 if parsing fails.
+    """
+    if not isinstance(value, str):
+        return 0.0
+    cleaned_value = value.replace("R$", "").replace("\xa0", "").replace(" ", "")
+    return parse_decimal(cleaned_value)
+
+
+def parse_money(value: str) -> Decimal:
+    """
+    Parse a BRL amount such as "R$ 1.234,56" into an exact Decimal.
+
+    Unlike `parse_currency`, the value never goes through float, so cost
+    roll-ups do not accumulate rounding errors.
+
+    Args:
+        value (str): Currency string, with or without the "R$" symbol.
+
+    Returns:
+        Decimal: Parsed amount. Returns Decimal("0") if parsing fails.
+    """
+    if not isinstance(value, str):
+        return Decimal("0")
+    cleaned_value = _normalize_number(
+        value.replace("R$", "").replace("\xa0", "").replace(" ", "")
+    )
+    if cleaned_value is None:
+        return Decimal("0")
+    try:
+        return Decimal(cleaned_value)
+    except InvalidOperation:
+        return Decimal("0")
+
+
+def parse_percent(value: str) -> float:
+    """
+    Parse a percentage such as "12,5%" into its numeric value (12.5).
+
+    Args:
+        value (str): Percentage string, with or without the "%" symbol.
+
+    Returns:
+        float: Parsed percentage. Returns 0.0 if parsing fails.
+    """
+    if not isinstance(value, str):
+        return 0.0
+    return parse_decimal(value.replace("%", ""))
+
+
+def parse_quantity(value: str) -> float:
+    """
+    Parse a quantity followed by its unit, such as "1.500,00 M".
+
+    Args:
+        value (str): Quantity string.
+
+    Returns:
+        float: Parsed quantity. Returns 0.0 if parsing fails.
+    """
+    if not isinstance(value, str) or not value.strip():
+        return 0.0
+    return parse_decimal(value.strip().split(" ")[0])
+
+
+TRUE_VALUES = {"sim", "s", "x", "yes", "y", "true", "1", "\u2713", "\u2714", "ok"}
+
+
+def parse_bool(value: str) -> bool:
+    """
+    Parse a flag cell such as "Sim", "S", "X" or a check mark.
+
+    Args:
+        value (str): Flag string.
+
+    Returns:
+        bool: True for affirmative values, False otherwise (including blanks).
+    """
+    if not isinstance(value, str):
+        return False
+    return value.strip().casefold() in TRUE_VALUES
+
+
+def parse_enum(enum_type: Type[E]) -> Callable[[str], Union[E, str]]:
+    """
+    Build a parser matching cell text against the values or names of an enum.
+
+    Matching ignores case and surrounding whitespace.
+
+    Args:
+        enum_type (Type[E]): Enum class.
+
+    Returns:
+        Callable[[str], Union[E, str]]: Parser returning the matching member,
+        or the stripped text when nothing matches (left for the model to reject).
+    """
+    members = {}
+    for member in enum_type:
+        members[member.name.casefold()] = member
+        members[str(member.value).casefold()] = member
+
+    def parser(value: str) -> Union[E, str]:
+        text = value.strip() if isinstance(value, str) else str(value)
+        return members.get(text.casefold(), text)
+
+    return parser
+
+
+def parse_date(date_str: str) -> Optional[date]:
+    """
+    Parse a pt-BR date string into a date object.
+
+    Args:
+        date_str (str): Date string in format "DD/MM/YYYY" or "DD/MM/YY".
+
+    Returns:
+        Optional[date]: Date object, or None if parsing fails.
+    """
+    if not date_str or not date_str.strip():
+        return None
+
+    for fmt in DATE_FORMATS:
+        try:
+            return datetime.strptime(date_str.strip(), fmt).date()
+        except ValueError:
+            continue
+
+    return None
+
+
+def parse_datetime(value: str) -> Optional[datetime]:
+    """
+    Parse a pt-BR timestamp into a timezone-aware datetime in the portal timezone.
+
+    Date-only values are interpreted as midnight of that day.
+
+    Args:
+        value (str): Timestamp string such as "02/01/2006 15:04" or "02/01/2006 15:04:05".
+
+    Returns:
+        Optional[datetime]: Aware datetime, or None if parsing fails.
+    """
+    if not value or not value.strip():
+        return None
+
+    cleaned_value = " ".join(value.split())
+    for fmt in DATETIME_FORMATS:
+        try:
+            return datetime.strptime(cleaned_value, fmt).replace(tzinfo=PORTAL_TZ)
+        except ValueError:
+            continue
+
+    parsed_date = parse_date(cleaned_value)
+    if parsed_date is None:
+        return None
+    return datetime.combine(parsed_date, datetime.min.time(), tzinfo=PORTAL_TZ)
+
+
+def portal_now() -> datetime:
+    """
+    Current time in the portal timezone.
+
+    Returns:
+        datetime: Aware datetime.
+    """
+    return datetime.now(PORTAL_TZ)
+
+
+def portal_today() -> date:
+    """
+    Current date in the portal timezone, used for default date ranges so the
+    server clock timezone does not shift report windows.
+
+    Returns:
+        date: Today's date in the portal timezone.
+    """
+    return portal_now().date()
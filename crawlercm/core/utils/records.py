@@ -0,0 +1,132 @@
+"""
+Dynamic access to report rows.
+
+Services keep working with the typed report models, while config-driven
+code (exporters, templates, column selections) addresses fields by name.
+`Record` wraps a model or a plain dictionary and resolves a name given as
+the Python field name or as its stable English name from
+`core.utils.field_names`:
+
+    record = Record(item)
+    record.get("valor_unitario") == record.get("unit_price") == item.valor_unitario
+"""
+
+from typing import Any, Dict, Iterator, List, Optional, Union
+
+from pydantic import BaseModel
+
+from core.utils.field_names import FIELD_NAMES
+
+_FIELD_BY_ENGLISH_NAME = {english: name for name, english in FIELD_NAMES.items()}
+
+_MISSING = object()
+
+
+def field_name(name: str) -> str:
+    """
+    Python field name of a name given as field name or stable English name.
+
+    Args:
+        name (str): Field name or stable English name.
+
+    Returns:
+        str: The field name.
+    """
+    return _FIELD_BY_ENGLISH_NAME.get(name, name) if name not in FIELD_NAMES else name
+
+
+def value_of(row: Union[BaseModel, Dict[str, Any]], name: str, default: Any = None) -> Any:
+    """
+    Value of a field of a model or dictionary.
+
+    Args:
+        row (Union[BaseModel, Dict[str, Any]]): Row to read.
+        name (str): Field name or stable English name.
+        default (Any, optional): Value returned when the field does not exist.
+
+    Returns:
+        Any: The field value, or `default`.
+    """
+    values = row.__dict__ if isinstance(row, BaseModel) else row
+    if name in values:
+        return values[name]
+    return values.get(_FIELD_BY_ENGLISH_NAME.get(name, name), default)
+
+
+class Record:
+    """
+    Name-based view of a report row.
+
+    Args:
+        row (Union[BaseModel, Dict[str, Any]]): Typed model or dictionary.
+    """
+
+    __slots__ = ("row",)
+
+    def __init__(self, row: Union[BaseModel, Dict[str, Any]]):
+        self.row = row
+
+    def get(self, name: str, default: Any = None) -> Any:
+        """
+        Value of a field, by field name or stable English name.
+
+        Args:
+            name (str): Field name.
+            default (Any, optional): Value returned when the field does not exist.
+
+        Returns:
+            Any: The field value, or `default`.
+        """
+        return value_of(self.row, name, default)
+
+    def __getitem__(self, name: str) -> Any:
+        value = value_of(self.row, name, _MISSING)
+        if value is _MISSING:
+            raise KeyError(name)
+        return value
+
+    def __contains__(self, name: str) -> bool:
+        return value_of(self.row, name, _MISSING) is not _MISSING
+
+    def __iter__(self) -> Iterator[str]:
+        return iter(self.keys())
+
+    def keys(self) -> List[str]:
+        """
+        Field names of the row, in declaration order.
+        """
+        if isinstance(self.row, BaseModel):
+            return list(type(self.row).model_fields)
+        return list(self.row)
+
+    def to_dict(self, by_alias: bool = False) -> Dict[str, Any]:
+        """
+        Field values of the row.
+
+        Args:
+            by_alias (bool, optional): Use the stable English names as keys.
+                Defaults to False.
+
+        Returns:
+            Dict[str, Any]: Values indexed by field name.
+        """
+        if isinstance(self.row, BaseModel):
+            return self.row.model_dump(by_alias=by_alias)
+        if by_alias:
+            return {FIELD_NAMES.get(name, name): value for name, value in self.row.items()}
+        return dict(self.row)
+
+    def typed(self, model: type) -> Optional[BaseModel]:
+        """
+        The wrapped row as an instance of a model, if it is one.
+
+        Args:
+            model (type): Expected model class.
+
+        Returns:
+            Optional[BaseModel]: The row, or None if it is not an instance of `model`.
+        """
+        return self.row if isinstance(self.row, model) else None
+
+    def __repr__(self) -> str:
+        return f"Record({self.row!r})"
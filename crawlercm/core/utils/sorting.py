@@ -0,0 +1,41 @@
+"""
+Deterministic ordering of report rows.
+
+The portal grids do not guarantee any row order, which makes diffs and
+exports change between runs with the same data. `sort_rows` orders rows
+by a list of sort keys, where a leading "-" sorts a field in descending
+order (e.g. ["codigo", "-previsao"]). Missing values always sort last.
+"""
+
+from typing import Any, Dict, List, Sequence, TypeVar, Union
+
+from pydantic import BaseModel
+
+from core.utils.records import value_of
+
+T = TypeVar("T", bound=Union[BaseModel, Dict[str, Any]])
+
+
+def sort_rows(rows: Sequence[T], sort_by: Sequence[str]) -> List[T]:
+    """
+    Sort rows by a list of sort keys.
+
+    The sort is stable, so rows with equal keys keep their relative order.
+
+    Args:
+        rows (Sequence[T]): Models or dictionaries to sort.
+        sort_by (Sequence[str]): Field names, prefixed with "-" for
+            descending order.
+
+    Returns:
+        List[T]: The sorted rows.
+    """
+    result = list(rows)
+    for key in reversed(sort_by):
+        descending = key.startswith("-")
+        field = key.lstrip("-")
+        present = [row for row in result if value_of(row, field) is not None]
+        missing = [row for row in result if value_of(row, field) is None]
+        present.sort(key=lambda row: value_of(row, field), reverse=descending)
+        result = present + missing
+    return result
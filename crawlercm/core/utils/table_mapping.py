@@ -0,0 +1,496 @@
+"""
+Mapping of HTML table cells into typed report models.
+
+Report models declare, for each field, the table column it comes from by
+annotating the field with `Column`:
+
+    codigo: Annotated[str, Column(2)] = Field(..., description="Product code.")
+
+`map_row` inspects the model fields and builds an instance from the cells
+of a table row, parsing each value according to the field type (or the
+parser given in the column), so scrapers no longer index cells by hand.
+Models deriving from `ReportRow` also keep the original cell text of every
+field and the `CellSource` it came from (page, row and column), so parsed
+values that look wrong can be traced back to the exact portal cell.
+
+Odd columns do not need forked scraper code: a parser can be registered
+for a field of a model or for every column with a given header, e.g.
+
+    register_field_parser(PendingMaterialsItem, "kanban", parse_bool)
+    register_header_parser("Classe", parse_enum(MaterialClass))
+
+Fields typed as bool or as an Enum are parsed without registration.
+
+Blank and missing cells have explicit semantics:
+
+- a blank cell of an Optional field is None, never a zero value, while
+  non-optional numeric fields keep the parser default (0);
+- a blank cell of a str field is the empty string;
+- a column absent from the row is None for Optional fields (and
+  `ReportRow.is_absent` is True), and an error otherwise.
+
+Cells that cannot be parsed never become silent zeros. Each failure is a
+`CellParseError` carrying the report, field, cell location and raw text.
+A failed Optional field is set to None and the row is kept as a partial
+result, with the failures listed in `ReportRow.parse_errors`; a failed
+required field makes `map_row` raise `RowParseError` with every failure
+of the row.
+"""
+
+import re
+import types
+from dataclasses import dataclass
+from datetime import date, datetime
+from decimal import Decimal
+from enum import Enum
+from typing import (
+    Any,
+    Callable,
+    Dict,
+    List,
+    Optional,
+    Sequence,
+    Tuple,
+    Type,
+    TypeVar,
+    Union,
+    get_args,
+    get_origin,
+)
+
+from pydantic import BaseModel, PrivateAttr, ValidationError
+
+from core.utils.parsers import (
+    parse_bool,
+    parse_date,
+    parse_datetime,
+    parse_decimal,
+    parse_enum,
+    parse_int,
+    parse_money,
+)
+from core.utils.records import value_of
+from schemas.dataset_schemas import ParseIssue
+
+M = TypeVar("M", bound=BaseModel)
+
+
+@dataclass(frozen=True)
+class Column:
+    """
+    Source column of a report model field.
+
+    Attributes:
+        index (int): Position of the cell in the table row.
+        header (Optional[str]): Header text of the column. When the table
+            headers are given to `map_row`, the column is located by header
+            and `index` is used as a fallback.
+        parser (Optional[Callable[[str], Any]]): Function converting the raw
+            cell text. Defaults to a parser chosen from the field type.
+    """
+
+    index: int
+    header: Optional[str] = None
+    parser: Optional[Callable[[str], Any]] = None
+
+
+@dataclass(frozen=True)
+class CellSource:
+    """
+    Location of the portal cell a field was parsed from.
+
+    Attributes:
+        column (int): Position of the cell in the table row.
+        header (Optional[str]): Header text of the column, when known.
+        row (Optional[int]): Position of the row in the table, starting at 1.
+        page (Optional[int]): Page of the report the row was on, starting at 1.
+        url (Optional[str]): URL of the page.
+    """
+
+    column: int
+    header: Optional[str] = None
+    row: Optional[int] = None
+    page: Optional[int] = None
+    url: Optional[str] = None
+
+    def __str__(self) -> str:
+        parts = [f"page {self.page}" if self.page is not None else None]
+        parts.append(f"row {self.row}" if self.row is not None else None)
+        parts.append(f"column {self.column}" + (f" ({self.header!r})" if self.header else ""))
+        location = ", ".join(part for part in parts if part)
+        return f"{location} of {self.url}" if self.url else location
+
+
+class CellParseError(ValueError):
+    """
+    A table cell whose text could not be parsed into its field.
+
+    Attributes:
+        report (str): Report (or model) name.
+        field (str): Field name.
+        source (CellSource): Location of the cell.
+        raw (str): Cell text.
+        reason (str): Why parsing failed.
+    """
+
+    def __init__(self, report: str, field: str, source: CellSource, raw: str, reason: str):
+        self.report = report
+        self.field = field
+        self.source = source
+        self.raw = raw
+        self.reason = reason
+        super().__init__(f"{report}: cannot parse {field} from {raw!r} at {source}: {reason}")
+
+    def to_issue(self) -> ParseIssue:
+        """
+        Serializable form of the error, stored with the dataset.
+
+        Returns:
+            ParseIssue: The error details.
+        """
+        return ParseIssue(
+            report=self.report,
+            field=self.field,
+            page=self.source.page,
+            row=self.source.row,
+            column=self.source.column,
+            header=self.source.header,
+            raw=self.raw,
+            message=self.reason,
+        )
+
+
+class RowParseError(ValueError):
+    """
+    A table row with required fields that could not be parsed.
+
+    Attributes:
+        errors (List[CellParseError]): Every cell of the row that failed.
+    """
+
+    def __init__(self, errors: List[CellParseError]):
+        self.errors = errors
+        super().__init__("; ".join(str(error) for error in errors))
+
+
+class PageParseError(ValueError):
+    """
+    A page of CM without the layout of its report, such as a report page
+    missing its table.
+    """
+
+
+class ReportRow(BaseModel):
+    """
+    Base class of report models built from CM table rows.
+
+    Keeps the original text of each mapped cell, which is not serialized but
+    allows round-tripping values exactly as the portal rendered them.
+    """
+
+    _raw: Dict[str, str] = PrivateAttr(default_factory=dict)
+    _sources: Dict[str, CellSource] = PrivateAttr(default_factory=dict)
+    _parse_errors: List[CellParseError] = PrivateAttr(default_factory=list)
+
+    @property
+    def parse_errors(self) -> List[CellParseError]:
+        """
+        Optional fields left as None because their cell could not be parsed.
+        """
+        return self._parse_errors
+
+    def raw(self, field_name: str) -> Optional[str]:
+        """
+        Original cell text of a field.
+
+        Args:
+            field_name (str): Field name.
+
+        Returns:
+            Optional[str]: The cell text, or None if the field was not mapped from a table.
+        """
+        return self._raw.get(field_name)
+
+    def source(self, field_name: str) -> Optional[CellSource]:
+        """
+        Portal cell a field was parsed from.
+
+        Args:
+            field_name (str): Field name.
+
+        Returns:
+            Optional[CellSource]: The cell location, or None if the field was
+            not mapped from a table or its column was absent.
+        """
+        return self._sources.get(field_name)
+
+    def get(self, name: str, default: Any = None) -> Any:
+        """
+        Value of a field by name, for code that does not know the model.
+
+        Args:
+            name (str): Field name or stable English name.
+            default (Any, optional): Value returned when the field does not exist.
+
+        Returns:
+            Any: The field value, or `default`.
+        """
+        return value_of(self, name, default)
+
+    def is_absent(self, field_name: str) -> bool:
+        """
+        Whether the column of a field was missing from the table row.
+
+        Args:
+            field_name (str): Field name.
+
+        Returns:
+            bool: True if the field was declared with a column but the row did
+            not have it.
+        """
+        return field_name in model_columns(type(self)) and field_name not in self._raw
+
+    def is_blank(self, field_name: str) -> bool:
+        """
+        Whether the cell of a field was present but empty.
+
+        Args:
+            field_name (str): Field name.
+
+        Returns:
+            bool: True if the cell text was empty or whitespace.
+        """
+        raw = self._raw.get(field_name)
+        return raw is not None and not raw.strip()
+
+
+def _strip(value: str) -> str:
+    return value.strip()
+
+
+_DEFAULT_PARSERS: Dict[type, Callable[[str], Any]] = {
+    str: _strip,
+    int: parse_int,
+    float: parse_decimal,
+    date: parse_date,
+    datetime: parse_datetime,
+    Decimal: parse_money,
+    bool: parse_bool,
+}
+
+_FIELD_PARSERS: Dict[Tuple[Type[BaseModel], str], Callable[[str], Any]] = {}
+_HEADER_PARSERS: Dict[str, Callable[[str], Any]] = {}
+
+
+def _header_key(header: str) -> str:
+    return " ".join(header.split()).casefold()
+
+
+def register_field_parser(
+    model: Type[BaseModel], field_name: str, parser: Callable[[str], Any]
+) -> None:
+    """
+    Register the parser of a field of a report model.
+
+    Takes precedence over header parsers and the default parser of the
+    field type, but not over a parser declared in the field `Column`.
+
+    Args:
+        model (Type[BaseModel]): Report model.
+        field_name (str): Field name.
+        parser (Callable[[str], Any]): Function converting the raw cell text.
+
+    Raises:
+        KeyError: If the model has no such field.
+    """
+    if field_name not in model.model_fields:
+        raise KeyError(f"{model.__name__} has no field {field_name}")
+    _FIELD_PARSERS[(model, field_name)] = parser
+
+
+def register_header_parser(header: str, parser: Callable[[str], Any]) -> None:
+    """
+    Register the parser of every column with a given header text.
+
+    Headers are compared ignoring case and repeated whitespace.
+
+    Args:
+        header (str): Column header text (e.g. "Kanban").
+        parser (Callable[[str], Any]): Function converting the raw cell text.
+    """
+    _HEADER_PARSERS[_header_key(header)] = parser
+
+
+def _parse_failure(raw: str, value: Any) -> Optional[str]:
+    """
+    Detect a parser that swallowed a cell it could not understand.
+
+    Args:
+        raw (str): Cell text, not blank.
+        value (Any): Parsed value.
+
+    Returns:
+        Optional[str]: Why parsing failed, or None if the value looks valid.
+    """
+    if value is None:
+        return "value not recognized"
+    if isinstance(value, (int, float, Decimal)) and not isinstance(value, bool):
+        if not re.search(r"\d", raw):
+            return "not a number"
+    return None
+
+
+def _is_nullable(annotation: Any) -> bool:
+    """
+    Whether an annotation accepts None.
+    """
+    return get_origin(annotation) in (Union, types.UnionType) and type(None) in get_args(annotation)
+
+
+def _base_type(annotation: Any) -> Any:
+    """
+    Unwrap Optional[...] annotations to the underlying type.
+    """
+    if get_origin(annotation) in (Union, types.UnionType):
+        args = [arg for arg in get_args(annotation) if arg is not type(None)]
+        if len(args) == 1:
+            return args[0]
+    return annotation
+
+
+def model_columns(model: Type[BaseModel]) -> Dict[str, Column]:
+    """
+    List the fields of a model annotated with `Column`.
+
+    Args:
+        model (Type[BaseModel]): Report model.
+
+    Returns:
+        Dict[str, Column]: Column of each annotated field, by field name.
+    """
+    columns: Dict[str, Column] = {}
+    for name, info in model.model_fields.items():
+        for meta in info.metadata:
+            if isinstance(meta, Column):
+                columns[name] = meta
+    return columns
+
+
+def column_parser(
+    model: Type[BaseModel], field_name: str, column: Column, header: Optional[str] = None
+) -> Callable[[str], Any]:
+    """
+    Resolve the parser of a model column.
+
+    The parser declared in the column wins, then the one registered for the
+    field, then the one registered for the column header and finally the
+    default parser of the field type.
+
+    Args:
+        model (Type[BaseModel]): Report model.
+        field_name (str): Field name.
+        column (Column): Column declared for the field.
+        header (Optional[str]): Header text of the column in the table, when
+            known. Defaults to the header declared in the column.
+
+    Returns:
+        Callable[[str], Any]: The column parser, or the default one for the field type.
+
+    Raises:
+        TypeError: If the field type has no default parser and none was declared.
+    """
+    if column.parser is not None:
+        return column.parser
+    for cls in model.__mro__:
+        if (cls, field_name) in _FIELD_PARSERS:
+            return _FIELD_PARSERS[(cls, field_name)]
+    header = header or column.header
+    if header and _header_key(header) in _HEADER_PARSERS:
+        return _HEADER_PARSERS[_header_key(header)]
+    field_type = _base_type(model.model_fields[field_name].annotation)
+    if isinstance(field_type, type) and issubclass(field_type, Enum):
+        return parse_enum(field_type)
+    if field_type not in _DEFAULT_PARSERS:
+        raise TypeError(f"No parser for field {model.__name__}.{field_name} of type {field_type}")
+    return _DEFAULT_PARSERS[field_type]
+
+
+def map_row(
+    model: Type[M],
+    cells: Sequence[str],
+    headers: Optional[List[str]] = None,
+    row: Optional[int] = None,
+    page: Optional[int] = None,
+    url: Optional[str] = None,
+    report: Optional[str] = None,
+) -> M:
+    """
+    Build a report model from the text of a table row.
+
+    Args:
+        model (Type[M]): Report model whose fields are annotated with `Column`.
+        cells (Sequence[str]): Raw text of each cell of the row.
+        headers (Optional[List[str]]): Header text of each column, used to
+            locate columns declared with a header.
+        row (Optional[int]): Position of the row in the table, recorded in
+            the provenance of each field.
+        page (Optional[int]): Report page of the row.
+        url (Optional[str]): URL of the page.
+        report (Optional[str]): Report name used in parse errors. Defaults
+            to the model name.
+
+    Returns:
+        M: The parsed model instance, possibly with `parse_errors` on
+        Optional fields.
+
+    Raises:
+        IndexError: If the row does not have a column declared by a non-optional field.
+        RowParseError: If a non-optional field cannot be parsed.
+    """
+    report = report or model.__name__
+    errors: List[CellParseError] = []
+    failed_required = False
+    values: Dict[str, Any] = {}
+    raw: Dict[str, str] = {}
+    sources: Dict[str, CellSource] = {}
+    for name, column in model_columns(model).items():
+        index = column.index
+        if headers and column.header and column.header in headers:
+            index = headers.index(column.header)
+        nullable = _is_nullable(model.model_fields[name].annotation)
+        if index >= len(cells):
+            if not nullable:
+                raise IndexError(f"Column {index} of {model.__name__}.{name} is missing")
+            values[name] = None
+            continue
+        raw[name] = cells[index]
+        header = headers[index] if headers and index < len(headers) else column.header
+        sources[name] = CellSource(column=index, header=header, row=row, page=page, url=url)
+        if nullable and not cells[index].strip():
+            values[name] = None
+            continue
+        try:
+            value = column_parser(model, name, column, header)(cells[index])
+            reason = None if not cells[index].strip() else _parse_failure(cells[index], value)
+        except (ValueError, TypeError, ArithmeticError) as e:
+            value, reason = None, str(e)
+        if reason is not None:
+            errors.append(CellParseError(report, name, sources[name], cells[index], reason))
+            failed_required = failed_required or not nullable
+            value = None
+        values[name] = value
+    if failed_required:
+        raise RowParseError(errors)
+    try:
+        instance = model(**values)
+    except ValidationError as e:
+        for error in e.errors():
+            name = str(error["loc"][0]) if error["loc"] else ""
+            source = sources.get(name, CellSource(column=-1, row=row, page=page, url=url))
+            errors.append(CellParseError(report, name, source, raw.get(name, ""), error["msg"]))
+        raise RowParseError(errors) from e
+    if isinstance(instance, ReportRow):
+        instance._raw = raw
+        instance._sources = sources
+        instance._parse_errors = errors
+    return instance
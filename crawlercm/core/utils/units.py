@@ -0,0 +1,148 @@
+"""
+Units of measure used by CM reports and conversion helpers.
+
+Reports render quantities with free-text units ("1.500,00 M", "3 PÇ",
+"25 KG"). `parse_unit` maps the common spellings into the `Unit` enum and
+`convert` translates quantities between units of the same dimension
+(e.g. meters and kilometers of cable, grams and kilograms of copper), which
+is needed when joining stock and consumption data.
+"""
+
+from enum import Enum
+from typing import Dict, Optional, Tuple, Union
+
+
+class Unit(str, Enum):
+    """
+    Known units of measure.
+    """
+
+    MM = "MM"
+    CM = "CM"
+    M = "M"
+    KM = "KM"
+    G = "G"
+    KG = "KG"
+    T = "T"
+    ML = "ML"
+    L = "L"
+    PC = "PC"
+    UN = "UN"
+    RL = "RL"
+    CX = "CX"
+    PAR = "PAR"
+
+
+_ALIASES: Dict[str, Unit] = {
+    "MT": Unit.M,
+    "MTS": Unit.M,
+    "METRO": Unit.M,
+    "METROS": Unit.M,
+    "GR": Unit.G,
+    "KGS": Unit.KG,
+    "TON": Unit.T,
+    "LT": Unit.L,
+    "PÇ": Unit.PC,
+    "PCS": Unit.PC,
+    "PÇS": Unit.PC,
+    "PECA": Unit.PC,
+    "PEÇA": Unit.PC,
+    "UND": Unit.UN,
+    "UNID": Unit.UN,
+    "ROLO": Unit.RL,
+    "ROLOS": Unit.RL,
+    "CAIXA": Unit.CX,
+}
+
+# Dimension and factor to the base unit of each dimension.
+_FACTORS: Dict[Unit, Tuple[str, float]] = {
+    Unit.MM: ("length", 0.001),
+    Unit.CM: ("length", 0.01),
+    Unit.M: ("length", 1.0),
+    Unit.KM: ("length", 1000.0),
+    Unit.G: ("mass", 0.001),
+    Unit.KG: ("mass", 1.0),
+    Unit.T: ("mass", 1000.0),
+    Unit.ML: ("volume", 0.001),
+    Unit.L: ("volume", 1.0),
+}
+
+
+def parse_unit(value: str) -> Union[Unit, str]:
+    """
+    Parse a unit of measure, mapping common spellings to `Unit`.
+
+    When the cell holds a quantity followed by its unit ("1.500,00 M"), the
+    last token is used.
+
+    Args:
+        value (str): Unit or quantity string.
+
+    Returns:
+        Union[Unit, str]: The known unit, or the original (uppercased) text
+        when the unit is not known.
+    """
+    if not isinstance(value, str) or not value.strip():
+        return ""
+    token = value.strip().split(" ")[-1].upper().rstrip(".")
+    if token in Unit.__members__:
+        return Unit(token)
+    return _ALIASES.get(token, token)
+
+
+def to_unit(value: Union[Unit, str, None]) -> Optional[Unit]:
+    """
+    Resolve a value into a known unit.
+
+    Args:
+        value (Union[Unit, str, None]): Unit or unit text.
+
+    Returns:
+        Optional[Unit]: The known unit, or None if it is not known.
+    """
+    if value is None:
+        return None
+    unit = parse_unit(value) if isinstance(value, str) else value
+    return unit if isinstance(unit, Unit) else None
+
+
+def can_convert(from_unit: Union[Unit, str], to_unit_: Union[Unit, str]) -> bool:
+    """
+    Whether quantities can be converted between two units.
+
+    Args:
+        from_unit (Union[Unit, str]): Source unit.
+        to_unit_ (Union[Unit, str]): Target unit.
+
+    Returns:
+        bool: True for identical units or units of the same dimension.
+    """
+    source, target = to_unit(from_unit), to_unit(to_unit_)
+    if source is None or target is None:
+        return False
+    if source == target:
+        return True
+    return source in _FACTORS and target in _FACTORS and _FACTORS[source][0] == _FACTORS[target][0]
+
+
+def convert(quantity: float, from_unit: Union[Unit, str], to_unit_: Union[Unit, str]) -> float:
+    """
+    Convert a quantity between units of the same dimension.
+
+    Args:
+        quantity (float): Quantity in `from_unit`.
+        from_unit (Union[Unit, str]): Source unit.
+        to_unit_ (Union[Unit, str]): Target unit.
+
+    Returns:
+        float: Quantity in `to_unit_`.
+
+    Raises:
+        ValueError: If the units are unknown or of different dimensions.
+    """
+    if not can_convert(from_unit, to_unit_):
+        raise ValueError(f"Cannot convert from {from_unit} to {to_unit_}")
+    source, target = to_unit(from_unit), to_unit(to_unit_)
+    if source == target:
+        return quantity
+    return quantity * _FACTORS[source][1] / _FACTORS[target][1]
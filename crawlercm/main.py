@@ -2,16 +2,73 @@
 Main module for the API.
 
 This module defines the FastAPI app and its routes.
-It also includes middleware for handling CORS and logging.
+It also includes middleware for handling CORS and logging. The export
+plugins are registered when the app starts, and the workers of the
+background job queue run for its lifetime, with its authenticated session.
+The OpenAPI document of the routes, with an operation per report, is
+served at `/openapi.json` (see `api.openapi`).
 """
 
-from fastapi import FastAPI
+from contextlib import asynccontextmanager
+
+from fastapi import Depends, FastAPI
 from fastapi.middleware.cors import CORSMiddleware
-from api.routes import report_router
+from api import deps
+from api.openapi import build_openapi, operation_id
+from api.routes import (
+    alert_router,
+    cache_router,
+    dataset_router,
+    graphql_router,
+    health_router,
+    job_router,
+    metrics_router,
+    report_router,
+    runner_router,
+    snapshot_router,
+    subscription_router,
+)
+from core.build_info import version
+from core.config import settings
+from core.logger import configure_logging, logger
 from core.session_manager import lifespan
+from core.tracing import configure_tracing
+from services.export.registry import load_plugins
+from services.job_queue import job_queue
+from services.report_registry import ReportContext
+
+configure_logging(settings.LOG_LEVEL, settings.LOG_FORMAT)
+configure_tracing()
+
+
+@asynccontextmanager
+async def app_lifespan(app: FastAPI):
+    """
+    Register the export plugins and log in to CM, then run the job queue
+    until the app stops.
+    """
+    logger.info("Initializing API")
+    load_plugins()
+    async with lifespan(app):
+        await job_queue.start(
+            lambda: ReportContext(
+                client=app.state.http_client, csrf_token=app.state.csrf_token
+            )
+        )
+        try:
+            yield
+        finally:
+            await job_queue.stop()
+
 
 origins = ["http://localhost", "http://localhost:8090", "*"]
-app = FastAPI(title="API de Scraping", lifespan=lifespan)
+app = FastAPI(
+    title="API de Scraping",
+    version=version(),
+    description="Reports of CM as JSON, scraped on demand or served from the report cache.",
+    lifespan=app_lifespan,
+    generate_unique_id_function=operation_id,
+)
 app.add_middleware(
     CORSMiddleware,
     allow_origins=origins,
@@ -19,17 +76,23 @@ app.add_middleware(
     allow_methods=["*"],
     allow_headers=["*"],
 )
+app.include_router(health_router.router, tags=["Health"])
+app.include_router(metrics_router.router, tags=["Health"])
 app.include_router(report_router.router, prefix="/api", tags=["Scraping"])
-
-
-@app.on_event("startup")
-async def startup_event():
-    """
-    Startup event for the API.
-    """
-    from core.logger import logger
-    logger.info("Initializing API")
-    logger.info("Configuring CORS and API Routes")
+app.include_router(runner_router.router, prefix="/api", tags=["Runner"])
+app.include_router(job_router.router, prefix="/api", tags=["Jobs"])
+app.include_router(snapshot_router.router, prefix="/api", tags=["Snapshots"])
+app.include_router(alert_router.router, prefix="/api", tags=["Alerts"])
+app.include_router(dataset_router.router, prefix="/api", tags=["Reports"])
+app.include_router(
+    graphql_router.router,
+    prefix="/api",
+    tags=["GraphQL"],
+    dependencies=[Depends(deps.get_principal)],
+)
+app.include_router(subscription_router.router, prefix="/api", tags=["Subscriptions"])
+app.include_router(cache_router.router, prefix="/api", tags=["Cache"])
+app.openapi = lambda: build_openapi(app)
 
 
 @app.get("/")
@@ -0,0 +1,20 @@
+"""
+Schemas for purchasing alerts generated from report runs.
+"""
+
+from typing import Any, Dict, Optional
+from pydantic import BaseModel, Field
+
+
+class PriceChange(BaseModel):
+    """
+    A material whose price moved beyond the alert threshold between two runs.
+    """
+
+    key: Dict[str, Any] = Field(..., description="Key values identifying the material.")
+    old_price: float = Field(..., description="Price in the previous run.")
+    new_price: float = Field(..., description="Price in the current run.")
+    change_pct: Optional[float] = Field(
+        None, description="Percentage change. None when the previous price was zero."
+    )
+    row: Dict[str, Any] = Field(..., description="Row of the current run.")
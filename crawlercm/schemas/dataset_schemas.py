@@ -0,0 +1,96 @@
+"""
+Schemas wrapping report results with their provenance.
+
+Every fetch performed through the report registry returns a `Dataset`,
+which carries the rows together with a `DatasetMetadata` envelope (when
+and where the data was fetched, with which filters, how many pages and
+rows, and how long it took), so exports and logs can record provenance.
+Rows keep their typed models, and `records`/`column` give name-based
+access for code that handles any report.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, Generic, List, Optional, TypeVar
+from pydantic import BaseModel, Field
+
+from core.utils.records import Record, value_of
+
+T = TypeVar("T")
+
+
+class ParseIssue(BaseModel):
+    """
+    A portal cell that could not be parsed.
+    """
+
+    report: str = Field(..., description="Report name.")
+    field: str = Field(..., description="Field the cell was parsed into.")
+    page: Optional[int] = Field(None, description="Report page of the row.")
+    row: Optional[int] = Field(None, description="Position of the row in the table.")
+    column: int = Field(..., description="Position of the cell in the row.")
+    header: Optional[str] = Field(None, description="Header text of the column, when known.")
+    raw: str = Field(..., description="Cell text.")
+    message: str = Field(..., description="Why parsing failed.")
+
+
+class DatasetMetadata(BaseModel):
+    """
+    Provenance of a fetched dataset.
+    """
+
+    report: str = Field(..., description="Report name.")
+    fetched_at: datetime = Field(
+        ..., description="When the fetch started, in the portal timezone."
+    )
+    source_url: Optional[str] = Field(None, description="URL the data was scraped from.")
+    filters: Dict[str, Any] = Field(default_factory=dict, description="Filters used.")
+    page_count: int = Field(0, description="Number of pages fetched from CM.")
+    row_count: int = Field(0, description="Number of rows in the dataset.")
+    elapsed_seconds: float = Field(0.0, description="Time spent fetching the data.")
+    parse_errors: List[ParseIssue] = Field(
+        default_factory=list,
+        description="Cells that could not be parsed; their rows were dropped or left partial.",
+    )
+
+
+class Dataset(BaseModel, Generic[T]):
+    """
+    Rows of a report together with their metadata envelope.
+    """
+
+    metadata: DatasetMetadata = Field(..., description="Provenance of the rows.")
+    rows: List[T] = Field(default_factory=list, description="Report rows.")
+
+    def __len__(self) -> int:
+        return len(self.rows)
+
+    @property
+    def field_names(self) -> List[str]:
+        """
+        Names of the fields of the rows, in order of first appearance.
+        """
+        names: List[str] = []
+        for record in self.records():
+            names.extend(name for name in record.keys() if name not in names)
+        return names
+
+    def records(self) -> List[Record]:
+        """
+        Name-based views of the rows, for code that handles any report.
+
+        Returns:
+            List[Record]: One record per row.
+        """
+        return [Record(row) for row in self.rows]
+
+    def column(self, name: str) -> List[Any]:
+        """
+        Values of a field across all rows.
+
+        Args:
+            name (str): Field name or stable English name.
+
+        Returns:
+            List[Any]: One value per row, None where the field does not exist.
+        """
+        return [value_of(row, name) for row in self.rows]
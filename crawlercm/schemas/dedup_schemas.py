@@ -0,0 +1,19 @@
+"""
+Schemas for key-based deduplication of report rows.
+"""
+
+from enum import Enum
+
+
+class DedupPolicy(str, Enum):
+    """
+    What to do with rows sharing the same business key.
+
+    - keep_latest: keep the row fetched last (e.g. from the latest page).
+    - keep_max_price: keep the row with the highest price.
+    - error: fail the fetch, listing the duplicated keys.
+    """
+
+    KEEP_LATEST = "keep_latest"
+    KEEP_MAX_PRICE = "keep_max_price"
+    ERROR = "error"
@@ -0,0 +1,48 @@
+"""
+Schemas describing the differences between two runs of a report.
+
+These Pydantic models are produced by `services.report_diff.diff` and are
+the base for change notifications (new items, price changes, removed items).
+"""
+
+from typing import Any, Dict, List
+from pydantic import BaseModel, Field
+
+
+class RowChange(BaseModel):
+    """
+    A row present in both runs whose values changed.
+    """
+
+    key: Dict[str, Any] = Field(..., description="Key values identifying the row.")
+    old: Dict[str, Any] = Field(..., description="Row as it was in the old run.")
+    new: Dict[str, Any] = Field(..., description="Row as it is in the new run.")
+    fields: List[str] = Field(..., description="Names of the fields that changed.")
+
+
+class ReportDiff(BaseModel):
+    """
+    Differences between two runs of the same report.
+    """
+
+    key_fields: List[str] = Field(..., description="Fields used to match rows.")
+    added: List[Dict[str, Any]] = Field(
+        default_factory=list, description="Rows only present in the new run."
+    )
+    removed: List[Dict[str, Any]] = Field(
+        default_factory=list, description="Rows only present in the old run."
+    )
+    changed: List[RowChange] = Field(
+        default_factory=list, description="Rows present in both runs with different values."
+    )
+    duplicated: List[Dict[str, Any]] = Field(
+        default_factory=list,
+        description="Keys shared by several rows of a run, compared by their last row.",
+    )
+
+    @property
+    def is_empty(self) -> bool:
+        """
+        Whether both runs hold exactly the same rows.
+        """
+        return not (self.added or self.removed or self.changed)
@@ -0,0 +1,80 @@
+"""
+Schemas of export files: their presentation and the manifests listing them.
+"""
+
+from datetime import datetime
+from typing import Dict, List, Literal, Optional
+from pydantic import BaseModel, Field
+
+TextEncoding = Literal["utf-8", "utf-8-sig", "iso-8859-1", "windows-1252"]
+
+
+class XlsxFormatting(BaseModel):
+    """
+    Presentation of the worksheets of an XLSX export.
+
+    Column formats and widths are given per field name, stable English name
+    or output header. A format is one of `currency` (R$), `decimal`,
+    `integer`, `percent` (values in percentage points, e.g. 12.5),
+    `date`, `datetime` and `text`, or any Excel number format such as
+    `0.000`.
+    """
+
+    column_formats: Dict[str, str] = Field(
+        default_factory=dict,
+        description="Number format per column, added to the defaults of the money and "
+        "percentage fields.",
+    )
+    default_formats: bool = Field(
+        True, description="Whether money and percentage fields get their formats by default."
+    )
+    autofilter: bool = Field(True, description="Whether the header row has an autofilter.")
+    freeze_header: bool = Field(True, description="Whether the header row stays visible.")
+    column_widths: Dict[str, float] = Field(
+        default_factory=dict, description="Width per column, in characters."
+    )
+    autofit: bool = Field(
+        True, description="Whether columns without a width are sized to their content."
+    )
+    max_width: Optional[float] = Field(
+        60, description="Widest automatic column width, in characters."
+    )
+
+
+class CsvFormatting(BaseModel):
+    """
+    Layout of CSV exports.
+
+    Excel in Brazilian Portuguese splits columns on semicolons and reads
+    commas as decimal separators; set `delimiter=";"` and
+    `decimal_separator=","` for files opened there with a double click.
+    """
+
+    delimiter: str = Field(",", min_length=1, max_length=1, description="Field delimiter.")
+    decimal_separator: Literal[".", ","] = Field(
+        ".", description="Decimal separator of fractional numbers (money, quantities, rates)."
+    )
+
+
+class ManifestEntry(BaseModel):
+    """
+    An export file listed in a manifest.
+    """
+
+    file: str = Field(..., description="File name, relative to the manifest.")
+    report: str = Field(..., description="Report, or bundle, the file holds.")
+    row_count: int = Field(..., description="Number of rows written to the file.")
+    size_bytes: int = Field(..., description="Size of the file.")
+    sha256: str = Field(..., description="SHA-256 checksum of the file content, in hex.")
+    generated_at: datetime = Field(..., description="When the file was written.")
+
+
+class Manifest(BaseModel):
+    """
+    Files written by a batch run to a directory, for consumers to verify
+    that a delivery is complete before loading it.
+    """
+
+    run_started_at: datetime = Field(..., description="When the batch run started.")
+    generated_at: datetime = Field(..., description="When the manifest was written.")
+    files: List[ManifestEntry] = Field(default_factory=list, description="Files of the run.")
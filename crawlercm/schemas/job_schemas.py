@@ -0,0 +1,43 @@
+"""
+Schemas of the background scrape jobs of the API.
+
+A `ScrapeJob` records a batch run submitted to the job queue (see
+`services.job_queue`): its configuration, its status as it moves from
+queued to running to finished, its timings and where its results went.
+"""
+
+from datetime import datetime
+from typing import List, Literal, Optional
+from pydantic import BaseModel, Field
+
+from schemas.runner_schemas import RunConfig, RunSummary
+
+JobStatus = Literal["queued", "running", "succeeded", "failed", "cancelled"]
+
+
+class ScrapeJob(BaseModel):
+    """
+    A batch run executed in the background by the job queue.
+    """
+
+    id: str = Field(..., description="Job identifier.")
+    status: JobStatus = Field(
+        "queued",
+        description="queued until a worker takes it, then running, and finally succeeded "
+        "(every report delivered), failed or cancelled (before it started).",
+    )
+    config: RunConfig = Field(..., description="Batch run executed by the job.")
+    created_at: datetime = Field(..., description="When the job was submitted.")
+    started_at: Optional[datetime] = Field(None, description="When a worker started the job.")
+    finished_at: Optional[datetime] = Field(None, description="When the job finished.")
+    queued_seconds: Optional[float] = Field(
+        None, description="Time the job waited for a worker."
+    )
+    duration_seconds: Optional[float] = Field(None, description="Time the run took.")
+    summary: Optional[RunSummary] = Field(None, description="Summary of the run, once finished.")
+    results: List[str] = Field(
+        default_factory=list,
+        description="Where the results are: the destinations written and the snapshots "
+        "stored, as snapshot:<report>/<id> (see /snapshots).",
+    )
+    error: Optional[str] = Field(None, description="Why the job failed, if it did.")
@@ -0,0 +1,30 @@
+"""
+Schemas for datasets produced by joining several reports.
+"""
+
+from typing import Any, Dict, List, Optional
+from pydantic import BaseModel, Field
+
+from core.utils.field_names import STABLE_NAMES
+
+
+class EnrichedMaterial(BaseModel):
+    """
+    A material combining its master data, current stock and supplier prices.
+    """
+
+    model_config = STABLE_NAMES
+
+    codigo: str = Field(..., description="Material code.")
+    cadastro: Optional[Dict[str, Any]] = Field(
+        None, description="Row of the material master, if the material is registered."
+    )
+    estoque: Optional[Dict[str, Any]] = Field(
+        None, description="Row of the stock report, if the material has stock."
+    )
+    precos: List[Dict[str, Any]] = Field(
+        default_factory=list, description="Supplier price rows of the material."
+    )
+    ausente_em: List[str] = Field(
+        default_factory=list, description="Sources where the material code was not found."
+    )
@@ -0,0 +1,47 @@
+"""
+Schemas for the delivery of report runs by notification channels.
+"""
+
+from typing import List, Literal, Optional
+from pydantic import BaseModel, Field
+
+AttachmentFormat = Literal["json", "ndjson", "csv", "xlsx", "parquet", "pdf", "html"]
+
+
+class EmailDelivery(BaseModel):
+    """
+    E-mail delivery of a report run.
+
+    Subject and body are templates with the placeholders of
+    `services.export.naming`, such as `report`, `date`, `fetched_at` and
+    `row_count` (e.g. "{report} - {date:%d/%m/%Y}").
+    """
+
+    to: List[str] = Field(..., min_length=1, description="Recipient addresses.")
+    cc: List[str] = Field(default_factory=list, description="Carbon copy addresses.")
+    subject: str = Field(
+        "[CM] {report} - {date:%d/%m/%Y}", description="Subject template."
+    )
+    body: str = Field(
+        "Report {report} fetched at {fetched_at:%d/%m/%Y %H:%M} with {row_count} rows.",
+        description="Body template, sent as text and as the first paragraph of the HTML body.",
+    )
+    attachments: List[AttachmentFormat] = Field(
+        default_factory=lambda: ["xlsx"], description="Export formats attached to the e-mail."
+    )
+    compression: Optional[Literal["gzip", "zip"]] = Field(
+        None,
+        description="Compress each attachment with gzip, or bundle every attachment "
+        "into a single zip archive.",
+    )
+    html_template: Optional[str] = Field(
+        None,
+        description="HTML template file of the section of each report "
+        "(see services.export.html_export); defaults to the body and the inline table.",
+    )
+    inline_max_rows: int = Field(
+        20,
+        ge=0,
+        description="Reports with at most this many rows are sent as an inline HTML table "
+        "instead of attachments. 0 always attaches.",
+    )
@@ -0,0 +1,31 @@
+"""
+Schemas for paged access to report rows.
+"""
+
+from typing import Generic, List, Optional, TypeVar
+from pydantic import BaseModel, Field
+
+T = TypeVar("T")
+
+
+class Page(BaseModel, Generic[T]):
+    """
+    A single page of a dataset.
+    """
+
+    page: int = Field(..., description="Page number, starting at 1.")
+    size: int = Field(..., description="Maximum number of items per page.")
+    items: List[T] = Field(default_factory=list, description="Items of the page.")
+    total_items: Optional[int] = Field(
+        None, description="Total number of items, when known."
+    )
+    has_next: bool = Field(..., description="Whether there is a page after this one.")
+
+    @property
+    def total_pages(self) -> Optional[int]:
+        """
+        Total number of pages, when the total number of items is known.
+        """
+        if self.total_items is None:
+            return None
+        return max(1, -(-self.total_items // self.size))
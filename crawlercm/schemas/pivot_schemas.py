@@ -0,0 +1,41 @@
+"""
+Schemas for pivot tables built from report rows.
+"""
+
+from typing import Any, Dict, List, Optional
+from pydantic import BaseModel, Field
+
+
+class PivotRow(BaseModel):
+    """
+    A line of a pivot table.
+    """
+
+    key: Any = Field(..., description="Value of the index field (e.g. material code).")
+    values: Dict[str, Optional[Any]] = Field(
+        default_factory=dict, description="Aggregated value per column; None where empty."
+    )
+
+
+class PivotTable(BaseModel):
+    """
+    Comparison matrix of a value field by an index field and a column field.
+    """
+
+    index: str = Field(..., description="Field whose values form the lines.")
+    column: str = Field(..., description="Field whose values form the columns.")
+    value: str = Field(..., description="Field aggregated into the cells.")
+    columns: List[str] = Field(default_factory=list, description="Column labels, sorted.")
+    rows: List[PivotRow] = Field(default_factory=list, description="Lines, sorted by key.")
+
+    def to_records(self) -> List[Dict[str, Any]]:
+        """
+        Flatten the table into one dictionary per line, for exporters.
+
+        Returns:
+            List[Dict[str, Any]]: The index value followed by one entry per column.
+        """
+        return [
+            {self.index: row.key, **{column: row.values.get(column) for column in self.columns}}
+            for row in self.rows
+        ]
@@ -0,0 +1,77 @@
+"""
+Schemas for data quality reports.
+
+A `QualityReport` summarizes the hygiene of a dataset as delivered by the
+portal: missing and unparsable values per field, duplicated keys and
+outlier prices. Reports are produced on every batch run so the quality of
+the portal data can be followed over time.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, List
+from pydantic import BaseModel, Field
+
+
+class FieldQuality(BaseModel):
+    """
+    Quality of the values of a single field.
+    """
+
+    field: str = Field(..., description="Field name.")
+    missing: int = Field(0, description="Rows where the value is empty or absent.")
+    unparsable: int = Field(0, description="Rows whose cell text could not be parsed.")
+    samples: List[str] = Field(
+        default_factory=list, description="Examples of cell texts that could not be parsed."
+    )
+
+
+class DuplicateKey(BaseModel):
+    """
+    A key shared by more than one row.
+    """
+
+    key: Dict[str, Any] = Field(..., description="Key values.")
+    count: int = Field(..., description="Number of rows with the key.")
+
+
+class PriceOutlier(BaseModel):
+    """
+    A price far from the distribution of the other prices of the field.
+    """
+
+    row_index: int = Field(..., description="Position of the row in the dataset.")
+    field: str = Field(..., description="Price field.")
+    value: float = Field(..., description="Outlier price.")
+    lower_bound: float = Field(..., description="Lowest price considered usual.")
+    upper_bound: float = Field(..., description="Highest price considered usual.")
+
+
+class QualityReport(BaseModel):
+    """
+    Data quality summary of a dataset.
+    """
+
+    report: str = Field(..., description="Report name.")
+    analyzed_at: datetime = Field(..., description="When the analysis was made.")
+    total_rows: int = Field(..., description="Number of rows analyzed.")
+    key_fields: List[str] = Field(
+        default_factory=list, description="Fields used to detect duplicated keys."
+    )
+    fields: List[FieldQuality] = Field(default_factory=list, description="Quality per field.")
+    duplicate_keys: List[DuplicateKey] = Field(
+        default_factory=list, description="Keys found in more than one row."
+    )
+    price_outliers: List[PriceOutlier] = Field(
+        default_factory=list, description="Outlier prices found."
+    )
+
+    @property
+    def is_clean(self) -> bool:
+        """
+        Whether no issue was found.
+        """
+        return (
+            not self.duplicate_keys
+            and not self.price_outliers
+            and not any(f.missing or f.unparsable for f in self.fields)
+        )
@@ -0,0 +1,52 @@
+"""
+Schemas for below-minimum stock detection.
+
+`MaterialMinimum` holds the stock policy of a material, maintained by
+purchasing, and `ReplenishItem` is a line of the resulting replenish list.
+"""
+
+from typing import Optional, Union
+from pydantic import BaseModel, Field
+
+from core.utils.field_names import STABLE_NAMES
+from core.utils.units import Unit
+
+
+class MaterialMinimum(BaseModel):
+    """
+    Stock policy of a material.
+    """
+
+    model_config = STABLE_NAMES
+
+    codigo: str = Field(..., description="Material code.")
+    minimo: float = Field(..., ge=0, description="Minimum stock level.")
+    lote_minimo: float = Field(0, ge=0, description="Minimum order quantity (MOQ).")
+    lote_multiplo: float = Field(0, ge=0, description="Order multiple, if any.")
+    fornecedor_preferido: Optional[str] = Field(None, description="Preferred supplier.")
+    unidade: Optional[Union[Unit, str]] = Field(
+        None,
+        union_mode="left_to_right",
+        description="Unit of the minimum and lots. Stock is converted to it when possible.",
+    )
+
+
+class ReplenishItem(BaseModel):
+    """
+    A material whose stock is below its minimum.
+    """
+
+    model_config = STABLE_NAMES
+
+    codigo: str = Field(..., description="Material code.")
+    material: Optional[str] = Field(None, description="Material description, when available.")
+    estoque: float = Field(..., description="Current stock level.")
+    minimo: float = Field(..., description="Minimum stock level.")
+    deficit: float = Field(..., description="Quantity missing to reach the minimum.")
+    qtde_sugerida: float = Field(
+        ..., description="Suggested order quantity, respecting MOQ and order multiple."
+    )
+    fornecedor_preferido: Optional[str] = Field(None, description="Preferred supplier.")
+    unidade: Optional[Union[Unit, str]] = Field(
+        None, union_mode="left_to_right", description="Unit of the quantities."
+    )
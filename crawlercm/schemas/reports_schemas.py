@@ -3,15 +3,30 @@ Schemas used for API communication and report data serialization.
 
 These Pydantic models define the structure of data returned by
 the scraping services. They represent reports for sales, production
-orders, and pending materials.
+orders, and pending materials. Fields scraped from CM tables are annotated
+with the `Column` they come from, which `core.utils.table_mapping.map_row`
+uses to build the models from table rows. Optional fields are None when
+the portal leaves the cell blank, so blanks are never reported as zeros.
+Date columns hold calendar dates as shown by the portal; the original cell
+text is kept by `ReportRow`.
+Product and material codes are stored in their canonical form so reports
+can be joined by code. Serialized rows use the stable English names of
+`core.utils.field_names` instead of the Portuguese field names.
 """
 
-from typing import List, Optional
+from typing import Annotated, List, Optional, Union
 from datetime import date
 from pydantic import BaseModel, Field
 
+from core.utils.canonical import canonical_material_code
+from core.utils.field_names import STABLE_NAMES
+from core.utils.money import Money
+from core.utils.parsers import parse_money, parse_percent, parse_quantity
+from core.utils.table_mapping import Column, ReportRow
+from core.utils.units import Unit, parse_unit
 
-class SalesReportItem(BaseModel):
+
+class SalesReportItem(ReportRow):
     """
     Represents a single sales report entry.
 
@@ -20,40 +35,68 @@ class SalesReportItem(BaseModel):
     profitability indicators.
     """
 
-    cliente: str = Field(..., description="Customer name.")
-    negociacao: str = Field(..., description="Negotiation or deal identifier.")
-    tipo_servico: str = Field(..., description="Type of service or sales operation.")
+    model_config = STABLE_NAMES
 
-    emissao_pv: Optional[date] = Field(
+    cliente: Annotated[str, Column(0)] = Field(..., description="Customer name.")
+    negociacao: Annotated[str, Column(1)] = Field(
+        ..., description="Negotiation or deal identifier."
+    )
+    tipo_servico: Annotated[str, Column(2)] = Field(
+        ..., description="Type of service or sales operation."
+    )
+
+    emissao_pv: Annotated[Optional[date], Column(3)] = Field(
         None, description="Sales order issue date (PV emission)."
     )
 
-    pedido_cliente: str = Field(..., description="Customer's order number.")
-    op: str = Field(..., description="Production order code (OP).")
-    numero_projeto: str = Field(..., description="Project or job number.")
-    codigo: str = Field(..., description="Product code.")
-    produto: str = Field(..., description="Product description.")
+    pedido_cliente: Annotated[str, Column(4)] = Field(
+        ..., description="Customer's order number."
+    )
+    op: Annotated[str, Column(5)] = Field(
+        ..., description="Production order code (OP)."
+    )
+    numero_projeto: Annotated[str, Column(6)] = Field(
+        ..., description="Project or job number."
+    )
+    codigo: Annotated[str, Column(7, parser=canonical_material_code)] = Field(..., description="Product code.")
+    produto: Annotated[str, Column(8)] = Field(..., description="Product description.")
 
-    previsao: Optional[date] = Field(
+    previsao: Annotated[Optional[date], Column(9)] = Field(
         None, description="Expected delivery or completion date."
     )
 
-    qtde_pendente: int = Field(..., description="Pending quantity.")
-    estoque: int = Field(..., description="Current stock level.")
+    qtde_pendente: Annotated[int, Column(10)] = Field(
+        ..., description="Pending quantity."
+    )
+    estoque: Annotated[Optional[int], Column(11)] = Field(
+        None, description="Current stock level, None when blank."
+    )
 
-    valor_unitario: float = Field(..., description="Unit price of the item.")
-    ipi: Optional[float] = Field(None, description="IPI tax percentage, if applicable.")
-    valor_total: float = Field(..., description="Total value for the order line.")
-    custo_estrutura: float = Field(..., description="Structure or production cost.")
-    lucratividade_rs: float = Field(..., description="Profitability in BRL.")
-    lucratividade_percentual: float = Field(
-        ..., description="Profitability percentage."
+    valor_unitario: Annotated[Money, Column(12, parser=parse_money)] = Field(
+        ..., description="Unit price of the item."
+    )
+    ipi: Annotated[Optional[float], Column(13, parser=parse_percent)] = Field(
+        None, description="IPI tax percentage, None when blank."
+    )
+    valor_total: Annotated[Money, Column(14, parser=parse_money)] = Field(
+        ..., description="Total value for the order line."
     )
+    custo_estrutura: Annotated[Optional[Money], Column(15, parser=parse_money)] = Field(
+        None, description="Structure or production cost, None when blank."
+    )
+    lucratividade_rs: Annotated[Optional[Money], Column(16, parser=parse_money)] = Field(
+        None, description="Profitability in BRL, None when blank."
+    )
+    lucratividade_percentual: Annotated[
+        Optional[float], Column(17, parser=parse_percent)
+    ] = Field(None, description="Profitability percentage, None when blank.")
 
-    condicao_pagamento: str = Field(..., description="Payment condition or terms.")
+    condicao_pagamento: Annotated[str, Column(18)] = Field(
+        ..., description="Payment condition or terms."
+    )
 
 
-class PendingOrdersItem(BaseModel):
+class PendingOrdersItem(ReportRow):
     """
     Represents a pending production order entry.
 
@@ -61,18 +104,30 @@ class PendingOrdersItem(BaseModel):
     including product, quantity, client, and schedule information.
     """
 
-    op: str = Field(..., description="Production order number.")
-    cliente: str = Field(..., description="Customer name.")
-    codigo: str = Field(..., description="Product code.")
-    produto: str = Field(..., description="Product description.")
-    criacao: Optional[date] = Field(None, description="Order creation date.")
-    prazo: Optional[date] = Field(None, description="Expected due or completion date.")
-    quantidade: int = Field(..., description="Total quantity to produce.")
-    peso: float = Field(..., description="Total weight of the production order.")
-    etapa: str = Field(..., description="Current production stage.")
+    model_config = STABLE_NAMES
+
+    op: Annotated[str, Column(0)] = Field(..., description="Production order number.")
+    cliente: Annotated[str, Column(1)] = Field(..., description="Customer name.")
+    codigo: Annotated[str, Column(2, parser=canonical_material_code)] = Field(..., description="Product code.")
+    produto: Annotated[str, Column(3)] = Field(..., description="Product description.")
+    criacao: Annotated[Optional[date], Column(4)] = Field(
+        None, description="Order creation date."
+    )
+    prazo: Annotated[Optional[date], Column(5)] = Field(
+        None, description="Expected due or completion date."
+    )
+    quantidade: Annotated[int, Column(6)] = Field(
+        ..., description="Total quantity to produce."
+    )
+    peso: Annotated[Optional[float], Column(7)] = Field(
+        None, description="Total weight of the production order, None when blank."
+    )
+    etapa: Annotated[str, Column(8)] = Field(
+        ..., description="Current production stage."
+    )
 
 
-class PendingMaterialsItem(BaseModel):
+class PendingMaterialsItem(ReportRow):
     """
     Represents a pending materials report entry.
 
@@ -81,23 +136,45 @@ class PendingMaterialsItem(BaseModel):
     order, product, and expected dates.
     """
 
-    criacao: Optional[date] = Field(None, description="Creation date of the record.")
-    servico: str = Field(..., description="Service or department responsible.")
-    codigo: str = Field(..., description="Material code.")
-    material: str = Field(..., description="Material name or description.")
-    op: str = Field(..., description="Related production order number.")
-    produto: str = Field(..., description="Product related to the material.")
-    sub_produto: str = Field(..., description="Subproduct or component, if applicable.")
-    previsao_op: Optional[date] = Field(
+    model_config = STABLE_NAMES
+
+    criacao: Annotated[Optional[date], Column(0)] = Field(
+        None, description="Creation date of the record."
+    )
+    servico: Annotated[str, Column(1)] = Field(
+        ..., description="Service or department responsible."
+    )
+    codigo: Annotated[str, Column(2, parser=canonical_material_code)] = Field(..., description="Material code.")
+    material: Annotated[str, Column(3)] = Field(
+        ..., description="Material name or description."
+    )
+    op: Annotated[str, Column(4)] = Field(
+        ..., description="Related production order number."
+    )
+    produto: Annotated[str, Column(5)] = Field(
+        ..., description="Product related to the material."
+    )
+    sub_produto: Annotated[str, Column(6)] = Field(
+        ..., description="Subproduct or component, if applicable."
+    )
+    previsao_op: Annotated[Optional[date], Column(7)] = Field(
         None, description="Expected date for the production order."
     )
-    quantidade: float = Field(..., description="Total quantity required.")
-    pendente: float = Field(
+    quantidade: Annotated[float, Column(8, parser=parse_quantity)] = Field(
+        ..., description="Total quantity required."
+    )
+    pendente: Annotated[float, Column(9, parser=parse_quantity)] = Field(
         ..., description="Pending quantity still not received or produced."
     )
-    unidade: str = Field(..., description="Measurement unit (e.g., kg, pcs).")
-    situacao: str = Field(..., description="Current situation or status.")
-    previsao_mp: Optional[date] = Field(
+    unidade: Annotated[Union[Unit, str], Column(9, parser=parse_unit)] = Field(
+        ...,
+        union_mode="left_to_right",
+        description="Measurement unit (e.g., KG, PC), as free text when not a known unit.",
+    )
+    situacao: Annotated[str, Column(10)] = Field(
+        ..., description="Current situation or status."
+    )
+    previsao_mp: Annotated[Optional[date], Column(11)] = Field(
         None, description="Expected date for material availability."
     )
 
@@ -107,6 +184,8 @@ class FilteredSalesReportItem(BaseModel):
     Class representing a filtered sales report item.
     """
 
+    model_config = STABLE_NAMES
+
     negociacao: str = Field(..., description="Negotiation or deal identifier.")
     pedido_cliente: str = Field(..., description="Customer's order number.")
     op: str = Field(..., description="Production order code (OP).")
@@ -118,8 +197,26 @@ class FilteredSalesReportItem(BaseModel):
         None, description="Expected delivery or completion date."
     )
     qtde_pendente: int = Field(..., description="Pending quantity.")
-    valor_unitario: float = Field(..., description="Unit price of the item.")
+    valor_unitario: Money = Field(..., description="Unit price of the item.")
     ipi: Optional[float] = Field(None, description="IPI tax percentage, if applicable.")
-    valor_total: float = Field(..., description="Total value for the order line.")
+    valor_total: Money = Field(..., description="Total value for the order line.")
     etapa: str = Field(..., description="Current production stage.")
     materiais_pendentes: List[PendingMaterialsItem] = Field(default_factory=list, description="List of pending materials.")
+
+
+class DateRangeFilters(BaseModel):
+    """
+    Filters accepted by reports that are queried by a date range.
+
+    When omitted, the range defaults to the last 15 days and the next 90 days,
+    the same window used by the report routes.
+    """
+
+    init_date: Optional[date] = Field(None, description="Start date for the search range.")
+    end_date: Optional[date] = Field(None, description="End date for the search range.")
+
+
+class EmptyFilters(BaseModel):
+    """
+    Filters for reports that do not accept any parameter.
+    """
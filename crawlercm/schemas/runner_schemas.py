@@ -0,0 +1,246 @@
+"""
+Schemas used by the batch runner.
+
+These Pydantic models describe the configuration of a batch run (which
+reports to execute, with which filters and where to deliver them) and the
+consolidated summary produced once the run finishes.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, List, Literal, Optional, Union
+from pydantic import BaseModel, Field
+
+from schemas.dedup_schemas import DedupPolicy
+from schemas.export_schemas import CsvFormatting, TextEncoding, XlsxFormatting
+from schemas.notify_schemas import EmailDelivery
+from schemas.quality_schemas import QualityReport
+from schemas.validation_schemas import Severity
+
+WriteMode = Literal["merge", "append", "replace"]
+ErrorKind = Literal["auth", "portal", "parse", "snapshot", "error"]
+
+
+class DestinationConfig(BaseModel):
+    """
+    A destination with the shape and presentation expected by its consumer.
+    """
+
+    target: str = Field(..., description="File path, database or URL, as in ReportJob.destinations.")
+    columns: Optional[List[str]] = Field(
+        None, description="Fields to export, in order, by field or English name. Defaults to all."
+    )
+    rename: Dict[str, str] = Field(
+        default_factory=dict, description="Output header per field or English name."
+    )
+    header_language: Literal["en", "pt-BR"] = Field(
+        "en", description="Language of the headers that are not renamed."
+    )
+    mode: Optional[WriteMode] = Field(
+        None,
+        description="How database targets are written: 'merge' upserts by the report key, "
+        "'append' adds the rows with the run id and 'replace' truncates and loads the table. "
+        "Defaults to the write mode of the report.",
+    )
+    delta: bool = Field(
+        False,
+        description="Write only the rows added or changed since the previous stored run, "
+        "with their change type in a change_type column. Requires the snapshot store.",
+    )
+    delta_removed: bool = Field(
+        False, description="Also write, as 'removed', the rows that left the report (delta only)."
+    )
+    xlsx: Optional[XlsxFormatting] = Field(
+        None,
+        description="Presentation of .xlsx files: column formats, widths, autofilter and "
+        "frozen header. Defaults to the standard formatting.",
+    )
+    csv: Optional[CsvFormatting] = Field(
+        None,
+        description="Layout of CSV files and uploads: delimiter and decimal separator. "
+        "Use ';' and ',' for Excel in Brazilian Portuguese. Defaults to ',' and '.'.",
+    )
+    encoding: Optional[TextEncoding] = Field(
+        None,
+        description="Encoding of text files (CSV, JSON) and uploads: 'utf-8', 'utf-8-sig' "
+        "(with a BOM, the CSV default), 'iso-8859-1' or 'windows-1252'. Characters the "
+        "encoding cannot represent are written as '?'.",
+    )
+
+    @property
+    def reshapes(self) -> bool:
+        """
+        Whether the destination changes the default column shape.
+        """
+        return self.columns is not None or bool(self.rename) or self.header_language != "en"
+
+
+class ReportJob(BaseModel):
+    """
+    A single report to be executed in a batch run.
+    """
+
+    report: str = Field(..., description="Registered report name.")
+    filters: Dict[str, Any] = Field(
+        default_factory=dict, description="Filters passed to the report."
+    )
+    destinations: List[Union[str, DestinationConfig]] = Field(
+        default_factory=list,
+        description="Where the report rows are written, as targets or as destinations with "
+        "their column shape. Targets are file paths (.json, .ndjson, .csv, .xlsx, .parquet, "
+        ".pdf, .html, .sqlite), 'postgres' / 'mysql' / a database URL, a "
+        "gsheets://<spreadsheet id>/<tab> Google Sheets tab, 'gdrive' / a gdrive://<folder id> "
+        "Google Drive folder, 's3' / an s3://<bucket>/<prefix> object storage location, "
+        "'azure' / an azure://<container>/<prefix> Azure Blob Storage location, "
+        "'powerbi' / a powerbi://<dataset id>/<table> Power BI push dataset, "
+        "'kafka' / a kafka://<topic> Kafka topic, 'amqp' / an amqp:// RabbitMQ broker, "
+        "'mqtt' / an mqtt:// broker of the shop-floor displays, an "
+        "sftp:// / ftp:// / ftps:// remote directory or 'webhook' / an http(s):// webhook. "
+        "File paths may use run placeholders such as {report} and {date:%Y-%m-%d}.",
+    )
+    email: Optional[EmailDelivery] = Field(
+        None, description="E-mail delivery of the report rows to its recipients."
+    )
+    sort_by: Optional[List[str]] = Field(
+        None,
+        description="Sort keys of the rows, '-' prefixed for descending. Defaults to the report key.",
+    )
+    dedup: Optional[DedupPolicy] = Field(
+        None, description="Deduplication policy overriding the one of the report."
+    )
+    validation: Dict[str, Severity] = Field(
+        default_factory=dict,
+        description="Severity overrides per validation rule name (e.g. 'sane_date:previsao').",
+    )
+    stream: bool = Field(
+        False,
+        description="Write the rows to the destinations as the pages arrive, without holding the "
+        "report in memory. Only reports paged by the portal (pending_materials) arrive in "
+        "several pages; the others are written as a single page. Only files and databases can "
+        "be streamed; rows are written in fetch order, without deduplication, sorting, "
+        "validation or snapshots.",
+    )
+    timeout: Optional[float] = Field(
+        None,
+        gt=0,
+        description="Seconds the report may take to be fetched before it fails as timed out, "
+        "leaving the rest of the run unaffected. Defaults to the timeout of the run.",
+    )
+
+
+class WorkbookBundle(BaseModel):
+    """
+    A single .xlsx workbook combining several reports of a batch run.
+    """
+
+    target: str = Field(
+        ...,
+        description="Workbook file path. May use run placeholders such as {date:%Y-%m-%d}; "
+        "{report} is the bundle name.",
+    )
+    name: str = Field("bundle", description="Bundle name, used as {report} in the target.")
+    reports: Optional[List[str]] = Field(
+        None,
+        description="Reports included, one sheet each, in order. Defaults to the configured ones.",
+    )
+    sheet_names: Dict[str, str] = Field(
+        default_factory=dict, description="Sheet name per report. Defaults to the report name."
+    )
+    summary: bool = Field(True, description="Whether a summary sheet of the run comes first.")
+    header_language: Literal["en", "pt-BR"] = Field("en", description="Language of the headers.")
+    xlsx: Optional[XlsxFormatting] = Field(
+        None, description="Presentation of the report sheets. Defaults to the standard formatting."
+    )
+
+
+class RunConfig(BaseModel):
+    """
+    Configuration of a batch run.
+    """
+
+    reports: List[ReportJob] = Field(..., description="Reports to be executed.")
+    bundles: List[WorkbookBundle] = Field(
+        default_factory=list,
+        description="Workbooks combining the reports of the run, written after every report.",
+    )
+    manifest: Optional[str] = Field(
+        None,
+        description="File name of the manifest (report, rows, size, SHA-256) written to every "
+        "directory that received files, e.g. 'manifest_{run_started_at:%Y%m%dT%H%M%S}.json'. "
+        "No manifest is written when unset.",
+    )
+    dry_run: bool = Field(
+        False,
+        description="Log in and fetch every report, but write, upload and e-mail nothing; the "
+        "summary lists what would be delivered where.",
+    )
+    max_parallel: Optional[int] = Field(
+        None,
+        ge=1,
+        description="Maximum number of reports fetched at once; every independent report of a "
+        "stage at once when unset.",
+    )
+    timeout: Optional[float] = Field(
+        None,
+        gt=0,
+        description="Seconds every report of the run may take to be fetched, unless it has a "
+        "timeout of its own. Reports are not timed out when unset.",
+    )
+
+
+class ReportRunStatus(BaseModel):
+    """
+    Outcome of a single report within a batch run.
+    """
+
+    report: str = Field(..., description="Report name.")
+    status: Literal["success", "failed", "skipped"] = Field(
+        ..., description="Final status of the report."
+    )
+    row_count: int = Field(0, description="Number of rows delivered after validation.")
+    rejected_rows: int = Field(0, description="Number of rows rejected by validation.")
+    validation_warnings: int = Field(0, description="Number of validation warnings.")
+    parse_errors: int = Field(0, description="Number of cells that could not be parsed.")
+    duration_seconds: float = Field(0.0, description="Time spent fetching the report.")
+    destinations: List[str] = Field(
+        default_factory=list,
+        description="Destinations written successfully, or that a dry run would write.",
+    )
+    quality: Optional[QualityReport] = Field(
+        None, description="Data quality of the rows as fetched from the portal."
+    )
+    snapshot_id: Optional[str] = Field(
+        None, description="Snapshot the rows were stored as, when stored."
+    )
+    error: Optional[str] = Field(None, description="Error message, if any.")
+    error_kind: Optional[ErrorKind] = Field(
+        None,
+        description="Kind of the error: auth (CM rejected the login), portal (CM unavailable), "
+        "parse (a page could not be parsed), snapshot (the previous snapshot of a delta "
+        "destination could not be loaded) or error (anything else, such as a destination).",
+    )
+
+
+class RunSummary(BaseModel):
+    """
+    Consolidated summary of a batch run.
+    """
+
+    started_at: datetime = Field(..., description="When the run started.")
+    finished_at: datetime = Field(..., description="When the run finished.")
+    results: List[ReportRunStatus] = Field(
+        default_factory=list, description="Per-report status, in execution order."
+    )
+    bundles: List[str] = Field(
+        default_factory=list, description="Workbook bundles written, by file path."
+    )
+    manifests: List[str] = Field(
+        default_factory=list, description="Manifests written, by file path."
+    )
+    dry_run: bool = Field(False, description="Whether nothing was written (a dry run).")
+
+    @property
+    def succeeded(self) -> bool:
+        """
+        Whether every report of the run finished successfully.
+        """
+        return all(result.status == "success" for result in self.results)
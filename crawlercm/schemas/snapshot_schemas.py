@@ -0,0 +1,49 @@
+"""
+Schemas for persisted report snapshots.
+
+A snapshot is the full result of a report run, stored compressed on disk
+by `core.snapshot_store` so past runs can be listed, reloaded and compared.
+Snapshots record the version of the row schema they were written with, so
+rows can be migrated when the report models change.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from pydantic import BaseModel, Field
+
+from schemas.dataset_schemas import DatasetMetadata
+
+
+class SnapshotInfo(BaseModel):
+    """
+    Identification of a stored snapshot.
+    """
+
+    id: str = Field(..., description="Snapshot identifier, unique within the report.")
+    report: str = Field(..., description="Report name.")
+    created_at: datetime = Field(..., description="When the report run was stored.")
+    size_bytes: int = Field(..., description="Compressed size on disk.")
+
+
+class Snapshot(BaseModel):
+    """
+    A stored report run with its rows.
+    """
+
+    id: str = Field(..., description="Snapshot identifier, unique within the report.")
+    report: str = Field(..., description="Report name.")
+    created_at: datetime = Field(..., description="When the report run was stored.")
+    schema_version: int = Field(
+        1,
+        description="Version of the row schema the snapshot was written with. "
+        "Snapshots written before versioning are version 1.",
+    )
+    filters: Dict[str, Any] = Field(
+        default_factory=dict, description="Filters used in the report run."
+    )
+    metadata: Optional[DatasetMetadata] = Field(
+        None, description="Provenance of the rows, when stored from a dataset."
+    )
+    rows: List[Dict[str, Any]] = Field(
+        default_factory=list, description="Report rows, serialized in JSON mode."
+    )
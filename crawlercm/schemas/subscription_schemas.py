@@ -0,0 +1,83 @@
+"""
+Schemas of the webhook subscriptions to report changes.
+
+External systems subscribe an endpoint to the changes of a report, some of
+its rows and some kinds of change with a `SubscriptionRequest`. Whenever a
+scheduled refresh stores a run that differs from the previous one, every
+matching subscription receives a `ChangeNotification` with the `ChangeEvent`s
+it asked for (see `services.subscriptions`).
+"""
+
+from datetime import datetime
+from typing import Any, Dict, List, Literal, Optional
+from pydantic import BaseModel, Field
+
+EventType = Literal["added", "removed", "changed", "price_changed", "late"]
+EVENT_TYPES: List[EventType] = ["added", "removed", "changed", "price_changed", "late"]
+
+
+class SubscriptionRequest(BaseModel):
+    """
+    A subscription of an endpoint to the changes of a report.
+    """
+
+    url: str = Field(
+        ..., pattern=r"^https?://", description="http(s) endpoint receiving the POST requests."
+    )
+    report: str = Field(..., description="Registered report name.")
+    filter: Dict[str, Any] = Field(
+        default_factory=dict,
+        description="Values the rows must have, by field or English name, e.g. "
+        "{'fornecedor': 'ACME'}; compared as text, ignoring case. Every row when empty.",
+    )
+    events: List[EventType] = Field(
+        default_factory=lambda: list(EVENT_TYPES),
+        description="Kinds of change sent: added (new rows), removed, changed (other "
+        "fields), price_changed (the price field of the report) and late (rows past "
+        "their due date). Every kind by default.",
+    )
+    secret: Optional[str] = Field(
+        None,
+        description="Secret signing the requests, as the webhook destination "
+        "(X-Lanx-Signature). Requests are not signed when unset.",
+    )
+
+
+class Subscription(SubscriptionRequest):
+    """
+    A stored subscription.
+    """
+
+    id: str = Field(..., description="Subscription identifier.")
+    created_at: datetime = Field(..., description="When the subscription was created.")
+
+
+class ChangeEvent(BaseModel):
+    """
+    A change of a row between two runs of a report.
+    """
+
+    type: EventType = Field(..., description="Kind of the change.")
+    key: Dict[str, Any] = Field(..., description="Key values identifying the row.")
+    row: Dict[str, Any] = Field(
+        ..., description="Row in the new run; in the old run for removed rows."
+    )
+    old: Optional[Dict[str, Any]] = Field(
+        None, description="Row in the old run, for changed and price_changed events."
+    )
+    fields: List[str] = Field(
+        default_factory=list, description="Fields that changed, for changed events."
+    )
+
+
+class ChangeNotification(BaseModel):
+    """
+    Body POSTed to a subscription when a refresh changed its rows.
+    """
+
+    subscription: str = Field(..., description="Subscription identifier.")
+    report: str = Field(..., description="Report name.")
+    snapshot: str = Field(..., description="Snapshot of the new run.")
+    previous_snapshot: str = Field(..., description="Snapshot of the run compared with.")
+    detected_at: datetime = Field(..., description="When the changes were detected.")
+    events: List[ChangeEvent] = Field(..., description="Changes matching the subscription.")
@@ -0,0 +1,55 @@
+"""
+Schemas for row-level validation of report data.
+"""
+
+from enum import Enum
+from typing import Any, List, Optional
+from pydantic import BaseModel, Field
+
+
+class Severity(str, Enum):
+    """
+    What happens to a row that breaks a validation rule.
+
+    - ignore: the rule is not evaluated.
+    - warning: the row is kept and the issue is reported.
+    - error: the row is rejected and the issue is reported.
+    """
+
+    IGNORE = "ignore"
+    WARNING = "warning"
+    ERROR = "error"
+
+
+class ValidationIssue(BaseModel):
+    """
+    A rule broken by a row.
+    """
+
+    row_index: int = Field(..., description="Position of the row in the dataset.")
+    rule: str = Field(..., description="Name of the broken rule.")
+    field: Optional[str] = Field(None, description="Field checked by the rule.")
+    value: Any = Field(None, description="Offending value.")
+    message: str = Field(..., description="Human readable description of the issue.")
+    severity: Severity = Field(..., description="Severity of the issue.")
+    source: Optional[str] = Field(
+        None, description="Portal cell the value was parsed from (page, row and column)."
+    )
+
+
+class ValidationReport(BaseModel):
+    """
+    Outcome of validating a dataset.
+    """
+
+    total_rows: int = Field(..., description="Number of rows validated.")
+    rejected_rows: List[int] = Field(
+        default_factory=list, description="Positions of the rows rejected."
+    )
+    issues: List[ValidationIssue] = Field(
+        default_factory=list, description="Every issue found."
+    )
+
+    @property
+    def warnings(self) -> List[ValidationIssue]:
+        return [issue for issue in self.issues if issue.severity == Severity.WARNING]
@@ -0,0 +1,137 @@
+"""
+Group-by and aggregation over report rows.
+
+Simple KPIs (average price per category, total stock value per warehouse,
+pending lines per customer) are computed directly from the rows instead of
+exporting them to Excel first:
+
+    aggregate(
+        rows,
+        group_by=["cliente"],
+        metrics={
+            "linhas": ("count", None),
+            "valor_pendente": ("sum", "valor_total"),
+            "preco_medio": ("avg", "valor_unitario"),
+        },
+    )
+
+A metric field can also be a function of the row, for derived values such
+as quantity times price. Money values are aggregated as `Decimal`, so
+totals stay exact.
+"""
+
+from decimal import Decimal
+from typing import Any, Callable, Dict, Iterable, List, Literal, Optional, Sequence, Tuple, Union, get_args
+
+from pydantic import BaseModel
+
+from core.utils.records import value_of
+from core.utils.sorting import sort_rows
+from schemas.dataset_schemas import Dataset
+
+Operation = Literal["sum", "avg", "min", "max", "count"]
+MetricField = Optional[Union[str, Callable[[Any], Any]]]
+Metric = Tuple[Operation, MetricField]
+
+Row = Union[BaseModel, Dict[str, Any]]
+
+
+def _metric_value(row: Row, field: MetricField) -> Any:
+    if field is None:
+        return None
+    if callable(field):
+        return field(row)
+    return value_of(row, field)
+
+
+def check_operations(operations: Iterable[str]) -> None:
+    """
+    Ensure every aggregation operation is supported.
+
+    Args:
+        operations (Iterable[str]): Operation names.
+
+    Raises:
+        ValueError: If an operation is unknown.
+    """
+    unknown = sorted(set(operations) - set(get_args(Operation)))
+    if unknown:
+        raise ValueError(f"Unknown aggregation: {', '.join(unknown)}")
+
+
+def apply_operation(operation: Operation, values: List[Any]) -> Any:
+    """
+    Apply an aggregation operation to the non-missing values of a group.
+
+    Args:
+        operation (Operation): Aggregation operation.
+        values (List[Any]): Non-missing values of the group.
+
+    Returns:
+        Any: The aggregated value, or None when there are no values (except
+        for counts).
+
+    Raises:
+        ValueError: If the operation is unknown.
+    """
+    if operation == "count":
+        return len(values)
+    if not values:
+        return None
+    if operation == "sum":
+        return sum(values, Decimal("0") if isinstance(values[0], Decimal) else 0)
+    if operation == "avg":
+        total = sum(values, Decimal("0") if isinstance(values[0], Decimal) else 0)
+        return total / len(values)
+    if operation == "min":
+        return min(values)
+    if operation == "max":
+        return max(values)
+    raise ValueError(f"Unknown aggregation: {operation}")
+
+
+def aggregate(
+    rows: Union[Dataset, Iterable[Row]],
+    group_by: Sequence[str],
+    metrics: Dict[str, Metric],
+) -> List[Dict[str, Any]]:
+    """
+    Group rows and compute metrics for each group.
+
+    Missing values (None) are ignored by every operation; `count` with a
+    field counts the rows where it is present, and without a field counts
+    every row of the group.
+
+    Args:
+        rows (Union[Dataset, Iterable[Row]]): Dataset or rows to aggregate.
+        group_by (Sequence[str]): Fields defining the groups. An empty list
+            aggregates every row into a single group.
+        metrics (Dict[str, Metric]): Output name -> (operation, field), where
+            operation is one of sum, avg, min, max or count.
+
+    Returns:
+        List[Dict[str, Any]]: One dictionary per group with the group fields
+        and the metrics, ordered by the group fields.
+
+    Raises:
+        ValueError: If an operation is unknown.
+    """
+    check_operations(operation for operation, _ in metrics.values())
+    if isinstance(rows, Dataset):
+        rows = rows.rows
+    groups: Dict[tuple, List[Row]] = {}
+    for row in rows:
+        key = tuple(value_of(row, field) for field in group_by)
+        groups.setdefault(key, []).append(row)
+
+    results = []
+    for key, group in groups.items():
+        result: Dict[str, Any] = dict(zip(group_by, key))
+        for name, (operation, field) in metrics.items():
+            if operation == "count" and field is None:
+                result[name] = len(group)
+                continue
+            values = [value for row in group if (value := _metric_value(row, field)) is not None]
+            result[name] = apply_operation(operation, values)
+        results.append(result)
+    return sort_rows(results, list(group_by))
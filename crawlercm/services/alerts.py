@@ -0,0 +1,99 @@
+"""
+Purchasing alerts built on top of report snapshots.
+
+Consecutive runs of a report are compared with the diff engine and the
+rows whose price moved more than a given percentage are returned, which is
+the core of the daily purchasing alert.
+"""
+
+from typing import Any, Dict, Iterable, List, Optional, Sequence, Union
+
+from pydantic import BaseModel
+
+from core.snapshot_store import SnapshotStore
+from schemas.alert_schemas import PriceChange
+from schemas.snapshot_schemas import Snapshot
+from services.report_diff import diff
+
+Rows = Union[Snapshot, Iterable[Union[BaseModel, Dict[str, Any]]]]
+
+
+def _rows(source: Rows) -> Iterable[Union[BaseModel, Dict[str, Any]]]:
+    return source.rows if isinstance(source, Snapshot) else source
+
+
+def price_changes(
+    prev: Rows,
+    cur: Rows,
+    threshold_pct: float,
+    key_fields: Sequence[str] = ("codigo",),
+    price_field: str = "valor_unitario",
+) -> List[PriceChange]:
+    """
+    Find the rows whose price moved more than a percentage between two runs.
+
+    Args:
+        prev (Rows): Previous run, as a snapshot or a list of rows.
+        cur (Rows): Current run, as a snapshot or a list of rows.
+        threshold_pct (float): Minimum absolute percentage change to report.
+        key_fields (Sequence[str], optional): Fields identifying a material
+            (e.g. material code and supplier). Defaults to ("codigo",).
+        price_field (str, optional): Field holding the price. Defaults to "valor_unitario".
+
+    Returns:
+        List[PriceChange]: Price changes above the threshold, largest first.
+    """
+    changes: List[PriceChange] = []
+    for change in diff(_rows(prev), _rows(cur), key_fields).changed:
+        if price_field not in change.fields:
+            continue
+        old_price = float(change.old.get(price_field) or 0)
+        new_price = float(change.new.get(price_field) or 0)
+        change_pct: Optional[float] = None
+        if old_price:
+            change_pct = (new_price - old_price) / old_price * 100
+            if abs(change_pct) <= threshold_pct:
+                continue
+        changes.append(
+            PriceChange(
+                key=change.key,
+                old_price=old_price,
+                new_price=new_price,
+                change_pct=change_pct,
+                row=change.new,
+            )
+        )
+
+    changes.sort(
+        key=lambda c: float("inf") if c.change_pct is None else abs(c.change_pct),
+        reverse=True,
+    )
+    return changes
+
+
+def latest_price_changes(
+    store: SnapshotStore,
+    report: str,
+    threshold_pct: float,
+    key_fields: Sequence[str] = ("codigo",),
+    price_field: str = "valor_unitario",
+) -> List[PriceChange]:
+    """
+    Compare the two most recent snapshots of a report.
+
+    Args:
+        store (SnapshotStore): Store holding the report snapshots.
+        report (str): Report name.
+        threshold_pct (float): Minimum absolute percentage change to report.
+        key_fields (Sequence[str], optional): Fields identifying a material.
+        price_field (str, optional): Field holding the price.
+
+    Returns:
+        List[PriceChange]: Price changes above the threshold, or an empty list
+        when fewer than two snapshots are stored.
+    """
+    cur = store.latest(report)
+    prev = store.latest(report, offset=1)
+    if cur is None or prev is None:
+        return []
+    return price_changes(prev, cur, threshold_pct, key_fields, price_field)
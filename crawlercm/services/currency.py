@@ -0,0 +1,170 @@
+"""
+Conversion of foreign-currency prices into BRL.
+
+Some suppliers quote in USD or EUR. `convert_prices` enriches the rows of
+a dataset with the BRL value of their prices, keeping the original price
+and currency next to the converted value. Exchange rates come from a
+pluggable `RateSource`: `StaticRateSource` uses fixed rates (e.g. from the
+`CURRENCY_RATES` setting) and `PtaxRateSource` queries the PTAX rates
+published by Banco Central do Brasil.
+"""
+
+from datetime import date, timedelta
+from decimal import Decimal
+from typing import Any, Dict, Iterable, List, Optional, Protocol, Sequence, Union
+
+import aiohttp
+from pydantic import BaseModel
+
+from core.config import settings
+from core.logger import logger
+from core.utils.money import round_money
+from core.utils.parsers import parse_money, portal_today
+
+BRL = "BRL"
+
+
+class RateSource(Protocol):
+    """
+    Provider of exchange rates into BRL.
+    """
+
+    async def rate(self, currency: str, on: date) -> Decimal:
+        """
+        Value in BRL of one unit of a currency.
+
+        Args:
+            currency (str): ISO 4217 currency code (e.g. "USD").
+            on (date): Date of the quote.
+
+        Returns:
+            Decimal: BRL per unit of the currency.
+
+        Raises:
+            LookupError: If no rate is available.
+        """
+        ...
+
+
+class StaticRateSource:
+    """
+    Rate source backed by fixed rates.
+
+    Args:
+        rates (Optional[Dict[str, Union[float, Decimal]]]): BRL per unit of each
+            currency. Defaults to the `CURRENCY_RATES` setting.
+    """
+
+    def __init__(self, rates: Optional[Dict[str, Union[float, Decimal]]] = None):
+        rates = settings.CURRENCY_RATES if rates is None else rates
+        self.rates = {code.upper(): Decimal(str(value)) for code, value in rates.items()}
+
+    async def rate(self, currency: str, on: date) -> Decimal:
+        currency = currency.upper()
+        if currency not in self.rates:
+            raise LookupError(f"No exchange rate configured for {currency}")
+        return self.rates[currency]
+
+
+class PtaxRateSource:
+    """
+    Rate source using the PTAX selling rates of Banco Central do Brasil.
+
+    PTAX is not published on weekends and holidays, so the most recent quote
+    up to `max_days_back` days before the requested date is used. Rates are
+    cached per currency and date.
+
+    Args:
+        client (aiohttp.ClientSession): HTTP client session.
+        base_url (Optional[str]): PTAX OData endpoint. Defaults to the `PTAX_URL` setting.
+        max_days_back (int, optional): Days to look back for a quote. Defaults to 7.
+    """
+
+    def __init__(
+        self,
+        client: aiohttp.ClientSession,
+        base_url: Optional[str] = None,
+        max_days_back: int = 7,
+    ):
+        self.client = client
+        self.base_url = (base_url or settings.PTAX_URL).rstrip("/")
+        self.max_days_back = max_days_back
+        self._cache: Dict[tuple, Decimal] = {}
+
+    async def _quote(self, currency: str, on: date) -> Optional[Decimal]:
+        url = (
+            f"{self.base_url}/CotacaoMoedaDia(moeda=@moeda,dataCotacao=@dataCotacao)"
+            f"?@moeda='{currency}'&@dataCotacao='{on.strftime('%m-%d-%Y')}'&$format=json"
+        )
+        async with self.client.get(url) as response:
+            response.raise_for_status()
+            data = await response.json()
+        quotes = data.get("value", [])
+        if not quotes:
+            return None
+        return Decimal(str(quotes[-1]["cotacaoVenda"]))
+
+    async def rate(self, currency: str, on: date) -> Decimal:
+        currency = currency.upper()
+        if (currency, on) in self._cache:
+            return self._cache[(currency, on)]
+        for days_back in range(self.max_days_back + 1):
+            quote = await self._quote(currency, on - timedelta(days=days_back))
+            if quote is not None:
+                self._cache[(currency, on)] = quote
+                return quote
+        raise LookupError(f"No PTAX rate for {currency} in the {self.max_days_back} days before {on}")
+
+
+async def convert_prices(
+    rows: Iterable[Union[BaseModel, Dict[str, Any]]],
+    source: RateSource,
+    price_fields: Sequence[str] = ("valor_unitario",),
+    currency_field: str = "moeda",
+    on: Optional[date] = None,
+) -> List[Dict[str, Any]]:
+    """
+    Add the BRL value of the prices of each row.
+
+    For every price field a `<field>_brl` value is added, and the rate used
+    is stored in `taxa_cambio`; the original price and currency are kept
+    untouched. Rows without currency, or already in BRL, get a rate of 1.
+    Rows whose currency has no rate available get None as converted values.
+
+    Args:
+        rows (Iterable[Union[BaseModel, Dict[str, Any]]]): Rows to enrich.
+        source (RateSource): Provider of the exchange rates.
+        price_fields (Sequence[str], optional): Fields holding prices.
+            Defaults to ("valor_unitario",).
+        currency_field (str, optional): Field holding the currency code.
+            Defaults to "moeda".
+        on (Optional[date], optional): Date of the quotes. Defaults to today
+            in the portal timezone.
+
+    Returns:
+        List[Dict[str, Any]]: The enriched rows, in the original order.
+    """
+    on = on or portal_today()
+    rates: Dict[str, Optional[Decimal]] = {BRL: Decimal("1")}
+    enriched: List[Dict[str, Any]] = []
+    for row in rows:
+        values = row.model_dump() if isinstance(row, BaseModel) else dict(row)
+        currency = str(values.get(currency_field) or BRL).strip().upper()
+        if currency not in rates:
+            try:
+                rates[currency] = await source.rate(currency, on)
+            except LookupError as e:
+                logger.warning(f"Prices in {currency} not converted: {e}")
+                rates[currency] = None
+        rate = rates[currency]
+        values["taxa_cambio"] = rate
+        for price_field in price_fields:
+            price = values.get(price_field)
+            if price is None or rate is None:
+                values[f"{price_field}_brl"] = None
+                continue
+            if not isinstance(price, Decimal):
+                price = parse_money(price) if isinstance(price, str) else Decimal(str(price))
+            values[f"{price_field}_brl"] = round_money(price * rate)
+        enriched.append(values)
+    return enriched
@@ -0,0 +1,81 @@
+"""
+Deduplication of report rows by business key.
+
+Paginated grids of the portal sometimes return the same row twice across
+pages (e.g. the same material and supplier in the supplier price grid).
+`deduplicate` keeps a single row per key according to a `DedupPolicy`,
+preserving the fetch order of the rows that are kept.
+"""
+
+from typing import Any, Dict, List, Sequence, TypeVar, Union
+
+from pydantic import BaseModel
+
+from core.logger import logger
+from core.utils.records import value_of
+from schemas.dedup_schemas import DedupPolicy
+
+T = TypeVar("T", bound=Union[BaseModel, Dict[str, Any]])
+
+
+class DuplicateKeyError(ValueError):
+    """
+    Raised by the `error` policy when rows share the same key.
+    """
+
+    def __init__(self, keys: List[tuple]):
+        self.keys = keys
+        super().__init__(f"{len(keys)} duplicated key(s): {keys[:5]}")
+
+
+def deduplicate(
+    rows: Sequence[T],
+    key_fields: Sequence[str],
+    policy: DedupPolicy = DedupPolicy.KEEP_LATEST,
+    price_field: str = "valor_unitario",
+) -> List[T]:
+    """
+    Keep a single row per business key.
+
+    Args:
+        rows (Sequence[T]): Rows in fetch order.
+        key_fields (Sequence[str]): Fields forming the business key.
+        policy (DedupPolicy, optional): How duplicated rows are resolved.
+            Defaults to keeping the latest row.
+        price_field (str, optional): Field compared by the `keep_max_price`
+            policy. Defaults to "valor_unitario".
+
+    Returns:
+        List[T]: The rows without duplicates, in fetch order.
+
+    Raises:
+        ValueError: If no key field is given.
+        DuplicateKeyError: If the policy is `error` and duplicated keys exist.
+    """
+    if not key_fields:
+        raise ValueError("At least one key field is required")
+    kept: Dict[tuple, int] = {}
+    duplicated: List[tuple] = []
+    for index, row in enumerate(rows):
+        key = tuple(value_of(row, field) for field in key_fields)
+        if key not in kept:
+            kept[key] = index
+            continue
+        if key not in duplicated:
+            duplicated.append(key)
+        if policy == DedupPolicy.KEEP_LATEST:
+            kept[key] = index
+        elif policy == DedupPolicy.KEEP_MAX_PRICE:
+            price = value_of(row, price_field)
+            best = value_of(rows[kept[key]], price_field)
+            if price is not None and (best is None or price > best):
+                kept[key] = index
+
+    if duplicated:
+        if policy == DedupPolicy.ERROR:
+            raise DuplicateKeyError(duplicated)
+        logger.warning(
+            f"Removed {len(rows) - len(kept)} duplicated rows ({len(duplicated)} keys) "
+            f"with policy {policy.value}."
+        )
+    return [rows[index] for index in sorted(kept.values())]
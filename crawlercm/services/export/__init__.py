@@ -0,0 +1,3 @@
+"""
+Exporters writing datasets to files and external systems.
+"""
@@ -0,0 +1,143 @@
+"""
+RabbitMQ (AMQP 0-9-1) publisher sink.
+
+Publishes report rows to an exchange for the plants that consume the
+integration events through RabbitMQ. Every row (every diff event for
+delta destinations, see `services.export.delta`) is one persistent JSON
+message with the stable English field names, published with the routing
+key of its report:
+
+    exchange     lanx.reports (topic, durable)
+    routing key  lanx.pending_sales
+    headers      report, run_id, change_type (diff events only)
+    body         {"deal": "123", "production_order": "12345", ...}
+
+The routing key is a template with run placeholders (`{report}` by
+default, see `services.export.naming`), so consumers bind their queues to
+the reports they need (`lanx.#` for every report). The exchange is
+declared as a durable topic exchange when missing, and messages are
+published with publisher confirms, so a delivery only succeeds once the
+broker accepted every message. Messages no queue is bound to are dropped
+by the broker, as with any topic exchange.
+
+Requires the optional `amqp` dependencies (pika).
+"""
+
+import json
+from typing import Any, Dict, List, Optional, Tuple
+from urllib.parse import urlparse
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.columns import row_values
+from services.export.naming import render_template
+from services.export.sql_schema import run_id_of, table_layout
+
+
+def _pika() -> Any:
+    try:
+        import pika
+    except ImportError as e:
+        raise RuntimeError("The AMQP sink requires pika; install the 'amqp' extra") from e
+    return pika
+
+
+def amqp_messages(dataset: Dataset) -> List[Tuple[Dict[str, str], bytes]]:
+    """
+    Messages of a dataset, as their headers and JSON body.
+
+    Args:
+        dataset (Dataset): Dataset to publish.
+
+    Returns:
+        List[Tuple[Dict[str, str], bytes]]: The headers and body of every row.
+    """
+    fields, columns = table_layout(dataset)
+    names = [name for name, _ in columns]
+    headers = {"report": dataset.metadata.report, "run_id": run_id_of(dataset)}
+    messages = []
+    for row in dataset.rows:
+        value = dict(zip(names, row_values(row, fields)))
+        message_headers = headers
+        if "change_type" in value:
+            message_headers = {**headers, "change_type": str(value["change_type"])}
+        messages.append((message_headers, json.dumps(value, ensure_ascii=False).encode("utf-8")))
+    return messages
+
+
+class AMQPSink:
+    """
+    Publisher of dataset rows to a RabbitMQ exchange.
+
+    Args:
+        url (Optional[str], optional): Broker URL, as
+            `amqp(s)://user:password@host:port/vhost`. Defaults to `AMQP_URL`.
+        exchange (Optional[str], optional): Destination exchange. Defaults to
+            `AMQP_EXCHANGE`.
+        routing_key (Optional[str], optional): Routing key template. Defaults
+            to `AMQP_ROUTING_KEY`.
+
+    Raises:
+        ValueError: If no broker is configured.
+    """
+
+    def __init__(
+        self,
+        url: Optional[str] = None,
+        exchange: Optional[str] = None,
+        routing_key: Optional[str] = None,
+    ):
+        self.url = url or settings.AMQP_URL
+        if not self.url:
+            raise ValueError("No AMQP broker configured; set AMQP_URL")
+        self.exchange = exchange or settings.AMQP_EXCHANGE
+        self.routing_key = routing_key or settings.AMQP_ROUTING_KEY
+
+    def _endpoint(self) -> str:
+        url = urlparse(self.url)
+        return f"{url.scheme}://{url.hostname}{f':{url.port}' if url.port else ''}{url.path}"
+
+    def publish(self, dataset: Dataset) -> int:
+        """
+        Publish the rows of a dataset and wait for the broker confirms.
+
+        Args:
+            dataset (Dataset): Dataset to publish.
+
+        Returns:
+            int: Number of messages published.
+
+        Raises:
+            RuntimeError: If pika is not installed, or the broker rejects a
+                message.
+        """
+        pika = _pika()
+        messages = amqp_messages(dataset)
+        routing_key = render_template(self.routing_key, dataset)
+        connection = pika.BlockingConnection(pika.URLParameters(self.url))
+        try:
+            channel = connection.channel()
+            channel.exchange_declare(self.exchange, exchange_type="topic", durable=True)
+            channel.confirm_delivery()
+            for headers, body in messages:
+                properties = pika.BasicProperties(
+                    content_type="application/json",
+                    delivery_mode=2,
+                    headers=headers,
+                )
+                try:
+                    channel.basic_publish(self.exchange, routing_key, body, properties)
+                except pika.exceptions.NackError as e:
+                    raise RuntimeError(
+                        f"AMQP broker {self._endpoint()} rejected a message of "
+                        f"{dataset.metadata.report}: {e}"
+                    ) from e
+        finally:
+            connection.close()
+        logger.info(
+            f"Published {len(messages)} messages of {dataset.metadata.report} to AMQP exchange "
+            f"{self.exchange} with routing key {routing_key}."
+        )
+        return len(messages)
+
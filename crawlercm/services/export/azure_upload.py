@@ -0,0 +1,152 @@
+"""
+Azure Blob Storage destination.
+
+Uploads dataset exports to a blob container, with names built like the
+S3 destination from a path template and a file name made of the report
+and the run id:
+
+    {report}/{fetched_at:%Y/%m/%d}/  ->  pending_orders/2025/01/31/pending_orders_<run id>.json
+
+The template accepts the placeholders of `services.export.naming`.
+Requests are authorized with the SAS token of `AZURE_STORAGE_SAS_TOKEN`
+when configured, otherwise with the Azure identity of the host (managed
+identity, environment or CLI credentials, see `DefaultAzureCredential`).
+
+Requires the optional `azure` dependencies (azure-storage-blob, azure-identity).
+"""
+
+from typing import Any, Optional
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.compression import COMPRESSION_MIME_TYPES, Compression, compress
+from services.export.exporter import Exporter
+from services.export.files import MIME_TYPES, ExportFormat, exporter_for
+from services.export.s3_upload import render_prefix
+from services.export.sql_schema import run_id_of
+
+
+def _azure_blob() -> Any:
+    try:
+        from azure.storage import blob
+    except ImportError as e:
+        raise RuntimeError(
+            "Azure Blob upload requires azure-storage-blob; install the 'azure' extra"
+        ) from e
+    return blob
+
+
+def _credential(sas_token: Optional[str]) -> Any:
+    if sas_token:
+        return sas_token.lstrip("?")
+    try:
+        from azure.identity import DefaultAzureCredential
+    except ImportError as e:
+        raise RuntimeError(
+            "Azure Blob upload without a SAS token requires azure-identity; "
+            "install the 'azure' extra"
+        ) from e
+    return DefaultAzureCredential()
+
+
+class AzureBlobArchive:
+    """
+    Uploader of exports to an Azure Blob Storage container.
+
+    Args:
+        container (Optional[str], optional): Container name. Defaults to
+            `AZURE_STORAGE_CONTAINER`.
+        prefix (Optional[str], optional): Blob name prefix template.
+            Defaults to `AZURE_STORAGE_PREFIX`.
+        account_url (Optional[str], optional): Blob endpoint of the storage
+            account (`https://<account>.blob.core.windows.net`). Defaults to
+            `AZURE_STORAGE_ACCOUNT_URL`.
+        sas_token (Optional[str], optional): SAS token authorizing the
+            uploads. Defaults to `AZURE_STORAGE_SAS_TOKEN`; the host identity
+            is used when unset.
+
+    Raises:
+        ValueError: If no account or container is configured.
+    """
+
+    def __init__(
+        self,
+        container: Optional[str] = None,
+        prefix: Optional[str] = None,
+        account_url: Optional[str] = None,
+        sas_token: Optional[str] = None,
+    ):
+        self.container = container or settings.AZURE_STORAGE_CONTAINER
+        if not self.container:
+            raise ValueError("No Azure Blob container configured; set AZURE_STORAGE_CONTAINER")
+        self.account_url = account_url or settings.AZURE_STORAGE_ACCOUNT_URL
+        if not self.account_url:
+            raise ValueError("No Azure storage account configured; set AZURE_STORAGE_ACCOUNT_URL")
+        self.prefix = settings.AZURE_STORAGE_PREFIX if prefix is None else prefix
+        self.sas_token = sas_token or settings.AZURE_STORAGE_SAS_TOKEN
+
+    def put(self, name: str, content: bytes, content_type: str) -> str:
+        """
+        Upload a blob, replacing an existing one with the same name.
+
+        Args:
+            name (str): Blob name.
+            content (bytes): Blob content.
+            content_type (str): MIME type of the content.
+
+        Returns:
+            str: URL of the blob.
+
+        Raises:
+            RuntimeError: If the Azure SDK is not installed.
+        """
+        azure_blob = _azure_blob()
+        service = azure_blob.BlobServiceClient(
+            self.account_url, credential=_credential(self.sas_token)
+        )
+        service.get_blob_client(self.container, name).upload_blob(
+            content,
+            overwrite=True,
+            content_settings=azure_blob.ContentSettings(content_type=content_type),
+        )
+        return f"{self.account_url.rstrip('/')}/{self.container}/{name}"
+
+    def upload_export(
+        self,
+        dataset: Dataset,
+        file_format: ExportFormat = "json",
+        run_id: Optional[str] = None,
+        compression: Optional[Compression] = None,
+        exporter: Optional[Exporter] = None,
+    ) -> str:
+        """
+        Upload a dataset export.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+            file_format (ExportFormat, optional): Export format. Defaults to "json".
+            run_id (Optional[str], optional): Run identifier used in the name.
+                Defaults to the fetch timestamp.
+            compression (Optional[Compression], optional): "gzip" or "zip" to
+                compress the export. Defaults to no compression.
+            exporter (Optional[Exporter], optional): Exporter of the format,
+                with the options of the destination. Defaults to the exporter
+                of the format with its default options.
+
+        Returns:
+            str: URL of the blob.
+        """
+        run_id = run_id or run_id_of(dataset)
+        name, content = compress(
+            f"{dataset.metadata.report}_{run_id}.{file_format}",
+            (exporter or exporter_for(file_format)).render(dataset),
+            compression,
+        )
+        content_type = (
+            COMPRESSION_MIME_TYPES[compression] if compression else MIME_TYPES[file_format]
+        )
+        blob_name = f"{render_prefix(self.prefix, dataset, run_id)}{name}"
+        url = self.put(blob_name, content, content_type)
+        logger.info(f"Uploaded {len(dataset.rows)} rows of {dataset.metadata.report} to {url}.")
+        return url
@@ -0,0 +1,197 @@
+"""
+BigQuery load destination.
+
+Loads datasets into a BigQuery table per report for the long-term price
+history of the analytics team. The table schema is generated from the
+typed report model (see `services.export.sql_schema`), with the stable
+English column names and three columns added to every row:
+
+    run_id      STRING     identifier of the run the row was loaded by
+    run_date    DATE       portal date of the fetch; the table partition
+    loaded_at   TIMESTAMP  when the load job was started
+
+Tables are created on the first load, partitioned by day on `run_date`,
+and new columns are added when the report model grows. Loads append the
+rows to the table, or replace the partition of the run date, so a report
+fetched again on the same day overwrites the rows of that day only.
+
+Requests are authorized with the service account of
+`GOOGLE_SERVICE_ACCOUNT_FILE` when configured, otherwise with the
+application default credentials of the host.
+
+Requires the optional `bigquery` dependencies (google-cloud-bigquery).
+"""
+
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Literal, Optional
+
+from core.config import settings
+from core.logger import logger
+from core.utils.parsers import PORTAL_TZ
+from schemas.dataset_schemas import Dataset
+from services.export.columns import row_values
+from services.export.sql_schema import ColumnKind, check_identifier, run_id_of, table_layout
+
+LoadMode = Literal["append", "replace"]
+
+BIGQUERY_TYPES: Dict[ColumnKind, str] = {
+    "boolean": "BOOL",
+    "integer": "INT64",
+    "real": "FLOAT64",
+    "numeric": "NUMERIC",
+    "date": "DATE",
+    "timestamp": "TIMESTAMP",
+    "text": "STRING",
+}
+PARTITION_FIELD = "run_date"
+
+
+def _bigquery() -> Any:
+    try:
+        from google.cloud import bigquery
+    except ImportError as e:
+        raise RuntimeError(
+            "BigQuery load requires google-cloud-bigquery; install the 'bigquery' extra"
+        ) from e
+    return bigquery
+
+
+def bigquery_schema(dataset: Dataset) -> List[Any]:
+    """
+    Schema of the BigQuery table of a dataset.
+
+    Args:
+        dataset (Dataset): Dataset to load.
+
+    Returns:
+        List[Any]: The `SchemaField` of every column, followed by the run
+        columns.
+
+    Raises:
+        RuntimeError: If google-cloud-bigquery is not installed.
+    """
+    bigquery = _bigquery()
+    _, columns = table_layout(dataset)
+    return [
+        *(bigquery.SchemaField(name, BIGQUERY_TYPES[kind]) for name, kind in columns),
+        bigquery.SchemaField("run_id", "STRING", mode="REQUIRED"),
+        bigquery.SchemaField(PARTITION_FIELD, "DATE", mode="REQUIRED"),
+        bigquery.SchemaField("loaded_at", "TIMESTAMP", mode="REQUIRED"),
+    ]
+
+
+def bigquery_rows(dataset: Dataset, run_id: str, loaded_at: datetime) -> List[Dict[str, Any]]:
+    """
+    Rows of a dataset as the JSON objects of a load job.
+
+    Args:
+        dataset (Dataset): Dataset to load.
+        run_id (str): Identifier of the run.
+        loaded_at (datetime): When the load started.
+
+    Returns:
+        List[Dict[str, Any]]: One object per row, with the run columns.
+    """
+    fields, columns = table_layout(dataset)
+    names = [name for name, _ in columns]
+    run = {
+        "run_id": run_id,
+        PARTITION_FIELD: dataset.metadata.fetched_at.astimezone(PORTAL_TZ).date().isoformat(),
+        "loaded_at": loaded_at.isoformat(),
+    }
+    return [{**dict(zip(names, row_values(row, fields))), **run} for row in dataset.rows]
+
+
+class BigQueryLoad:
+    """
+    Loader of datasets into the BigQuery tables of a dataset.
+
+    Args:
+        dataset_id (Optional[str], optional): BigQuery dataset holding the
+            report tables. Defaults to `BIGQUERY_DATASET`.
+        project (Optional[str], optional): Google Cloud project. Defaults to
+            `BIGQUERY_PROJECT`, or the project of the credentials.
+        table (Optional[str], optional): Table name. Defaults to the report name.
+
+    Raises:
+        ValueError: If no dataset is configured or the table name is invalid.
+    """
+
+    def __init__(
+        self,
+        dataset_id: Optional[str] = None,
+        project: Optional[str] = None,
+        table: Optional[str] = None,
+    ):
+        self.dataset_id = dataset_id or settings.BIGQUERY_DATASET
+        if not self.dataset_id:
+            raise ValueError("No BigQuery dataset configured; set BIGQUERY_DATASET")
+        self.project = project or settings.BIGQUERY_PROJECT
+        self.table = check_identifier(table) if table else None
+
+    def client(self) -> Any:
+        """
+        BigQuery client with the configured credentials.
+
+        Raises:
+            RuntimeError: If google-cloud-bigquery is not installed.
+        """
+        bigquery = _bigquery()
+        if settings.GOOGLE_SERVICE_ACCOUNT_FILE:
+            return bigquery.Client.from_service_account_json(
+                settings.GOOGLE_SERVICE_ACCOUNT_FILE,
+                project=self.project,
+                location=settings.BIGQUERY_LOCATION,
+            )
+        return bigquery.Client(project=self.project, location=settings.BIGQUERY_LOCATION)
+
+    def load(
+        self, dataset: Dataset, mode: LoadMode = "append", run_id: Optional[str] = None
+    ) -> str:
+        """
+        Load a dataset into the table of its report and wait for the job.
+
+        Args:
+            dataset (Dataset): Dataset to load.
+            mode (LoadMode, optional): "append" to add the rows, "replace" to
+                replace the partition of the run date. Defaults to "append".
+            run_id (Optional[str], optional): Identifier of the run. Defaults
+                to the fetch timestamp of the dataset.
+
+        Returns:
+            str: Id of the table, as `project.dataset.table`.
+
+        Raises:
+            ValueError: If the mode is not supported or the report name is
+                not a valid table name.
+            RuntimeError: If google-cloud-bigquery is not installed.
+        """
+        if mode not in ("append", "replace"):
+            raise ValueError(f"BigQuery loads append or replace the run date partition, not {mode}")
+        bigquery = _bigquery()
+        client = self.client()
+        table = self.table or check_identifier(dataset.metadata.report)
+        table_id = f"{self.project or client.project}.{self.dataset_id}.{table}"
+        run_id = run_id or run_id_of(dataset)
+        rows = bigquery_rows(dataset, run_id, datetime.now(timezone.utc))
+        job_config = bigquery.LoadJobConfig(
+            schema=bigquery_schema(dataset),
+            source_format=bigquery.SourceFormat.NEWLINE_DELIMITED_JSON,
+            time_partitioning=bigquery.TimePartitioning(
+                type_=bigquery.TimePartitioningType.DAY, field=PARTITION_FIELD
+            ),
+            schema_update_options=[bigquery.SchemaUpdateOption.ALLOW_FIELD_ADDITION],
+        )
+        destination = table_id
+        if mode == "replace":
+            partition = dataset.metadata.fetched_at.astimezone(PORTAL_TZ).strftime("%Y%m%d")
+            destination = f"{table_id}${partition}"
+            job_config.write_disposition = bigquery.WriteDisposition.WRITE_TRUNCATE
+        else:
+            job_config.write_disposition = bigquery.WriteDisposition.WRITE_APPEND
+        client.load_table_from_json(rows, destination, job_config=job_config).result()
+        logger.info(
+            f"Loaded {len(rows)} rows of {dataset.metadata.report} into {table_id} ({mode}) "
+            f"as run {run_id}."
+        )
+        return table_id
@@ -0,0 +1,187 @@
+"""
+Workbook bundles of a batch run.
+
+Management receives the weekly pack as one .xlsx file: a summary sheet of
+the run (report, status, rows, fetch time, filters) followed by one sheet
+per report, written with the typed cells and formatting of
+`services.export.xlsx_export`. Reports that failed in the run are listed
+in the summary without a sheet.
+"""
+
+import json
+import re
+from io import BytesIO
+from pathlib import Path
+from typing import Dict, List, Mapping, Optional, Sequence
+
+import xlsxwriter
+
+from core.logger import logger
+from core.utils.parsers import PORTAL_TZ
+from schemas.dataset_schemas import Dataset
+from schemas.export_schemas import XlsxFormatting
+from schemas.runner_schemas import ReportRunStatus, WorkbookBundle
+from services.export.columns import HeaderLanguage
+from services.export.naming import template_values
+from services.export.xlsx_export import DATETIME_FORMAT, MAX_SHEET_NAME, write_sheet
+
+SUMMARY_HEADERS: Dict[HeaderLanguage, List[str]] = {
+    "en": ["Report", "Status", "Rows", "Fetched at", "Source", "Filters", "Error"],
+    "pt-BR": ["Relatório", "Situação", "Linhas", "Obtido em", "Origem", "Filtros", "Erro"],
+}
+SUMMARY_SHEET = {"en": "Summary", "pt-BR": "Resumo"}
+
+_INVALID_SHEET_CHARS = re.compile(r"[\[\]:*?/\\]")
+
+
+def sheet_name(name: str, taken: Sequence[str]) -> str:
+    """
+    A valid Excel sheet name, unique among the ones already taken.
+
+    Args:
+        name (str): Desired name.
+        taken (Sequence[str]): Names of the sheets already in the workbook.
+
+    Returns:
+        str: The name without the characters Excel rejects, truncated to
+        its limit and numbered when it is taken.
+    """
+    base = _INVALID_SHEET_CHARS.sub("_", name).strip("'")[:MAX_SHEET_NAME] or "Sheet"
+    lowered = {existing.lower() for existing in taken}
+    candidate, number = base, 2
+    while candidate.lower() in lowered:
+        suffix = f" ({number})"
+        candidate = f"{base[: MAX_SHEET_NAME - len(suffix)]}{suffix}"
+        number += 1
+    return candidate
+
+
+def _write_summary(
+    workbook: xlsxwriter.Workbook,
+    datasets: Sequence[Dataset],
+    statuses: Optional[Sequence[ReportRunStatus]],
+    header_language: HeaderLanguage,
+) -> None:
+    worksheet = workbook.add_worksheet(SUMMARY_SHEET[header_language])
+    header_format = workbook.add_format({"bold": True})
+    datetime_format = workbook.add_format({"num_format": DATETIME_FORMAT})
+    for col, header in enumerate(SUMMARY_HEADERS[header_language]):
+        worksheet.write_string(0, col, header, header_format)
+    by_report = {dataset.metadata.report: dataset for dataset in datasets}
+    if statuses is None:
+        statuses = [
+            ReportRunStatus(report=name, status="success", row_count=len(dataset.rows))
+            for name, dataset in by_report.items()
+        ]
+    for row, status in enumerate(statuses, start=1):
+        worksheet.write_string(row, 0, status.report)
+        worksheet.write_string(row, 1, status.status)
+        worksheet.write_number(row, 2, status.row_count)
+        dataset = by_report.get(status.report)
+        if dataset is not None:
+            metadata = dataset.metadata
+            fetched_at = metadata.fetched_at
+            if fetched_at.tzinfo is not None:
+                fetched_at = fetched_at.astimezone(PORTAL_TZ).replace(tzinfo=None)
+            worksheet.write_datetime(row, 3, fetched_at, datetime_format)
+            worksheet.write_string(row, 4, metadata.source_url or "")
+            worksheet.write_string(
+                row, 5, json.dumps(metadata.filters, ensure_ascii=False, default=str)
+            )
+        if status.error:
+            worksheet.write_string(row, 6, status.error)
+    worksheet.set_column(0, 0, 28)
+    worksheet.set_column(3, 3, 16)
+    worksheet.set_column(4, 6, 40)
+    worksheet.freeze_panes(1, 0)
+
+
+def export_workbook_bytes(
+    datasets: Sequence[Dataset],
+    statuses: Optional[Sequence[ReportRunStatus]] = None,
+    sheet_names: Optional[Mapping[str, str]] = None,
+    summary: bool = True,
+    header_language: HeaderLanguage = "en",
+    formatting: Optional[XlsxFormatting] = None,
+) -> bytes:
+    """
+    Render several datasets as one workbook, one sheet per report.
+
+    Args:
+        datasets (Sequence[Dataset]): Datasets to include, in sheet order.
+        statuses (Optional[Sequence[ReportRunStatus]], optional): Outcome of
+            the reports of the run, listed in the summary. Defaults to the
+            datasets, as successful.
+        sheet_names (Optional[Mapping[str, str]], optional): Sheet name per
+            report. Defaults to the report names.
+        summary (bool, optional): Whether a summary sheet comes first.
+            Defaults to True.
+        header_language (HeaderLanguage, optional): "en" or "pt-BR" headers.
+            Defaults to "en".
+        formatting (Optional[XlsxFormatting], optional): Presentation of the
+            report sheets. Defaults to `XlsxFormatting()`.
+
+    Returns:
+        bytes: The workbook content.
+    """
+    output = BytesIO()
+    workbook = xlsxwriter.Workbook(output, {"in_memory": True})
+    taken: List[str] = []
+    if summary:
+        _write_summary(workbook, datasets, statuses, header_language)
+        taken.append(SUMMARY_SHEET[header_language])
+    for dataset in datasets:
+        report = dataset.metadata.report
+        name = sheet_name((sheet_names or {}).get(report, report), taken)
+        taken.append(name)
+        write_sheet(workbook, dataset, name, header_language, formatting=formatting)
+    workbook.close()
+    return output.getvalue()
+
+
+def write_bundle(
+    bundle: WorkbookBundle,
+    datasets: Mapping[str, Dataset],
+    statuses: Optional[Sequence[ReportRunStatus]] = None,
+) -> Path:
+    """
+    Write the workbook of a bundle with the datasets of a run.
+
+    Args:
+        bundle (WorkbookBundle): Bundle configuration.
+        datasets (Mapping[str, Dataset]): Datasets of the run by report name.
+        statuses (Optional[Sequence[ReportRunStatus]], optional): Outcome of
+            the reports of the run.
+
+    Returns:
+        Path: The written file.
+
+    Raises:
+        ValueError: If none of the reports of the bundle has a dataset, or the
+            target template is invalid.
+    """
+    reports = bundle.reports if bundle.reports is not None else list(datasets)
+    included = [datasets[report] for report in reports if report in datasets]
+    if not included:
+        raise ValueError(f"No dataset available for bundle {bundle.name}")
+    if statuses is not None:
+        statuses = [status for status in statuses if status.report in reports]
+    values = template_values(included[0])
+    values.update(report=bundle.name, row_count=sum(len(dataset.rows) for dataset in included))
+    try:
+        path = Path(bundle.target.format_map(values))
+    except (KeyError, IndexError, ValueError) as e:
+        raise ValueError(f"Invalid template {bundle.target!r}: {e}") from e
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_bytes(
+        export_workbook_bytes(
+            included,
+            statuses,
+            bundle.sheet_names,
+            bundle.summary,
+            bundle.header_language,
+            bundle.xlsx,
+        )
+    )
+    logger.info(f"Wrote bundle {bundle.name} with {len(included)} reports to {path}.")
+    return path
@@ -0,0 +1,198 @@
+"""
+Column layout shared by the tabular exporters.
+
+Exporters write the fields of a dataset in declaration order, with their
+stable English names (or pt-BR labels) as headers. A column subset can be
+given by field name or English name. Values are converted to their JSON
+form (dates as ISO strings, money as numbers, enums by value and nested
+rows as JSON text), kept as Python values for formats with typed cells,
+or formatted as pt-BR text for printed and HTML reports.
+"""
+
+import json
+from datetime import date, datetime
+from decimal import Decimal
+from enum import Enum
+from typing import Any, Dict, List, Literal, Optional, Sequence, Tuple
+
+from pydantic import BaseModel
+
+from core.utils.field_names import PT_BR_LABELS, english_name
+from core.utils.records import field_name, value_of
+from schemas.dataset_schemas import Dataset
+from services.aggregation import aggregate
+
+HeaderLanguage = Literal["en", "pt-BR"]
+
+
+def header_of(name: str, language: HeaderLanguage = "en") -> str:
+    """
+    Header text of a field.
+
+    Args:
+        name (str): Field name.
+        language (HeaderLanguage, optional): "en" for the stable English name,
+            "pt-BR" for the pt-BR label. Defaults to "en".
+
+    Returns:
+        str: The header text.
+    """
+    english = english_name(name)
+    return PT_BR_LABELS.get(english, english) if language == "pt-BR" else english
+
+
+def select_columns(
+    dataset: Dataset,
+    columns: Optional[Sequence[str]] = None,
+    language: HeaderLanguage = "en",
+) -> List[Tuple[str, str]]:
+    """
+    Fields to export and their headers.
+
+    Args:
+        dataset (Dataset): Dataset being exported.
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order, by field name or English name. Defaults to every field.
+        language (HeaderLanguage, optional): Header language. Defaults to "en".
+
+    Returns:
+        List[Tuple[str, str]]: (field name, header) pairs.
+
+    Raises:
+        KeyError: If a requested column does not exist in the dataset.
+    """
+    available = dataset.field_names
+    if columns is None:
+        names = available
+    else:
+        names = [field_name(column) for column in columns]
+        unknown = [column for column, name in zip(columns, names) if name not in available]
+        if unknown and dataset.rows:
+            raise KeyError(f"Unknown columns for {dataset.metadata.report}: {', '.join(unknown)}")
+    return [(name, header_of(name, language)) for name in names]
+
+
+def _native(value: Any) -> Any:
+    if isinstance(value, Enum):
+        return value.value
+    if isinstance(value, (list, dict, BaseModel)):
+        return json.dumps(_jsonable(value), ensure_ascii=False)
+    return value
+
+
+def _jsonable(value: Any) -> Any:
+    if isinstance(value, BaseModel):
+        return value.model_dump(mode="json")
+    if isinstance(value, list):
+        return [_jsonable(item) for item in value]
+    return json.loads(json.dumps(value, default=str))
+
+
+def native_values(row: Any, fields: Sequence[str]) -> List[Any]:
+    """
+    Typed values of the given fields of a row, for formats with typed cells.
+
+    Numbers, dates and booleans are kept as Python values; enums are
+    replaced by their value and nested values by JSON text.
+
+    Args:
+        row (Any): Model or dictionary.
+        fields (Sequence[str]): Field names.
+
+    Returns:
+        List[Any]: One value per field; missing ones are None.
+    """
+    return [_native(value_of(row, name)) for name in fields]
+
+
+def _json_values(row: Any) -> Dict[str, Any]:
+    if isinstance(row, BaseModel):
+        return row.model_dump(mode="json")
+    return json.loads(json.dumps(dict(row), default=str))
+
+
+def row_values(row: Any, fields: Sequence[str]) -> List[Any]:
+    """
+    JSON-compatible values of the given fields of a row.
+
+    Args:
+        row (Any): Model or dictionary.
+        fields (Sequence[str]): Field names.
+
+    Returns:
+        List[Any]: One value per field; nested values are JSON text and
+        missing ones are None.
+    """
+    values = _json_values(row)
+    result = []
+    for name in fields:
+        value = values.get(name)
+        if isinstance(value, (list, dict)):
+            value = json.dumps(value, ensure_ascii=False)
+        result.append(value)
+    return result
+
+
+def _pt_br_number(value: float | Decimal, decimals: int) -> str:
+    text = f"{value:,.{decimals}f}"
+    return text.replace(",", "_").replace(".", ",").replace("_", ".")
+
+
+def display_value(value: Any) -> str:
+    """
+    pt-BR text of a value, as shown in printed and HTML reports.
+
+    Dates are written as dd/mm/yyyy, numbers with "." thousands and ","
+    decimal separators (two decimals for money and fractional values) and
+    missing values as empty text.
+
+    Args:
+        value (Any): Typed value.
+
+    Returns:
+        str: The display text.
+    """
+    value = _native(value)
+    if value is None:
+        return ""
+    if isinstance(value, bool):
+        return "Sim" if value else "Não"
+    if isinstance(value, datetime):
+        return value.strftime("%d/%m/%Y %H:%M")
+    if isinstance(value, date):
+        return value.strftime("%d/%m/%Y")
+    if isinstance(value, int):
+        return _pt_br_number(value, 0)
+    if isinstance(value, (float, Decimal)):
+        return _pt_br_number(value, 2)
+    return str(value)
+
+
+def display_values(row: Any, fields: Sequence[str]) -> List[str]:
+    """
+    pt-BR display texts of the given fields of a row.
+
+    Args:
+        row (Any): Model or dictionary.
+        fields (Sequence[str]): Field names.
+
+    Returns:
+        List[str]: One text per field; missing values are empty.
+    """
+    return [display_value(value_of(row, name)) for name in fields]
+
+
+def report_totals(dataset: Dataset, fields: Sequence[str]) -> Dict[str, Any]:
+    """
+    Sums of the given fields over the rows of a dataset, for totals lines.
+
+    Args:
+        dataset (Dataset): Dataset to total.
+        fields (Sequence[str]): Fields to sum.
+
+    Returns:
+        Dict[str, Any]: Total per field; None for fields without values.
+    """
+    if not fields:
+        return {}
+    return aggregate(dataset, [], {name: ("sum", name) for name in fields})[0]
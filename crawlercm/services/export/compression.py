@@ -0,0 +1,80 @@
+"""
+Compression of export artifacts.
+
+Large exports (the full materials report in particular) exceed e-mail
+attachment limits, so generated files can be compressed before they are
+uploaded or attached: `gzip` compresses each file on its own (`.csv` ->
+`.csv.gz`) and `zip` bundles one or more files, e.g. every report of an
+e-mail, into a single archive.
+"""
+
+import gzip
+import zipfile
+from io import BytesIO
+from typing import Literal, Optional, Sequence, Tuple
+
+Compression = Literal["gzip", "zip"]
+
+COMPRESSION_SUFFIXES = {"gzip": ".gz", "zip": ".zip"}
+
+COMPRESSION_MIME_TYPES = {"gzip": "application/gzip", "zip": "application/zip"}
+
+
+def check_compression(compression: Optional[str]) -> Optional[Compression]:
+    """
+    Ensure a compression name is supported.
+
+    Args:
+        compression (Optional[str]): "gzip", "zip" or None for no compression.
+
+    Returns:
+        Optional[Compression]: The compression.
+
+    Raises:
+        ValueError: If the compression is unknown.
+    """
+    if compression not in (None, *COMPRESSION_SUFFIXES):
+        raise ValueError(f"Unknown compression: {compression}")
+    return compression
+
+
+def zip_bundle(files: Sequence[Tuple[str, bytes]]) -> bytes:
+    """
+    Bundle files into a zip archive.
+
+    Args:
+        files (Sequence[Tuple[str, bytes]]): (file name, content) pairs.
+
+    Returns:
+        bytes: The archive content.
+    """
+    output = BytesIO()
+    with zipfile.ZipFile(output, "w", compression=zipfile.ZIP_DEFLATED) as archive:
+        for name, content in files:
+            archive.writestr(name, content)
+    return output.getvalue()
+
+
+def compress(name: str, content: bytes, compression: Optional[Compression]) -> Tuple[str, bytes]:
+    """
+    Compress a single file.
+
+    Args:
+        name (str): File name.
+        content (bytes): File content.
+        compression (Optional[Compression]): "gzip", "zip" or None.
+
+    Returns:
+        Tuple[str, bytes]: The compressed file name (with the compression
+        suffix appended) and content; the file as is when `compression` is None.
+
+    Raises:
+        ValueError: If the compression is unknown.
+    """
+    compression = check_compression(compression)
+    if compression is None:
+        return name, content
+    suffix = COMPRESSION_SUFFIXES[compression]
+    if compression == "gzip":
+        return f"{name}{suffix}", gzip.compress(content, mtime=0)
+    return f"{name}{suffix}", zip_bundle([(name, content)])
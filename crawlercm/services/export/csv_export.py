@@ -0,0 +1,180 @@
+"""
+CSV export of datasets.
+
+Writes any dataset as CSV, with configurable delimiter, decimal
+separator, header language and column subset, so reports can be dropped
+straight into the shared drive. Excel in Brazilian Portuguese opens files
+delimited by semicolons, with commas as decimal separators, as columns:
+
+    write_csv(dataset, stream, delimiter=";", decimal_separator=",")
+
+Large reports are written page by page by the `CsvPageWriter` of
+`CsvExporter.open_pages`, with the columns of the first page.
+"""
+
+import csv
+from dataclasses import dataclass
+from decimal import Decimal
+from pathlib import Path
+from typing import Any, BinaryIO, List, Optional, Sequence, TextIO
+
+from core.logger import logger
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from services.export.columns import (
+    HeaderLanguage,
+    native_values,
+    row_values,
+    select_columns,
+)
+from services.export.exporter import PageWriter, TextExporter, TextPageWriter
+
+
+def _write_rows(
+    writer: Any, rows: Sequence[Any], fields: Sequence[str], decimal_separator: str
+) -> None:
+    for row in rows:
+        values = row_values(row, fields)
+        if decimal_separator != ".":
+            values = [
+                str(value).replace(".", decimal_separator)
+                if value is not None and isinstance(native, (float, Decimal))
+                else value
+                for value, native in zip(values, native_values(row, fields))
+            ]
+        writer.writerow(["" if value is None else value for value in values])
+
+
+def write_csv(
+    dataset: Dataset,
+    stream: TextIO,
+    delimiter: str = ",",
+    header_language: HeaderLanguage = "en",
+    columns: Optional[Sequence[str]] = None,
+    decimal_separator: str = ".",
+) -> int:
+    """
+    Write a dataset as CSV to a text stream.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        stream (TextIO): Destination stream, opened with `newline=""`.
+        delimiter (str, optional): Field delimiter. Defaults to ",".
+        header_language (HeaderLanguage, optional): "en" or "pt-BR" headers.
+            Defaults to "en".
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        decimal_separator (str, optional): Decimal separator of fractional
+            numbers. Defaults to ".".
+
+    Returns:
+        int: Number of rows written.
+
+    Raises:
+        KeyError: If a requested column does not exist in the dataset.
+    """
+    layout = select_columns(dataset, columns, header_language)
+    fields = [name for name, _ in layout]
+    writer = csv.writer(stream, delimiter=delimiter)
+    writer.writerow([header for _, header in layout])
+    _write_rows(writer, dataset.rows, fields, decimal_separator)
+    return len(dataset.rows)
+
+
+def export_csv(
+    dataset: Dataset,
+    path: str | Path,
+    delimiter: str = ",",
+    header_language: HeaderLanguage = "en",
+    columns: Optional[Sequence[str]] = None,
+    decimal_separator: str = ".",
+) -> Path:
+    """
+    Write a dataset to a CSV file.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        path (str | Path): Destination file path; parent directories are created.
+        delimiter (str, optional): Field delimiter. Defaults to ",".
+        header_language (HeaderLanguage, optional): "en" or "pt-BR" headers.
+            Defaults to "en".
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        decimal_separator (str, optional): Decimal separator of fractional
+            numbers. Defaults to ".".
+
+    Returns:
+        Path: The written file.
+    """
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    with path.open("w", encoding="utf-8", newline="") as stream:
+        count = write_csv(
+            dataset, stream, delimiter, header_language, columns, decimal_separator
+        )
+    logger.info(f"Exported {count} rows of {dataset.metadata.report} to {path}.")
+    return path
+
+
+class CsvPageWriter(TextPageWriter):
+    """
+    CSV written page by page. The header holds the columns of the first
+    page, which every later page is written with.
+    """
+
+    def __init__(self, exporter: "CsvExporter", metadata: DatasetMetadata, stream: BinaryIO):
+        super().__init__(metadata, stream, exporter.encoding)
+        self.exporter = exporter
+        self.writer = csv.writer(self.text, delimiter=exporter.delimiter)
+        self.fields: Optional[List[str]] = None
+
+    def _header(self, rows: Sequence[Any]) -> None:
+        layout = select_columns(
+            Dataset(metadata=self.metadata, rows=list(rows)),
+            self.exporter.columns,
+            self.exporter.header_language,
+        )
+        self.fields = [name for name, _ in layout]
+        self.writer.writerow([header for _, header in layout])
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        if not rows:
+            return
+        if self.fields is None:
+            self._header(rows)
+        _write_rows(self.writer, rows, self.fields, self.exporter.decimal_separator)
+
+    def close(self) -> int:
+        if self.fields is None:
+            self._header([])
+        return super().close()
+
+
+@dataclass
+class CsvExporter(TextExporter):
+    """
+    CSV format, encoded as UTF-8 with a BOM by default so Excel detects
+    the encoding.
+    """
+
+    delimiter: str = ","
+    header_language: HeaderLanguage = "en"
+    columns: Optional[Sequence[str]] = None
+    encoding: str = "utf-8-sig"
+    decimal_separator: str = "."
+
+    format = "csv"
+    extension = ".csv"
+    media_type = "text/csv"
+
+    def write_text(self, dataset: Dataset, stream: TextIO) -> int:
+        return write_csv(
+            dataset,
+            stream,
+            self.delimiter,
+            self.header_language,
+            self.columns,
+            self.decimal_separator,
+        )
+
+    def open_pages(self, metadata: DatasetMetadata, stream: BinaryIO) -> PageWriter:
+        return CsvPageWriter(self, metadata, stream)
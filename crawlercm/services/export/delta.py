@@ -0,0 +1,86 @@
+"""
+Delta exports of a report run.
+
+Integrations that import every file they receive (such as the ERP
+integration folder) only need the rows that changed since the previous
+run. `delta_dataset` compares a run with the rows of the previous one
+using the diff engine and keeps the rows added or changed, marked in a
+`tipo_alteracao` (`change_type`) column placed first:
+
+    tipo_alteracao  op      codigo  ...
+    added           12345   MP-01   ...
+    changed         12001   MP-07   ...
+
+Rows that disappeared can be included as `removed`, with their values
+from the previous run. The surviving rows keep their typed values.
+"""
+
+from typing import Any, Dict, List, Literal, Optional, Sequence, Tuple
+
+from pydantic import BaseModel
+
+from core.utils.records import field_name
+from schemas.dataset_schemas import Dataset
+from services.report_diff import diff
+from services.report_registry import REPORTS
+
+ChangeType = Literal["added", "changed", "removed"]
+
+CHANGE_TYPE_FIELD = "tipo_alteracao"
+
+
+def _json_key(row: Any, key_fields: Sequence[str]) -> Tuple:
+    values = row.model_dump(mode="json") if isinstance(row, BaseModel) else dict(row)
+    return tuple(values[name] for name in key_fields)
+
+
+def delta_dataset(
+    dataset: Dataset,
+    previous: Sequence[Any],
+    key_fields: Optional[Sequence[str]] = None,
+    include_removed: bool = False,
+    ignore_fields: Sequence[str] = (),
+) -> Dataset:
+    """
+    Rows of a run that were added or changed since the previous run.
+
+    Args:
+        dataset (Dataset): Current run.
+        previous (Sequence[Any]): Rows of the previous run, as models or as
+            stored in its snapshot. Empty when there is no previous run, in
+            which case every row is added.
+        key_fields (Optional[Sequence[str]], optional): Fields identifying a
+            row. Defaults to the key of the report in the registry.
+        include_removed (bool, optional): Whether rows missing from the
+            current run are included as "removed". Defaults to False.
+        ignore_fields (Sequence[str], optional): Fields not considered when
+            detecting changes.
+
+    Returns:
+        Dataset: A dataset with the same metadata whose rows are dictionaries
+        of the change type followed by the row fields.
+
+    Raises:
+        ValueError: If the report has no key.
+        KeyError: If a row does not have one of the key fields.
+    """
+    if key_fields is None:
+        definition = REPORTS.get(dataset.metadata.report)
+        key_fields = definition.key_fields if definition else []
+    keys = [field_name(name) for name in key_fields]
+    changes = diff(previous, dataset.rows, keys, [field_name(name) for name in ignore_fields])
+    change_types: Dict[Tuple, ChangeType] = {
+        **{tuple(row[name] for name in keys): "added" for row in changes.added},
+        **{tuple(change.key[name] for name in keys): "changed" for change in changes.changed},
+    }
+    rows: List[Dict[str, Any]] = []
+    for row in dataset.rows:
+        change_type = change_types.get(_json_key(row, keys))
+        if change_type is not None:
+            values = dict(row.__dict__) if isinstance(row, BaseModel) else dict(row)
+            rows.append({CHANGE_TYPE_FIELD: change_type, **values})
+    if include_removed:
+        rows.extend({CHANGE_TYPE_FIELD: "removed", **row} for row in changes.removed)
+    return Dataset(
+        metadata=dataset.metadata.model_copy(update={"row_count": len(rows)}), rows=rows
+    )
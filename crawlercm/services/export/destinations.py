@@ -0,0 +1,554 @@
+"""
+Built-in destinations of the batch runner.
+
+Each destination writes a report to one kind of target, selected by its
+name or URL scheme in the `destinations` of a report (see
+`services.runner`):
+
+    postgres, postgres://user@host/db      PostgresDestination
+    s3://bucket/prefix?format=csv          S3Destination
+    https://host/path                      WebhookDestination
+    pedidos_{date:%Y-%m-%d}.csv.gz         FileDestination (any other target)
+
+Destinations registered by plugins (see `services.export.registry`) are
+selected first, so a plugin may replace a built-in destination.
+"""
+
+from dataclasses import replace
+from functools import partial
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Tuple
+from urllib.parse import parse_qs, parse_qsl, unquote, urlencode, urlparse
+
+from core.logger import logger
+from core.utils.format_excel import format_data_for_excel
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.runner_schemas import DestinationConfig
+from services.export.amqp_sink import AMQPSink
+from services.export.azure_upload import AzureBlobArchive
+from services.export.bigquery_load import BigQueryLoad
+from services.export.compression import COMPRESSION_SUFFIXES, compress
+from services.export.csv_export import CsvExporter
+from services.export.drive_upload import upload_to_drive
+from services.export.exporter import Exporter, PageWriter
+from services.export.files import exporter_for, format_of, with_encoding
+from services.export.influx_sink import InfluxSink
+from services.export.kafka_sink import KafkaSink
+from services.export.mqtt_sink import MQTTSink
+from services.export.mysql_sink import MySQLSink
+from services.export.naming import is_template, render_template, unique_path
+from services.export.postgres_sink import PostgresSink
+from services.export.powerbi_push import PowerBIPush
+from services.export.registry import Destination, destination_for
+from services.export.s3_upload import S3Archive
+from services.export.sftp_upload import upload_to_url
+from services.export.sheets_export import export_google_sheet
+from services.export.sqlite_sink import SQLiteSink
+from services.export.webhook import Webhook
+from services.export.xlsx_export import XlsxExporter
+
+UPLOAD_DESTINATIONS = (
+    "gdrive",
+    "s3",
+    "azure",
+    "powerbi",
+    "bigquery",
+    "influxdb",
+    "kafka",
+    "amqp",
+    "mqtt",
+    "webhook",
+)
+
+# Forms of every built-in destination, for the documentation of the CLI
+# (`lanx reports list`).
+DESTINATION_FORMS: Dict[str, str] = {
+    "file": "path such as pedidos_{date:%Y-%m-%d}.csv.gz, .sqlite or .db",
+    "postgres": "postgres, postgres://user@host/db",
+    "mysql": "mysql, mysql://user@host/db",
+    "sheets": "sheets://<spreadsheet id>/<tab>?mode=replace",
+    "gdrive": "gdrive, gdrive://<folder id>?format=xlsx",
+    "s3": "s3, s3://bucket/prefix?format=csv&compression=gzip",
+    "azure": "azure, azure://container/prefix?format=csv",
+    "powerbi": "powerbi, powerbi://<dataset id>/<table>, a push URL of api.powerbi.com",
+    "bigquery": "bigquery, bigquery://project/dataset/table?mode=append",
+    "influxdb": "influxdb, influxdb://bucket?measurement=m&tags=a,b&fields=c",
+    "kafka": "kafka, kafka://topic?key=a,b&schema=none",
+    "amqp": "amqp, amqp://host/vhost?exchange=e&routing_key=k",
+    "mqtt": "mqtt, mqtt://host:1883?topic=t&fields=a,b",
+    "sftp": "sftp://host/dir, ftp://host/dir, ftps://host/dir",
+    "webhook": "webhook, https://host/path",
+}
+
+
+def file_exporter(file_format: str, options: Optional[DestinationConfig] = None) -> Exporter:
+    """
+    Exporter of a destination file format, with the CSV layout, XLSX
+    formatting and encoding of the destination.
+    """
+    exporter = exporter_for(file_format)
+    if options is None:
+        return exporter
+    if options.xlsx is not None and isinstance(exporter, XlsxExporter):
+        exporter = replace(exporter, formatting=options.xlsx)
+    if options.csv is not None and isinstance(exporter, CsvExporter):
+        exporter = replace(
+            exporter,
+            delimiter=options.csv.delimiter,
+            decimal_separator=options.csv.decimal_separator,
+        )
+    return with_encoding(exporter, options.encoding)
+
+
+def _exporter_of(options: Optional[DestinationConfig]) -> Callable[[str], Exporter]:
+    return partial(file_exporter, options=options)
+
+
+def _mode(options: Optional[DestinationConfig]) -> Optional[str]:
+    return options.mode if options else None
+
+
+def _upload_query(target: str) -> Tuple[Optional[str], Optional[str]]:
+    query = parse_qs(urlparse(target).query)
+    return query.get("format", [None])[0], query.get("compression", [None])[0]
+
+
+class PostgresDestination(Destination):
+    """
+    Upserts the dataset into the PostgreSQL table of its report, or writes
+    it with `mode` (see `services.export.sql_sink`). Named `postgres`, or
+    given as a connection URL.
+    """
+
+    name = "postgres"
+    schemes = ("postgresql",)
+
+    def _sink(self, target: str) -> PostgresSink:
+        return PostgresSink(dsn=target if "://" in target else None)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        self._sink(target).write(dataset, mode=_mode(options))
+        return target
+
+    def open_pages(
+        self, metadata: DatasetMetadata, target: str, options: DestinationConfig
+    ) -> Tuple[str, PageWriter]:
+        return target, self._sink(target).open_pages(metadata, mode=options.mode)
+
+
+class MySQLDestination(Destination):
+    """
+    Upserts the dataset into the MySQL table of its report, or writes it
+    with `mode`. Named `mysql`, or given as a connection URL.
+    """
+
+    name = "mysql"
+
+    def _sink(self, target: str) -> MySQLSink:
+        return MySQLSink(dsn=target if "://" in target else None)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        self._sink(target).write(dataset, mode=_mode(options))
+        return target
+
+    def open_pages(
+        self, metadata: DatasetMetadata, target: str, options: DestinationConfig
+    ) -> Tuple[str, PageWriter]:
+        return target, self._sink(target).open_pages(metadata, mode=options.mode)
+
+
+class SheetsDestination(Destination):
+    """
+    Replaces the contents of a Google Sheets tab, given as
+    `gsheets://<spreadsheet id>/<tab>` (or `sheets://`), or appends to it
+    with `?mode=append`.
+    """
+
+    name = "gsheets"
+    schemes = ("sheets",)
+
+    def accepts(self, target: str) -> bool:
+        return "://" in target and super().accepts(target)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        mode = parse_qs(url.query).get("mode", ["replace"])[0]
+        export_google_sheet(dataset, url.netloc, unquote(url.path.strip("/")) or None, mode)
+        return target
+
+
+class DriveDestination(Destination):
+    """
+    Uploads an XLSX export to Google Drive (CSV with `?format=csv`,
+    compressed with `?compression=`). Named `gdrive`, or given as
+    `gdrive://<folder id>`.
+    """
+
+    name = "gdrive"
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        file_format, compression = _upload_query(target)
+        file_format = file_format or "xlsx"
+        upload_to_drive(
+            dataset,
+            urlparse(target).netloc or None,
+            file_format,
+            compression=compression,
+            exporter=file_exporter(file_format, options),
+        )
+        return target
+
+
+class S3Destination(Destination):
+    """
+    Uploads a JSON export to object storage (any file format with
+    `?format=`, compressed with `?compression=`). Named `s3`, or given as
+    `s3://<bucket>/<prefix template>`.
+    """
+
+    name = "s3"
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        file_format, compression = _upload_query(target)
+        file_format = file_format or "json"
+        prefix = unquote(url.path.strip("/")) if url.netloc else None
+        exporter = file_exporter(file_format, options)
+        S3Archive(url.netloc or None, prefix).upload_export(
+            dataset, file_format, compression=compression, exporter=exporter
+        )
+        return target
+
+
+class AzureDestination(Destination):
+    """
+    Uploads a JSON export to Azure Blob Storage (any file format with
+    `?format=`, compressed with `?compression=`). Named `azure`, or given as
+    `azure://<container>/<prefix template>`.
+    """
+
+    name = "azure"
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        file_format, compression = _upload_query(target)
+        file_format = file_format or "json"
+        prefix = unquote(url.path.strip("/")) if url.netloc else None
+        exporter = file_exporter(file_format, options)
+        AzureBlobArchive(url.netloc or None, prefix).upload_export(
+            dataset, file_format, compression=compression, exporter=exporter
+        )
+        return target
+
+
+class PowerBIDestination(Destination):
+    """
+    Appends the rows to Power BI, or replaces the rows of a push dataset
+    table with `?mode=replace`. Named `powerbi`, or given as
+    `powerbi://<dataset id>/<table>` or as the push URL of a streaming
+    dataset.
+    """
+
+    name = "powerbi"
+
+    def accepts(self, target: str) -> bool:
+        return target.startswith("https://api.powerbi.com/") or super().accepts(target)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        if url.scheme == "https":
+            PowerBIPush(push_url=target).push(dataset)
+            return target
+        mode = parse_qs(url.query).get("mode", ["append"])[0]
+        table = unquote(url.path.strip("/")) or None
+        PowerBIPush(url.netloc or None, table).push(dataset, mode)
+        return target
+
+
+class BigQueryDestination(Destination):
+    """
+    Loads the dataset into the BigQuery table of its report, partitioned by
+    run date, appending the rows or replacing the partition of the run date
+    with `?mode=replace` or `mode`. Named `bigquery`, or given as
+    `bigquery://<project>/<dataset>`, optionally followed by `/<table>`.
+    """
+
+    name = "bigquery"
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        path = [unquote(part) for part in url.path.split("/") if part] if url.netloc else []
+        mode = parse_qs(url.query).get("mode", [_mode(options) or "append"])[0]
+        BigQueryLoad(
+            path[0] if path else None, url.netloc or None, path[1] if len(path) > 1 else None
+        ).load(dataset, mode)
+        return target
+
+
+class InfluxDestination(Destination):
+    """
+    Writes the numeric fields of the rows as time-series points tagged by
+    the report key (`?tags=`, `?fields=` and `?measurement=` change the
+    points, see `services.export.influx_sink`). Named `influxdb`, or given
+    as `influxdb://<bucket>`.
+    """
+
+    name = "influxdb"
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        query = parse_qs(url.query)
+        tags, fields = (
+            query[name][0].split(",") if name in query else None for name in ("tags", "fields")
+        )
+        bucket = unquote(f"{url.netloc}{url.path}".rstrip("/")) if url.netloc else None
+        InfluxSink(bucket).write(dataset, tags, fields, query.get("measurement", [None])[0])
+        return target
+
+
+class KafkaDestination(Destination):
+    """
+    Publishes one message per row (per diff event for delta destinations)
+    keyed by the report key (`?key=` changes the key fields, `?schema=connect`
+    wraps the values in a Kafka Connect schema, see
+    `services.export.kafka_sink`). Named `kafka`, or given as
+    `kafka://<topic>`.
+    """
+
+    name = "kafka"
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        query = parse_qs(url.query, keep_blank_values=True)
+        key_fields = (
+            [name for name in query["key"][0].split(",") if name] if "key" in query else None
+        )
+        topic = unquote(f"{url.netloc}{url.path}".rstrip("/")) if url.netloc else None
+        KafkaSink(topic).publish(dataset, key_fields, query.get("schema", ["none"])[0])
+        return target
+
+
+class AMQPDestination(Destination):
+    """
+    Publishes one message per row to a RabbitMQ exchange, with the routing
+    key of the report (`?exchange=` and `?routing_key=` change them, see
+    `services.export.amqp_sink`). Named `amqp`, or given as the `amqp://` or
+    `amqps://` URL of the broker.
+    """
+
+    name = "amqp"
+    schemes = ("amqps",)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        query = parse_qsl(url.query, keep_blank_values=True)
+        routing = {name: value for name, value in query if name in ("exchange", "routing_key")}
+        broker = url._replace(
+            query=urlencode([(name, value) for name, value in query if name not in routing])
+        )
+        AMQPSink(
+            broker.geturl() if url.netloc else None,
+            routing.get("exchange"),
+            routing.get("routing_key"),
+        ).publish(dataset)
+        return target
+
+
+class MQTTDestination(Destination):
+    """
+    Publishes a compact JSON payload per row to a topic of its own, for the
+    shop-floor displays (`?topic=`, `?fields=` and `?retain=false` change
+    the messages, see `services.export.mqtt_sink`). Named `mqtt`, or given
+    as an `mqtt://` or `mqtts://` broker URL.
+    """
+
+    name = "mqtt"
+    schemes = ("mqtts",)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        url = urlparse(target)
+        query = parse_qs(url.query)
+        retain = query["retain"][0].lower() not in ("0", "false") if "retain" in query else None
+        MQTTSink(
+            url.hostname,
+            url.port,
+            url.scheme == "mqtts" if url.netloc else None,
+            query.get("topic", [None])[0],
+            retain,
+        ).publish(dataset, query["fields"][0].split(",") if "fields" in query else None)
+        return target
+
+
+class RemoteDirectoryDestination(Destination):
+    """
+    Uploads a CSV export to the `sftp://`, `ftp://` or `ftps://` URL of a
+    remote directory (`?format=`, `?name=<file name template>` and
+    `?compression=` select the file); the uploaded file URL is returned
+    without credentials.
+    """
+
+    name = "sftp"
+    schemes = ("ftp", "ftps")
+
+    def accepts(self, target: str) -> bool:
+        return "://" in target and super().accepts(target)
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        return upload_to_url(dataset, target, _exporter_of(options))
+
+
+class WebhookDestination(Destination):
+    """
+    Sends the dataset as signed JSON POST requests (see
+    `services.export.webhook`). Named `webhook`, or given as any `http://`
+    or `https://` URL no other destination accepts.
+    """
+
+    name = "webhook"
+    schemes = ("http", "https")
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        Webhook(target if "://" in target else None).send(dataset)
+        return target
+
+
+class FileDestination(Destination):
+    """
+    Writes the dataset to a file, with the exporter of its suffix (`.json`,
+    `.ndjson` or `.jsonl`, `.csv`, `.xlsx`, `.parquet`, `.pdf`, `.html`, see
+    `services.export.files`); any other path receives the dataset as JSON,
+    including its metadata envelope. The filtered sales report keeps the
+    layout of the legacy Excel formatter in `.xlsx` files unless its columns
+    were reshaped. Files ending in `.sqlite` or `.db` are SQLite databases
+    the dataset is appended to, or written with `mode`. Paths with a further
+    `.gz` or `.zip` suffix (e.g. `materials.csv.gz`) are written compressed.
+    Paths with placeholders (see `services.export.naming`, e.g.
+    `precos_{report}_{date}.xlsx`) are filled with the run metadata and
+    never overwrite an existing file.
+
+    Every target no other destination accepts is a file path.
+    """
+
+    name = "file"
+
+    def accepts(self, target: str) -> bool:
+        return True
+
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        path = Path(target)
+        if is_template(target):
+            path = unique_path(Path(render_template(target, dataset)))
+        path.parent.mkdir(parents=True, exist_ok=True)
+        compression = next(
+            (name for name, suffix in COMPRESSION_SUFFIXES.items() if path.suffix == suffix), None
+        )
+        if compression is not None:
+            file_format = format_of(path.stem)
+            if file_format is None:
+                raise ValueError(f"Unknown export format of {path.name}")
+            content = file_exporter(file_format, options).render(dataset)
+            path.write_bytes(compress(path.stem, content, compression)[1])
+            logger.info(
+                f"Exported {len(dataset.rows)} rows of {dataset.metadata.report} to {path}."
+            )
+        elif (
+            path.suffix == ".xlsx"
+            and dataset.metadata.report == "filtered_sales_report"
+            and not any(isinstance(row, dict) for row in dataset.rows)
+        ):
+            path.write_bytes(format_data_for_excel(dataset.rows))
+        elif path.suffix in (".sqlite", ".db"):
+            SQLiteSink(path).write(dataset, mode=_mode(options))
+        else:
+            file_exporter(format_of(path) or "json", options).export(dataset, path)
+        return str(path)
+
+    def open_pages(
+        self, metadata: DatasetMetadata, target: str, options: DestinationConfig
+    ) -> Tuple[str, PageWriter]:
+        """
+        Start a streamed file: CSV and NDJSON files and SQLite databases
+        receive every page as it arrives, files of the other formats are
+        written once the report is complete.
+        """
+        path = Path(target)
+        if is_template(target):
+            path = unique_path(Path(render_template(target, Dataset(metadata=metadata))))
+        if path.suffix in COMPRESSION_SUFFIXES.values():
+            raise ValueError("Compressed files cannot be streamed")
+        if path.suffix in (".sqlite", ".db"):
+            return str(path), SQLiteSink(path).open_pages(metadata, mode=options.mode)
+        writer = file_exporter(format_of(path) or "json", options).open_file(metadata, path)
+        return str(path), writer
+
+
+# Built-in destinations, in the order they are tried: the push URLs of
+# Power BI are `https://` URLs the webhooks would accept otherwise.
+BUILTIN_DESTINATIONS: List[Destination] = [
+    PostgresDestination(),
+    MySQLDestination(),
+    SheetsDestination(),
+    DriveDestination(),
+    S3Destination(),
+    AzureDestination(),
+    PowerBIDestination(),
+    BigQueryDestination(),
+    InfluxDestination(),
+    KafkaDestination(),
+    AMQPDestination(),
+    MQTTDestination(),
+    RemoteDirectoryDestination(),
+    WebhookDestination(),
+]
+
+FILE_DESTINATION = FileDestination()
+
+
+def destination_of(target: str) -> Destination:
+    """
+    Destination writing a configured target.
+
+    Args:
+        target (str): Destination as configured.
+
+    Returns:
+        Destination: The destination registered by a plugin for the target,
+        else the built-in destination accepting it, else `FILE_DESTINATION`.
+    """
+    plugin = destination_for(target)
+    if plugin is not None:
+        return plugin
+    return next(
+        (destination for destination in BUILTIN_DESTINATIONS if destination.accepts(target)),
+        FILE_DESTINATION,
+    )
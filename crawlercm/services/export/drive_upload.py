@@ -0,0 +1,145 @@
+"""
+Google Drive upload of dataset exports.
+
+Renders a dataset as CSV or XLSX and uploads it to a Drive folder shared
+with the service account, replacing the manual upload done after every
+export. Files are named after the report and the portal date of the
+fetch (e.g. `pending_orders_2025-01-31.xlsx`); uploading again on the
+same day replaces the content of the existing file, so the folder keeps
+one file per report and day.
+
+Requires the optional `drive` dependencies (google-api-python-client).
+"""
+
+from datetime import date
+from io import BytesIO
+from typing import Any, Literal, Optional, Tuple
+
+from core.config import settings
+from core.logger import logger
+from core.utils.parsers import PORTAL_TZ
+from schemas.dataset_schemas import Dataset
+from services.export.compression import COMPRESSION_MIME_TYPES, Compression, compress
+from services.export.exporter import Exporter
+from services.export.files import MIME_TYPES, exporter_for
+
+DriveFormat = Literal["csv", "xlsx"]
+
+DRIVE_SCOPES = ["https://www.googleapis.com/auth/drive"]
+
+
+def _drive_client(credentials_file: str) -> Tuple[Any, Any]:
+    try:
+        from google.oauth2 import service_account
+        from googleapiclient.discovery import build
+        from googleapiclient.http import MediaIoBaseUpload
+    except ImportError as e:
+        raise RuntimeError(
+            "Google Drive upload requires google-api-python-client; install the 'drive' extra"
+        ) from e
+    credentials = service_account.Credentials.from_service_account_file(
+        credentials_file, scopes=DRIVE_SCOPES
+    )
+    return build("drive", "v3", credentials=credentials, cache_discovery=False), MediaIoBaseUpload
+
+
+def drive_file_name(dataset: Dataset, file_format: DriveFormat, on: Optional[date] = None) -> str:
+    """
+    Date-based file name of an upload.
+
+    Args:
+        dataset (Dataset): Exported dataset.
+        file_format (DriveFormat): File format.
+        on (Optional[date], optional): Date in the name. Defaults to the portal
+            date of the fetch.
+
+    Returns:
+        str: The file name, e.g. `pending_orders_2025-01-31.xlsx`.
+    """
+    on = on or dataset.metadata.fetched_at.astimezone(PORTAL_TZ).date()
+    return f"{dataset.metadata.report}_{on.isoformat()}.{file_format}"
+
+
+def upload_to_drive(
+    dataset: Dataset,
+    folder_id: Optional[str] = None,
+    file_format: DriveFormat = "xlsx",
+    credentials_file: Optional[str] = None,
+    compression: Optional[Compression] = None,
+    exporter: Optional[Exporter] = None,
+) -> str:
+    """
+    Upload a dataset export to a Google Drive folder.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        folder_id (Optional[str], optional): Destination folder. Defaults to
+            `GOOGLE_DRIVE_FOLDER_ID` from settings.
+        file_format (DriveFormat, optional): "csv" or "xlsx". Defaults to "xlsx".
+        credentials_file (Optional[str], optional): Service account key file.
+            Defaults to `GOOGLE_SERVICE_ACCOUNT_FILE` from settings.
+        compression (Optional[Compression], optional): "gzip" or "zip" to
+            compress the file. Defaults to no compression.
+        exporter (Optional[Exporter], optional): Exporter of the format, with
+            the options of the destination. Defaults to the exporter of the
+            format with its default options.
+
+    Returns:
+        str: Id of the uploaded Drive file.
+
+    Raises:
+        ValueError: If the folder, the credentials or the format are not valid.
+        RuntimeError: If the Google API client is not installed.
+    """
+    folder_id = folder_id or settings.GOOGLE_DRIVE_FOLDER_ID
+    credentials_file = credentials_file or settings.GOOGLE_SERVICE_ACCOUNT_FILE
+    if not folder_id:
+        raise ValueError("No Google Drive folder configured; set GOOGLE_DRIVE_FOLDER_ID")
+    if not credentials_file:
+        raise ValueError(
+            "No Google service account configured; set GOOGLE_SERVICE_ACCOUNT_FILE"
+        )
+    if file_format not in ("csv", "xlsx"):
+        raise ValueError(f"Unknown Google Drive upload format: {file_format}")
+    name, content = compress(
+        drive_file_name(dataset, file_format),
+        (exporter or exporter_for(file_format)).render(dataset),
+        compression,
+    )
+    mimetype = COMPRESSION_MIME_TYPES[compression] if compression else MIME_TYPES[file_format]
+    service, media_upload = _drive_client(credentials_file)
+    media = media_upload(BytesIO(content), mimetype=mimetype, resumable=False)
+
+    escaped = name.replace("\\", "\\\\").replace("'", "\\'")
+    existing = (
+        service.files()
+        .list(
+            q=f"name = '{escaped}' and '{folder_id}' in parents and trashed = false",
+            fields="files(id)",
+            supportsAllDrives=True,
+            includeItemsFromAllDrives=True,
+        )
+        .execute()
+        .get("files", [])
+    )
+    if existing:
+        file_id = existing[0]["id"]
+        service.files().update(
+            fileId=file_id, media_body=media, supportsAllDrives=True
+        ).execute()
+    else:
+        file_id = (
+            service.files()
+            .create(
+                body={"name": name, "parents": [folder_id]},
+                media_body=media,
+                fields="id",
+                supportsAllDrives=True,
+            )
+            .execute()["id"]
+        )
+    logger.info(
+        f"Uploaded {len(dataset.rows)} rows of {dataset.metadata.report} to Google Drive "
+        f"as {name} ({file_id})."
+    )
+    return file_id
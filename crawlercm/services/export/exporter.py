@@ -0,0 +1,337 @@
+"""
+Common interface of the export formats.
+
+Every file format implements `Exporter.write`, which writes a dataset to
+a binary stream. Destinations (local files, object storage, e-mail
+attachments, HTTP responses) own where the bytes go and compose with any
+format through this interface, instead of each exporter handling files:
+
+    exporter = exporter_for("csv")             # services.export.files
+    exporter.write(dataset, response_stream)
+    exporter.export(dataset, "tmp/orders.csv")
+
+Text formats derive from `TextExporter` and write to a text stream in
+their encoding, which destinations can change (e.g. to ISO-8859-1 for
+legacy consumers) with `with_encoding`.
+
+Reports too large to hold in memory are exported page by page, as the
+pages arrive, through the `PageWriter` of `open_pages` or `open_file`:
+
+    writer = exporter.open_file(metadata, "tmp/ledger.csv")
+    for rows in pages:
+        writer.write_page(rows)
+    writer.close()
+
+CSV and NDJSON write every page straight to the stream; the other formats
+need the whole dataset and buffer the rows until the writer is closed.
+"""
+
+import copy
+from abc import ABC, abstractmethod
+from io import BytesIO, TextIOWrapper
+from pathlib import Path
+from typing import Any, BinaryIO, Iterable, List, Sequence, TextIO
+
+from core.logger import logger
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+
+
+class PageWriter(ABC):
+    """
+    Writer of an export that receives the rows page by page.
+
+    The export is finished by `close`, or discarded by `abort` when the
+    fetch fails; rows already written to a stream stay there.
+
+    Args:
+        metadata (DatasetMetadata): Metadata of the exported report.
+
+    Attributes:
+        row_count (int): Rows written so far.
+    """
+
+    def __init__(self, metadata: DatasetMetadata):
+        self.metadata = metadata
+        self.row_count = 0
+
+    def write_page(self, rows: Sequence[Any]) -> None:
+        """
+        Write the rows of a page.
+
+        Args:
+            rows (Sequence[Any]): Rows of the page, in order.
+        """
+        self._write_page(rows)
+        self.row_count += len(rows)
+
+    @abstractmethod
+    def _write_page(self, rows: Sequence[Any]) -> None: ...
+
+    def close(self) -> int:
+        """
+        Finish the export.
+
+        Returns:
+            int: Number of rows written.
+        """
+        return self.row_count
+
+    def abort(self) -> None:
+        """
+        Stop the export without finishing it.
+        """
+
+
+class BufferedPageWriter(PageWriter):
+    """
+    Page writer of the formats that need the whole dataset, writing it
+    on `close`.
+    """
+
+    def __init__(self, exporter: "Exporter", metadata: DatasetMetadata, stream: BinaryIO):
+        super().__init__(metadata)
+        self.exporter = exporter
+        self.stream = stream
+        self.rows: List[Any] = []
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        self.rows.extend(rows)
+
+    def close(self) -> int:
+        metadata = self.metadata.model_copy(update={"row_count": len(self.rows)})
+        return self.exporter.write(Dataset(metadata=metadata, rows=self.rows), self.stream)
+
+
+class TextPageWriter(PageWriter):
+    """
+    Page writer of a text format writing every page as it arrives.
+
+    Args:
+        metadata (DatasetMetadata): Metadata of the exported report.
+        stream (BinaryIO): Writable binary stream; it is left open.
+        encoding (str): Text encoding of the content.
+    """
+
+    def __init__(self, metadata: DatasetMetadata, stream: BinaryIO, encoding: str):
+        super().__init__(metadata)
+        self.text = text_stream(stream, encoding)
+
+    def close(self) -> int:
+        self.text.flush()
+        self.text.detach()
+        return self.row_count
+
+    def abort(self) -> None:
+        self.close()
+
+
+class FilePageWriter(PageWriter):
+    """
+    Page writer of an export file, closed with the export. Aborted exports
+    are deleted, so no partial file is left behind.
+    """
+
+    def __init__(self, writer: PageWriter, stream: BinaryIO, path: Path):
+        super().__init__(writer.metadata)
+        self.writer = writer
+        self.stream = stream
+        self.path = path
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        self.writer.write_page(rows)
+
+    def close(self) -> int:
+        try:
+            count = self.writer.close()
+        finally:
+            self.stream.close()
+        logger.info(f"Exported {count} rows of {self.metadata.report} to {self.path}.")
+        return count
+
+    def abort(self) -> None:
+        try:
+            self.writer.abort()
+        finally:
+            self.stream.close()
+            self.path.unlink(missing_ok=True)
+
+
+def text_stream(stream: BinaryIO, encoding: str) -> TextIOWrapper:
+    """
+    Text stream over a binary stream, writing characters the encoding
+    cannot represent as "?" for single-byte encodings.
+    """
+    errors = "strict" if encoding.startswith("utf") else "replace"
+    return TextIOWrapper(stream, encoding=encoding, errors=errors, newline="")
+
+
+class Exporter(ABC):
+    """
+    A file format datasets can be exported to.
+
+    Attributes:
+        format (str): Format name used in configuration (e.g. "csv").
+        extension (str): File extension, including the dot.
+        media_type (str): MIME type of the content.
+    """
+
+    format: str = ""
+    extension: str = ""
+    media_type: str = "application/octet-stream"
+
+    @abstractmethod
+    def write(self, dataset: Dataset, stream: BinaryIO) -> int:
+        """
+        Write a dataset to a binary stream.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+            stream (BinaryIO): Writable binary stream; it is left open.
+
+        Returns:
+            int: Number of rows written.
+        """
+
+    def render(self, dataset: Dataset) -> bytes:
+        """
+        Content of the export of a dataset.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+
+        Returns:
+            bytes: The file content.
+        """
+        output = BytesIO()
+        self.write(dataset, output)
+        return output.getvalue()
+
+    def export(self, dataset: Dataset, path: str | Path) -> Path:
+        """
+        Write the export of a dataset to a file.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+            path (str | Path): Destination file path; parent directories are created.
+
+        Returns:
+            Path: The written file.
+        """
+        path = Path(path)
+        path.parent.mkdir(parents=True, exist_ok=True)
+        with path.open("wb") as stream:
+            count = self.write(dataset, stream)
+        logger.info(f"Exported {count} rows of {dataset.metadata.report} to {path}.")
+        return path
+
+    def open_pages(self, metadata: DatasetMetadata, stream: BinaryIO) -> PageWriter:
+        """
+        Start an export written page by page.
+
+        Args:
+            metadata (DatasetMetadata): Metadata of the exported report.
+            stream (BinaryIO): Writable binary stream; it is left open.
+
+        Returns:
+            PageWriter: Writer of the pages; formats that need the whole
+            dataset write it when the writer is closed.
+        """
+        return BufferedPageWriter(self, metadata, stream)
+
+    def open_file(self, metadata: DatasetMetadata, path: str | Path) -> PageWriter:
+        """
+        Start an export file written page by page.
+
+        Args:
+            metadata (DatasetMetadata): Metadata of the exported report.
+            path (str | Path): Destination file path; parent directories are created.
+
+        Returns:
+            PageWriter: Writer of the pages, closing the file with the export.
+        """
+        path = Path(path)
+        path.parent.mkdir(parents=True, exist_ok=True)
+        stream = path.open("wb")
+        return FilePageWriter(self.open_pages(metadata, stream), stream, path)
+
+    def export_pages(
+        self, metadata: DatasetMetadata, pages: Iterable[Sequence[Any]], path: str | Path
+    ) -> Path:
+        """
+        Write the export of a report given page by page to a file.
+
+        Args:
+            metadata (DatasetMetadata): Metadata of the exported report.
+            pages (Iterable[Sequence[Any]]): Rows of the report, page by page
+                (e.g. `PagedDataset.iter_pages`).
+            path (str | Path): Destination file path; parent directories are created.
+
+        Returns:
+            Path: The written file.
+        """
+        writer = self.open_file(metadata, path)
+        try:
+            for rows in pages:
+                writer.write_page(rows)
+        except Exception:
+            writer.abort()
+            raise
+        writer.close()
+        return Path(path)
+
+
+class TextExporter(Exporter):
+    """
+    A text format, encoded on the way to the binary stream.
+
+    Characters that a single-byte encoding such as ISO-8859-1 cannot
+    represent are written as "?" instead of failing the export.
+
+    Attributes:
+        encoding (str): Text encoding of the content.
+        fixed_encoding (bool): Whether the format declares its encoding in
+            the content, so it cannot be changed.
+    """
+
+    encoding: str = "utf-8"
+    fixed_encoding: bool = False
+
+    def with_encoding(self, encoding: str) -> "TextExporter":
+        """
+        A copy of the exporter writing in another encoding.
+
+        Args:
+            encoding (str): Text encoding, e.g. "utf-8-sig" or "iso-8859-1".
+
+        Returns:
+            TextExporter: The exporter with the encoding.
+
+        Raises:
+            ValueError: If the format cannot be written in the encoding.
+        """
+        if self.fixed_encoding and encoding != self.encoding:
+            raise ValueError(f"{self.format} exports are always encoded as {self.encoding}")
+        exporter = copy.copy(self)
+        exporter.encoding = encoding
+        return exporter
+
+    @abstractmethod
+    def write_text(self, dataset: Dataset, stream: TextIO) -> int:
+        """
+        Write a dataset to a text stream.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+            stream (TextIO): Writable text stream, with newline translation disabled.
+
+        Returns:
+            int: Number of rows written.
+        """
+
+    def write(self, dataset: Dataset, stream: BinaryIO) -> int:
+        text = text_stream(stream, self.encoding)
+        try:
+            return self.write_text(dataset, text)
+        finally:
+            text.flush()
+            text.detach()
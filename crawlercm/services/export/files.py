@@ -0,0 +1,148 @@
+"""
+Export formats by name.
+
+Maps the format names used in configuration (`?format=csv`, e-mail
+attachments) and the file suffixes of destination paths to the
+`Exporter` of each format. Uploaders (Google Drive, object storage,
+e-mail) build the export content in memory with `render_export` instead
+of writing it to disk first. Formats outside this package are added with
+`register_exporter` (see `services.export.registry` for plugins).
+"""
+
+from pathlib import Path
+from typing import Dict, Literal, Optional, Sequence
+
+from schemas.dataset_schemas import Dataset
+from services.export.csv_export import CsvExporter
+from services.export.exporter import Exporter, TextExporter
+from services.export.html_export import HtmlExporter
+from services.export.json_export import JsonExporter, NdjsonExporter
+from services.export.parquet_export import ParquetExporter
+from services.export.pdf_export import PdfExporter
+from services.export.table_export import TableExporter
+from services.export.xlsx_export import XlsxExporter
+
+ExportFormat = Literal["json", "ndjson", "csv", "xlsx", "parquet", "pdf", "html", "table"]
+
+EXPORTERS: Dict[str, Exporter] = {
+    exporter.format: exporter
+    for exporter in (
+        JsonExporter(),
+        NdjsonExporter(),
+        CsvExporter(),
+        XlsxExporter(),
+        ParquetExporter(),
+        PdfExporter(),
+        HtmlExporter(),
+        TableExporter(),
+    )
+}
+
+MIME_TYPES = {name: exporter.media_type for name, exporter in EXPORTERS.items()}
+
+SUFFIX_ALIASES = {".jsonl": "ndjson"}
+
+
+def register_exporter(
+    exporter: Exporter, suffix_aliases: Sequence[str] = (), replace: bool = False
+) -> None:
+    """
+    Make an exporter available by its format name and file suffix.
+
+    Args:
+        exporter (Exporter): Exporter of the format, with its default options.
+        suffix_aliases (Sequence[str], optional): Further file suffixes of
+            the format (e.g. ".jsonl").
+        replace (bool, optional): Whether an exporter already registered for
+            the format is replaced. Defaults to False.
+
+    Raises:
+        ValueError: If the format is already registered and `replace` is not set.
+    """
+    if exporter.format in EXPORTERS and not replace:
+        raise ValueError(f"Export format {exporter.format} is already registered")
+    EXPORTERS[exporter.format] = exporter
+    MIME_TYPES[exporter.format] = exporter.media_type
+    for suffix in suffix_aliases:
+        SUFFIX_ALIASES[suffix.lower()] = exporter.format
+
+
+def exporter_for(file_format: str) -> Exporter:
+    """
+    Exporter of a format, with its default options.
+
+    Args:
+        file_format (str): Format name.
+
+    Returns:
+        Exporter: The exporter.
+
+    Raises:
+        ValueError: If the format is unknown.
+    """
+    try:
+        return EXPORTERS[file_format]
+    except KeyError:
+        raise ValueError(f"Unknown export format: {file_format}") from None
+
+
+def format_of(path: str | Path) -> Optional[str]:
+    """
+    Format of a file path, from its suffix.
+
+    Args:
+        path (str | Path): File path.
+
+    Returns:
+        Optional[str]: The format name, or None for unknown suffixes.
+    """
+    suffix = Path(path).suffix.lower()
+    if suffix in SUFFIX_ALIASES:
+        return SUFFIX_ALIASES[suffix]
+    return next((name for name, e in EXPORTERS.items() if e.extension == suffix), None)
+
+
+def with_encoding(exporter: Exporter, encoding: Optional[str]) -> Exporter:
+    """
+    An exporter writing text in the given encoding.
+
+    Args:
+        exporter (Exporter): Exporter of a format.
+        encoding (Optional[str]): Text encoding, or None to keep the default
+            of the format.
+
+    Returns:
+        Exporter: The exporter, with the encoding when one is given.
+
+    Raises:
+        ValueError: If the format is binary or cannot be written in the encoding.
+    """
+    if encoding is None:
+        return exporter
+    if not isinstance(exporter, TextExporter):
+        raise ValueError(f"{exporter.format} exports have no text encoding")
+    return exporter.with_encoding(encoding)
+
+
+def render_export(
+    dataset: Dataset, file_format: ExportFormat, encoding: Optional[str] = None
+) -> bytes:
+    """
+    Content of a dataset export file.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        file_format (ExportFormat): One of json, ndjson, csv, xlsx, parquet,
+            pdf, html or table.
+        encoding (Optional[str], optional): Encoding of text formats. Defaults
+            to UTF-8; CSV includes a BOM so Excel detects the encoding.
+
+    Returns:
+        bytes: The file content.
+
+    Raises:
+        ValueError: If the format is unknown, or binary while an encoding is given.
+        RuntimeError: If the format requires an optional dependency that is
+            not installed.
+    """
+    return with_encoding(exporter_for(file_format), encoding).render(dataset)
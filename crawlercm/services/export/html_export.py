@@ -0,0 +1,242 @@
+"""
+HTML rendering of datasets.
+
+Renders a dataset as an HTML page with a default styled table layout, or
+through a custom template. Templates use `string.Template` placeholders,
+all of them already escaped HTML:
+
+    $report       report name
+    $title        report description (or the given title)
+    $filters      filters of the run ("nenhum" when there are none)
+    $row_count    number of rows
+    $table        the rows as a styled <table>, with a totals line
+    $generated_at rendering timestamp
+    $body         free text (the e-mail body when used by `services.notify.email`)
+
+The table is styled inline, so the same markup survives e-mail clients
+that strip `<style>` blocks; values are formatted as pt-BR text.
+"""
+
+import html
+from dataclasses import dataclass
+from decimal import Decimal
+from pathlib import Path
+from string import Template
+from typing import Mapping, Optional, Sequence, TextIO
+
+from core.logger import logger
+from core.utils.parsers import portal_now
+from schemas.dataset_schemas import Dataset
+from services.export.columns import (
+    HeaderLanguage,
+    display_value,
+    display_values,
+    report_totals,
+    select_columns,
+)
+from services.export.exporter import TextExporter
+from services.report_registry import REPORTS
+
+_CELL = "border:1px solid #d0d7de;padding:4px 8px"
+_NUMERIC_CELL = f"{_CELL};text-align:right;white-space:nowrap"
+
+DEFAULT_TEMPLATE = """<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>$title</title>
+</head>
+<body style="font-family:Arial,Helvetica,sans-serif;color:#1f2328;margin:16px">
+<h1 style="font-size:20px;margin:0 0 8px">$title</h1>
+<p style="margin:0 0 4px">$body</p>
+<p style="margin:0 0 4px;color:#59636e">Filtros: $filters &middot; Linhas: $row_count</p>
+$table
+<p style="margin:12px 0 0;color:#59636e;font-size:11px">Gerado em $generated_at</p>
+</body>
+</html>
+"""
+
+
+def html_table(
+    dataset: Dataset,
+    columns: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "pt-BR",
+    totals: Optional[Sequence[str]] = None,
+) -> str:
+    """
+    Render the rows of a dataset as a styled HTML table.
+
+    Args:
+        dataset (Dataset): Dataset to render.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        header_language (HeaderLanguage, optional): Header language. Defaults to "pt-BR".
+        totals (Optional[Sequence[str]], optional): Fields summed in a totals
+            line. Defaults to no totals.
+
+    Returns:
+        str: The `<table>` element.
+    """
+    layout = select_columns(dataset, columns, header_language)
+    fields = [name for name, _ in layout]
+    numeric = {
+        name
+        for name in fields
+        if any(
+            isinstance(value, (int, float, Decimal)) and not isinstance(value, bool)
+            for value in dataset.column(name)
+        )
+    }
+
+    def cells(values: Sequence[str], tag: str = "td", bold: bool = False) -> str:
+        result = []
+        for name, value in zip(fields, values):
+            style = _NUMERIC_CELL if name in numeric else _CELL
+            text = f"<b>{html.escape(value)}</b>" if bold and value else html.escape(value)
+            result.append(f'<{tag} style="{style}">{text}</{tag}>')
+        return "".join(result)
+
+    header = "".join(
+        f'<th style="{_CELL};background:#f6f8fa;text-align:left">{html.escape(title)}</th>'
+        for _, title in layout
+    )
+    body = "".join(
+        f'<tr style="background:{"#ffffff" if i % 2 == 0 else "#f6f8fa"}">'
+        f"{cells(display_values(row, fields))}</tr>"
+        for i, row in enumerate(dataset.rows)
+    )
+    footer = ""
+    sums = report_totals(dataset, [name for name in totals or [] if name in fields])
+    if sums:
+        values = [display_value(sums[name]) if name in sums else "" for name in fields]
+        if fields and fields[0] not in sums:
+            values[0] = "Total"
+        footer = f"<tfoot><tr>{cells(values, bold=True)}</tr></tfoot>"
+    return (
+        '<table style="border-collapse:collapse;font-size:12px">'
+        f"<thead><tr>{header}</tr></thead><tbody>{body}</tbody>{footer}</table>"
+    )
+
+
+def render_html(
+    dataset: Dataset,
+    template: Optional[str | Path] = None,
+    title: Optional[str] = None,
+    columns: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "pt-BR",
+    totals: Optional[Sequence[str]] = None,
+    body: str = "",
+    values: Optional[Mapping[str, str]] = None,
+) -> str:
+    """
+    Render a dataset through an HTML template.
+
+    Args:
+        dataset (Dataset): Dataset to render.
+        template (Optional[str | Path], optional): Template text, or the path
+            of a template file. Defaults to `DEFAULT_TEMPLATE`.
+        title (Optional[str], optional): Title. Defaults to the description of
+            the report in the registry.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        header_language (HeaderLanguage, optional): Header language. Defaults to "pt-BR".
+        totals (Optional[Sequence[str]], optional): Fields summed in the
+            totals line. Defaults to the `total_fields` of the report.
+        body (str, optional): Plain text for the `$body` placeholder.
+        values (Optional[Mapping[str, str]], optional): Extra placeholders,
+            inserted as given (not escaped).
+
+    Returns:
+        str: The rendered HTML.
+
+    Raises:
+        ValueError: If the template uses an unknown placeholder.
+        OSError: If the template file cannot be read.
+    """
+    if isinstance(template, Path):
+        template = template.read_text(encoding="utf-8")
+    definition = REPORTS.get(dataset.metadata.report)
+    title = title or (definition.description if definition else dataset.metadata.report)
+    if totals is None:
+        totals = definition.total_fields if definition else []
+    filters = ", ".join(
+        f"{name}: {display_value(value)}"
+        for name, value in dataset.metadata.filters.items()
+        if value is not None
+    )
+    placeholders = {
+        "report": html.escape(dataset.metadata.report),
+        "title": html.escape(title),
+        "filters": html.escape(filters or "nenhum"),
+        "row_count": display_value(len(dataset.rows)),
+        "table": html_table(dataset, columns, header_language, totals),
+        "generated_at": display_value(portal_now()),
+        "body": html.escape(body),
+        **(values or {}),
+    }
+    try:
+        return Template(template or DEFAULT_TEMPLATE).substitute(placeholders)
+    except KeyError as e:
+        raise ValueError(f"Unknown placeholder in HTML template: {e}") from e
+    except ValueError as e:
+        raise ValueError(f"Invalid HTML template: {e}") from e
+
+
+def export_html(
+    dataset: Dataset,
+    path: str | Path,
+    template: Optional[str | Path] = None,
+    title: Optional[str] = None,
+    columns: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "pt-BR",
+) -> Path:
+    """
+    Write a dataset to an HTML file.
+
+    Args:
+        dataset (Dataset): Dataset to render.
+        path (str | Path): Destination file path; parent directories are created.
+        template (Optional[str | Path], optional): Template text, or the path
+            of a template file. Defaults to `DEFAULT_TEMPLATE`.
+        title (Optional[str], optional): Title. Defaults to the description of
+            the report in the registry.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        header_language (HeaderLanguage, optional): Header language. Defaults to "pt-BR".
+
+    Returns:
+        Path: The written file.
+
+    Raises:
+        ValueError: If the template uses an unknown placeholder.
+    """
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_text(
+        render_html(dataset, template, title, columns, header_language), encoding="utf-8"
+    )
+    logger.info(f"Exported {len(dataset.rows)} rows of {dataset.metadata.report} to {path}.")
+    return path
+
+
+@dataclass
+class HtmlExporter(TextExporter):
+    """
+    HTML page rendered from the default or a custom template.
+    """
+
+    template: Optional[str | Path] = None
+    title: Optional[str] = None
+    columns: Optional[Sequence[str]] = None
+    header_language: HeaderLanguage = "pt-BR"
+
+    format = "html"
+    extension = ".html"
+    media_type = "text/html"
+    fixed_encoding = True
+
+    def write_text(self, dataset: Dataset, stream: TextIO) -> int:
+        stream.write(
+            render_html(dataset, self.template, self.title, self.columns, self.header_language)
+        )
+        return len(dataset.rows)
@@ -0,0 +1,194 @@
+"""
+InfluxDB time-series sink.
+
+Writes the numeric fields of report rows as time-series points, so
+Grafana can chart the trend of prices per material and supplier or of the
+OEE per machine without an intermediate database. Every row becomes one
+point of the report measurement, tagged by the business key of the
+report and timestamped with the fetch time:
+
+    prices,material_code=MP-001,supplier=ACME unit_price=12.5,lead_time_days=7i 1738332000
+
+Tags and fields use the stable English field names. The fields are the
+numeric values of the row (booleans excluded) unless a field list is
+given, and missing values are skipped. Points are written through the v2
+write API (InfluxDB 2.x, 3.x and the 1.8 compatibility endpoint),
+authenticated with `INFLUXDB_TOKEN`.
+"""
+
+from decimal import Decimal
+from typing import Any, Dict, List, Optional, Sequence
+from urllib.error import HTTPError
+from urllib.parse import urlencode
+from urllib.request import Request, urlopen
+
+from core.config import settings
+from core.logger import logger
+from core.utils.field_names import english_name
+from core.utils.records import field_name
+from schemas.dataset_schemas import Dataset
+from services.export.columns import native_values, select_columns
+from services.report_registry import REPORTS
+
+MAX_POINTS_PER_REQUEST = 5_000
+TIMEOUT_SECONDS = 60
+
+_MEASUREMENT_ESCAPES = str.maketrans({",": r"\,", " ": r"\ "})
+_KEY_ESCAPES = str.maketrans({",": r"\,", "=": r"\=", " ": r"\ "})
+
+
+def _escape_key(value: str) -> str:
+    return value.replace("\\", "\\\\").translate(_KEY_ESCAPES)
+
+
+def _field_value(value: Any) -> Optional[str]:
+    if isinstance(value, bool) or value is None:
+        return None
+    if isinstance(value, int):
+        return f"{value}i"
+    if isinstance(value, (float, Decimal)):
+        return repr(float(value))
+    return None
+
+
+def line_protocol(
+    dataset: Dataset,
+    tags: Optional[Sequence[str]] = None,
+    fields: Optional[Sequence[str]] = None,
+    measurement: Optional[str] = None,
+) -> List[str]:
+    """
+    Points of a dataset in the InfluxDB line protocol.
+
+    Args:
+        dataset (Dataset): Dataset to write.
+        tags (Optional[Sequence[str]], optional): Fields written as tags, by
+            field name or English name. Defaults to the business key of the
+            report.
+        fields (Optional[Sequence[str]], optional): Fields written as point
+            fields. Defaults to every numeric field that is not a tag.
+        measurement (Optional[str], optional): Measurement name. Defaults to
+            the report name.
+
+    Returns:
+        List[str]: One line per row with at least one numeric value,
+        timestamped in seconds.
+    """
+    if tags is None:
+        definition = REPORTS.get(dataset.metadata.report)
+        tags = definition.key_fields if definition else []
+    tag_names = [field_name(tag) for tag in tags]
+    if fields is None:
+        field_names = [
+            name for name, _ in select_columns(dataset, None, "en") if name not in tag_names
+        ]
+    else:
+        field_names = [field_name(name) for name in fields]
+    prefix = (measurement or dataset.metadata.report).translate(_MEASUREMENT_ESCAPES)
+    timestamp = int(dataset.metadata.fetched_at.timestamp())
+    lines = []
+    for row in dataset.rows:
+        tag_set = "".join(
+            f",{_escape_key(english_name(name))}={_escape_key(str(value))}"
+            for name, value in zip(tag_names, native_values(row, tag_names))
+            if value is not None and str(value) != ""
+        )
+        field_set = ",".join(
+            f"{_escape_key(english_name(name))}={text}"
+            for name, value in zip(field_names, native_values(row, field_names))
+            if (text := _field_value(value)) is not None
+        )
+        if field_set:
+            lines.append(f"{prefix}{tag_set} {field_set} {timestamp}")
+    return lines
+
+
+class InfluxSink:
+    """
+    Writer of dataset points to an InfluxDB bucket.
+
+    Args:
+        bucket (Optional[str], optional): Destination bucket (or database
+            with its retention policy, `db/rp`, on InfluxDB 1.8). Defaults to
+            `INFLUXDB_BUCKET`.
+        url (Optional[str], optional): Base URL of the server. Defaults to
+            `INFLUXDB_URL`.
+        org (Optional[str], optional): Organization of the bucket. Defaults
+            to `INFLUXDB_ORG`.
+        token (Optional[str], optional): API token. Defaults to `INFLUXDB_TOKEN`.
+
+    Raises:
+        ValueError: If no server or bucket is configured.
+    """
+
+    def __init__(
+        self,
+        bucket: Optional[str] = None,
+        url: Optional[str] = None,
+        org: Optional[str] = None,
+        token: Optional[str] = None,
+    ):
+        self.url = (url or settings.INFLUXDB_URL or "").rstrip("/")
+        if not self.url:
+            raise ValueError("No InfluxDB server configured; set INFLUXDB_URL")
+        self.bucket = bucket or settings.INFLUXDB_BUCKET
+        if not self.bucket:
+            raise ValueError("No InfluxDB bucket configured; set INFLUXDB_BUCKET")
+        self.org = org or settings.INFLUXDB_ORG
+        self.token = token or settings.INFLUXDB_TOKEN
+
+    def write_url(self) -> str:
+        """
+        Endpoint of the v2 write API for the bucket, in seconds precision.
+        """
+        query: Dict[str, str] = {"bucket": self.bucket, "precision": "s"}
+        if self.org:
+            query["org"] = self.org
+        return f"{self.url}/api/v2/write?{urlencode(query)}"
+
+    def _post(self, lines: List[str]) -> None:
+        headers = {"Content-Type": "text/plain; charset=utf-8"}
+        if self.token:
+            headers["Authorization"] = f"Token {self.token}"
+        body = "\n".join(lines).encode("utf-8")
+        request = Request(self.write_url(), data=body, method="POST", headers=headers)
+        try:
+            with urlopen(request, timeout=TIMEOUT_SECONDS):
+                pass
+        except HTTPError as e:
+            detail = e.read().decode("utf-8", "replace")[:500]
+            raise RuntimeError(f"InfluxDB write to {self.bucket} failed: {e.code} {detail}") from e
+
+    def write(
+        self,
+        dataset: Dataset,
+        tags: Optional[Sequence[str]] = None,
+        fields: Optional[Sequence[str]] = None,
+        measurement: Optional[str] = None,
+    ) -> int:
+        """
+        Write the rows of a dataset as points.
+
+        Args:
+            dataset (Dataset): Dataset to write.
+            tags (Optional[Sequence[str]], optional): Fields written as tags.
+                Defaults to the business key of the report.
+            fields (Optional[Sequence[str]], optional): Fields written as
+                point fields. Defaults to every numeric field that is not a tag.
+            measurement (Optional[str], optional): Measurement name. Defaults
+                to the report name.
+
+        Returns:
+            int: Number of points written.
+
+        Raises:
+            RuntimeError: If InfluxDB rejects a write.
+        """
+        lines = line_protocol(dataset, tags, fields, measurement)
+        for start in range(0, len(lines), MAX_POINTS_PER_REQUEST):
+            self._post(lines[start : start + MAX_POINTS_PER_REQUEST])
+        logger.info(
+            f"Wrote {len(lines)} points of {dataset.metadata.report} to InfluxDB bucket "
+            f"{self.bucket}."
+        )
+        return len(lines)
@@ -0,0 +1,141 @@
+"""
+JSON and NDJSON export of datasets.
+
+`write_json` writes the dataset as a single JSON document with its
+metadata envelope and rows. `write_ndjson` writes one JSON object per
+line, each carrying the report provenance next to the row, for log
+pipelines and downstream scripts that process rows one at a time. Rows use
+the stable English field names of `core.utils.field_names`. NDJSON is
+also written page by page, as the pages of a large report arrive.
+"""
+
+import json
+from dataclasses import dataclass
+from decimal import Decimal
+from pathlib import Path
+from typing import Any, BinaryIO, Dict, Sequence, TextIO
+
+from pydantic import BaseModel
+
+from core.logger import logger
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from services.export.exporter import PageWriter, TextExporter, TextPageWriter
+
+_LINE_METADATA = {"report", "fetched_at", "source_url", "filters"}
+
+
+def _json_default(value: Any) -> Any:
+    return float(value) if isinstance(value, Decimal) else str(value)
+
+
+def row_json(row: Any) -> Dict[str, Any]:
+    """
+    JSON object of a row, keyed by its field names or aliases.
+    """
+    if isinstance(row, BaseModel):
+        return row.model_dump(mode="json", by_alias=True)
+    return json.loads(json.dumps(dict(row), default=_json_default))
+
+
+def write_json(dataset: Dataset, stream: TextIO, indent: int | None = 2) -> int:
+    """
+    Write a dataset as a JSON document with its metadata envelope.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        stream (TextIO): Destination stream.
+        indent (int | None, optional): Indentation; None for compact output.
+            Defaults to 2.
+
+    Returns:
+        int: Number of rows written.
+    """
+    payload = {
+        "metadata": dataset.metadata.model_dump(mode="json"),
+        "rows": [row_json(row) for row in dataset.rows],
+    }
+    json.dump(payload, stream, ensure_ascii=False, indent=indent)
+    return len(dataset.rows)
+
+
+def write_ndjson(dataset: Dataset, stream: TextIO) -> int:
+    """
+    Write a dataset as newline-delimited JSON, one row per line.
+
+    Every line is an object with the row provenance (`metadata`: report,
+    fetch time, source URL and filters) and the `row` itself.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        stream (TextIO): Destination stream.
+
+    Returns:
+        int: Number of rows written.
+    """
+    metadata = dataset.metadata.model_dump(mode="json", include=_LINE_METADATA)
+    for row in dataset.rows:
+        stream.write(json.dumps({"metadata": metadata, "row": row_json(row)}, ensure_ascii=False))
+        stream.write("\n")
+    return len(dataset.rows)
+
+
+def export_json(dataset: Dataset, path: str | Path, ndjson: bool = False) -> Path:
+    """
+    Write a dataset to a JSON or NDJSON file.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        path (str | Path): Destination file path; parent directories are created.
+        ndjson (bool, optional): Write newline-delimited JSON instead of a
+            single document. Defaults to False.
+
+    Returns:
+        Path: The written file.
+    """
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    with path.open("w", encoding="utf-8") as stream:
+        count = write_ndjson(dataset, stream) if ndjson else write_json(dataset, stream)
+    logger.info(f"Exported {count} rows of {dataset.metadata.report} to {path}.")
+    return path
+
+
+@dataclass
+class JsonExporter(TextExporter):
+    """
+    JSON document with the metadata envelope.
+    """
+
+    indent: int | None = 2
+
+    format = "json"
+    extension = ".json"
+    media_type = "application/json"
+
+    def write_text(self, dataset: Dataset, stream: TextIO) -> int:
+        return write_json(dataset, stream, self.indent)
+
+
+class NdjsonPageWriter(TextPageWriter):
+    """
+    NDJSON written page by page.
+    """
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        write_ndjson(Dataset(metadata=self.metadata, rows=list(rows)), self.text)
+
+
+class NdjsonExporter(TextExporter):
+    """
+    Newline-delimited JSON, one row per line.
+    """
+
+    format = "ndjson"
+    extension = ".ndjson"
+    media_type = "application/x-ndjson"
+
+    def write_text(self, dataset: Dataset, stream: TextIO) -> int:
+        return write_ndjson(dataset, stream)
+
+    def open_pages(self, metadata: DatasetMetadata, stream: BinaryIO) -> PageWriter:
+        return NdjsonPageWriter(metadata, stream, self.encoding)
@@ -0,0 +1,227 @@
+"""
+Kafka producer sink.
+
+Publishes report rows to a Kafka topic, one message per row, so the event
+pipeline consumes the portal data as it is fetched. Delta destinations
+(see `services.export.delta`) publish one message per diff event instead,
+with its `change_type` (added, changed or removed) in the value and in a
+header.
+
+Messages are keyed by the business key of the report, its values joined
+by `|` (e.g. `MP-001|ACME`), so the events of a row land in the same
+partition and compacted topics keep its latest state. Values are JSON
+objects with the stable English field names:
+
+    {"material_code": "MP-001", "supplier": "ACME", "unit_price": "12.50"}
+
+or, with the `connect` schema, the Kafka Connect JSON envelope whose
+schema is generated from the typed report model (see
+`services.export.sql_schema`), for the JDBC and object storage sink
+connectors:
+
+    {"schema": {"type": "struct", "name": "prices", "fields": [...]}, "payload": {...}}
+
+Every message carries the `report` and `run_id` headers. The producer is
+configured with `KAFKA_BOOTSTRAP_SERVERS` and the librdkafka properties
+of `KAFKA_PRODUCER_CONFIG` (security protocol, SASL credentials, ...).
+
+Requires the optional `kafka` dependencies (confluent-kafka).
+"""
+
+import json
+from typing import Any, Dict, List, Literal, Optional, Sequence
+
+from core.config import settings
+from core.logger import logger
+from core.utils.records import field_name
+from schemas.dataset_schemas import Dataset
+from services.export.columns import row_values
+from services.export.naming import render_template
+from services.export.sql_schema import ColumnKind, run_id_of, table_layout
+from services.report_registry import REPORTS
+
+ValueSchema = Literal["none", "connect"]
+
+CONNECT_TYPES: Dict[ColumnKind, str] = {
+    "boolean": "boolean",
+    "integer": "int64",
+    "real": "double",
+    "numeric": "string",
+    "date": "string",
+    "timestamp": "string",
+    "text": "string",
+}
+KEY_SEPARATOR = "|"
+
+
+def _kafka() -> Any:
+    try:
+        import confluent_kafka
+    except ImportError as e:
+        raise RuntimeError(
+            "The Kafka sink requires confluent-kafka; install the 'kafka' extra"
+        ) from e
+    return confluent_kafka
+
+
+def connect_schema(dataset: Dataset) -> Dict[str, Any]:
+    """
+    Kafka Connect schema of the messages of a dataset.
+
+    Args:
+        dataset (Dataset): Dataset to publish.
+
+    Returns:
+        Dict[str, Any]: A struct schema with one optional field per column.
+    """
+    _, columns = table_layout(dataset)
+    return {
+        "type": "struct",
+        "name": dataset.metadata.report,
+        "optional": False,
+        "fields": [
+            {"field": name, "type": CONNECT_TYPES[kind], "optional": True}
+            for name, kind in columns
+        ],
+    }
+
+
+def kafka_messages(
+    dataset: Dataset,
+    key_fields: Optional[Sequence[str]] = None,
+    schema: ValueSchema = "none",
+) -> List[Dict[str, Any]]:
+    """
+    Messages of a dataset, as the arguments of `Producer.produce`.
+
+    Args:
+        dataset (Dataset): Dataset to publish.
+        key_fields (Optional[Sequence[str]], optional): Fields of the message
+            key, by field name or English name; empty for messages without a
+            key. Defaults to the business key of the report.
+        schema (ValueSchema, optional): "none" for plain JSON values,
+            "connect" for the Kafka Connect JSON envelope. Defaults to "none".
+
+    Returns:
+        List[Dict[str, Any]]: The `key`, `value` and `headers` of every row.
+
+    Raises:
+        ValueError: If the schema is not supported.
+    """
+    if schema not in ("none", "connect"):
+        raise ValueError(f"Unknown Kafka value schema {schema}; use 'none' or 'connect'")
+    if key_fields is None:
+        definition = REPORTS.get(dataset.metadata.report)
+        key_fields = definition.key_fields if definition else []
+    key_names = [field_name(name) for name in key_fields]
+    fields, columns = table_layout(dataset)
+    names = [name for name, _ in columns]
+    envelope = connect_schema(dataset) if schema == "connect" else None
+    headers = [("report", dataset.metadata.report), ("run_id", run_id_of(dataset))]
+    messages = []
+    for row in dataset.rows:
+        value = dict(zip(names, row_values(row, fields)))
+        key = KEY_SEPARATOR.join(
+            "" if part is None else str(part) for part in row_values(row, key_names)
+        )
+        message_headers = headers
+        if "change_type" in value:
+            message_headers = [*headers, ("change_type", str(value["change_type"]))]
+        if envelope is not None:
+            value = {"schema": envelope, "payload": value}
+        messages.append(
+            {
+                "key": key.encode("utf-8") if key_names else None,
+                "value": json.dumps(value, ensure_ascii=False).encode("utf-8"),
+                "headers": message_headers,
+            }
+        )
+    return messages
+
+
+class KafkaSink:
+    """
+    Producer of dataset rows to a Kafka topic.
+
+    Args:
+        topic (Optional[str], optional): Destination topic; may use run
+            placeholders such as {report}. Defaults to `KAFKA_TOPIC`.
+        bootstrap_servers (Optional[str], optional): Brokers, comma
+            separated. Defaults to `KAFKA_BOOTSTRAP_SERVERS`.
+
+    Raises:
+        ValueError: If no brokers are configured.
+    """
+
+    def __init__(self, topic: Optional[str] = None, bootstrap_servers: Optional[str] = None):
+        self.topic = topic or settings.KAFKA_TOPIC
+        self.bootstrap_servers = bootstrap_servers or settings.KAFKA_BOOTSTRAP_SERVERS
+        if not self.bootstrap_servers:
+            raise ValueError("No Kafka brokers configured; set KAFKA_BOOTSTRAP_SERVERS")
+
+    def producer(self) -> Any:
+        """
+        Producer with the configured brokers and properties.
+
+        Raises:
+            RuntimeError: If confluent-kafka is not installed.
+        """
+        kafka = _kafka()
+        return kafka.Producer(
+            {**settings.KAFKA_PRODUCER_CONFIG, "bootstrap.servers": self.bootstrap_servers}
+        )
+
+    def publish(
+        self,
+        dataset: Dataset,
+        key_fields: Optional[Sequence[str]] = None,
+        schema: ValueSchema = "none",
+    ) -> int:
+        """
+        Publish the rows of a dataset and wait for their delivery.
+
+        Args:
+            dataset (Dataset): Dataset to publish.
+            key_fields (Optional[Sequence[str]], optional): Fields of the
+                message key. Defaults to the business key of the report.
+            schema (ValueSchema, optional): "none" for plain JSON values,
+                "connect" for the Kafka Connect JSON envelope. Defaults to "none".
+
+        Returns:
+            int: Number of messages published.
+
+        Raises:
+            ValueError: If the schema is not supported.
+            RuntimeError: If confluent-kafka is not installed, or messages
+                could not be delivered.
+        """
+        messages = kafka_messages(dataset, key_fields, schema)
+        topic = render_template(self.topic, dataset)
+        producer = self.producer()
+        errors: List[str] = []
+
+        def on_delivery(error: Any, _message: Any) -> None:
+            if error is not None:
+                errors.append(str(error))
+
+        for message in messages:
+            while True:
+                try:
+                    producer.produce(topic, on_delivery=on_delivery, **message)
+                    break
+                except BufferError:
+                    producer.poll(1)
+            producer.poll(0)
+        pending = producer.flush(settings.KAFKA_FLUSH_TIMEOUT_SECONDS)
+        if pending:
+            errors.append(f"{pending} messages still pending after the flush timeout")
+        if errors:
+            raise RuntimeError(
+                f"Kafka delivery of {dataset.metadata.report} to {topic} failed: {errors[0]} "
+                f"({len(errors)} errors)"
+            )
+        logger.info(
+            f"Published {len(messages)} messages of {dataset.metadata.report} to Kafka topic "
+            f"{topic}."
+        )
+        return len(messages)
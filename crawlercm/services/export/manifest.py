@@ -0,0 +1,102 @@
+"""
+Manifests of the files written by a batch run.
+
+Downstream loaders cannot tell a complete delivery from one still being
+written, or from a truncated file. Once every export of a run is written,
+a manifest is added to each directory that received files, listing them
+with their report, row count, size and SHA-256 checksum:
+
+    {"run_started_at": "...", "generated_at": "...", "files": [
+        {"file": "pending_sales.csv", "report": "pending_sales", "row_count": 120,
+         "size_bytes": 18342, "sha256": "9f2c...", "generated_at": "..."}]}
+
+Consumers wait for the manifest and verify the checksums before loading.
+"""
+
+import hashlib
+from datetime import datetime
+from pathlib import Path
+from typing import Dict, List, NamedTuple
+
+from core.logger import logger
+from core.utils.parsers import PORTAL_TZ, portal_now
+from schemas.export_schemas import Manifest, ManifestEntry
+
+
+class Artifact(NamedTuple):
+    """
+    A file written by a run, with what it holds.
+    """
+
+    path: Path
+    report: str
+    row_count: int
+
+
+def sha256_of(path: Path) -> str:
+    """
+    SHA-256 checksum of a file, in hex.
+    """
+    digest = hashlib.sha256()
+    with path.open("rb") as stream:
+        for chunk in iter(lambda: stream.read(1 << 20), b""):
+            digest.update(chunk)
+    return digest.hexdigest()
+
+
+def manifest_entry(artifact: Artifact) -> ManifestEntry:
+    """
+    Manifest entry of a written file.
+
+    Args:
+        artifact (Artifact): The file and what it holds.
+
+    Returns:
+        ManifestEntry: The entry, with the current size and checksum of the file.
+    """
+    stat = artifact.path.stat()
+    return ManifestEntry(
+        file=artifact.path.name,
+        report=artifact.report,
+        row_count=artifact.row_count,
+        size_bytes=stat.st_size,
+        sha256=sha256_of(artifact.path),
+        generated_at=datetime.fromtimestamp(stat.st_mtime, PORTAL_TZ),
+    )
+
+
+def write_manifests(artifacts: List[Artifact], name: str, run_started_at: datetime) -> List[Path]:
+    """
+    Write a manifest to every directory holding files of a run.
+
+    Args:
+        artifacts (List[Artifact]): Files written by the run.
+        name (str): File name of the manifests, filled with `run_started_at`
+            (e.g. "manifest_{run_started_at:%Y%m%dT%H%M%S}.json").
+        run_started_at (datetime): When the run started.
+
+    Returns:
+        List[Path]: The manifests written.
+
+    Raises:
+        ValueError: If the name template is invalid.
+    """
+    try:
+        file_name = name.format(run_started_at=run_started_at)
+    except (KeyError, IndexError, ValueError) as e:
+        raise ValueError(f"Invalid manifest name {name!r}: {e}") from e
+    by_directory: Dict[Path, List[Artifact]] = {}
+    for artifact in artifacts:
+        by_directory.setdefault(artifact.path.parent, []).append(artifact)
+    written = []
+    for directory, files in by_directory.items():
+        manifest = Manifest(
+            run_started_at=run_started_at,
+            generated_at=portal_now(),
+            files=[manifest_entry(artifact) for artifact in files],
+        )
+        path = directory / file_name
+        path.write_text(manifest.model_dump_json(indent=2), encoding="utf-8")
+        logger.info(f"Wrote manifest of {len(files)} files to {path}.")
+        written.append(path)
+    return written
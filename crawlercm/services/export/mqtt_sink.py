@@ -0,0 +1,179 @@
+"""
+MQTT publishing for shop-floor displays.
+
+Publishes one compact JSON payload per row to a topic of its own, for the
+Raspberry Pi displays of the factory floor that subscribe to the machines
+or materials they show (current OP per machine, stock alerts, ...):
+
+    topic    lanx/pending_orders/MAQ-03
+    payload  {"production_order":"12345","material_code":"MP-001","ts":1738332000}
+
+Topics are templates filled with the report name, the business key of
+the row (`{key}`, its values joined by `-`) and the fields of the row, by
+field name or English name (e.g. `factory/{maquina}/op`); characters with a meaning in MQTT
+topics (`/`, `+`, `#`) are replaced by `_` in the values. Payloads hold
+the selected fields only (all by default), without missing values, and
+the fetch time in `ts`. Messages are retained by default, so a display
+shows the current state as soon as it connects.
+
+Requires the optional `mqtt` dependencies (paho-mqtt).
+"""
+
+import json
+import string
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from core.config import settings
+from core.logger import logger
+from core.utils.field_names import english_name
+from core.utils.records import field_name
+from schemas.dataset_schemas import Dataset
+from services.export.columns import row_values
+from services.export.sql_schema import table_layout
+from services.report_registry import REPORTS
+
+PUBLISH_TIMEOUT_SECONDS = 30
+
+_TOPIC_ESCAPES = str.maketrans({"/": "_", "+": "_", "#": "_"})
+
+
+def _mqtt() -> Any:
+    try:
+        import paho.mqtt.client as mqtt
+    except ImportError as e:
+        raise RuntimeError("MQTT publishing requires paho-mqtt; install the 'mqtt' extra") from e
+    return mqtt
+
+
+def mqtt_messages(
+    dataset: Dataset, topic: str, fields: Optional[Sequence[str]] = None
+) -> List[Tuple[str, bytes]]:
+    """
+    Messages of a dataset, as their topic and compact JSON payload.
+
+    Args:
+        dataset (Dataset): Dataset to publish.
+        topic (str): Topic template with `{report}`, `{key}` and row fields.
+        fields (Optional[Sequence[str]], optional): Fields of the payload, by
+            field name or English name. Defaults to every field.
+
+    Returns:
+        List[Tuple[str, bytes]]: The topic and payload of every row.
+
+    Raises:
+        ValueError: If the topic uses a field the rows do not have.
+    """
+    definition = REPORTS.get(dataset.metadata.report)
+    key_fields = definition.key_fields if definition else []
+    all_fields, columns = table_layout(dataset)
+    names = [name for name, _ in columns]
+    selected = (
+        [english_name(field_name(name)) for name in fields] if fields is not None else names
+    )
+    placeholders = {name for _, name, _, _ in string.Formatter().parse(topic) if name}
+    placeholders -= {"report", "key"}
+    missing = placeholders - set(names) - set(all_fields)
+    if missing:
+        raise ValueError(f"Unknown fields in MQTT topic {topic}: {', '.join(sorted(missing))}")
+    timestamp = int(dataset.metadata.fetched_at.timestamp())
+    messages = []
+    for row in dataset.rows:
+        values = dict(zip(names, row_values(row, all_fields)))
+        key = "-".join(
+            "" if value is None else str(value) for value in row_values(row, key_fields)
+        )
+        topic_values: Dict[str, str] = {}
+        for field, name in zip(all_fields, names):
+            value = "" if values[name] is None else str(values[name])
+            topic_values[field] = topic_values[name] = value.translate(_TOPIC_ESCAPES)
+        topic_values["report"] = dataset.metadata.report
+        topic_values["key"] = key.translate(_TOPIC_ESCAPES)
+        payload = {name: values[name] for name in selected if values.get(name) is not None}
+        payload["ts"] = timestamp
+        body = json.dumps(payload, ensure_ascii=False, separators=(",", ":"))
+        messages.append((topic.format_map(topic_values), body.encode("utf-8")))
+    return messages
+
+
+class MQTTSink:
+    """
+    Publisher of dataset rows to an MQTT broker.
+
+    Args:
+        host (Optional[str], optional): Broker host. Defaults to `MQTT_HOST`.
+        port (Optional[int], optional): Broker port. Defaults to `MQTT_PORT`.
+        tls (Optional[bool], optional): Whether the connection uses TLS.
+            Defaults to `MQTT_TLS`.
+        topic (Optional[str], optional): Topic template. Defaults to `MQTT_TOPIC`.
+        retain (Optional[bool], optional): Whether messages are retained.
+            Defaults to `MQTT_RETAIN`.
+
+    Raises:
+        ValueError: If no broker is configured.
+    """
+
+    def __init__(
+        self,
+        host: Optional[str] = None,
+        port: Optional[int] = None,
+        tls: Optional[bool] = None,
+        topic: Optional[str] = None,
+        retain: Optional[bool] = None,
+    ):
+        self.host = host or settings.MQTT_HOST
+        if not self.host:
+            raise ValueError("No MQTT broker configured; set MQTT_HOST")
+        self.port = port or settings.MQTT_PORT
+        self.tls = settings.MQTT_TLS if tls is None else tls
+        self.topic = topic or settings.MQTT_TOPIC
+        self.retain = settings.MQTT_RETAIN if retain is None else retain
+
+    def publish(self, dataset: Dataset, fields: Optional[Sequence[str]] = None) -> int:
+        """
+        Publish the rows of a dataset and wait until the broker received them.
+
+        Args:
+            dataset (Dataset): Dataset to publish.
+            fields (Optional[Sequence[str]], optional): Fields of the
+                payloads. Defaults to every field.
+
+        Returns:
+            int: Number of messages published.
+
+        Raises:
+            ValueError: If the topic uses a field the rows do not have.
+            RuntimeError: If paho-mqtt is not installed, the broker cannot be
+                reached or a message is not acknowledged in time.
+        """
+        messages = mqtt_messages(dataset, self.topic, fields)
+        mqtt = _mqtt()
+        client = mqtt.Client(mqtt.CallbackAPIVersion.VERSION2)
+        if settings.MQTT_USERNAME:
+            client.username_pw_set(settings.MQTT_USERNAME, settings.MQTT_PASSWORD)
+        if self.tls:
+            client.tls_set()
+        try:
+            client.connect(self.host, self.port)
+        except OSError as e:
+            raise RuntimeError(f"MQTT broker {self.host}:{self.port} unreachable: {e}") from e
+        client.loop_start()
+        try:
+            pending = [
+                client.publish(topic, payload, qos=settings.MQTT_QOS, retain=self.retain)
+                for topic, payload in messages
+            ]
+            for info in pending:
+                info.wait_for_publish(PUBLISH_TIMEOUT_SECONDS)
+                if not info.is_published():
+                    raise RuntimeError(
+                        f"MQTT broker {self.host}:{self.port} did not acknowledge a message of "
+                        f"{dataset.metadata.report}"
+                    )
+        finally:
+            client.disconnect()
+            client.loop_stop()
+        logger.info(
+            f"Published {len(messages)} messages of {dataset.metadata.report} to MQTT broker "
+            f"{self.host}:{self.port}."
+        )
+        return len(messages)
@@ -0,0 +1,104 @@
+"""
+Templated names of export artifacts.
+
+Output file names, object key prefixes and e-mail subjects are templates
+filled with the metadata of the run, so scheduled exports are
+self-describing and never overwrite each other accidentally:
+
+    precos_{report}_{date:%Y-%m-%d}.xlsx   ->  precos_pending_sales_2025-01-31.xlsx
+
+Templates use `str.format` placeholders:
+
+    report      report name
+    date        portal date of the fetch (a `date`, accepts format specs)
+    fetched_at  portal time of the fetch (a `datetime`, accepts format specs)
+    run_id      run identifier (the fetch timestamp by default)
+    row_count   number of rows
+    filters     filters of the run, e.g. {filters[init_date]}
+"""
+
+from pathlib import Path
+from typing import Any, Dict, Optional
+
+from core.logger import logger
+from core.utils.parsers import PORTAL_TZ
+from schemas.dataset_schemas import Dataset
+from services.export.sql_schema import run_id_of
+
+
+def template_values(dataset: Dataset, run_id: Optional[str] = None) -> Dict[str, Any]:
+    """
+    Placeholder values of a dataset for name templates.
+
+    Args:
+        dataset (Dataset): Exported dataset.
+        run_id (Optional[str], optional): Run identifier. Defaults to the
+            fetch timestamp.
+
+    Returns:
+        Dict[str, Any]: Values by placeholder name.
+    """
+    fetched_at = dataset.metadata.fetched_at.astimezone(PORTAL_TZ)
+    return {
+        "report": dataset.metadata.report,
+        "date": fetched_at.date(),
+        "fetched_at": fetched_at,
+        "run_id": run_id or run_id_of(dataset),
+        "row_count": len(dataset.rows),
+        "filters": dict(dataset.metadata.filters),
+    }
+
+
+def render_template(template: str, dataset: Dataset, run_id: Optional[str] = None) -> str:
+    """
+    Fill a name template with the metadata of a dataset.
+
+    Args:
+        template (str): Template with the placeholders of `template_values`.
+        dataset (Dataset): Exported dataset.
+        run_id (Optional[str], optional): Run identifier. Defaults to the
+            fetch timestamp.
+
+    Returns:
+        str: The rendered text.
+
+    Raises:
+        ValueError: If the template is malformed or uses an unknown placeholder.
+    """
+    try:
+        return template.format_map(template_values(dataset, run_id))
+    except (KeyError, IndexError) as e:
+        raise ValueError(f"Invalid template {template!r}: unknown placeholder {e}") from e
+    except ValueError as e:
+        raise ValueError(f"Invalid template {template!r}: {e}") from e
+
+
+def is_template(name: str) -> bool:
+    """
+    Whether a name has placeholders to be filled.
+    """
+    return "{" in name.replace("{{", "")
+
+
+def unique_path(path: Path) -> Path:
+    """
+    A path that does not exist yet, numbering the name when it is taken.
+
+    `prices.xlsx` becomes `prices_2.xlsx`, `prices_3.xlsx` and so on; the
+    numbering goes before every suffix (`prices_2.csv.gz`).
+
+    Args:
+        path (Path): Desired path.
+
+    Returns:
+        Path: The path itself, or the first free numbered variant.
+    """
+    if not path.exists():
+        return path
+    suffixes = "".join(path.suffixes)
+    stem = path.name[: -len(suffixes)] if suffixes else path.name
+    number = 2
+    while (candidate := path.with_name(f"{stem}_{number}{suffixes}")).exists():
+        number += 1
+    logger.warning(f"{path} already exists; writing {candidate.name} instead.")
+    return candidate
@@ -0,0 +1,137 @@
+"""
+Parquet export of datasets.
+
+Writes datasets as Parquet files so report snapshots can be landed in the
+data lake and queried from DuckDB or Athena. Column types are inferred
+from the typed values (money as decimals, dates and timestamps as
+temporal types) and the dataset metadata is stored in the file schema
+metadata under the `crawlercm.metadata` key.
+
+Requires the optional `parquet` dependencies (pyarrow).
+"""
+
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Any, BinaryIO, Optional, Sequence
+
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.columns import native_values, select_columns
+from services.export.exporter import Exporter
+
+METADATA_KEY = b"crawlercm.metadata"
+
+
+def _pyarrow() -> Any:
+    try:
+        import pyarrow
+        import pyarrow.parquet  # noqa: F401
+    except ImportError as e:
+        raise RuntimeError(
+            "Parquet export requires pyarrow; install the 'parquet' extra"
+        ) from e
+    return pyarrow
+
+
+def to_arrow_table(dataset: Dataset, columns: Optional[Sequence[str]] = None) -> Any:
+    """
+    Convert a dataset into an Arrow table.
+
+    Columns are named with the stable English field names.
+
+    Args:
+        dataset (Dataset): Dataset to convert.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+
+    Returns:
+        pyarrow.Table: The table, with the dataset metadata in its schema.
+
+    Raises:
+        RuntimeError: If pyarrow is not installed.
+    """
+    pa = _pyarrow()
+    layout = select_columns(dataset, columns, "en")
+    fields = [name for name, _ in layout]
+    values = [native_values(row, fields) for row in dataset.rows]
+    table = pa.table(
+        {header: [row[i] for row in values] for i, (_, header) in enumerate(layout)}
+    )
+    metadata = json.dumps(dataset.metadata.model_dump(mode="json"), ensure_ascii=False)
+    return table.replace_schema_metadata({METADATA_KEY: metadata.encode("utf-8")})
+
+
+def write_parquet(
+    dataset: Dataset,
+    destination: str | Path | BinaryIO,
+    columns: Optional[Sequence[str]] = None,
+    compression: str = "snappy",
+) -> int:
+    """
+    Write a dataset as Parquet to a file path or binary stream.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        destination (str | Path | BinaryIO): File path or writable binary stream.
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        compression (str, optional): Parquet compression codec. Defaults to "snappy".
+
+    Returns:
+        int: Number of rows written.
+
+    Raises:
+        RuntimeError: If pyarrow is not installed.
+    """
+    pa = _pyarrow()
+    if isinstance(destination, (str, Path)):
+        destination = str(destination)
+    pa.parquet.write_table(to_arrow_table(dataset, columns), destination, compression=compression)
+    return len(dataset.rows)
+
+
+def export_parquet(
+    dataset: Dataset,
+    path: str | Path,
+    columns: Optional[Sequence[str]] = None,
+    compression: str = "snappy",
+) -> Path:
+    """
+    Write a dataset to a Parquet file.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        path (str | Path): Destination file path; parent directories are created.
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        compression (str, optional): Parquet compression codec. Defaults to "snappy".
+
+    Returns:
+        Path: The written file.
+
+    Raises:
+        RuntimeError: If pyarrow is not installed.
+    """
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    count = write_parquet(dataset, path, columns, compression)
+    logger.info(f"Exported {count} rows of {dataset.metadata.report} to {path}.")
+    return path
+
+
+@dataclass
+class ParquetExporter(Exporter):
+    """
+    Parquet file with the dataset metadata in its schema.
+    """
+
+    columns: Optional[Sequence[str]] = None
+    compression: str = "snappy"
+
+    format = "parquet"
+    extension = ".parquet"
+    media_type = "application/vnd.apache.parquet"
+
+    def write(self, dataset: Dataset, stream: BinaryIO) -> int:
+        return write_parquet(dataset, stream, self.columns, self.compression)
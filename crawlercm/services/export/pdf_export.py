@@ -0,0 +1,201 @@
+"""
+PDF rendering of datasets.
+
+Renders a dataset as a printable landscape A4 document for the reports
+posted on the shop-floor board: a title, the filters of the run, the rows
+as a table with a repeated header, a totals line and the generation
+timestamp. Values are formatted as pt-BR text and the totals sum the
+`total_fields` of the report in the registry.
+
+Requires the optional `pdf` dependencies (reportlab).
+"""
+
+from dataclasses import dataclass
+from io import BytesIO
+from pathlib import Path
+from typing import Any, BinaryIO, Dict, Optional, Sequence
+from xml.sax.saxutils import escape
+
+from core.logger import logger
+from core.utils.parsers import portal_now
+from schemas.dataset_schemas import Dataset
+from services.export.columns import (
+    HeaderLanguage,
+    display_value,
+    display_values,
+    report_totals,
+    select_columns,
+)
+from services.export.exporter import Exporter
+from services.report_registry import REPORTS
+
+
+def _reportlab() -> Dict[str, Any]:
+    try:
+        from reportlab.lib import colors
+        from reportlab.lib.pagesizes import A4, landscape
+        from reportlab.lib.styles import getSampleStyleSheet
+        from reportlab.lib.units import mm
+        from reportlab.platypus import Paragraph, SimpleDocTemplate, Spacer, Table, TableStyle
+    except ImportError as e:
+        raise RuntimeError("PDF export requires reportlab; install the 'pdf' extra") from e
+    return {
+        "colors": colors,
+        "pagesize": landscape(A4),
+        "styles": getSampleStyleSheet(),
+        "mm": mm,
+        "Paragraph": Paragraph,
+        "SimpleDocTemplate": SimpleDocTemplate,
+        "Spacer": Spacer,
+        "Table": Table,
+        "TableStyle": TableStyle,
+    }
+
+
+def export_pdf_bytes(
+    dataset: Dataset,
+    title: Optional[str] = None,
+    columns: Optional[Sequence[str]] = None,
+    totals: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "pt-BR",
+) -> bytes:
+    """
+    Render a dataset as a PDF document.
+
+    Args:
+        dataset (Dataset): Dataset to render.
+        title (Optional[str], optional): Document title. Defaults to the
+            description of the report in the registry.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        totals (Optional[Sequence[str]], optional): Fields summed in the
+            totals line. Defaults to the `total_fields` of the report.
+        header_language (HeaderLanguage, optional): Header language.
+            Defaults to "pt-BR".
+
+    Returns:
+        bytes: The PDF content.
+
+    Raises:
+        RuntimeError: If reportlab is not installed.
+    """
+    rl = _reportlab()
+    definition = REPORTS.get(dataset.metadata.report)
+    title = title or (definition.description if definition else dataset.metadata.report)
+    if totals is None:
+        totals = definition.total_fields if definition else []
+    layout = select_columns(dataset, columns, header_language)
+    fields = [name for name, _ in layout]
+    styles = rl["styles"]
+    cell = styles["BodyText"].clone("cell", fontSize=7, leading=8)
+
+    data = [[rl["Paragraph"](escape(header), cell) for _, header in layout]]
+    data.extend(
+        [rl["Paragraph"](escape(value), cell) for value in display_values(row, fields)]
+        for row in dataset.rows
+    )
+    sums = report_totals(dataset, [name for name in totals if name in fields])
+    if sums:
+        data.append(
+            [
+                rl["Paragraph"](f"<b>{display_value(sums.get(name))}</b>", cell)
+                if name in sums
+                else rl["Paragraph"]("<b>Total</b>" if i == 0 else "", cell)
+                for i, name in enumerate(fields)
+            ]
+        )
+
+    table = rl["Table"](data, repeatRows=1)
+    style = [
+        ("GRID", (0, 0), (-1, -1), 0.25, rl["colors"].grey),
+        ("BACKGROUND", (0, 0), (-1, 0), rl["colors"].lightgrey),
+        ("VALIGN", (0, 0), (-1, -1), "TOP"),
+    ]
+    if sums:
+        style.append(("BACKGROUND", (0, -1), (-1, -1), rl["colors"].whitesmoke))
+    table.setStyle(rl["TableStyle"](style))
+
+    filters = ", ".join(
+        f"{name}: {display_value(value)}"
+        for name, value in dataset.metadata.filters.items()
+        if value is not None
+    )
+    story = [
+        rl["Paragraph"](escape(title), styles["Title"]),
+        rl["Paragraph"](escape(f"Filtros: {filters or 'nenhum'}"), styles["Normal"]),
+        rl["Paragraph"](f"Linhas: {display_value(len(dataset.rows))}", styles["Normal"]),
+        rl["Spacer"](1, 4 * rl["mm"]),
+        table,
+        rl["Spacer"](1, 4 * rl["mm"]),
+        rl["Paragraph"](f"Gerado em {display_value(portal_now())}", styles["Italic"]),
+    ]
+    output = BytesIO()
+    document = rl["SimpleDocTemplate"](
+        output,
+        pagesize=rl["pagesize"],
+        leftMargin=10 * rl["mm"],
+        rightMargin=10 * rl["mm"],
+        topMargin=10 * rl["mm"],
+        bottomMargin=10 * rl["mm"],
+        title=title,
+    )
+    document.build(story)
+    return output.getvalue()
+
+
+def export_pdf(
+    dataset: Dataset,
+    path: str | Path,
+    title: Optional[str] = None,
+    columns: Optional[Sequence[str]] = None,
+    totals: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "pt-BR",
+) -> Path:
+    """
+    Write a dataset to a PDF file.
+
+    Args:
+        dataset (Dataset): Dataset to render.
+        path (str | Path): Destination file path; parent directories are created.
+        title (Optional[str], optional): Document title. Defaults to the
+            description of the report in the registry.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        totals (Optional[Sequence[str]], optional): Fields summed in the
+            totals line. Defaults to the `total_fields` of the report.
+        header_language (HeaderLanguage, optional): Header language.
+            Defaults to "pt-BR".
+
+    Returns:
+        Path: The written file.
+
+    Raises:
+        RuntimeError: If reportlab is not installed.
+    """
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_bytes(export_pdf_bytes(dataset, title, columns, totals, header_language))
+    logger.info(f"Exported {len(dataset.rows)} rows of {dataset.metadata.report} to {path}.")
+    return path
+
+
+@dataclass
+class PdfExporter(Exporter):
+    """
+    Printable PDF document with filters, totals and generation time.
+    """
+
+    title: Optional[str] = None
+    columns: Optional[Sequence[str]] = None
+    totals: Optional[Sequence[str]] = None
+    header_language: HeaderLanguage = "pt-BR"
+
+    format = "pdf"
+    extension = ".pdf"
+    media_type = "application/pdf"
+
+    def write(self, dataset: Dataset, stream: BinaryIO) -> int:
+        stream.write(
+            export_pdf_bytes(dataset, self.title, self.columns, self.totals, self.header_language)
+        )
+        return len(dataset.rows)
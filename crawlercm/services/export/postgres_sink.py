@@ -0,0 +1,77 @@
+"""
+PostgreSQL sink keeping a staging schema current.
+
+Upserts report rows by business key into a table per report (`INSERT ...
+ON CONFLICT DO UPDATE`), creating and migrating the tables as needed, and
+logs every write in the `run_log` table. The connection string and schema
+come from the `POSTGRES_DSN` and `POSTGRES_SCHEMA` settings.
+
+Requires the optional `postgres` dependencies (psycopg).
+"""
+
+from typing import Any, Dict, List, Optional, Sequence
+
+from core.config import settings
+from services.export.sql_schema import ColumnKind
+from services.export.sql_sink import SQLSink
+
+
+class PostgresSink(SQLSink):
+    """
+    Writes datasets into PostgreSQL with upsert semantics.
+
+    Args:
+        dsn (Optional[str]): Connection string. Defaults to the `POSTGRES_DSN` setting.
+        schema (Optional[str]): Target schema. Defaults to the `POSTGRES_SCHEMA` setting.
+    """
+
+    types: Dict[ColumnKind, str] = {
+        "boolean": "BOOLEAN",
+        "integer": "BIGINT",
+        "real": "DOUBLE PRECISION",
+        "numeric": "NUMERIC",
+        "date": "DATE",
+        "timestamp": "TIMESTAMPTZ",
+        "text": "TEXT",
+    }
+
+    def __init__(self, dsn: Optional[str] = None, schema: Optional[str] = None):
+        super().__init__(schema or settings.POSTGRES_SCHEMA)
+        self.dsn = dsn or settings.POSTGRES_DSN
+        if not self.dsn:
+            raise ValueError("POSTGRES_DSN is not configured")
+
+    def connect(self) -> Any:
+        try:
+            import psycopg
+        except ImportError as e:
+            raise RuntimeError(
+                "The PostgreSQL sink requires psycopg; install the 'postgres' extra"
+            ) from e
+        connection = psycopg.connect(self.dsn)
+        if self.schema:
+            connection.execute(f"CREATE SCHEMA IF NOT EXISTS {self.quote(self.schema)}")
+        return connection
+
+    def quote(self, identifier: str) -> str:
+        return f'"{identifier}"'
+
+    def existing_columns(self, cursor: Any, table: str) -> List[str]:
+        cursor.execute(
+            "SELECT column_name FROM information_schema.columns "
+            "WHERE table_schema = %s AND table_name = %s ORDER BY ordinal_position",
+            (self.schema or "public", table),
+        )
+        return [row[0] for row in cursor.fetchall()]
+
+    def upsert_sql(self, table: str, columns: Sequence[str], keys: Sequence[str]) -> str:
+        names = ", ".join(self.quote(name) for name in columns)
+        marks = ", ".join([self.placeholder] * len(columns))
+        conflict = ", ".join(self.quote(key) for key in keys)
+        updates = ", ".join(
+            f"{self.quote(name)} = EXCLUDED.{self.quote(name)}" for name in columns if name not in keys
+        )
+        return (
+            f"INSERT INTO {self.table_name(table)} ({names}) VALUES ({marks}) "
+            f"ON CONFLICT ({conflict}) DO UPDATE SET {updates}"
+        )
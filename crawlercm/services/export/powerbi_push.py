@@ -0,0 +1,182 @@
+"""
+Power BI push dataset destination.
+
+Pushes report rows to the table of a Power BI push dataset through its
+REST API, so dashboards refresh right after each scrape without an
+intermediate database. Two kinds of targets are supported:
+
+- a push dataset in a workspace, addressed by dataset id and table name
+  (the report name by default), authenticated with an Azure AD app
+  registration (client credentials); its rows can be replaced on every
+  run or appended;
+- a streaming dataset, addressed by the push URL (with its key) shown by
+  Power BI, which only accepts appended rows.
+
+Columns use the stable English field names and JSON values, matching the
+table schema the dataset is created with. Rows are sent in batches of the
+API limit.
+"""
+
+import json
+from typing import Any, Dict, List, Literal, Optional
+from urllib.error import HTTPError
+from urllib.parse import quote, urlencode
+from urllib.request import Request, urlopen
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.columns import row_values, select_columns
+
+API_URL = "https://api.powerbi.com/v1.0/myorg"
+TOKEN_URL = "https://login.microsoftonline.com/{tenant}/oauth2/v2.0/token"
+SCOPE = "https://analysis.windows.net/powerbi/api/.default"
+MAX_ROWS_PER_REQUEST = 10_000
+TIMEOUT_SECONDS = 60
+
+PushMode = Literal["append", "replace"]
+
+
+def powerbi_rows(dataset: Dataset) -> List[Dict[str, Any]]:
+    """
+    Rows of a dataset as objects keyed by the stable English names.
+
+    Args:
+        dataset (Dataset): Dataset to push.
+
+    Returns:
+        List[Dict[str, Any]]: One object of JSON values per row.
+    """
+    layout = select_columns(dataset, None, "en")
+    fields = [name for name, _ in layout]
+    headers = [header for _, header in layout]
+    return [dict(zip(headers, row_values(row, fields))) for row in dataset.rows]
+
+
+def _request(
+    method: str,
+    url: str,
+    body: Optional[bytes] = None,
+    headers: Optional[Dict[str, str]] = None,
+) -> bytes:
+    request = Request(url, data=body, method=method, headers=headers or {})
+    try:
+        with urlopen(request, timeout=TIMEOUT_SECONDS) as response:
+            return response.read()
+    except HTTPError as e:
+        detail = e.read().decode("utf-8", "replace")[:500]
+        endpoint = url.split("?")[0]
+        raise RuntimeError(f"Power BI request {method} {endpoint} failed: {e.code} {detail}") from e
+
+
+class PowerBIPush:
+    """
+    Pusher of dataset rows to a Power BI push or streaming dataset.
+
+    Args:
+        dataset_id (Optional[str], optional): Push dataset id. Defaults to
+            `POWERBI_DATASET_ID`.
+        table (Optional[str], optional): Table of the push dataset. Defaults
+            to the report name.
+        push_url (Optional[str], optional): Push URL of a streaming dataset,
+            used instead of the dataset id. Defaults to `POWERBI_PUSH_URL`
+            when no dataset id is configured.
+        group_id (Optional[str], optional): Workspace of the push dataset.
+            Defaults to `POWERBI_GROUP_ID`, or "My workspace" when unset.
+
+    Raises:
+        ValueError: If neither a dataset id nor a push URL is configured.
+    """
+
+    def __init__(
+        self,
+        dataset_id: Optional[str] = None,
+        table: Optional[str] = None,
+        push_url: Optional[str] = None,
+        group_id: Optional[str] = None,
+    ):
+        self.dataset_id = dataset_id or (None if push_url else settings.POWERBI_DATASET_ID)
+        self.push_url = None if self.dataset_id else push_url or settings.POWERBI_PUSH_URL
+        if not self.dataset_id and not self.push_url:
+            raise ValueError(
+                "No Power BI dataset configured; set POWERBI_DATASET_ID or POWERBI_PUSH_URL"
+            )
+        self.table = table
+        self.group_id = group_id or settings.POWERBI_GROUP_ID
+
+    def token(self) -> str:
+        """
+        Access token of the app registration, from the client credentials.
+
+        Raises:
+            ValueError: If the app registration is not configured.
+            RuntimeError: If Azure AD rejects the credentials.
+        """
+        credentials = (
+            settings.POWERBI_TENANT_ID,
+            settings.POWERBI_CLIENT_ID,
+            settings.POWERBI_CLIENT_SECRET,
+        )
+        if not all(credentials):
+            raise ValueError(
+                "Power BI push datasets require POWERBI_TENANT_ID, POWERBI_CLIENT_ID "
+                "and POWERBI_CLIENT_SECRET"
+            )
+        body = urlencode(
+            {
+                "grant_type": "client_credentials",
+                "client_id": settings.POWERBI_CLIENT_ID,
+                "client_secret": settings.POWERBI_CLIENT_SECRET,
+                "scope": SCOPE,
+            }
+        ).encode("ascii")
+        response = _request(
+            "POST",
+            TOKEN_URL.format(tenant=quote(settings.POWERBI_TENANT_ID)),
+            body,
+            {"Content-Type": "application/x-www-form-urlencoded"},
+        )
+        return json.loads(response)["access_token"]
+
+    def rows_url(self, table: str) -> str:
+        """
+        Endpoint of the rows of a table of the push dataset.
+        """
+        group = f"/groups/{quote(self.group_id)}" if self.group_id else ""
+        return f"{API_URL}{group}/datasets/{quote(self.dataset_id)}/tables/{quote(table)}/rows"
+
+    def push(self, dataset: Dataset, mode: PushMode = "append") -> int:
+        """
+        Push the rows of a dataset.
+
+        Args:
+            dataset (Dataset): Dataset to push.
+            mode (PushMode, optional): "append" adds the rows to the table,
+                "replace" deletes the rows of the table first. Defaults to "append".
+
+        Returns:
+            int: Number of rows pushed.
+
+        Raises:
+            ValueError: If the mode is unknown, or a streaming dataset is replaced.
+            RuntimeError: If Power BI rejects a request.
+        """
+        if mode not in ("append", "replace"):
+            raise ValueError(f"Unknown Power BI push mode: {mode}")
+        if self.push_url:
+            if mode == "replace":
+                raise ValueError("Rows of a Power BI streaming dataset can only be appended")
+            url, headers = self.push_url, {}
+        else:
+            url = self.rows_url(self.table or dataset.metadata.report)
+            headers = {"Authorization": f"Bearer {self.token()}"}
+            if mode == "replace":
+                _request("DELETE", url, headers=headers)
+        headers["Content-Type"] = "application/json"
+        rows = powerbi_rows(dataset)
+        for start in range(0, len(rows), MAX_ROWS_PER_REQUEST):
+            batch = rows[start : start + MAX_ROWS_PER_REQUEST]
+            body = json.dumps({"rows": batch}, ensure_ascii=False).encode("utf-8")
+            _request("POST", url, body, headers)
+        logger.info(f"Pushed {len(rows)} rows of {dataset.metadata.report} to Power BI ({mode}).")
+        return len(rows)
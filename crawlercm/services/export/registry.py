@@ -0,0 +1,182 @@
+"""
+Pluggable exporters and destinations.
+
+Internal-only or third-party outputs are added without changing the
+export package: a plugin module registers its exporters (new file
+formats, see `services.export.files.register_exporter`) and its
+destinations when imported, and is listed in `EXPORT_PLUGINS`:
+
+    # lanx_plugins/erp.py
+    class ErpInbox(Destination):
+        name = "erp"
+
+        def write(self, dataset, target, options=None):
+            ...
+            return target
+
+    register_destination(ErpInbox())
+    register_exporter(FixedWidthExporter())
+
+    # .env
+    EXPORT_PLUGINS=["lanx_plugins.erp"]
+
+Registered formats are selected by name wherever a format is configured
+(`?format=`, e-mail attachments) and by their suffix in file paths.
+Registered destinations are selected in the `destinations` of a report by
+their name, alone (`erp`) or as a URL scheme (`erp://inbox?layout=v2`),
+and take precedence over the built-in destinations of the same name (see
+`services.export.destinations`).
+"""
+
+import importlib
+from abc import ABC, abstractmethod
+from typing import Dict, List, Optional, Sequence, Set, Tuple
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.runner_schemas import DestinationConfig
+from services.export.exporter import PageWriter
+
+
+class Destination(ABC):
+    """
+    A place report rows are delivered to, selected by name.
+
+    Attributes:
+        name (str): Destination name and URL scheme used in configuration.
+        schemes (Tuple[str, ...]): Further URL schemes of the destination,
+            e.g. `postgresql` for `postgres`.
+    """
+
+    name: str = ""
+    schemes: Tuple[str, ...] = ()
+
+    def accepts(self, target: str) -> bool:
+        """
+        Whether a configured target is delivered to this destination: by
+        its name, alone or as the URL scheme of the target, or by one of its
+        further schemes.
+        """
+        return target_name(target) in (self.name, *self.schemes)
+
+    @abstractmethod
+    def write(
+        self, dataset: Dataset, target: str, options: Optional[DestinationConfig] = None
+    ) -> str:
+        """
+        Deliver a dataset.
+
+        Args:
+            dataset (Dataset): Report rows and metadata, shaped for the
+                destination.
+            target (str): Destination as configured, e.g. `erp://inbox?layout=v2`.
+            options (Optional[DestinationConfig], optional): Destination with
+                its write mode, layout and encoding, when configured as one.
+
+        Returns:
+            str: The destination written, as reported in the run summary.
+        """
+
+    def open_pages(
+        self, metadata: DatasetMetadata, target: str, options: DestinationConfig
+    ) -> Tuple[str, PageWriter]:
+        """
+        Start delivering a streamed report page by page.
+
+        Args:
+            metadata (DatasetMetadata): Metadata of the report, updated as
+                the pages arrive.
+            target (str): Destination as configured.
+            options (DestinationConfig): Destination of the report.
+
+        Returns:
+            Tuple[str, PageWriter]: The destination written and the writer
+            of its pages.
+
+        Raises:
+            ValueError: If the destination cannot be streamed to, the default.
+        """
+        raise ValueError(f"Streamed reports cannot be written to {self.name} destinations")
+
+
+DESTINATIONS: Dict[str, Destination] = {}
+
+
+def target_name(target: str) -> str:
+    """
+    Name a configured target selects its destination by: the URL scheme of
+    `erp://inbox`, or `erp` of `erp` and `erp?layout=v2`.
+    """
+    return target.split("://", 1)[0] if "://" in target else target.split("?", 1)[0]
+
+_LOADED_PLUGINS: Set[str] = set()
+
+
+def register_destination(destination: Destination, replace: bool = False) -> None:
+    """
+    Make a destination available by its name.
+
+    Args:
+        destination (Destination): Destination to register.
+        replace (bool, optional): Whether a destination already registered
+            with the name is replaced. Defaults to False.
+
+    Raises:
+        ValueError: If the destination has no name, or the name is already
+            registered and `replace` is not set.
+    """
+    if not destination.name:
+        raise ValueError(f"{type(destination).__name__} has no destination name")
+    if destination.name in DESTINATIONS and not replace:
+        raise ValueError(f"Destination {destination.name} is already registered")
+    DESTINATIONS[destination.name] = destination
+
+
+def destination_for(target: str) -> Optional[Destination]:
+    """
+    Registered destination of a configured target.
+
+    Args:
+        target (str): Destination as configured.
+
+    Returns:
+        Optional[Destination]: The destination accepting the target, or None
+        when no registered destination matches.
+    """
+    return next(
+        (destination for destination in DESTINATIONS.values() if destination.accepts(target)),
+        None,
+    )
+
+
+def load_plugins(modules: Optional[Sequence[str]] = None) -> List[str]:
+    """
+    Import the plugin modules registering exporters and destinations.
+
+    Modules are imported once; loading them again has no effect.
+
+    Args:
+        modules (Optional[Sequence[str]], optional): Module names. Defaults
+            to `EXPORT_PLUGINS`.
+
+    Returns:
+        List[str]: The modules imported by this call.
+
+    Raises:
+        ImportError: If a plugin module cannot be imported.
+    """
+    names = [
+        name
+        for name in (settings.EXPORT_PLUGINS if modules is None else modules)
+        if name not in _LOADED_PLUGINS
+    ]
+    for name in names:
+        importlib.import_module(name)
+        _LOADED_PLUGINS.add(name)
+    if names:
+        logger.info(
+            f"Export plugins loaded: {', '.join(names)} "
+            f"(destinations: {', '.join(sorted(DESTINATIONS)) or 'none'})."
+        )
+    return names
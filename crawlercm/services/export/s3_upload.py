@@ -0,0 +1,188 @@
+"""
+S3-compatible object storage destination.
+
+Uploads dataset exports and stored snapshots to an S3 bucket, or to the
+MinIO archive through a custom endpoint. Object keys are built from a
+prefix template and a file name made of the report and the run id:
+
+    {report}/{fetched_at:%Y/%m/%d}/  ->  pending_orders/2025/01/31/pending_orders_<run id>.parquet
+
+The template accepts the placeholders of `services.export.naming`, such
+as `report`, `run_id`, `date` and `fetched_at`.
+Objects can be encrypted server-side with S3 managed keys (`AES256`) or
+KMS (`aws:kms`, optionally with a key id). Credentials come from the
+standard AWS environment variables or configuration files.
+
+Requires the optional `s3` dependencies (boto3).
+"""
+
+from pathlib import Path
+from typing import Any, Dict, Optional
+
+from core.config import settings
+from core.logger import logger
+from core.snapshot_store import SnapshotStore
+from schemas.dataset_schemas import Dataset
+from schemas.snapshot_schemas import SnapshotInfo
+from services.export.compression import COMPRESSION_MIME_TYPES, Compression, compress
+from services.export.exporter import Exporter
+from services.export.files import MIME_TYPES, ExportFormat, exporter_for
+from services.export.naming import render_template
+from services.export.sql_schema import run_id_of
+
+
+def _boto3() -> Any:
+    try:
+        import boto3
+    except ImportError as e:
+        raise RuntimeError("S3 upload requires boto3; install the 's3' extra") from e
+    return boto3
+
+
+def render_prefix(template: str, dataset: Dataset, run_id: Optional[str] = None) -> str:
+    """
+    Render an object key prefix template for a dataset.
+
+    Args:
+        template (str): Prefix template, e.g. "{report}/{date:%Y/%m}/".
+        dataset (Dataset): Dataset being uploaded.
+        run_id (Optional[str], optional): Run identifier. Defaults to the
+            fetch timestamp.
+
+    Returns:
+        str: The prefix, ending with "/" unless it is empty.
+
+    Raises:
+        ValueError: If the template is malformed or uses an unknown placeholder.
+    """
+    prefix = render_template(template, dataset, run_id).strip("/")
+    return f"{prefix}/" if prefix else ""
+
+
+class S3Archive:
+    """
+    Uploader of exports and snapshots to an S3-compatible bucket.
+
+    Args:
+        bucket (Optional[str], optional): Bucket name. Defaults to `S3_BUCKET`.
+        prefix (Optional[str], optional): Object key prefix template.
+            Defaults to `S3_PREFIX`.
+        endpoint_url (Optional[str], optional): Endpoint of an S3-compatible
+            service such as MinIO. Defaults to `S3_ENDPOINT_URL`, or AWS when unset.
+        encryption (Optional[str], optional): Server-side encryption, "AES256"
+            or "aws:kms". Defaults to `S3_SSE`.
+        kms_key_id (Optional[str], optional): KMS key used with "aws:kms".
+            Defaults to `S3_KMS_KEY_ID`.
+
+    Raises:
+        ValueError: If no bucket is configured or the encryption is unknown.
+    """
+
+    def __init__(
+        self,
+        bucket: Optional[str] = None,
+        prefix: Optional[str] = None,
+        endpoint_url: Optional[str] = None,
+        encryption: Optional[str] = None,
+        kms_key_id: Optional[str] = None,
+    ):
+        self.bucket = bucket or settings.S3_BUCKET
+        if not self.bucket:
+            raise ValueError("No S3 bucket configured; set S3_BUCKET")
+        self.prefix = settings.S3_PREFIX if prefix is None else prefix
+        self.endpoint_url = endpoint_url or settings.S3_ENDPOINT_URL
+        self.encryption = encryption or settings.S3_SSE
+        self.kms_key_id = kms_key_id or settings.S3_KMS_KEY_ID
+        if self.encryption not in (None, "AES256", "aws:kms"):
+            raise ValueError(f"Unknown S3 server-side encryption: {self.encryption}")
+
+    def client(self) -> Any:
+        """
+        Open an S3 client for the configured endpoint.
+
+        Raises:
+            RuntimeError: If boto3 is not installed.
+        """
+        return _boto3().client("s3", endpoint_url=self.endpoint_url)
+
+    def _extra_args(self, content_type: str) -> Dict[str, str]:
+        extra = {"ContentType": content_type}
+        if self.encryption:
+            extra["ServerSideEncryption"] = self.encryption
+        if self.encryption == "aws:kms" and self.kms_key_id:
+            extra["SSEKMSKeyId"] = self.kms_key_id
+        return extra
+
+    def put(self, key: str, content: bytes, content_type: str) -> str:
+        """
+        Upload an object.
+
+        Args:
+            key (str): Object key.
+            content (bytes): Object content.
+            content_type (str): MIME type of the content.
+
+        Returns:
+            str: The `s3://` URL of the object.
+        """
+        self.client().put_object(
+            Bucket=self.bucket, Key=key, Body=content, **self._extra_args(content_type)
+        )
+        return f"s3://{self.bucket}/{key}"
+
+    def upload_export(
+        self,
+        dataset: Dataset,
+        file_format: ExportFormat = "json",
+        run_id: Optional[str] = None,
+        compression: Optional[Compression] = None,
+        exporter: Optional[Exporter] = None,
+    ) -> str:
+        """
+        Upload a dataset export.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+            file_format (ExportFormat, optional): Export format. Defaults to "json".
+            run_id (Optional[str], optional): Run identifier used in the key.
+                Defaults to the fetch timestamp.
+            compression (Optional[Compression], optional): "gzip" or "zip" to
+                compress the export. Defaults to no compression.
+            exporter (Optional[Exporter], optional): Exporter of the format,
+                with the options of the destination. Defaults to the exporter
+                of the format with its default options.
+
+        Returns:
+            str: The `s3://` URL of the object.
+        """
+        run_id = run_id or run_id_of(dataset)
+        name, content = compress(
+            f"{dataset.metadata.report}_{run_id}.{file_format}",
+            (exporter or exporter_for(file_format)).render(dataset),
+            compression,
+        )
+        content_type = (
+            COMPRESSION_MIME_TYPES[compression] if compression else MIME_TYPES[file_format]
+        )
+        key = f"{render_prefix(self.prefix, dataset, run_id)}{name}"
+        url = self.put(key, content, content_type)
+        logger.info(f"Uploaded {len(dataset.rows)} rows of {dataset.metadata.report} to {url}.")
+        return url
+
+    def upload_snapshot(self, store: SnapshotStore, info: SnapshotInfo, dataset: Dataset) -> str:
+        """
+        Upload a stored snapshot file as is (gzip-compressed JSON).
+
+        Args:
+            store (SnapshotStore): Store holding the snapshot.
+            info (SnapshotInfo): Snapshot to upload.
+            dataset (Dataset): Dataset of the snapshot, used to render the prefix.
+
+        Returns:
+            str: The `s3://` URL of the object.
+        """
+        path: Path = store.path(info.report, info.id)
+        key = f"{render_prefix(self.prefix, dataset, info.id)}{info.report}_{path.name}"
+        url = self.put(key, path.read_bytes(), "application/gzip")
+        logger.info(f"Archived snapshot {info.id} of {info.report} to {url}.")
+        return url
@@ -0,0 +1,243 @@
+"""
+SFTP and FTP upload destination.
+
+Delivers dataset exports to the inbox folders watched by integrations
+such as the ERP. Destinations are URLs of the remote directory:
+
+    sftp://erp@files.lanx.local/inbox/precos?format=csv
+    ftps://user@ftp.example.com:2121/in?name=precos_{date:%Y%m%d}.csv
+
+SFTP authenticates with the private key of `SFTP_KEY_FILE` (or the
+password of the URL) and only connects to hosts known by the system or
+listed in `SFTP_KNOWN_HOSTS`. FTP uses the password of the URL or
+`FTP_PASSWORD`; `ftps` protects the control and data channels with TLS.
+
+Files are uploaded under a temporary `.part` name and renamed once
+complete, so watchers never pick up a partial file. Failed uploads are
+retried `UPLOAD_RETRIES` times with an exponential delay.
+
+SFTP requires the optional `sftp` dependencies (paramiko).
+"""
+
+import ftplib
+import time
+from io import BytesIO
+from typing import Any, Callable, Optional
+from urllib.parse import parse_qs, unquote, urlparse
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.compression import Compression, compress
+from services.export.exporter import Exporter
+from services.export.files import exporter_for, format_of
+from services.export.naming import render_template
+from services.export.sql_schema import run_id_of
+
+DEFAULT_PORTS = {"sftp": 22, "ftp": 21, "ftps": 21}
+
+
+def _paramiko() -> Any:
+    try:
+        import paramiko
+    except ImportError as e:
+        raise RuntimeError("SFTP upload requires paramiko; install the 'sftp' extra") from e
+    return paramiko
+
+
+class RemoteUpload:
+    """
+    Uploader of files to a directory of an SFTP, FTP or FTPS server.
+
+    Args:
+        url (str): `sftp://`, `ftp://` or `ftps://` URL of the remote
+            directory, with the user name and optionally the password.
+        retries (Optional[int], optional): Attempts after a failed upload.
+            Defaults to `UPLOAD_RETRIES`.
+        retry_delay (Optional[float], optional): Seconds before the first
+            retry, doubled on every attempt. Defaults to `UPLOAD_RETRY_DELAY_SECONDS`.
+
+    Raises:
+        ValueError: If the URL scheme is not supported or has no host.
+    """
+
+    def __init__(
+        self, url: str, retries: Optional[int] = None, retry_delay: Optional[float] = None
+    ):
+        self.url = urlparse(url)
+        if self.url.scheme not in DEFAULT_PORTS:
+            raise ValueError(f"Unsupported upload URL scheme: {self.url.scheme}")
+        if not self.url.hostname:
+            raise ValueError(f"No host in upload URL: {url}")
+        self.directory = self.url.path.rstrip("/") or "."
+        self.retries = settings.UPLOAD_RETRIES if retries is None else retries
+        self.retry_delay = (
+            settings.UPLOAD_RETRY_DELAY_SECONDS if retry_delay is None else retry_delay
+        )
+
+    @property
+    def host(self) -> str:
+        """
+        Host and port of the server, for logs.
+        """
+        return f"{self.url.hostname}:{self.url.port or DEFAULT_PORTS[self.url.scheme]}"
+
+    def _password(self) -> Optional[str]:
+        if self.url.password is not None:
+            return unquote(self.url.password)
+        return settings.FTP_PASSWORD if self.url.scheme != "sftp" else None
+
+    def _put_sftp(self, name: str, content: bytes) -> None:
+        paramiko = _paramiko()
+        client = paramiko.SSHClient()
+        client.load_system_host_keys()
+        if settings.SFTP_KNOWN_HOSTS:
+            client.load_host_keys(settings.SFTP_KNOWN_HOSTS)
+        client.set_missing_host_key_policy(paramiko.RejectPolicy())
+        client.connect(
+            self.url.hostname,
+            port=self.url.port or DEFAULT_PORTS["sftp"],
+            username=unquote(self.url.username or ""),
+            password=self._password(),
+            key_filename=settings.SFTP_KEY_FILE,
+            passphrase=settings.SFTP_KEY_PASSPHRASE,
+            allow_agent=False,
+        )
+        try:
+            sftp = client.open_sftp()
+            target = f"{self.directory}/{name}"
+            partial = f"{self.directory}/.{name}.part"
+            sftp.putfo(BytesIO(content), partial)
+            try:
+                sftp.posix_rename(partial, target)
+            except IOError:
+                try:
+                    sftp.remove(target)
+                except IOError:
+                    pass
+                sftp.rename(partial, target)
+        finally:
+            client.close()
+
+    def _put_ftp(self, name: str, content: bytes) -> None:
+        ftp = ftplib.FTP_TLS() if self.url.scheme == "ftps" else ftplib.FTP()
+        ftp.connect(self.url.hostname, self.url.port or DEFAULT_PORTS["ftp"])
+        try:
+            ftp.login(unquote(self.url.username or "anonymous"), self._password() or "")
+            if isinstance(ftp, ftplib.FTP_TLS):
+                ftp.prot_p()
+            ftp.cwd(self.directory)
+            partial = f".{name}.part"
+            ftp.storbinary(f"STOR {partial}", BytesIO(content))
+            try:
+                ftp.rename(partial, name)
+            except ftplib.error_perm:
+                ftp.delete(name)
+                ftp.rename(partial, name)
+        finally:
+            ftp.close()
+
+    def upload(self, name: str, content: bytes) -> str:
+        """
+        Upload a file to the remote directory, retrying on failures.
+
+        Args:
+            name (str): File name.
+            content (bytes): File content.
+
+        Returns:
+            str: URL of the uploaded file, without credentials.
+
+        Raises:
+            RuntimeError: If paramiko is not installed for SFTP.
+            Exception: The error of the last attempt, once retries are exhausted.
+        """
+        put = self._put_sftp if self.url.scheme == "sftp" else self._put_ftp
+        for attempt in range(self.retries + 1):
+            try:
+                put(name, content)
+                break
+            except RuntimeError:
+                raise
+            except Exception as e:
+                if attempt == self.retries:
+                    raise
+                delay = self.retry_delay * 2**attempt
+                logger.warning(
+                    f"Upload of {name} to {self.host} failed ({e}); retrying in {delay:.0f}s."
+                )
+                time.sleep(delay)
+        return f"{self.url.scheme}://{self.host}{self.directory}/{name}"
+
+    def upload_export(
+        self,
+        dataset: Dataset,
+        file_format: Optional[str] = None,
+        name: Optional[str] = None,
+        compression: Optional[Compression] = None,
+        exporter: Optional[Exporter] = None,
+    ) -> str:
+        """
+        Upload a dataset export.
+
+        Args:
+            dataset (Dataset): Dataset to export.
+            file_format (Optional[str], optional): Export format. Defaults to
+                the format of the file name suffix, or "csv".
+            name (Optional[str], optional): File name template with the
+                placeholders of `services.export.naming`. Defaults to
+                `<report>_<run id>.<format>`.
+            compression (Optional[Compression], optional): "gzip" or "zip" to
+                compress the export. Defaults to no compression.
+            exporter (Optional[Exporter], optional): Exporter of the format,
+                with the options of the destination. Defaults to the exporter
+                of the format with its default options.
+
+        Returns:
+            str: URL of the uploaded file, without credentials.
+
+        Raises:
+            ValueError: If the format is unknown or the name template invalid.
+        """
+        if name is not None:
+            name = render_template(name, dataset)
+        file_format = file_format or (format_of(name) if name else None) or "csv"
+        name = name or f"{dataset.metadata.report}_{run_id_of(dataset)}.{file_format}"
+        content = (exporter or exporter_for(file_format)).render(dataset)
+        name, content = compress(name, content, compression)
+        url = self.upload(name, content)
+        logger.info(f"Uploaded {len(dataset.rows)} rows of {dataset.metadata.report} to {url}.")
+        return url
+
+
+def upload_to_url(
+    dataset: Dataset, destination: str, exporter_of: Callable[[str], Exporter] = exporter_for
+) -> str:
+    """
+    Upload a dataset export to an SFTP or FTP destination URL.
+
+    The query of the URL selects the export: `format`, `name` (file name
+    template) and `compression`.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        destination (str): Destination URL.
+        exporter_of (Callable[[str], Exporter], optional): Exporter of the
+            selected format, with the options of the destination. Defaults
+            to the exporter of the format with its default options.
+
+    Returns:
+        str: URL of the uploaded file, without credentials.
+    """
+    url = urlparse(destination)
+    query = parse_qs(url.query)
+    name = query.get("name", [None])[0]
+    file_format = query.get("format", [None])[0] or (format_of(name) if name else None) or "csv"
+    uploader = RemoteUpload(url._replace(query="").geturl())
+    return uploader.upload_export(
+        dataset,
+        file_format,
+        name,
+        query.get("compression", [None])[0],
+        exporter_of(file_format),
+    )
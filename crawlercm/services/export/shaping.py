@@ -0,0 +1,131 @@
+"""
+Per-destination shape of exported datasets.
+
+Each consumer of a report expects its own spreadsheet shape: a subset of
+the columns, in a given order, under the headers they know. `shape_dataset`
+applies that shape once, producing a dataset of plain dictionaries keyed
+by the output headers, so every exporter writes it unchanged:
+
+    shape_dataset(dataset, columns=["codigo", "unit_price"], rename={"codigo": "SKU"})
+    # rows: {"SKU": ..., "unit_price": ...}
+
+Columns are given by field name or stable English name. Values keep their
+Python types, so typed formats (XLSX, Parquet) still get numbers and dates.
+Reports written page by page are shaped page by page by `ShapedPageWriter`.
+"""
+
+from typing import Any, Dict, List, Mapping, Optional, Sequence, Tuple
+
+from core.utils.records import field_name, value_of
+from schemas.dataset_schemas import Dataset
+from services.export.columns import HeaderLanguage, header_of, select_columns
+from services.export.exporter import PageWriter
+
+
+def output_columns(
+    dataset: Dataset,
+    columns: Optional[Sequence[str]] = None,
+    rename: Optional[Mapping[str, str]] = None,
+    header_language: HeaderLanguage = "en",
+) -> List[Tuple[str, str]]:
+    """
+    Fields of a shaped export and their output headers.
+
+    Args:
+        dataset (Dataset): Dataset being exported.
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        rename (Optional[Mapping[str, str]], optional): Output header per
+            field name or English name.
+        header_language (HeaderLanguage, optional): Language of the headers
+            not renamed. Defaults to "en".
+
+    Returns:
+        List[Tuple[str, str]]: (field name, output header) pairs.
+
+    Raises:
+        KeyError: If a column does not exist in the dataset.
+        ValueError: If two columns end up with the same header.
+    """
+    renamed = {field_name(name): header for name, header in (rename or {}).items()}
+    layout = [
+        (name, renamed.get(name) or header_of(name, header_language))
+        for name, _ in select_columns(dataset, columns, header_language)
+    ]
+    headers = [header for _, header in layout]
+    duplicated = sorted({header for header in headers if headers.count(header) > 1})
+    if duplicated:
+        raise ValueError(f"Duplicated export headers: {', '.join(duplicated)}")
+    return layout
+
+
+def shape_dataset(
+    dataset: Dataset,
+    columns: Optional[Sequence[str]] = None,
+    rename: Optional[Mapping[str, str]] = None,
+    header_language: HeaderLanguage = "en",
+) -> Dataset:
+    """
+    Select, order and rename the columns of a dataset.
+
+    Args:
+        dataset (Dataset): Dataset to shape.
+        columns (Optional[Sequence[str]], optional): Fields to keep, in order.
+            Defaults to every field.
+        rename (Optional[Mapping[str, str]], optional): Output header per
+            field name or English name.
+        header_language (HeaderLanguage, optional): Language of the headers
+            not renamed. Defaults to "en".
+
+    Returns:
+        Dataset: A dataset with the same metadata whose rows are
+        dictionaries keyed by the output headers.
+
+    Raises:
+        KeyError: If a column does not exist in the dataset.
+        ValueError: If two columns end up with the same header.
+    """
+    layout = output_columns(dataset, columns, rename, header_language)
+    rows: List[Dict[str, object]] = [
+        {header: value_of(row, name) for name, header in layout} for row in dataset.rows
+    ]
+    return Dataset(metadata=dataset.metadata, rows=rows)
+
+
+class ShapedPageWriter(PageWriter):
+    """
+    Page writer shaping every page before handing it to another writer.
+
+    Args:
+        writer (PageWriter): Writer of the shaped pages.
+        columns (Optional[Sequence[str]], optional): Fields to keep, in order.
+            Defaults to every field.
+        rename (Optional[Mapping[str, str]], optional): Output header per
+            field name or English name.
+        header_language (HeaderLanguage, optional): Language of the headers
+            not renamed. Defaults to "en".
+    """
+
+    def __init__(
+        self,
+        writer: PageWriter,
+        columns: Optional[Sequence[str]] = None,
+        rename: Optional[Mapping[str, str]] = None,
+        header_language: HeaderLanguage = "en",
+    ):
+        super().__init__(writer.metadata)
+        self.writer = writer
+        self.columns = columns
+        self.rename = rename
+        self.header_language = header_language
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        page = Dataset(metadata=self.metadata, rows=list(rows))
+        shaped = shape_dataset(page, self.columns, self.rename, self.header_language)
+        self.writer.write_page(shaped.rows)
+
+    def close(self) -> int:
+        return self.writer.close()
+
+    def abort(self) -> None:
+        self.writer.abort()
@@ -0,0 +1,135 @@
+"""
+Google Sheets export of datasets.
+
+Writes a dataset to a tab of a spreadsheet shared with a service account,
+so the planning dashboards built on Sheets are fed directly by the report
+runs. In `replace` mode the tab is cleared and rewritten with the header
+and every row; in `append` mode the rows are added below the existing
+ones, and the header is only written to an empty tab. Missing tabs are
+created.
+
+Cells are written as raw values: numbers stay numbers, dates and
+timestamps are written as ISO text and missing values as empty cells.
+
+Requires the optional `sheets` dependencies (gspread).
+"""
+
+from datetime import date, datetime
+from decimal import Decimal
+from typing import Any, List, Literal, Optional, Sequence
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.columns import HeaderLanguage, native_values, select_columns
+
+SheetMode = Literal["replace", "append"]
+
+
+def _gspread() -> Any:
+    try:
+        import gspread
+    except ImportError as e:
+        raise RuntimeError(
+            "Google Sheets export requires gspread; install the 'sheets' extra"
+        ) from e
+    return gspread
+
+
+def _cell(value: Any) -> Any:
+    if value is None:
+        return ""
+    if isinstance(value, Decimal):
+        return float(value)
+    if isinstance(value, (date, datetime)):
+        return value.isoformat()
+    return value
+
+
+def sheet_values(
+    dataset: Dataset,
+    columns: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "en",
+) -> List[List[Any]]:
+    """
+    Header and rows of a dataset as Sheets cell values.
+
+    Args:
+        dataset (Dataset): Dataset to convert.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        header_language (HeaderLanguage, optional): Header language. Defaults to "en".
+
+    Returns:
+        List[List[Any]]: The header row followed by one row per dataset row.
+    """
+    layout = select_columns(dataset, columns, header_language)
+    fields = [name for name, _ in layout]
+    rows = [[_cell(value) for value in native_values(row, fields)] for row in dataset.rows]
+    return [[header for _, header in layout], *rows]
+
+
+def export_google_sheet(
+    dataset: Dataset,
+    spreadsheet_id: str,
+    worksheet: Optional[str] = None,
+    mode: SheetMode = "replace",
+    columns: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "en",
+    credentials_file: Optional[str] = None,
+) -> int:
+    """
+    Write a dataset to a Google Sheets tab.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        spreadsheet_id (str): Key of the spreadsheet, as found in its URL.
+        worksheet (Optional[str], optional): Tab title. Defaults to the report name.
+        mode (SheetMode, optional): "replace" to clear the tab first, "append"
+            to add the rows after the existing ones. Defaults to "replace".
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        header_language (HeaderLanguage, optional): Header language. Defaults to "en".
+        credentials_file (Optional[str], optional): Service account key file.
+            Defaults to `GOOGLE_SERVICE_ACCOUNT_FILE` from settings, or the
+            gspread default location when it is not set.
+
+    Returns:
+        int: Number of rows written.
+
+    Raises:
+        RuntimeError: If gspread is not installed.
+        ValueError: If the mode is unknown.
+    """
+    if mode not in ("replace", "append"):
+        raise ValueError(f"Unknown Google Sheets mode: {mode}")
+    gspread = _gspread()
+    credentials_file = credentials_file or settings.GOOGLE_SERVICE_ACCOUNT_FILE
+    client = (
+        gspread.service_account(filename=credentials_file)
+        if credentials_file
+        else gspread.service_account()
+    )
+    spreadsheet = client.open_by_key(spreadsheet_id)
+    title = worksheet or dataset.metadata.report
+    values = sheet_values(dataset, columns, header_language)
+    try:
+        sheet = spreadsheet.worksheet(title)
+    except gspread.WorksheetNotFound:
+        sheet = spreadsheet.add_worksheet(title=title, rows=len(values), cols=len(values[0]) or 1)
+
+    if mode == "replace":
+        sheet.clear()
+        sheet.update(values, "A1", value_input_option="RAW")
+    elif sheet.row_values(1):
+        if values[1:]:
+            sheet.append_rows(values[1:], value_input_option="RAW")
+    else:
+        sheet.append_rows(values, value_input_option="RAW")
+
+    count = len(values) - 1
+    logger.info(
+        f"Exported {count} rows of {dataset.metadata.report} to Google Sheets "
+        f"{spreadsheet_id} ({title}, {mode})."
+    )
+    return count
@@ -0,0 +1,150 @@
+"""
+Table schemas of datasets written to SQL databases.
+
+Shared by the database sinks: columns are named with the stable English
+field names and their logical type is inferred from the typed values of
+the rows. Each sink maps the logical types to its own SQL types.
+"""
+
+import re
+import types
+from datetime import date, datetime
+from decimal import Decimal
+from typing import Any, Dict, List, Literal, Sequence, Tuple, Union, get_args, get_origin
+
+from pydantic import BaseModel
+
+from schemas.dataset_schemas import Dataset
+from services.export.columns import native_values, select_columns
+
+ColumnKind = Literal["boolean", "integer", "real", "numeric", "date", "timestamp", "text"]
+
+_IDENTIFIER = re.compile(r"^[A-Za-z_][A-Za-z0-9_]*$")
+
+
+def check_identifier(name: str) -> str:
+    """
+    Ensure a table or column name is a safe SQL identifier.
+
+    Args:
+        name (str): Identifier.
+
+    Returns:
+        str: The identifier.
+
+    Raises:
+        ValueError: If the identifier contains anything but letters, digits
+            and underscores.
+    """
+    if not _IDENTIFIER.match(name):
+        raise ValueError(f"Invalid SQL identifier: {name}")
+    return name
+
+
+def _kind(value: Any) -> ColumnKind:
+    if isinstance(value, bool):
+        return "boolean"
+    if isinstance(value, int):
+        return "integer"
+    if isinstance(value, float):
+        return "real"
+    if isinstance(value, Decimal):
+        return "numeric"
+    if isinstance(value, datetime):
+        return "timestamp"
+    if isinstance(value, date):
+        return "date"
+    return "text"
+
+
+_TYPE_KINDS: List[Tuple[type, ColumnKind]] = [
+    (bool, "boolean"),
+    (int, "integer"),
+    (float, "real"),
+    (Decimal, "numeric"),
+    (datetime, "timestamp"),
+    (date, "date"),
+]
+
+
+def annotation_kind(annotation: Any) -> ColumnKind:
+    """
+    Column kind of a model field annotation.
+
+    Args:
+        annotation (Any): Field annotation; `Optional[...]` is unwrapped.
+
+    Returns:
+        ColumnKind: The kind of the type, or "text" for any other type.
+    """
+    if get_origin(annotation) in (Union, types.UnionType):
+        args = [arg for arg in get_args(annotation) if arg is not type(None)]
+        if len(args) == 1:
+            annotation = args[0]
+    if isinstance(annotation, type):
+        for python_type, kind in _TYPE_KINDS:
+            if issubclass(annotation, python_type):
+                return kind
+    return "text"
+
+
+def table_layout(dataset: Dataset) -> Tuple[List[str], List[Tuple[str, ColumnKind]]]:
+    """
+    Fields of a dataset and the columns they are stored in.
+
+    The kind of a column is taken from its first non-missing value; columns
+    without any value take the kind of their model field annotation, or are
+    text for dictionary rows.
+
+    Args:
+        dataset (Dataset): Dataset to store.
+
+    Returns:
+        Tuple[List[str], List[Tuple[str, ColumnKind]]]: Field names, and the
+        (column name, kind) pair of each field.
+    """
+    layout = select_columns(dataset, None, "en")
+    fields = [name for name, _ in layout]
+    kinds: Dict[str, ColumnKind] = {}
+    for row in dataset.rows:
+        for (_, column), value in zip(layout, native_values(row, fields)):
+            if column not in kinds and value is not None:
+                kinds[column] = _kind(value)
+        if len(kinds) == len(layout):
+            break
+    model_fields = (
+        type(dataset.rows[0]).model_fields
+        if dataset.rows and isinstance(dataset.rows[0], BaseModel)
+        else {}
+    )
+    for name, column in layout:
+        if column not in kinds and name in model_fields:
+            kinds[column] = annotation_kind(model_fields[name].annotation)
+    return fields, [(check_identifier(column), kinds.get(column, "text")) for _, column in layout]
+
+
+def table_rows(dataset: Dataset, fields: Sequence[str]) -> List[List[Any]]:
+    """
+    Typed values of every row, in the order of `fields`.
+
+    Args:
+        dataset (Dataset): Dataset to store.
+        fields (Sequence[str]): Field names.
+
+    Returns:
+        List[List[Any]]: One list of values per row.
+    """
+    return [native_values(row, fields) for row in dataset.rows]
+
+
+def run_id_of(dataset: Dataset) -> str:
+    """
+    Default identifier of the run a dataset was fetched in.
+
+    Args:
+        dataset (Dataset): Fetched dataset.
+
+    Returns:
+        str: The fetch timestamp, in the snapshot id format.
+    """
+    return dataset.metadata.fetched_at.strftime("%Y%m%dT%H%M%S%f")
@@ -0,0 +1,340 @@
+"""
+Base class of the database sinks.
+
+`SQLSink` holds the logic shared by the server databases (PostgreSQL,
+MySQL): the target table of a report is created on first use and new
+columns are added when the report model grows, rows are written with the
+write mode of the report, and every write is recorded in a run log
+table. Subclasses provide the connection, identifier quoting, column
+types and the dialect-specific upsert statement, using any DB-API 2.0
+driver.
+
+Write modes:
+
+    merge    rows are upserted by the business key of the report (default)
+    append   rows are added with the run id, keeping the history of every
+             run; the key of the table is the business key plus `run_id`
+    replace  the table is emptied and loaded with the rows, in the same
+             transaction
+
+The mode a table was created with defines its primary key, so a report
+should keep writing to a table with the same mode.
+
+Large reports are written page by page, as they are fetched, with the
+`SQLPageWriter` of `open_pages`.
+"""
+
+import json
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from core.logger import logger
+from core.utils.field_names import english_name
+from core.utils.records import field_name
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.runner_schemas import WriteMode
+from services.export.exporter import PageWriter
+from services.export.sql_schema import (
+    ColumnKind,
+    check_identifier,
+    run_id_of,
+    table_layout,
+    table_rows,
+)
+from services.report_registry import REPORTS
+
+RUN_LOG_TABLE = "run_log"
+
+
+class SQLSink(ABC):
+    """
+    Writes datasets into a database table per report.
+
+    Args:
+        schema (Optional[str]): Database schema of the tables, if any.
+    """
+
+    placeholder = "%s"
+    types: Dict[ColumnKind, str] = {}
+
+    def __init__(self, schema: Optional[str] = None):
+        self.schema = check_identifier(schema) if schema else None
+
+    @abstractmethod
+    def connect(self) -> Any:
+        """
+        Open a DB-API connection.
+        """
+
+    @abstractmethod
+    def quote(self, identifier: str) -> str:
+        """
+        Quote an identifier for the dialect.
+        """
+
+    @abstractmethod
+    def upsert_sql(self, table: str, columns: Sequence[str], keys: Sequence[str]) -> str:
+        """
+        Statement inserting a row, or updating it when its key already exists.
+        """
+
+    @abstractmethod
+    def existing_columns(self, cursor: Any, table: str) -> List[str]:
+        """
+        Columns of a table, empty when the table does not exist.
+        """
+
+    def column_type(self, kind: ColumnKind, key: bool = False) -> str:
+        """
+        SQL type of a column of a logical kind.
+
+        Args:
+            kind (ColumnKind): Logical kind of the column.
+            key (bool, optional): Whether the column is part of the primary key.
+
+        Returns:
+            str: The SQL type.
+        """
+        return self.types[kind]
+
+    def table_name(self, table: str) -> str:
+        """
+        Qualified and quoted name of a table.
+        """
+        name = self.quote(check_identifier(table))
+        return f"{self.quote(self.schema)}.{name}" if self.schema else name
+
+    def _ensure_table(
+        self, cursor: Any, table: str, columns: Sequence[Tuple[str, ColumnKind]], keys: Sequence[str]
+    ) -> None:
+        existing = self.existing_columns(cursor, table)
+        all_columns = [*columns, ("run_id", "text"), ("updated_at", "timestamp")]
+        if not existing:
+            definitions = [
+                f"{self.quote(name)} {self.column_type(kind, name in keys)}"
+                + (" NOT NULL" if name in keys else "")
+                for name, kind in all_columns
+            ]
+            if keys:
+                definitions.append(f"PRIMARY KEY ({', '.join(self.quote(key) for key in keys)})")
+            cursor.execute(f"CREATE TABLE {self.table_name(table)} ({', '.join(definitions)})")
+            logger.info(f"Created table {self.table_name(table)}.")
+            return
+        for name, kind in all_columns:
+            if name not in existing:
+                logger.info(f"Adding column {name} to {self.table_name(table)}.")
+                cursor.execute(
+                    f"ALTER TABLE {self.table_name(table)} "
+                    f"ADD COLUMN {self.quote(name)} {self.column_type(kind)}"
+                )
+
+    def _ensure_run_log(self, cursor: Any) -> None:
+        if self.existing_columns(cursor, RUN_LOG_TABLE):
+            return
+        columns = [
+            ("run_id", "text"),
+            ("report", "text"),
+            ("fetched_at", "timestamp"),
+            ("source_url", "text"),
+            ("filters", "text"),
+            ("page_count", "integer"),
+            ("row_count", "integer"),
+            ("elapsed_seconds", "real"),
+            ("parse_errors", "integer"),
+            ("written_at", "timestamp"),
+        ]
+        definitions = ", ".join(f"{self.quote(name)} {self.column_type(kind)}" for name, kind in columns)
+        cursor.execute(f"CREATE TABLE {self.table_name(RUN_LOG_TABLE)} ({definitions})")
+
+    def insert_sql(self, table: str, columns: Sequence[str]) -> str:
+        """
+        Statement inserting a row.
+        """
+        names = ", ".join(self.quote(name) for name in columns)
+        marks = ", ".join([self.placeholder] * len(columns))
+        return f"INSERT INTO {self.table_name(table)} ({names}) VALUES ({marks})"
+
+    def _key_columns(
+        self, report: str, key_fields: Optional[Sequence[str]], mode: WriteMode
+    ) -> List[str]:
+        if key_fields is None:
+            definition = REPORTS.get(report)
+            key_fields = definition.key_fields if definition else []
+        if not key_fields and mode == "merge":
+            raise ValueError(f"A business key is required to merge {report}")
+        keys = [check_identifier(english_name(field_name(key))) for key in key_fields]
+        return [*keys, "run_id"] if keys and mode == "append" else keys
+
+    def open_pages(
+        self,
+        metadata: DatasetMetadata,
+        key_fields: Optional[Sequence[str]] = None,
+        run_id: Optional[str] = None,
+        mode: Optional[WriteMode] = None,
+    ) -> "SQLPageWriter":
+        """
+        Start writing a report into its table page by page, in one
+        transaction committed with the run log when the writer is closed.
+
+        Args:
+            metadata (DatasetMetadata): Metadata of the report.
+            key_fields (Optional[Sequence[str]], optional): Business key of the
+                rows. Defaults to the key of the report in the registry.
+            run_id (Optional[str], optional): Identifier of the run. Defaults
+                to the fetch timestamp of the report.
+            mode (Optional[WriteMode], optional): "merge", "append" or
+                "replace". Defaults to the write mode of the report in the
+                registry, or "merge".
+
+        Returns:
+            SQLPageWriter: Writer of the pages.
+
+        Raises:
+            ValueError: If the report has no business key to merge by or an
+                invalid name.
+        """
+        if mode is None:
+            definition = REPORTS.get(metadata.report)
+            mode = (definition.write_mode if definition else None) or "merge"
+        keys = self._key_columns(metadata.report, key_fields, mode)
+        return SQLPageWriter(self, metadata, keys, run_id, mode)
+
+    def write(
+        self,
+        dataset: Dataset,
+        key_fields: Optional[Sequence[str]] = None,
+        run_id: Optional[str] = None,
+        mode: Optional[WriteMode] = None,
+    ) -> int:
+        """
+        Write a dataset into the table of its report and log the run.
+
+        Args:
+            dataset (Dataset): Dataset to store.
+            key_fields (Optional[Sequence[str]], optional): Business key of the
+                rows. Defaults to the key of the report in the registry.
+            run_id (Optional[str], optional): Identifier of the run. Defaults
+                to the fetch timestamp of the dataset.
+            mode (Optional[WriteMode], optional): "merge", "append" or
+                "replace". Defaults to the write mode of the report in the
+                registry, or "merge".
+
+        Returns:
+            int: Number of rows written.
+
+        Raises:
+            ValueError: If the report has no business key to merge by or an
+                invalid name.
+        """
+        writer = self.open_pages(dataset.metadata, key_fields, run_id, mode)
+        try:
+            writer.write_page(dataset.rows)
+        except Exception:
+            writer.abort()
+            raise
+        return writer.close()
+
+    def to_db(self, value: Any) -> Any:
+        """
+        Convert a typed value into a parameter accepted by the driver.
+        """
+        return value
+
+
+class SQLPageWriter(PageWriter):
+    """
+    Rows of a report written into its table page by page, in a single
+    transaction. New columns of later pages are added to the table.
+    """
+
+    def __init__(
+        self,
+        sink: SQLSink,
+        metadata: DatasetMetadata,
+        keys: Sequence[str],
+        run_id: Optional[str],
+        mode: WriteMode,
+    ):
+        super().__init__(metadata)
+        self.sink = sink
+        self.table = check_identifier(metadata.report)
+        self.keys = keys
+        self.run_id = run_id or run_id_of(Dataset(metadata=metadata, rows=[]))
+        self.mode = mode
+        self.now = datetime.now(timezone.utc)
+        self.emptied = False
+        self.connection = sink.connect()
+        try:
+            self.cursor = self.connection.cursor()
+            sink._ensure_run_log(self.cursor)
+        except Exception:
+            self.connection.close()
+            raise
+
+    def _prepare(self, columns: Sequence[Tuple[str, ColumnKind]]) -> None:
+        self.sink._ensure_table(self.cursor, self.table, columns, self.keys)
+        if self.mode == "replace" and not self.emptied:
+            self.cursor.execute(f"DELETE FROM {self.sink.table_name(self.table)}")
+            self.emptied = True
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        if not rows:
+            return
+        page = Dataset(metadata=self.metadata, rows=list(rows))
+        fields, columns = table_layout(page)
+        names = [name for name, _ in columns]
+        missing = [key for key in self.keys if key not in names and key != "run_id"]
+        if missing:
+            raise ValueError(f"Key columns not in {self.table}: {', '.join(missing)}")
+        to_db = self.sink.to_db
+        values = [
+            [*map(to_db, row), self.run_id, to_db(self.now)] for row in table_rows(page, fields)
+        ]
+        self._prepare(columns)
+        all_names = [*names, "run_id", "updated_at"]
+        if self.keys:
+            statement = self.sink.upsert_sql(self.table, all_names, self.keys)
+        else:
+            statement = self.sink.insert_sql(self.table, all_names)
+        self.cursor.executemany(statement, values)
+
+    def close(self) -> int:
+        metadata = self.metadata
+        try:
+            if self.mode == "replace" and not self.emptied:
+                self._prepare(table_layout(Dataset(metadata=metadata, rows=[]))[1])
+            log = (
+                self.run_id,
+                metadata.report,
+                metadata.fetched_at,
+                metadata.source_url,
+                json.dumps(metadata.filters, ensure_ascii=False, default=str),
+                metadata.page_count,
+                self.row_count,
+                metadata.elapsed_seconds,
+                len(metadata.parse_errors),
+                self.now,
+            )
+            marks = ", ".join([self.sink.placeholder] * len(log))
+            self.cursor.execute(
+                f"INSERT INTO {self.sink.table_name(RUN_LOG_TABLE)} VALUES ({marks})",
+                tuple(map(self.sink.to_db, log)),
+            )
+            self.connection.commit()
+        except Exception:
+            self.connection.rollback()
+            raise
+        finally:
+            self.connection.close()
+        logger.info(
+            f"Wrote {self.row_count} rows into {self.sink.table_name(self.table)} ({self.mode}) "
+            f"as run {self.run_id}."
+        )
+        return self.row_count
+
+    def abort(self) -> None:
+        try:
+            self.connection.rollback()
+        finally:
+            self.connection.close()
@@ -0,0 +1,267 @@
+"""
+SQLite persistence of datasets.
+
+Gives small automations a queryable history without a database server:
+every dataset is appended to a table named after its report, created on
+first use and extended with new columns when the report model grows, and
+each write is recorded in the `runs` table with the dataset metadata.
+Rows carry the `run_id` of the write they came from.
+
+Reports that only need their latest state are written with the `merge`
+mode, upserting by the business key through a unique index on it, or the
+`replace` mode, which empties the table before loading the rows. The
+unique index created by `merge` stays on the table, so a report should
+keep the same mode. Large reports are written page by page, as they are
+fetched, with the `SQLitePageWriter` of `open_pages`.
+"""
+
+import json
+import sqlite3
+from datetime import date, datetime
+from decimal import Decimal
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Sequence
+
+from core.logger import logger
+from core.utils.field_names import english_name
+from core.utils.records import field_name
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.runner_schemas import WriteMode
+from services.export.exporter import PageWriter
+from services.export.sql_schema import (
+    ColumnKind,
+    check_identifier,
+    run_id_of,
+    table_layout,
+    table_rows,
+)
+from services.report_registry import REPORTS
+
+RUNS_TABLE = "runs"
+
+_TYPES: Dict[ColumnKind, str] = {
+    "boolean": "INTEGER",
+    "integer": "INTEGER",
+    "real": "REAL",
+    "numeric": "NUMERIC",
+    "date": "TEXT",
+    "timestamp": "TEXT",
+    "text": "TEXT",
+}
+
+
+def _to_sqlite(value: Any) -> Any:
+    if isinstance(value, bool):
+        return int(value)
+    if isinstance(value, Decimal):
+        return float(value)
+    if isinstance(value, (date, datetime)):
+        return value.isoformat()
+    return value
+
+
+class SQLiteSink:
+    """
+    Writes datasets into a local SQLite file.
+
+    Args:
+        path (str | Path): Database file; created with its parent directories
+            when missing.
+    """
+
+    def __init__(self, path: str | Path):
+        self.path = Path(path)
+
+    def _connect(self) -> sqlite3.Connection:
+        self.path.parent.mkdir(parents=True, exist_ok=True)
+        # Streamed reports write their pages from the worker threads of the
+        # runner, one page at a time.
+        return sqlite3.connect(self.path, check_same_thread=False)
+
+    def _ensure_runs_table(self, connection: sqlite3.Connection) -> None:
+        connection.execute(
+            f"CREATE TABLE IF NOT EXISTS {RUNS_TABLE} ("
+            "run_id TEXT NOT NULL, report TEXT NOT NULL, fetched_at TEXT, source_url TEXT, "
+            "filters TEXT, page_count INTEGER, row_count INTEGER, elapsed_seconds REAL, "
+            "parse_errors INTEGER, written_at TEXT NOT NULL, PRIMARY KEY (run_id, report))"
+        )
+
+    def _ensure_table(self, connection: sqlite3.Connection, table: str, columns) -> None:
+        definitions = ", ".join(f"{name} {_TYPES[kind]}" for name, kind in columns)
+        connection.execute(
+            f"CREATE TABLE IF NOT EXISTS {table} (run_id TEXT NOT NULL"
+            + (f", {definitions}" if definitions else "")
+            + ")"
+        )
+        existing = {row[1] for row in connection.execute(f"PRAGMA table_info({table})")}
+        for name, kind in columns:
+            if name not in existing:
+                logger.info(f"Adding column {name} to SQLite table {table}.")
+                connection.execute(f"ALTER TABLE {table} ADD COLUMN {name} {_TYPES[kind]}")
+
+    def _key_columns(self, report: str) -> List[str]:
+        definition = REPORTS.get(report)
+        if not definition or not definition.key_fields:
+            raise ValueError(f"A business key is required to merge {report}")
+        return [check_identifier(english_name(field_name(key))) for key in definition.key_fields]
+
+    def open_pages(
+        self,
+        metadata: DatasetMetadata,
+        run_id: Optional[str] = None,
+        mode: Optional[WriteMode] = None,
+    ) -> "SQLitePageWriter":
+        """
+        Start writing a report to its table page by page, in one transaction
+        committed with the run record when the writer is closed.
+
+        Args:
+            metadata (DatasetMetadata): Metadata of the report.
+            run_id (Optional[str], optional): Identifier of the run. Defaults
+                to the fetch timestamp of the report.
+            mode (Optional[WriteMode], optional): "append", "merge" or
+                "replace". Defaults to the write mode of the report in the
+                registry, or "append".
+
+        Returns:
+            SQLitePageWriter: Writer of the pages.
+
+        Raises:
+            ValueError: If the report name is not a valid table name, or the
+                report has no business key to merge by.
+        """
+        table = check_identifier(metadata.report)
+        if mode is None:
+            definition = REPORTS.get(metadata.report)
+            mode = (definition.write_mode if definition else None) or "append"
+        keys = self._key_columns(metadata.report) if mode == "merge" else []
+        return SQLitePageWriter(self, metadata, table, keys, run_id, mode)
+
+    def write(
+        self, dataset: Dataset, run_id: Optional[str] = None, mode: Optional[WriteMode] = None
+    ) -> int:
+        """
+        Write a dataset to the table of its report and record the run.
+
+        Args:
+            dataset (Dataset): Dataset to store.
+            run_id (Optional[str], optional): Identifier of the run. Defaults
+                to the fetch timestamp of the dataset.
+            mode (Optional[WriteMode], optional): "append", "merge" or
+                "replace". Defaults to the write mode of the report in the
+                registry, or "append".
+
+        Returns:
+            int: Number of rows written.
+
+        Raises:
+            ValueError: If the report name is not a valid table name, or the
+                report has no business key to merge by.
+            sqlite3.IntegrityError: If the run was already written for the
+                report, or rows already stored repeat the key to merge by.
+        """
+        writer = self.open_pages(dataset.metadata, run_id, mode)
+        try:
+            writer.write_page(dataset.rows)
+        except Exception:
+            writer.abort()
+            raise
+        return writer.close()
+
+
+class SQLitePageWriter(PageWriter):
+    """
+    Rows of a report written to its SQLite table page by page, in a single
+    transaction. New columns of later pages are added to the table.
+    """
+
+    def __init__(
+        self,
+        sink: SQLiteSink,
+        metadata: DatasetMetadata,
+        table: str,
+        keys: List[str],
+        run_id: Optional[str],
+        mode: WriteMode,
+    ):
+        super().__init__(metadata)
+        self.sink = sink
+        self.table = table
+        self.keys = keys
+        self.run_id = run_id or run_id_of(Dataset(metadata=metadata, rows=[]))
+        self.mode = mode
+        self.prepared = False
+        self.connection = sink._connect()
+        try:
+            sink._ensure_runs_table(self.connection)
+        except Exception:
+            self.connection.close()
+            raise
+
+    def _prepare(self, columns) -> None:
+        self.sink._ensure_table(self.connection, self.table, columns)
+        if self.prepared:
+            return
+        if self.keys:
+            self.connection.execute(
+                f"CREATE UNIQUE INDEX IF NOT EXISTS {self.table}_key "
+                f"ON {self.table} ({', '.join(self.keys)})"
+            )
+        if self.mode == "replace":
+            self.connection.execute(f"DELETE FROM {self.table}")
+        self.prepared = True
+
+    def _write_page(self, rows: Sequence[Any]) -> None:
+        if not rows:
+            return
+        page = Dataset(metadata=self.metadata, rows=list(rows))
+        fields, columns = table_layout(page)
+        self._prepare(columns)
+        values = [[self.run_id, *map(_to_sqlite, row)] for row in table_rows(page, fields)]
+        names = ["run_id", *(name for name, _ in columns)]
+        marks = ", ".join("?" * len(names))
+        statement = f"INSERT INTO {self.table} ({', '.join(names)}) VALUES ({marks})"
+        if self.keys:
+            updates = ", ".join(
+                f"{name} = excluded.{name}" for name in names if name not in self.keys
+            )
+            statement += f" ON CONFLICT ({', '.join(self.keys)}) DO UPDATE SET {updates}"
+        self.connection.executemany(statement, values)
+
+    def close(self) -> int:
+        metadata = self.metadata
+        try:
+            if not self.prepared:
+                self._prepare(table_layout(Dataset(metadata=metadata, rows=[]))[1])
+            self.connection.execute(
+                f"INSERT INTO {RUNS_TABLE} VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+                (
+                    self.run_id,
+                    metadata.report,
+                    metadata.fetched_at.isoformat(),
+                    metadata.source_url,
+                    json.dumps(metadata.filters, ensure_ascii=False, default=str),
+                    metadata.page_count,
+                    self.row_count,
+                    metadata.elapsed_seconds,
+                    len(metadata.parse_errors),
+                    datetime.now().isoformat(),
+                ),
+            )
+            self.connection.commit()
+        except Exception:
+            self.connection.rollback()
+            raise
+        finally:
+            self.connection.close()
+        logger.info(
+            f"Stored {self.row_count} rows of {self.table} in {self.sink.path} ({self.mode}) "
+            f"as run {self.run_id}."
+        )
+        return self.row_count
+
+    def abort(self) -> None:
+        try:
+            self.connection.rollback()
+        finally:
+            self.connection.close()
@@ -0,0 +1,96 @@
+"""
+Plain text table of datasets.
+
+Renders the rows as an aligned text table for reading in a terminal (the
+default output of the `lanx` CLI on a TTY) or in a `.txt` file, with the
+values formatted as in printed reports:
+
+    OP     Código  Quantidade
+    -----  ------  ----------
+    12001  MP-07       1.250
+    12345  MP-01          30
+
+Numeric columns are aligned to the right, and values longer than the
+column limit are cut with an ellipsis. A line with the row count closes
+the table.
+"""
+
+from dataclasses import dataclass
+from decimal import Decimal
+from typing import List, Optional, Sequence, TextIO
+
+from schemas.dataset_schemas import Dataset
+from services.export.columns import HeaderLanguage, display_values, select_columns
+from services.export.exporter import TextExporter
+
+
+def _cut(text: str, width: int) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= width else f"{text[: width - 1]}…"
+
+
+def text_table(
+    dataset: Dataset,
+    columns: Optional[Sequence[str]] = None,
+    header_language: HeaderLanguage = "pt-BR",
+    max_width: int = 40,
+) -> str:
+    """
+    Render the rows of a dataset as an aligned text table.
+
+    Args:
+        dataset (Dataset): Dataset to render.
+        columns (Optional[Sequence[str]], optional): Fields to include, in
+            order. Defaults to every field.
+        header_language (HeaderLanguage, optional): Header language. Defaults to "pt-BR".
+        max_width (int, optional): Maximum width of a column. Defaults to 40.
+
+    Returns:
+        str: The table, ending with the row count.
+    """
+    layout = select_columns(dataset, columns, header_language)
+    fields = [name for name, _ in layout]
+    numeric = [
+        any(
+            isinstance(value, (int, float, Decimal)) and not isinstance(value, bool)
+            for value in dataset.column(name)
+        )
+        for name in fields
+    ]
+    header = [_cut(title, max_width) for _, title in layout]
+    rows = [
+        [_cut(text, max_width) for text in display_values(row, fields)] for row in dataset.rows
+    ]
+    widths = [max([len(title), *(len(row[i]) for row in rows)]) for i, title in enumerate(header)]
+
+    def line(values: Sequence[str]) -> str:
+        cells: List[str] = [
+            value.rjust(width) if right else value.ljust(width)
+            for value, width, right in zip(values, widths, numeric)
+        ]
+        return "  ".join(cells).rstrip()
+
+    lines = [line(header), line(["-" * width for width in widths])]
+    lines.extend(line(row) for row in rows)
+    count = len(dataset.rows)
+    lines.append(f"({count} {'linha' if count == 1 else 'linhas'})")
+    return "\n".join(lines) + "\n"
+
+
+@dataclass
+class TableExporter(TextExporter):
+    """
+    Aligned plain text table, for terminals.
+    """
+
+    columns: Optional[Sequence[str]] = None
+    header_language: HeaderLanguage = "pt-BR"
+    max_width: int = 40
+
+    format = "table"
+    extension = ".txt"
+    media_type = "text/plain"
+
+    def write_text(self, dataset: Dataset, stream: TextIO) -> int:
+        stream.write(text_table(dataset, self.columns, self.header_language, self.max_width))
+        return len(dataset.rows)
@@ -0,0 +1,175 @@
+"""
+Webhook destination.
+
+POSTs report outputs to the endpoints of internal systems subscribed to
+them, so they no longer poll the exports. The body is a JSON document with
+the dataset metadata, the position of the chunk and its rows:
+
+    {"metadata": {...}, "chunk": {"index": 1, "count": 3, "rows": 5000}, "rows": [...]}
+
+Datasets are sent in one request, or in chunks of `WEBHOOK_CHUNK_SIZE`
+rows. When `WEBHOOK_SECRET` is configured every request is signed with an
+HMAC-SHA256 of the timestamp and the body, which receivers verify before
+trusting the payload:
+
+    X-Lanx-Timestamp: 1738332000
+    X-Lanx-Signature: sha256=<hex digest of "1738332000.<body>">
+    X-Lanx-Delivery: <id shared by every chunk and retry of a delivery>
+
+Failed requests (network errors, 429 and 5xx responses) are retried
+`UPLOAD_RETRIES` times with an exponential delay; other responses fail
+the delivery at once.
+"""
+
+import hashlib
+import hmac
+import json
+import time
+import uuid
+from typing import Dict, Optional
+from urllib.error import HTTPError, URLError
+from urllib.request import Request, urlopen
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from services.export.json_export import row_json
+
+TIMEOUT_SECONDS = 60
+
+
+def signature(secret: str, timestamp: str, body: bytes) -> str:
+    """
+    Signature of a webhook request.
+
+    Args:
+        secret (str): Secret shared with the receiver.
+        timestamp (str): Unix time of the request, as sent in `X-Lanx-Timestamp`.
+        body (bytes): Request body.
+
+    Returns:
+        str: `sha256=` followed by the hex HMAC-SHA256 of `<timestamp>.<body>`.
+    """
+    message = f"{timestamp}.".encode("ascii") + body
+    digest = hmac.new(secret.encode("utf-8"), message, hashlib.sha256)
+    return f"sha256={digest.hexdigest()}"
+
+
+def _retryable(error: Exception) -> bool:
+    if isinstance(error, HTTPError):
+        return error.code == 429 or error.code >= 500
+    return isinstance(error, (URLError, TimeoutError, ConnectionError))
+
+
+class Webhook:
+    """
+    Sender of datasets to a webhook endpoint.
+
+    Args:
+        url (Optional[str], optional): Endpoint receiving the POST requests.
+            Defaults to `WEBHOOK_URL`.
+        secret (Optional[str], optional): Secret signing the requests.
+            Defaults to `WEBHOOK_SECRET`; requests are not signed when unset.
+        chunk_size (Optional[int], optional): Rows per request; 0 sends the
+            dataset in a single request. Defaults to `WEBHOOK_CHUNK_SIZE`.
+        retries (Optional[int], optional): Attempts after a failed request.
+            Defaults to `UPLOAD_RETRIES`.
+        retry_delay (Optional[float], optional): Seconds before the first
+            retry, doubled on every attempt. Defaults to `UPLOAD_RETRY_DELAY_SECONDS`.
+
+    Raises:
+        ValueError: If no endpoint is configured or the chunk size is negative.
+    """
+
+    def __init__(
+        self,
+        url: Optional[str] = None,
+        secret: Optional[str] = None,
+        chunk_size: Optional[int] = None,
+        retries: Optional[int] = None,
+        retry_delay: Optional[float] = None,
+    ):
+        self.url = url or settings.WEBHOOK_URL
+        if not self.url:
+            raise ValueError("No webhook configured; set WEBHOOK_URL")
+        self.secret = secret or settings.WEBHOOK_SECRET
+        self.chunk_size = settings.WEBHOOK_CHUNK_SIZE if chunk_size is None else chunk_size
+        if self.chunk_size < 0:
+            raise ValueError("The webhook chunk size cannot be negative")
+        self.retries = settings.UPLOAD_RETRIES if retries is None else retries
+        self.retry_delay = (
+            settings.UPLOAD_RETRY_DELAY_SECONDS if retry_delay is None else retry_delay
+        )
+
+    def _headers(self, body: bytes, delivery: str) -> Dict[str, str]:
+        headers = {"Content-Type": "application/json", "X-Lanx-Delivery": delivery}
+        if self.secret:
+            timestamp = str(int(time.time()))
+            headers["X-Lanx-Timestamp"] = timestamp
+            headers["X-Lanx-Signature"] = signature(self.secret, timestamp, body)
+        return headers
+
+    def post(self, body: bytes, delivery: str) -> None:
+        """
+        POST a body to the endpoint, retrying on transient failures.
+
+        Args:
+            body (bytes): JSON body.
+            delivery (str): Delivery id sent in `X-Lanx-Delivery`.
+
+        Raises:
+            RuntimeError: If the endpoint rejects the request, or it still
+                fails once retries are exhausted.
+        """
+        endpoint = self.url.split("?")[0]
+        for attempt in range(self.retries + 1):
+            request = Request(
+                self.url, data=body, method="POST", headers=self._headers(body, delivery)
+            )
+            try:
+                with urlopen(request, timeout=TIMEOUT_SECONDS):
+                    return
+            except Exception as e:
+                if isinstance(e, HTTPError):
+                    detail = e.read().decode("utf-8", "replace")[:500]
+                    error = f"{e.code} {detail}"
+                else:
+                    error = str(e)
+                if attempt == self.retries or not _retryable(e):
+                    raise RuntimeError(f"Webhook POST {endpoint} failed: {error}") from e
+                delay = self.retry_delay * 2**attempt
+                logger.warning(
+                    f"Webhook POST {endpoint} failed ({error}); retrying in {delay:.0f}s."
+                )
+                time.sleep(delay)
+
+    def send(self, dataset: Dataset) -> int:
+        """
+        Send the rows of a dataset, in chunks when a chunk size is set.
+
+        Args:
+            dataset (Dataset): Dataset to send.
+
+        Returns:
+            int: Number of requests sent.
+
+        Raises:
+            RuntimeError: If a request fails.
+        """
+        rows = [row_json(row) for row in dataset.rows]
+        size = self.chunk_size or len(rows) or 1
+        chunks = [rows[start : start + size] for start in range(0, len(rows), size)] or [[]]
+        metadata = dataset.metadata.model_dump(mode="json")
+        delivery = str(uuid.uuid4())
+        for index, chunk in enumerate(chunks, start=1):
+            payload = {
+                "metadata": metadata,
+                "chunk": {"index": index, "count": len(chunks), "rows": len(chunk)},
+                "rows": chunk,
+            }
+            self.post(json.dumps(payload, ensure_ascii=False).encode("utf-8"), delivery)
+        logger.info(
+            f"Sent {len(rows)} rows of {dataset.metadata.report} to webhook "
+            f"{self.url.split('?')[0]} in {len(chunks)} requests."
+        )
+        return len(chunks)
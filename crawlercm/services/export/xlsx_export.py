@@ -0,0 +1,265 @@
+"""
+Excel export of datasets.
+
+Writes any dataset to an .xlsx workbook with typed cells: numbers and
+money as numbers, dates and timestamps as Excel dates and flags as
+booleans, so consumers do not have to re-type the values. Timestamps are
+written in the portal timezone, since Excel has no timezone support.
+
+Worksheets are presentation-ready by default: money fields are formatted
+as R$ and percentages with their symbol, the header row is frozen with an
+autofilter and columns are sized to their content. `XlsxFormatting`
+overrides the formats and widths per column or turns these off.
+"""
+
+from dataclasses import dataclass
+from datetime import date, datetime
+from decimal import Decimal
+from io import BytesIO
+from pathlib import Path
+from typing import Any, BinaryIO, Dict, List, Optional, Sequence, Tuple
+
+import xlsxwriter
+
+from core.logger import logger
+from core.utils.field_names import english_name
+from core.utils.parsers import PORTAL_TZ
+from schemas.dataset_schemas import Dataset
+from schemas.export_schemas import XlsxFormatting
+from services.export.columns import HeaderLanguage, native_values, select_columns
+from services.export.exporter import Exporter
+
+DATE_FORMAT = "dd/mm/yyyy"
+DATETIME_FORMAT = "dd/mm/yyyy hh:mm"
+MAX_SHEET_NAME = 31
+
+NUMBER_FORMATS = {
+    "currency": '"R$" #,##0.00',
+    "decimal": "#,##0.00",
+    "integer": "#,##0",
+    "percent": '0.00"%"',
+    "date": DATE_FORMAT,
+    "datetime": DATETIME_FORMAT,
+    "text": "@",
+}
+
+DEFAULT_COLUMN_FORMATS = {
+    "unit_price": "currency",
+    "total_price": "currency",
+    "structure_cost": "currency",
+    "profit": "currency",
+    "ipi_pct": "percent",
+    "profit_pct": "percent",
+}
+
+
+def _write_cell(
+    worksheet: Any, row: int, col: int, value: Any, formats: dict, cell_format: Any = None
+) -> None:
+    """
+    Write a value to a cell using the Excel type matching its Python type.
+
+    The column format, when given, replaces the default format of numbers
+    and dates.
+    """
+    if value is None:
+        worksheet.write_blank(row, col, None, cell_format)
+    elif isinstance(value, bool):
+        worksheet.write_boolean(row, col, value, cell_format)
+    elif isinstance(value, (int, float, Decimal)):
+        worksheet.write_number(row, col, float(value), cell_format)
+    elif isinstance(value, datetime):
+        if value.tzinfo is not None:
+            value = value.astimezone(PORTAL_TZ).replace(tzinfo=None)
+        worksheet.write_datetime(row, col, value, cell_format or formats["datetime"])
+    elif isinstance(value, date):
+        value = datetime(value.year, value.month, value.day)
+        worksheet.write_datetime(row, col, value, cell_format or formats["date"])
+    else:
+        worksheet.write_string(row, col, str(value), cell_format)
+
+
+def _column_setting(values: Dict[str, Any], name: str, header: str) -> Any:
+    for key in (header, name, english_name(name)):
+        if key in values:
+            return values[key]
+    return None
+
+
+def _text_width(value: Any) -> int:
+    if value is None:
+        return 0
+    if isinstance(value, datetime):
+        return len(DATETIME_FORMAT)
+    if isinstance(value, date):
+        return len(DATE_FORMAT)
+    if isinstance(value, (float, Decimal)):
+        return len(f"{value:,.2f}")
+    return max((len(line) for line in str(value).splitlines()), default=0)
+
+
+def _format_columns(
+    workbook: Any,
+    worksheet: Any,
+    layout: List[Tuple[str, str]],
+    rows: List[List[Any]],
+    formatting: XlsxFormatting,
+) -> List[Any]:
+    """
+    Apply the formatting of a worksheet and return the format of each column.
+    """
+    column_formats = {**(DEFAULT_COLUMN_FORMATS if formatting.default_formats else {})}
+    column_formats.update(formatting.column_formats)
+    cell_formats = []
+    for col, (name, header) in enumerate(layout):
+        number_format = _column_setting(column_formats, name, header)
+        cell_formats.append(
+            workbook.add_format({"num_format": NUMBER_FORMATS.get(number_format, number_format)})
+            if number_format
+            else None
+        )
+        width = _column_setting(formatting.column_widths, name, header)
+        if width is None and formatting.autofit:
+            width = max([len(header), *(_text_width(values[col]) for values in rows)]) + 2
+            if formatting.max_width:
+                width = min(width, formatting.max_width)
+        if width is not None:
+            worksheet.set_column(col, col, width)
+    if formatting.freeze_header:
+        worksheet.freeze_panes(1, 0)
+    if formatting.autofilter and layout:
+        worksheet.autofilter(0, 0, len(rows), len(layout) - 1)
+    return cell_formats
+
+
+def write_sheet(
+    workbook: Any,
+    dataset: Dataset,
+    sheet_name: Optional[str] = None,
+    header_language: HeaderLanguage = "en",
+    columns: Optional[Sequence[str]] = None,
+    formatting: Optional[XlsxFormatting] = None,
+) -> Any:
+    """
+    Add a worksheet with the rows of a dataset to a workbook.
+
+    Args:
+        workbook (xlsxwriter.Workbook): Workbook being written.
+        dataset (Dataset): Dataset to export.
+        sheet_name (Optional[str], optional): Worksheet name. Defaults to the
+            report name, truncated to the Excel limit.
+        header_language (HeaderLanguage, optional): "en" or "pt-BR" headers.
+            Defaults to "en".
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        formatting (Optional[XlsxFormatting], optional): Column formats,
+            widths, autofilter and frozen header. Defaults to `XlsxFormatting()`.
+
+    Returns:
+        xlsxwriter.worksheet.Worksheet: The new worksheet.
+    """
+    worksheet = workbook.add_worksheet((sheet_name or dataset.metadata.report)[:MAX_SHEET_NAME])
+    formats = {
+        "header": workbook.add_format({"bold": True}),
+        "date": workbook.add_format({"num_format": DATE_FORMAT}),
+        "datetime": workbook.add_format({"num_format": DATETIME_FORMAT}),
+    }
+    layout = select_columns(dataset, columns, header_language)
+    fields = [name for name, _ in layout]
+    for col, (_, header) in enumerate(layout):
+        worksheet.write_string(0, col, header, formats["header"])
+    rows = [native_values(row, fields) for row in dataset.rows]
+    formatting = formatting or XlsxFormatting()
+    cell_formats = _format_columns(workbook, worksheet, layout, rows, formatting)
+    for row_index, values in enumerate(rows, start=1):
+        for col, value in enumerate(values):
+            _write_cell(worksheet, row_index, col, value, formats, cell_formats[col])
+    return worksheet
+
+
+def export_xlsx_bytes(
+    dataset: Dataset,
+    sheet_name: Optional[str] = None,
+    header_language: HeaderLanguage = "en",
+    columns: Optional[Sequence[str]] = None,
+    formatting: Optional[XlsxFormatting] = None,
+) -> bytes:
+    """
+    Render a dataset as an .xlsx workbook with a single worksheet.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        sheet_name (Optional[str], optional): Worksheet name. Defaults to the report name.
+        header_language (HeaderLanguage, optional): "en" or "pt-BR" headers.
+            Defaults to "en".
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        formatting (Optional[XlsxFormatting], optional): Presentation of the
+            worksheet. Defaults to `XlsxFormatting()`.
+
+    Returns:
+        bytes: The workbook content.
+    """
+    output = BytesIO()
+    workbook = xlsxwriter.Workbook(output, {"in_memory": True})
+    write_sheet(workbook, dataset, sheet_name, header_language, columns, formatting)
+    workbook.close()
+    return output.getvalue()
+
+
+def export_xlsx(
+    dataset: Dataset,
+    path: str | Path,
+    sheet_name: Optional[str] = None,
+    header_language: HeaderLanguage = "en",
+    columns: Optional[Sequence[str]] = None,
+    formatting: Optional[XlsxFormatting] = None,
+) -> Path:
+    """
+    Write a dataset to an .xlsx file.
+
+    Args:
+        dataset (Dataset): Dataset to export.
+        path (str | Path): Destination file path; parent directories are created.
+        sheet_name (Optional[str], optional): Worksheet name. Defaults to the report name.
+        header_language (HeaderLanguage, optional): "en" or "pt-BR" headers.
+            Defaults to "en".
+        columns (Optional[Sequence[str]], optional): Fields to export, in
+            order. Defaults to every field.
+        formatting (Optional[XlsxFormatting], optional): Presentation of the
+            worksheet. Defaults to `XlsxFormatting()`.
+
+    Returns:
+        Path: The written file.
+    """
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_bytes(
+        export_xlsx_bytes(dataset, sheet_name, header_language, columns, formatting)
+    )
+    logger.info(f"Exported {len(dataset.rows)} rows of {dataset.metadata.report} to {path}.")
+    return path
+
+
+@dataclass
+class XlsxExporter(Exporter):
+    """
+    Excel workbook with a single typed worksheet.
+    """
+
+    sheet_name: Optional[str] = None
+    header_language: HeaderLanguage = "en"
+    columns: Optional[Sequence[str]] = None
+    formatting: Optional[XlsxFormatting] = None
+
+    format = "xlsx"
+    extension = ".xlsx"
+    media_type = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+    def write(self, dataset: Dataset, stream: BinaryIO) -> int:
+        stream.write(
+            export_xlsx_bytes(
+                dataset, self.sheet_name, self.header_language, self.columns, self.formatting
+            )
+        )
+        return len(dataset.rows)
@@ -0,0 +1,179 @@
+"""
+Background queue of scrape jobs.
+
+Slow batch runs are submitted as jobs instead of being run within an HTTP
+request: `submit` records the job as queued and returns at once, and a
+fixed pool of workers (`JOB_WORKERS`) executes the jobs in submission
+order through the batch runner (see `services.runner`), storing their
+snapshots. Workers start jobs at most once every
+`JOB_START_INTERVAL_SECONDS`, so bursts of submissions do not all hit CM
+at once, on top of the pacing of the portal requests themselves.
+
+Every change of a job is persisted in the job store (see
+`core.job_store`) with its timings, summary and result locations. Jobs
+left queued or running by a previous process are queued again when the
+queue starts; a running job can not be cancelled.
+"""
+
+import asyncio
+import time
+import uuid
+from dataclasses import replace
+from typing import Callable, List, Optional
+
+from core.config import settings
+from core.job_store import JobStore, job_store
+from core.logger import logger, mask_secrets
+from core.metrics import register_gauge
+from core.snapshot_store import snapshot_store
+from core.utils.parsers import portal_now
+from schemas.job_schemas import ScrapeJob
+from schemas.runner_schemas import RunConfig
+from services.progress import ProgressLog
+from services.report_registry import ReportContext
+from services.runner import run_reports
+
+
+class JobQueue:
+    """
+    Queue of scrape jobs executed by a pool of workers.
+
+    Args:
+        store (JobStore): Store of the job records.
+        workers (int): Number of jobs run at once.
+        start_interval (float): Minimum seconds between the starts of two jobs.
+    """
+
+    def __init__(self, store: JobStore, workers: int = 1, start_interval: float = 0.0):
+        self.store = store
+        self.workers = max(workers, 1)
+        self.start_interval = start_interval
+        self._queue: "asyncio.Queue[str]" = asyncio.Queue()
+        self._tasks: List[asyncio.Task] = []
+        self._context: Optional[Callable[[], ReportContext]] = None
+        self._start_lock = asyncio.Lock()
+        self._last_start = float("-inf")
+
+    @property
+    def depth(self) -> int:
+        """
+        Number of jobs waiting for a worker, including cancelled jobs not yet skipped.
+        """
+        return self._queue.qsize()
+
+    def submit(self, config: RunConfig) -> ScrapeJob:
+        """
+        Queue a batch run.
+
+        Args:
+            config (RunConfig): Batch run of the job.
+
+        Returns:
+            ScrapeJob: The queued job.
+        """
+        job = ScrapeJob(id=uuid.uuid4().hex, config=config, created_at=portal_now())
+        self.store.save(job)
+        self._queue.put_nowait(job.id)
+        logger.info(f"Job {job.id} queued with {len(config.reports)} reports.")
+        return job
+
+    def cancel(self, job_id: str) -> ScrapeJob:
+        """
+        Cancel a job that has not started.
+
+        Raises:
+            ValueError: If the job is not queued.
+            FileNotFoundError: If the job does not exist.
+        """
+        job = self.store.load(job_id)
+        if job.status != "queued":
+            raise ValueError(f"Job {job_id} is {job.status}; only queued jobs can be cancelled")
+        job.status = "cancelled"
+        job.finished_at = portal_now()
+        self.store.save(job)
+        return job
+
+    async def start(self, context: Callable[[], ReportContext]) -> None:
+        """
+        Start the workers, queueing again the jobs a previous process left.
+
+        Args:
+            context (Callable[[], ReportContext]): Scraping context of the
+                jobs, called when each job starts.
+        """
+        self._context = context
+        for job in self.store.list():
+            if job.status in ("queued", "running"):
+                if job.status == "running":
+                    logger.warning(f"Job {job.id} was interrupted; queueing it again.")
+                    job.status, job.started_at = "queued", None
+                    self.store.save(job)
+                self._queue.put_nowait(job.id)
+        self._tasks = [asyncio.create_task(self._work()) for _ in range(self.workers)]
+        logger.info(f"Job queue started with {self.workers} workers.")
+
+    async def stop(self) -> None:
+        """
+        Stop the workers; jobs they were running are queued again at the next start.
+        """
+        for task in self._tasks:
+            task.cancel()
+        await asyncio.gather(*self._tasks, return_exceptions=True)
+        self._tasks = []
+
+    async def _wait_turn(self) -> None:
+        async with self._start_lock:
+            delay = self._last_start + self.start_interval - time.monotonic()
+            if delay > 0:
+                await asyncio.sleep(delay)
+            self._last_start = time.monotonic()
+
+    async def _work(self) -> None:
+        while True:
+            job_id = await self._queue.get()
+            try:
+                job = self.store.load(job_id)
+                if job.status == "queued":
+                    await self._wait_turn()
+                    await self._run(job)
+            except Exception as e:
+                logger.error(f"Error running job {job_id}: {e}")
+            finally:
+                self._queue.task_done()
+
+    async def _run(self, job: ScrapeJob) -> None:
+        job.status = "running"
+        job.started_at = portal_now()
+        # Jobs left by older versions have naive times, in the local timezone.
+        job.queued_seconds = (job.started_at - job.created_at.astimezone()).total_seconds()
+        self.store.save(job)
+        logger.info(f"Job {job.id} started.")
+        try:
+            with ProgressLog() as progress:
+                context = replace(self._context(), progress=progress)
+                summary = await run_reports(context, job.config, snapshot_store)
+        except Exception as e:
+            job.status, job.error = "failed", mask_secrets(str(e))
+        else:
+            job.summary = summary
+            job.status = "succeeded" if summary.succeeded else "failed"
+            failed = [status for status in summary.results if status.status != "success"]
+            if failed:
+                job.error = "; ".join(
+                    f"{status.report}: {status.error or status.status}" for status in failed
+                )
+            for status in summary.results:
+                job.results.extend(status.destinations)
+                if status.snapshot_id:
+                    job.results.append(f"snapshot:{status.report}/{status.snapshot_id}")
+            job.results.extend(summary.bundles)
+        job.finished_at = portal_now()
+        job.duration_seconds = (job.finished_at - job.started_at).total_seconds()
+        self.store.save(job)
+        logger.info(f"Job {job.id} {job.status} in {job.duration_seconds:.1f}s.")
+
+
+job_queue = JobQueue(job_store, settings.JOB_WORKERS, settings.JOB_START_INTERVAL_SECONDS)
+register_gauge(
+    "lanx_job_queue_depth", "Background jobs waiting for a worker.", lambda: job_queue.depth
+)
@@ -0,0 +1,3 @@
+"""
+Notification channels delivering report runs to people.
+"""
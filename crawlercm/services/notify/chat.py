@@ -0,0 +1,40 @@
+"""
+Chat notifications through incoming webhooks.
+
+Posts short text messages to the channels of `NOTIFY_WEBHOOKS`, named
+incoming webhook URLs of Slack, Microsoft Teams, Google Chat or
+Mattermost, which all accept a JSON body with a `text` field:
+
+    NOTIFY_WEBHOOKS:
+      slack: https://hooks.slack.com/services/T000/B000/XXXX
+
+Requests are retried as the requests of the webhook destination (see
+`services.export.webhook`).
+"""
+
+import json
+import uuid
+
+from core.config import settings
+from core.logger import logger
+from services.export.webhook import Webhook
+
+
+def post_chat_message(channel: str, text: str) -> None:
+    """
+    Post a text message to a chat channel.
+
+    Args:
+        channel (str): Channel name, a key of `NOTIFY_WEBHOOKS`.
+        text (str): Message text.
+
+    Raises:
+        KeyError: If the channel is not configured.
+        RuntimeError: If the webhook rejects the message, or it still fails
+            once retries are exhausted.
+    """
+    if channel not in settings.NOTIFY_WEBHOOKS:
+        raise KeyError(f"Unknown notification channel: {channel}; configure NOTIFY_WEBHOOKS")
+    body = json.dumps({"text": text}, ensure_ascii=False).encode("utf-8")
+    Webhook(settings.NOTIFY_WEBHOOKS[channel]).post(body, str(uuid.uuid4()))
+    logger.info(f"Notified {channel}.")
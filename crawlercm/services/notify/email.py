@@ -0,0 +1,143 @@
+"""
+E-mail delivery of report runs over SMTP.
+
+Sends a templated e-mail with the exports of one or more datasets
+attached, in the formats configured for the recipients. Small reports
+(up to `inline_max_rows` rows) are sent as HTML tables in the body
+instead, which reads better on a phone than an attachment. The SMTP
+server and sender come from settings (`SMTP_*`); recipients and templates
+come from the `EmailDelivery` of each report in the batch run
+configuration.
+"""
+
+import html
+import smtplib
+from email.message import EmailMessage
+from pathlib import Path
+from typing import Sequence
+
+from core.config import settings
+from core.logger import logger
+from schemas.dataset_schemas import Dataset
+from schemas.notify_schemas import EmailDelivery
+from services.export.compression import COMPRESSION_MIME_TYPES, compress, zip_bundle
+from services.export.files import MIME_TYPES, render_export
+from services.export.html_export import html_table, render_html
+from services.export.naming import render_template, template_values
+from services.report_registry import REPORTS
+
+
+def build_message(datasets: Sequence[Dataset], delivery: EmailDelivery) -> EmailMessage:
+    """
+    Build the e-mail delivering one or more datasets.
+
+    The subject is rendered for the first dataset and the body for each of
+    them. Datasets with at most `delivery.inline_max_rows` rows are inlined
+    as HTML tables; the others are attached in every configured format,
+    compressed or bundled into a zip archive as configured.
+    With `delivery.html_template`, the HTML section of each dataset is
+    rendered by `services.export.html_export` from that template file.
+
+    Args:
+        datasets (Sequence[Dataset]): Datasets to deliver.
+        delivery (EmailDelivery): Recipients and templates.
+
+    Returns:
+        EmailMessage: The message, ready to be sent.
+
+    Raises:
+        ValueError: If no dataset is given, no sender is configured or a
+            template is invalid.
+        OSError: If the HTML template file cannot be read.
+    """
+    if not datasets:
+        raise ValueError("No datasets to deliver by e-mail")
+    if not settings.SMTP_SENDER:
+        raise ValueError("No e-mail sender configured; set SMTP_SENDER")
+
+    message = EmailMessage()
+    message["Subject"] = render_template(delivery.subject, datasets[0])
+    message["From"] = settings.SMTP_SENDER
+    message["To"] = ", ".join(delivery.to)
+    if delivery.cc:
+        message["Cc"] = ", ".join(delivery.cc)
+
+    text_parts, html_parts, attachments = [], [], []
+    for dataset in datasets:
+        body = render_template(delivery.body, dataset)
+        text_parts.append(body)
+        inline = 0 < delivery.inline_max_rows and len(dataset.rows) <= delivery.inline_max_rows
+        table = ""
+        if inline:
+            definition = REPORTS.get(dataset.metadata.report)
+            table = html_table(dataset, totals=definition.total_fields if definition else None)
+        if delivery.html_template:
+            template = Path(delivery.html_template)
+            html_parts.append(render_html(dataset, template, body=body, values={"table": table}))
+        else:
+            html_parts.append(f"<p>{html.escape(body)}</p>{table}")
+        if inline:
+            continue
+        date = template_values(dataset)["date"]
+        for file_format in delivery.attachments:
+            name = f"{dataset.metadata.report}_{date.isoformat()}.{file_format}"
+            attachments.append((name, render_export(dataset, file_format), MIME_TYPES[file_format]))
+
+    message.set_content("\n\n".join(text_parts))
+    message.add_alternative("".join(html_parts), subtype="html")
+    if delivery.compression == "zip" and attachments:
+        report = datasets[0].metadata.report if len(datasets) == 1 else "reports"
+        date = template_values(datasets[0])["date"]
+        bundle = zip_bundle([(name, content) for name, content, _ in attachments])
+        attachments = [(f"{report}_{date.isoformat()}.zip", bundle, COMPRESSION_MIME_TYPES["zip"])]
+    elif delivery.compression == "gzip":
+        attachments = [
+            (*compress(name, content, "gzip"), COMPRESSION_MIME_TYPES["gzip"])
+            for name, content, _ in attachments
+        ]
+    for name, content, content_type in attachments:
+        maintype, subtype = content_type.split("/")
+        message.add_attachment(content, maintype=maintype, subtype=subtype, filename=name)
+    return message
+
+
+def send_message(message: EmailMessage) -> None:
+    """
+    Send a message through the configured SMTP server.
+
+    Args:
+        message (EmailMessage): Message to send.
+
+    Raises:
+        ValueError: If no SMTP server is configured.
+        smtplib.SMTPException: If the server rejects the message.
+    """
+    if not settings.SMTP_HOST:
+        raise ValueError("No SMTP server configured; set SMTP_HOST")
+    if settings.SMTP_SECURITY == "ssl":
+        server = smtplib.SMTP_SSL(settings.SMTP_HOST, settings.SMTP_PORT, timeout=30)
+    else:
+        server = smtplib.SMTP(settings.SMTP_HOST, settings.SMTP_PORT, timeout=30)
+    with server:
+        if settings.SMTP_SECURITY == "starttls":
+            server.starttls()
+        if settings.SMTP_USERNAME:
+            server.login(settings.SMTP_USERNAME, settings.SMTP_PASSWORD or "")
+        server.send_message(message)
+
+
+def send_report_email(datasets: Sequence[Dataset], delivery: EmailDelivery) -> None:
+    """
+    Deliver one or more datasets by e-mail.
+
+    Args:
+        datasets (Sequence[Dataset]): Datasets to deliver.
+        delivery (EmailDelivery): Recipients and templates.
+
+    Raises:
+        ValueError: If the delivery or the SMTP settings are not valid.
+        smtplib.SMTPException: If the server rejects the message.
+    """
+    send_message(build_message(datasets, delivery))
+    reports = ", ".join(dataset.metadata.report for dataset in datasets)
+    logger.info(f"E-mailed {reports} to {', '.join([*delivery.to, *delivery.cc])}.")
@@ -0,0 +1,74 @@
+"""
+Pivot tables over report rows.
+
+`pivot` builds the comparison matrix purchasing assembles by hand every
+month: one line per material, one column per supplier and the price in
+each cell. Rows sharing the same line and column are combined with an
+aggregation of `services.aggregation` (the lowest price by default).
+"""
+
+from typing import Any, Dict, Iterable, List, Union
+
+from core.utils.records import value_of
+from core.utils.sorting import sort_rows
+from schemas.dataset_schemas import Dataset
+from schemas.pivot_schemas import PivotRow, PivotTable
+from services.aggregation import Operation, Row, apply_operation, check_operations
+
+
+def pivot(
+    rows: Union[Dataset, Iterable[Row]],
+    index: str = "codigo",
+    column: str = "fornecedor",
+    value: str = "valor_unitario",
+    aggregation: Operation = "min",
+) -> PivotTable:
+    """
+    Build a pivot table from report rows.
+
+    Rows without index or column value are ignored, and missing values do
+    not count towards the aggregation.
+
+    Args:
+        rows (Union[Dataset, Iterable[Row]]): Dataset or rows to pivot.
+        index (str, optional): Field forming the lines. Defaults to "codigo".
+        column (str, optional): Field forming the columns. Defaults to "fornecedor".
+        value (str, optional): Field aggregated into the cells. Defaults to "valor_unitario".
+        aggregation (Operation, optional): How the values of a cell are
+            combined (sum, avg, min, max or count). Defaults to "min".
+
+    Returns:
+        PivotTable: The comparison matrix.
+
+    Raises:
+        ValueError: If the aggregation is unknown.
+    """
+    check_operations([aggregation])
+    if isinstance(rows, Dataset):
+        rows = rows.rows
+    cells: Dict[Any, Dict[str, List[Any]]] = {}
+    labels = set()
+    for row in rows:
+        key, label = value_of(row, index), value_of(row, column)
+        if key is None or label is None:
+            continue
+        label = str(label)
+        labels.add(label)
+        cell = cells.setdefault(key, {}).setdefault(label, [])
+        if (cell_value := value_of(row, value)) is not None:
+            cell.append(cell_value)
+
+    lines = [
+        PivotRow(
+            key=key,
+            values={label: apply_operation(aggregation, values) for label, values in line.items()},
+        )
+        for key, line in cells.items()
+    ]
+    return PivotTable(
+        index=index,
+        column=column,
+        value=value,
+        columns=sorted(labels),
+        rows=sort_rows(lines, ["key"]),
+    )
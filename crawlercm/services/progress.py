@@ -0,0 +1,245 @@
+"""
+Progress of long report fetches.
+
+The batch runner reports the pages and rows of every report it fetches to
+the `Progress` of the `ReportContext`, if any, which shows them while the
+fetch goes on:
+
+- `ProgressBar` redraws a status line on a terminal, with a bar and ETA
+  when the number of pages is known (page fetchers may give it with
+  `set_total`) and the elapsed time otherwise:
+
+      pending_orders [########------------] 12/30 pages, 3,600 rows, ETA 4m10s
+
+- `ProgressLog` logs the progress of every running report periodically
+  (`PROGRESS_LOG_INTERVAL_SECONDS`), for runs without a terminal such as
+  scheduled runs; in JSON logs the counts are fields of the record. The
+  `lanx` commands log it as warnings, so cron runs show it at the default
+  level of the CLI.
+
+Both refresh from a background thread while they are open (`with`), so a
+single page taking minutes still shows its elapsed time.
+"""
+
+import logging
+import sys
+import threading
+import time
+from dataclasses import dataclass, field
+from typing import Dict, Optional, TextIO
+
+from core.config import settings
+from core.logger import logger
+
+
+def _duration(seconds: float) -> str:
+    minutes, seconds = divmod(int(seconds), 60)
+    hours, minutes = divmod(minutes, 60)
+    if hours:
+        return f"{hours}h{minutes:02d}m"
+    return f"{minutes}m{seconds:02d}s" if minutes else f"{seconds}s"
+
+
+@dataclass
+class ReportProgress:
+    """
+    Progress of the fetch of a report.
+    """
+
+    report: str
+    started: float = field(default_factory=time.monotonic)
+    pages: int = 0
+    rows: int = 0
+    total_pages: Optional[int] = None
+
+    @property
+    def elapsed(self) -> float:
+        """
+        Seconds since the fetch started.
+        """
+        return time.monotonic() - self.started
+
+    @property
+    def eta(self) -> Optional[float]:
+        """
+        Estimated seconds until the last page, when the page count is known.
+        """
+        if not self.total_pages or not self.pages:
+            return None
+        return self.elapsed / self.pages * max(self.total_pages - self.pages, 0)
+
+    def describe(self) -> str:
+        """
+        Pages, rows and time of the fetch, e.g. "12/30 pages, 3,600 rows, ETA 4m10s".
+        """
+        pages = f"{self.pages}/{self.total_pages}" if self.total_pages else f"{self.pages}"
+        text = f"{pages} pages, {self.rows:,} rows"
+        eta = self.eta
+        if eta is not None:
+            return f"{text}, ETA {_duration(eta)}"
+        return f"{text}, {_duration(self.elapsed)} elapsed"
+
+
+class Progress:
+    """
+    Tracks the reports being fetched; subclasses show it with `render`.
+
+    Args:
+        interval (float): Seconds between two refreshes of the background thread.
+    """
+
+    def __init__(self, interval: float):
+        self.interval = interval
+        self.reports: Dict[str, ReportProgress] = {}
+        self._lock = threading.Lock()
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+    def __enter__(self) -> "Progress":
+        self._thread = threading.Thread(target=self._refresh, name="progress", daemon=True)
+        self._thread.start()
+        return self
+
+    def __exit__(self, *_: object) -> None:
+        self._stop.set()
+        if self._thread is not None:
+            self._thread.join()
+
+    def _refresh(self) -> None:
+        while not self._stop.wait(self.interval):
+            with self._lock:
+                self.render()
+
+    def start(self, report: str, total_pages: Optional[int] = None) -> None:
+        """
+        A report started being fetched.
+        """
+        with self._lock:
+            self.reports[report] = ReportProgress(report, total_pages=total_pages)
+            self.changed(self.reports[report])
+
+    def set_total(self, report: str, total_pages: int) -> None:
+        """
+        The number of pages of a report became known.
+        """
+        with self._lock:
+            if report in self.reports:
+                self.reports[report].total_pages = total_pages
+                self.changed(self.reports[report])
+
+    def advance(self, report: str, rows: int, pages: int = 1) -> None:
+        """
+        Pages of a report were fetched.
+        """
+        with self._lock:
+            if report in self.reports:
+                self.reports[report].pages += pages
+                self.reports[report].rows += rows
+                self.changed(self.reports[report])
+
+    def finish(self, report: str) -> None:
+        """
+        A report was fetched, or failed.
+        """
+        with self._lock:
+            progress = self.reports.pop(report, None)
+            if progress is not None:
+                self.finished(progress)
+
+    def changed(self, progress: ReportProgress) -> None:
+        """
+        Called, with the lock held, when the progress of a report changes.
+        """
+
+    def finished(self, progress: ReportProgress) -> None:
+        """
+        Called, with the lock held, when a report finishes.
+        """
+
+    def render(self) -> None:
+        """
+        Show the progress of the running reports, with the lock held.
+        """
+
+
+class ProgressBar(Progress):
+    """
+    Status line on a terminal, redrawn as pages arrive and every second.
+
+    Args:
+        stream (TextIO, optional): Terminal. Defaults to stderr.
+        width (int, optional): Width of the bar. Defaults to 20.
+    """
+
+    def __init__(self, stream: TextIO = sys.stderr, width: int = 20):
+        super().__init__(interval=1.0)
+        self.stream = stream
+        self.width = width
+
+    def _bar(self, progress: ReportProgress) -> str:
+        if not progress.total_pages:
+            return ""
+        done = min(progress.pages * self.width // progress.total_pages, self.width)
+        return f" [{'#' * done}{'-' * (self.width - done)}]"
+
+    def changed(self, progress: ReportProgress) -> None:
+        self.render()
+
+    def finished(self, progress: ReportProgress) -> None:
+        self.stream.write(
+            f"\r\x1b[K{progress.report}: {progress.pages} pages, {progress.rows:,} rows "
+            f"in {_duration(progress.elapsed)}\n"
+        )
+        self.render()
+
+    def render(self) -> None:
+        if not self.reports:
+            return
+        line = " | ".join(
+            f"{progress.report}{self._bar(progress)} {progress.describe()}"
+            for progress in self.reports.values()
+        )
+        self.stream.write(f"\r\x1b[K{line}")
+        self.stream.flush()
+
+
+class ProgressLog(Progress):
+    """
+    Periodic logs of the running reports.
+
+    Args:
+        interval (Optional[float], optional): Seconds between two logs.
+            Defaults to `PROGRESS_LOG_INTERVAL_SECONDS`.
+        level (int, optional): Level of the logs. Defaults to INFO.
+    """
+
+    def __init__(self, interval: Optional[float] = None, level: int = logging.INFO):
+        super().__init__(interval=interval or settings.PROGRESS_LOG_INTERVAL_SECONDS)
+        self.level = level
+
+    def render(self) -> None:
+        for progress in self.reports.values():
+            logger.log(
+                self.level,
+                f"Progress of {progress.report}: {progress.describe()}",
+                extra={
+                    "fields": {
+                        "report": progress.report,
+                        "pages": progress.pages,
+                        "total_pages": progress.total_pages,
+                        "rows": progress.rows,
+                        "elapsed_seconds": round(progress.elapsed, 1),
+                        "eta_seconds": None if progress.eta is None else round(progress.eta, 1),
+                    }
+                },
+            )
+
+
+def progress_for(stream: TextIO = sys.stderr) -> Progress:
+    """
+    Progress bar when a stream is a terminal, periodic warning logs otherwise,
+    shown by `lanx` without `--verbose`.
+    """
+    if stream.isatty():
+        return ProgressBar(stream)
+    return ProgressLog(level=logging.WARNING)
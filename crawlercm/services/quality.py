@@ -0,0 +1,128 @@
+"""
+Data quality analysis of report datasets.
+
+`analyze` inspects a dataset and reports missing fields, duplicated keys,
+outlier prices and cell texts that could not be parsed (as recorded in
+the dataset metadata, including the cells of dropped rows). Unlike
+`services.validation`, nothing is rejected: the analysis only measures the
+hygiene of the portal data, and the batch runner attaches it to the status
+of every report run.
+"""
+
+import statistics
+from collections import Counter
+from decimal import Decimal
+from typing import Any, Dict, List, Optional, Sequence
+
+from pydantic import BaseModel
+
+from core.utils.parsers import portal_now
+from core.utils.table_mapping import ReportRow
+from schemas.dataset_schemas import Dataset
+from schemas.quality_schemas import DuplicateKey, FieldQuality, PriceOutlier, QualityReport
+from services.report_registry import REPORTS
+
+MAX_SAMPLES = 5
+
+
+def _values(row: Any) -> Dict[str, Any]:
+    return dict(row.__dict__) if isinstance(row, BaseModel) else dict(row)
+
+
+def _is_unparsable(row: Any, field: str) -> bool:
+    """
+    Whether the cell of a field could not be parsed when the row was mapped.
+    """
+    return isinstance(row, ReportRow) and any(e.field == field for e in row.parse_errors)
+
+
+def _price_outliers(values: List[Dict[str, Any]], field: str, factor: float) -> List[PriceOutlier]:
+    """
+    Find prices outside the Tukey fences of a field.
+
+    Args:
+        values (List[Dict[str, Any]]): Row values.
+        field (str): Price field.
+        factor (float): Multiple of the interquartile range defining the fences.
+
+    Returns:
+        List[PriceOutlier]: Outlier prices, in row order.
+    """
+    prices = [(i, float(v[field])) for i, v in enumerate(values) if v.get(field) is not None]
+    if len(prices) < 4:
+        return []
+    q1, _, q3 = statistics.quantiles([price for _, price in prices], n=4)
+    lower, upper = q1 - factor * (q3 - q1), q3 + factor * (q3 - q1)
+    return [
+        PriceOutlier(row_index=i, field=field, value=price, lower_bound=lower, upper_bound=upper)
+        for i, price in prices
+        if price < lower or price > upper
+    ]
+
+
+def analyze(
+    dataset: Dataset,
+    key_fields: Optional[Sequence[str]] = None,
+    price_fields: Optional[Sequence[str]] = None,
+    outlier_factor: float = 3.0,
+) -> QualityReport:
+    """
+    Produce the data quality report of a dataset.
+
+    Args:
+        dataset (Dataset): Dataset to analyze.
+        key_fields (Optional[Sequence[str]], optional): Fields identifying a
+            row. Defaults to the key of the report in the registry.
+        price_fields (Optional[Sequence[str]], optional): Fields checked for
+            outliers. Defaults to every field holding money values.
+        outlier_factor (float, optional): Multiple of the interquartile range
+            beyond which a price is an outlier. Defaults to 3.0.
+
+    Returns:
+        QualityReport: The quality summary.
+    """
+    if key_fields is None:
+        definition = REPORTS.get(dataset.metadata.report)
+        key_fields = definition.key_fields if definition else []
+    values = [_values(row) for row in dataset.rows]
+    names: List[str] = []
+    for row_values in values:
+        names.extend(name for name in row_values if name not in names)
+    if price_fields is None:
+        price_fields = [
+            name for name in names if any(isinstance(v.get(name), Decimal) for v in values)
+        ]
+
+    fields = {name: FieldQuality(field=name) for name in names}
+    for name in names:
+        for row, row_values in zip(dataset.rows, values):
+            value = row_values.get(name)
+            if (value is None or value == "" or value == []) and not _is_unparsable(row, name):
+                fields[name].missing += 1
+    for issue in dataset.metadata.parse_errors:
+        quality = fields.setdefault(issue.field, FieldQuality(field=issue.field))
+        quality.unparsable += 1
+        if len(quality.samples) < MAX_SAMPLES:
+            quality.samples.append(issue.raw)
+
+    duplicates = []
+    if key_fields:
+        counts = Counter(tuple(v.get(name) for name in key_fields) for v in values)
+        duplicates = [
+            DuplicateKey(key=dict(zip(key_fields, key)), count=count)
+            for key, count in counts.items()
+            if count > 1
+        ]
+
+    outliers = [
+        outlier for name in price_fields for outlier in _price_outliers(values, name, outlier_factor)
+    ]
+    return QualityReport(
+        report=dataset.metadata.report,
+        analyzed_at=portal_now(),
+        total_rows=len(dataset.rows),
+        key_fields=list(key_fields),
+        fields=list(fields.values()),
+        duplicate_keys=duplicates,
+        price_outliers=outliers,
+    )
@@ -0,0 +1,113 @@
+"""
+Below-minimum stock detection.
+
+Combines a stock report with the material minimums maintained by
+purchasing and produces a typed replenish list, ready to be exported or
+posted to the purchasing channel. The `replenish_list` report applies it
+to the stock of the pending sales, with the minimums of
+`MATERIAL_MINIMUMS`:
+
+    material_minimums:
+      - {codigo: "PA-1020", minimo: 40, lote_minimo: 10, lote_multiplo: 5}
+      - {codigo: "PA-2210", minimo: 12, fornecedor_preferido: Lanx Metais}
+"""
+
+import math
+from typing import Any, Dict, Iterable, List, Optional, Union
+
+from pydantic import BaseModel
+
+from core.logger import logger
+from core.utils.canonical import canonical_material_code
+from core.utils.units import can_convert, convert
+from schemas.replenish_schemas import MaterialMinimum, ReplenishItem
+
+
+def _suggested_quantity(deficit: float, minimum: MaterialMinimum) -> float:
+    """
+    Round a deficit up to the order policy of a material.
+
+    Args:
+        deficit (float): Quantity missing to reach the minimum.
+        minimum (MaterialMinimum): Stock policy of the material.
+
+    Returns:
+        float: Quantity to order.
+    """
+    quantity = max(deficit, minimum.lote_minimo)
+    if minimum.lote_multiplo:
+        quantity = math.ceil(quantity / minimum.lote_multiplo) * minimum.lote_multiplo
+    return quantity
+
+
+def replenish_list(
+    stock: Iterable[Union[BaseModel, Dict[str, Any]]],
+    minimums: Iterable[MaterialMinimum],
+    code_field: str = "codigo",
+    quantity_field: str = "estoque",
+    description_field: str = "material",
+    unit_field: Optional[str] = "unidade",
+) -> List[ReplenishItem]:
+    """
+    List the materials whose stock is below the configured minimum.
+
+    Materials with a minimum but absent from the stock report are considered
+    out of stock. Codes are compared in their canonical form and stock rows
+    of the same material are summed; when both the stock row and the
+    minimum declare their unit, the stock is converted to the unit of the
+    minimum (e.g. KM of cable into M).
+
+    Args:
+        stock (Iterable[Union[BaseModel, Dict[str, Any]]]): Rows of the stock report.
+        minimums (Iterable[MaterialMinimum]): Stock policy per material.
+        code_field (str, optional): Field holding the material code. Defaults to "codigo".
+        quantity_field (str, optional): Field holding the stock level. Defaults to "estoque".
+        description_field (str, optional): Field holding the material description.
+            Defaults to "material".
+        unit_field (Optional[str], optional): Field holding the stock unit.
+            Defaults to "unidade".
+
+    Returns:
+        List[ReplenishItem]: Materials to replenish, largest deficit first.
+    """
+    minimums = list(minimums)
+    units = {canonical_material_code(minimum.codigo): minimum.unidade for minimum in minimums}
+    levels: Dict[str, float] = {}
+    descriptions: Dict[str, str] = {}
+    for row in stock:
+        values = row.model_dump() if isinstance(row, BaseModel) else row
+        code = canonical_material_code(values[code_field])
+        quantity = float(values.get(quantity_field) or 0)
+        stock_unit = values.get(unit_field) if unit_field else None
+        target_unit = units.get(code)
+        if stock_unit and target_unit and stock_unit != target_unit:
+            if can_convert(stock_unit, target_unit):
+                quantity = convert(quantity, stock_unit, target_unit)
+            else:
+                logger.warning(f"Stock of {code} in {stock_unit} cannot be compared to {target_unit}")
+        levels[code] = levels.get(code, 0.0) + quantity
+        if values.get(description_field):
+            descriptions.setdefault(code, values[description_field])
+
+    items: List[ReplenishItem] = []
+    for minimum in minimums:
+        code = canonical_material_code(minimum.codigo)
+        level = levels.get(code, 0.0)
+        deficit = minimum.minimo - level
+        if deficit <= 0:
+            continue
+        items.append(
+            ReplenishItem(
+                codigo=code,
+                material=descriptions.get(code),
+                estoque=level,
+                minimo=minimum.minimo,
+                deficit=deficit,
+                qtde_sugerida=_suggested_quantity(deficit, minimum),
+                fornecedor_preferido=minimum.fornecedor_preferido,
+                unidade=minimum.unidade,
+            )
+        )
+
+    items.sort(key=lambda item: item.deficit, reverse=True)
+    return items
@@ -0,0 +1,118 @@
+"""
+Diff engine comparing two runs of a report.
+
+Rows are matched by a configurable key (e.g. material code + supplier) and
+classified as added, removed or changed, so notifications about new items
+and price changes can be generated from consecutive snapshots. Keys repeated
+within a run, which the portal returns when rows are not deduplicated, are
+compared by their last row and listed as duplicated in the result.
+"""
+
+from typing import Any, Dict, Iterable, List, Sequence, Tuple, Union
+
+from pydantic import BaseModel
+
+from schemas.diff_schemas import ReportDiff, RowChange
+
+Row = Union[BaseModel, Dict[str, Any]]
+
+
+def _as_dict(row: Row) -> Dict[str, Any]:
+    """
+    Convert a report row into a plain dictionary.
+
+    Args:
+        row (Row): A Pydantic model or a dictionary.
+
+    Returns:
+        Dict[str, Any]: The row values, serialized in JSON mode for models.
+    """
+    if isinstance(row, BaseModel):
+        return row.model_dump(mode="json")
+    return dict(row)
+
+
+def _index_rows(
+    rows: Iterable[Row], key_fields: Sequence[str], duplicated: Dict[Tuple, None]
+) -> Dict[Tuple, Dict[str, Any]]:
+    """
+    Index rows by the values of their key fields.
+
+    Args:
+        rows (Iterable[Row]): Rows to index.
+        key_fields (Sequence[str]): Fields composing the key.
+        duplicated (Dict[Tuple, None]): Receives the keys shared by several
+            rows, whose last row is kept.
+
+    Returns:
+        Dict[Tuple, Dict[str, Any]]: Rows indexed by key, in their original order.
+
+    Raises:
+        KeyError: If a row does not have one of the key fields.
+    """
+    indexed: Dict[Tuple, Dict[str, Any]] = {}
+    for row in rows:
+        values = _as_dict(row)
+        key = tuple(values[field] for field in key_fields)
+        if key in indexed:
+            duplicated[key] = None
+        indexed[key] = values
+    return indexed
+
+
+def diff(
+    old: Iterable[Row],
+    new: Iterable[Row],
+    key_fields: Sequence[str],
+    ignore_fields: Sequence[str] = (),
+) -> ReportDiff:
+    """
+    Compare two runs of a report.
+
+    Args:
+        old (Iterable[Row]): Rows of the previous run.
+        new (Iterable[Row]): Rows of the current run.
+        key_fields (Sequence[str]): Fields identifying a row (e.g. ["codigo", "fornecedor"]).
+        ignore_fields (Sequence[str], optional): Fields not considered when
+            detecting changes, such as fetch timestamps.
+
+    Returns:
+        ReportDiff: Added, removed and changed rows, and the keys duplicated
+        in either run.
+
+    Raises:
+        ValueError: If no key field is given.
+        KeyError: If a row does not have one of the key fields.
+    """
+    if not key_fields:
+        raise ValueError("At least one key field is required to compare runs.")
+
+    duplicated: Dict[Tuple, None] = {}
+    old_rows = _index_rows(old, key_fields, duplicated)
+    new_rows = _index_rows(new, key_fields, duplicated)
+    ignored = set(ignore_fields)
+
+    result = ReportDiff(
+        key_fields=list(key_fields),
+        duplicated=[dict(zip(key_fields, key)) for key in duplicated],
+    )
+    for key, new_row in new_rows.items():
+        old_row = old_rows.get(key)
+        if old_row is None:
+            result.added.append(new_row)
+            continue
+
+        fields: List[str] = [
+            field
+            for field in dict.fromkeys([*old_row, *new_row])
+            if field not in ignored and old_row.get(field) != new_row.get(field)
+        ]
+        if fields:
+            result.changed.append(
+                RowChange(
+                    key=dict(zip(key_fields, key)), old=old_row, new=new_row, fields=fields
+                )
+            )
+
+    result.removed = [row for key, row in old_rows.items() if key not in new_rows]
+    return result
@@ -0,0 +1,120 @@
+"""
+Join utility merging several reports by a common key.
+
+Rows of every source are grouped by key (e.g. material code) and merged
+into one row per key, keeping track of which sources had no match, so
+dashboards can consume one enriched dataset instead of several exports.
+The `enriched_materials` report joins the pending materials, as master
+data, with the stock and prices of the pending sales.
+"""
+
+from typing import Any, Callable, Dict, Iterable, List, Literal, Optional, Sequence, Union
+
+from pydantic import BaseModel
+
+from core.logger import logger
+from core.utils.canonical import canonical_material_code
+from schemas.join_schemas import EnrichedMaterial
+
+Row = Union[BaseModel, Dict[str, Any]]
+
+
+def join_datasets(
+    sources: Dict[str, Iterable[Row]],
+    key_field: str = "codigo",
+    how: Literal["outer", "left"] = "outer",
+    many: Sequence[str] = (),
+    normalize: Optional[Callable[[Any], Any]] = None,
+) -> List[Dict[str, Any]]:
+    """
+    Join several datasets by a key field.
+
+    Each output row holds the key, one entry per source and a `missing` list
+    with the sources that had no row for the key. Sources listed in `many`
+    contribute a list of rows; the others contribute a single row (the first
+    one found) or None.
+
+    Args:
+        sources (Dict[str, Iterable[Row]]): Datasets indexed by source name.
+            With `how="left"`, the first source drives which keys are kept.
+        key_field (str, optional): Field holding the key in every source. Defaults to "codigo".
+        how (Literal["outer", "left"], optional): Keep every key ("outer") or
+            only the keys of the first source ("left"). Defaults to "outer".
+        many (Sequence[str], optional): Sources that may have several rows per key.
+        normalize (Optional[Callable[[Any], Any]], optional): Function applied to
+            every key before matching, such as `canonical_material_code`.
+
+    Returns:
+        List[Dict[str, Any]]: Joined rows, in order of first appearance of each key.
+    """
+    grouped: Dict[str, Dict[Any, List[Dict[str, Any]]]] = {}
+    keys: Dict[Any, None] = {}
+    for position, (name, rows) in enumerate(sources.items()):
+        grouped[name] = {}
+        for row in rows:
+            values = row.model_dump(mode="json") if isinstance(row, BaseModel) else dict(row)
+            key = values.get(key_field)
+            if normalize is not None and key is not None:
+                key = normalize(key)
+            if key in (None, ""):
+                logger.warning(f"Skipping row of {name} without {key_field}")
+                continue
+            grouped[name].setdefault(key, []).append(values)
+            if how == "outer" or position == 0:
+                keys.setdefault(key, None)
+
+    joined: List[Dict[str, Any]] = []
+    for key in keys:
+        row: Dict[str, Any] = {key_field: key, "missing": []}
+        for name, by_key in grouped.items():
+            matches = by_key.get(key, [])
+            if not matches:
+                row["missing"].append(name)
+            if name in many:
+                row[name] = matches
+            else:
+                if len(matches) > 1:
+                    logger.warning(f"{name} has {len(matches)} rows for {key_field}={key}, using the first")
+                row[name] = matches[0] if matches else None
+        joined.append(row)
+    return joined
+
+
+def enrich_materials(
+    master: Iterable[Row],
+    stock: Iterable[Row],
+    prices: Iterable[Row],
+    key_field: str = "codigo",
+) -> List[EnrichedMaterial]:
+    """
+    Merge the material master, current stock and supplier prices by material code.
+
+    Codes are compared in their canonical form. Every code present in any
+    of the three reports is kept; `ausente_em` lists the reports where it
+    was not found.
+
+    Args:
+        master (Iterable[Row]): Rows of the material master.
+        stock (Iterable[Row]): Rows of the stock report.
+        prices (Iterable[Row]): Rows of the supplier prices report.
+        key_field (str, optional): Field holding the material code. Defaults to "codigo".
+
+    Returns:
+        List[EnrichedMaterial]: One enriched row per material code.
+    """
+    joined = join_datasets(
+        {"cadastro": master, "estoque": stock, "precos": prices},
+        key_field=key_field,
+        many=["precos"],
+        normalize=canonical_material_code,
+    )
+    return [
+        EnrichedMaterial(
+            codigo=str(row[key_field]),
+            cadastro=row["cadastro"],
+            estoque=row["estoque"],
+            precos=row["precos"],
+            ausente_em=row["missing"],
+        )
+        for row in joined
+    ]
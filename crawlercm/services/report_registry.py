@@ -0,0 +1,450 @@
+"""
+Registry of the reports that can be scraped from CM.
+
+Each report is described by a `ReportDefinition` holding its name, the
+filters it accepts, the reports it depends on and the coroutine that
+produces its rows. Batch executions (see `services.runner`) use this
+registry to resolve report names coming from configuration, and
+`fetch_dataset` wraps every fetch in a `Dataset` with its provenance,
+with rows in a deterministic order. Reports paged by the portal (so far
+`pending_materials`) also yield their rows page by page, as they arrive,
+through `fetch_pages`; `report_pages` gives the pages of any report, the
+others as a single page.
+"""
+
+import time
+from dataclasses import dataclass, field, replace
+from datetime import timedelta
+from typing import Any, AsyncIterator, Awaitable, Callable, Dict, List, Optional, Type
+
+import aiohttp
+from pydantic import BaseModel
+
+from core.config import settings
+from core.utils.parsers import portal_now, portal_today
+from core.utils.records import value_of
+from core.utils.sorting import sort_rows
+from core.utils.table_mapping import CellParseError
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.dedup_schemas import DedupPolicy
+from schemas.reports_schemas import (
+    DateRangeFilters,
+    EmptyFilters,
+    FilteredSalesReportItem,
+    PendingMaterialsItem,
+    PendingOrdersItem,
+    SalesReportItem,
+)
+from schemas.join_schemas import EnrichedMaterial
+from schemas.replenish_schemas import ReplenishItem
+from schemas.runner_schemas import WriteMode
+from services.dedup import deduplicate
+from services.progress import Progress
+from services.replenishment import replenish_list
+from services.report_join import enrich_materials
+from services.scrape_reports import (
+    combine_data,
+    scrape_pending_materials,
+    scrape_pending_materials_pages,
+    scrape_prod_pending_orders,
+    scrape_sales_pending_orders,
+)
+
+
+@dataclass
+class ReportContext:
+    """
+    Shared state handed to every report fetcher.
+
+    Attributes:
+        client (aiohttp.ClientSession): Authenticated aiohttp client session.
+        csrf_token (str): CSRF token obtained during login.
+        parse_errors (List[CellParseError]): Cells that could not be parsed
+            by the current fetch. `fetch_dataset` gives every fetch its own list.
+        progress (Optional[Progress]): Shows the progress of the fetches, if
+            set; page fetchers knowing their page count give it with
+            `progress.set_total`.
+    """
+
+    client: aiohttp.ClientSession
+    csrf_token: str
+    parse_errors: List[CellParseError] = field(default_factory=list)
+    progress: Optional[Progress] = None
+
+
+ReportFetcher = Callable[
+    [ReportContext, BaseModel, Dict[str, List[BaseModel]]], Awaitable[List[BaseModel]]
+]
+PageFetcher = Callable[[ReportContext, BaseModel], AsyncIterator[List[BaseModel]]]
+
+
+@dataclass
+class ReportDefinition:
+    """
+    Describes a report available for batch execution.
+
+    Attributes:
+        name (str): Unique report name used in configuration.
+        description (str): Human readable description.
+        fetch (ReportFetcher): Coroutine receiving the context, the validated
+            filters and the rows of each dependency, returning the report rows.
+        filters_model (Type[BaseModel]): Model used to validate the filters.
+        row_model (Optional[Type[BaseModel]]): Model of the rows, documenting
+            the report in the OpenAPI document of the API (see `api.openapi`).
+        depends_on (List[str]): Reports whose rows are required by `fetch`.
+        source_url (Optional[str]): CM URL the report is scraped from, if any.
+        key_fields (List[str]): Fields identifying a row of the report.
+        sort_by (List[str]): Sort keys applied to the rows after every fetch
+            (see `core.utils.sorting`). Defaults to the key fields, ascending.
+        dedup (Optional[DedupPolicy]): How rows sharing the same key are
+            resolved. Rows are not deduplicated when None.
+        price_field (Optional[str]): Field holding the price of a row,
+            compared by the `keep_max_price` policy and by price alerts.
+            None when the rows have no price.
+        due_field (Optional[str]): Date by which a row is due; rows past it
+            are late (see `services.subscriptions`).
+        total_fields (List[str]): Fields summed in the totals of printed reports.
+        write_mode (Optional[WriteMode]): How database sinks write the report
+            ("merge", "append" or "replace", see `services.export.sql_sink`).
+            Each sink uses its own default when None.
+        fetch_pages (Optional[PageFetcher]): Async generator receiving the
+            context and the validated filters and yielding the report rows
+            page by page, as they arrive. Reports without it are fetched
+            whole by `fetch`.
+    """
+
+    name: str
+    description: str
+    fetch: ReportFetcher
+    filters_model: Type[BaseModel] = EmptyFilters
+    row_model: Optional[Type[BaseModel]] = None
+    depends_on: List[str] = field(default_factory=list)
+    source_url: Optional[str] = None
+    key_fields: List[str] = field(default_factory=list)
+    sort_by: List[str] = field(default_factory=list)
+    dedup: Optional[DedupPolicy] = None
+    price_field: Optional[str] = None
+    due_field: Optional[str] = None
+    total_fields: List[str] = field(default_factory=list)
+    write_mode: Optional[WriteMode] = None
+    fetch_pages: Optional[PageFetcher] = None
+
+    def parse_filters(self, filters: Dict[str, Any]) -> BaseModel:
+        """
+        Validate raw filters against the report filter model.
+
+        Args:
+            filters (Dict[str, Any]): Raw filters, usually from configuration.
+
+        Returns:
+            BaseModel: The validated filters.
+        """
+        return self.filters_model.model_validate(filters or {})
+
+    def dedup_policy(self, dedup: Optional[DedupPolicy] = None) -> Optional[DedupPolicy]:
+        """
+        Deduplication policy of a fetch.
+
+        Args:
+            dedup (Optional[DedupPolicy], optional): Policy overriding the one
+                of the report.
+
+        Returns:
+            Optional[DedupPolicy]: The policy, None when rows are not deduplicated.
+
+        Raises:
+            ValueError: If the policy is `keep_max_price` and the report has
+                no price field.
+        """
+        dedup = dedup or self.dedup
+        if dedup == DedupPolicy.KEEP_MAX_PRICE and self.price_field is None:
+            raise ValueError(f"{self.name} has no price field for the keep_max_price policy")
+        return dedup
+
+
+def _format_range(filters: DateRangeFilters) -> tuple[str, str]:
+    """
+    Format a date range as the DD/MM/YYYY strings expected by CM.
+
+    Args:
+        filters (DateRangeFilters): Filters holding the optional dates.
+
+    Returns:
+        tuple[str, str]: Formatted start and end dates.
+    """
+    init_date = filters.init_date or portal_today() - timedelta(days=15)
+    end_date = filters.end_date or portal_today() + timedelta(days=90)
+    return init_date.strftime("%d/%m/%Y"), end_date.strftime("%d/%m/%Y")
+
+
+async def _fetch_pending_sales(context, filters, deps):
+    init_date, end_date = _format_range(filters)
+    return await scrape_sales_pending_orders(
+        context.client,
+        settings.SALES_PENDING_ORDER_URL,
+        init_date,
+        end_date,
+        errors=context.parse_errors,
+    )
+
+
+async def _fetch_pending_orders(context, filters, deps):
+    init_date, end_date = _format_range(filters)
+    return await scrape_prod_pending_orders(
+        context.client,
+        settings.PROD_PENDING_ORDER_URL,
+        init_date,
+        end_date,
+        context.csrf_token,
+        errors=context.parse_errors,
+    )
+
+
+def _page_count_of(context: ReportContext, report: str) -> Optional[Callable[[int], None]]:
+    if context.progress is None:
+        return None
+    return lambda total_pages: context.progress.set_total(report, total_pages)
+
+
+async def _fetch_pending_materials(context, filters, deps):
+    return await scrape_pending_materials(
+        context.client,
+        settings.PENDING_MATERIALS_URL,
+        errors=context.parse_errors,
+        on_page_count=_page_count_of(context, "pending_materials"),
+    )
+
+
+async def _fetch_pending_materials_pages(context, filters):
+    async for rows in scrape_pending_materials_pages(
+        context.client,
+        settings.PENDING_MATERIALS_URL,
+        errors=context.parse_errors,
+        on_page_count=_page_count_of(context, "pending_materials"),
+    ):
+        yield rows
+
+
+async def _fetch_filtered_sales_report(context, filters, deps):
+    return combine_data(
+        deps["pending_sales"], deps["pending_orders"], deps["pending_materials"]
+    )
+
+
+def _first_by_code(rows):
+    """
+    First row of every code, in fetch order.
+    """
+    first = {}
+    for row in rows:
+        first.setdefault(value_of(row, "codigo"), row)
+    return list(first.values())
+
+
+async def _fetch_enriched_materials(context, filters, deps):
+    # The pending materials serve as master data and the pending sales as
+    # stock (one row per product) and prices (every order line).
+    return enrich_materials(
+        _first_by_code(deps["pending_materials"]),
+        _first_by_code(deps["pending_sales"]),
+        deps["pending_sales"],
+    )
+
+
+async def _fetch_replenish_list(context, filters, deps):
+    # Every pending order of a product repeats its stock, so only one row
+    # per product is counted.
+    return replenish_list(
+        _first_by_code(deps["pending_sales"]),
+        settings.MATERIAL_MINIMUMS,
+        description_field="produto",
+        unit_field=None,
+    )
+
+
+REPORTS: Dict[str, ReportDefinition] = {
+    definition.name: definition
+    for definition in [
+        ReportDefinition(
+            name="pending_sales",
+            description="Pending sales orders.",
+            fetch=_fetch_pending_sales,
+            row_model=SalesReportItem,
+            filters_model=DateRangeFilters,
+            source_url=settings.SALES_PENDING_ORDER_URL,
+            key_fields=["negociacao", "op", "codigo"],
+            price_field="valor_unitario",
+            total_fields=["qtde_pendente", "valor_total", "lucratividade_rs"],
+            due_field="previsao",
+        ),
+        ReportDefinition(
+            name="pending_orders",
+            description="Pending production orders.",
+            fetch=_fetch_pending_orders,
+            row_model=PendingOrdersItem,
+            filters_model=DateRangeFilters,
+            source_url=settings.PROD_PENDING_ORDER_URL,
+            key_fields=["op"],
+            total_fields=["quantidade", "peso"],
+            due_field="prazo",
+        ),
+        ReportDefinition(
+            name="pending_materials",
+            description="Pending material items.",
+            fetch=_fetch_pending_materials,
+            fetch_pages=_fetch_pending_materials_pages,
+            row_model=PendingMaterialsItem,
+            source_url=settings.PENDING_MATERIALS_URL,
+            key_fields=["op", "codigo"],
+            due_field="previsao_mp",
+        ),
+        ReportDefinition(
+            name="filtered_sales_report",
+            description="Pending sales enriched with production stage and pending materials.",
+            fetch=_fetch_filtered_sales_report,
+            row_model=FilteredSalesReportItem,
+            filters_model=DateRangeFilters,
+            depends_on=["pending_sales", "pending_orders", "pending_materials"],
+            key_fields=["negociacao", "op", "codigo"],
+            price_field="valor_unitario",
+            total_fields=["qtde_pendente", "valor_total"],
+            due_field="previsao",
+        ),
+        ReportDefinition(
+            name="enriched_materials",
+            description="Pending materials with the stock and prices of their code.",
+            fetch=_fetch_enriched_materials,
+            row_model=EnrichedMaterial,
+            filters_model=DateRangeFilters,
+            depends_on=["pending_sales", "pending_materials"],
+            key_fields=["codigo"],
+        ),
+        ReportDefinition(
+            name="replenish_list",
+            description="Products whose stock is below their minimum (MATERIAL_MINIMUMS).",
+            fetch=_fetch_replenish_list,
+            row_model=ReplenishItem,
+            filters_model=DateRangeFilters,
+            depends_on=["pending_sales"],
+            key_fields=["codigo"],
+            sort_by=["-deficit", "codigo"],
+            total_fields=["deficit", "qtde_sugerida"],
+        ),
+    ]
+}
+
+
+def get_report(name: str) -> ReportDefinition:
+    """
+    Look up a report definition by name.
+
+    Args:
+        name (str): Report name.
+
+    Returns:
+        ReportDefinition: The registered definition.
+
+    Raises:
+        KeyError: If no report is registered with the given name.
+    """
+    if name not in REPORTS:
+        raise KeyError(f"Unknown report: {name}")
+    return REPORTS[name]
+
+
+async def fetch_dataset(
+    definition: ReportDefinition,
+    context: ReportContext,
+    filters: BaseModel,
+    deps: Dict[str, Dataset],
+    sort_by: Optional[List[str]] = None,
+    dedup: Optional[DedupPolicy] = None,
+) -> Dataset:
+    """
+    Fetch a report and wrap its rows with their provenance.
+
+    Reports with a page fetcher count the pages it yields, other reports
+    scraped directly from CM count as one page, and reports derived from
+    others add up the pages of their dependencies. Rows sharing the same
+    key are deduplicated first, while still in fetch order, and then sorted
+    so diffs and exports do not depend on the order of the portal grid.
+
+    Args:
+        definition (ReportDefinition): Report to fetch.
+        context (ReportContext): Shared scraping context.
+        filters (BaseModel): Validated report filters.
+        deps (Dict[str, Dataset]): Datasets of the report dependencies.
+        sort_by (Optional[List[str]], optional): Sort keys overriding the ones
+            of the report definition.
+        dedup (Optional[DedupPolicy], optional): Deduplication policy
+            overriding the one of the report definition.
+
+    Returns:
+        Dataset: The report rows and their metadata.
+
+    Raises:
+        DuplicateKeyError: If the deduplication policy is `error` and
+            duplicated keys are found.
+        ValueError: If the policy is `keep_max_price` and the report has no
+            price field.
+    """
+    dedup = definition.dedup_policy(dedup)
+    fetched_at = portal_now()
+    started = time.perf_counter()
+    context = replace(context, parse_errors=[])
+    if definition.fetch_pages is not None:
+        rows, page_count = [], 0
+        async for page in definition.fetch_pages(context, filters):
+            rows.extend(page)
+            page_count += 1
+    else:
+        rows = await definition.fetch(
+            context, filters, {name: dataset.rows for name, dataset in deps.items()}
+        )
+        page_count = sum(d.metadata.page_count for d in deps.values()) if deps else 1
+    if dedup and definition.key_fields:
+        rows = deduplicate(rows, definition.key_fields, dedup, definition.price_field)
+    rows = sort_rows(rows, sort_by or definition.sort_by or definition.key_fields)
+    return Dataset(
+        metadata=DatasetMetadata(
+            report=definition.name,
+            fetched_at=fetched_at,
+            source_url=definition.source_url,
+            filters=filters.model_dump(mode="json"),
+            page_count=page_count,
+            row_count=len(rows),
+            elapsed_seconds=time.perf_counter() - started,
+            parse_errors=[error.to_issue() for error in context.parse_errors],
+        ),
+        rows=rows,
+    )
+
+
+async def report_pages(
+    definition: ReportDefinition,
+    context: ReportContext,
+    filters: BaseModel,
+    deps: Dict[str, Dataset],
+) -> AsyncIterator[List[BaseModel]]:
+    """
+    Rows of a report page by page, as they are fetched.
+
+    Rows are yielded in fetch order, without deduplication or sorting.
+    Reports without a page fetcher are fetched whole, as a single page.
+
+    Args:
+        definition (ReportDefinition): Report to fetch.
+        context (ReportContext): Shared scraping context.
+        filters (BaseModel): Validated report filters.
+        deps (Dict[str, Dataset]): Datasets of the report dependencies.
+
+    Yields:
+        List[BaseModel]: The rows of every page.
+    """
+    if definition.fetch_pages is None:
+        yield await definition.fetch(
+            context, filters, {name: dataset.rows for name, dataset in deps.items()}
+        )
+        return
+    async for rows in definition.fetch_pages(context, filters):
+        yield rows
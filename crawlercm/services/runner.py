@@ -0,0 +1,660 @@
+"""
+Batch runner for CM reports.
+
+Executes a configured list of reports (`RunConfig`) and returns a
+`RunSummary` with the status of every report. Reports run in stages, so
+those enriching others (e.g. the filtered sales report) only run after the
+reports they consume (see `services.report_registry`); the reports of a
+stage are scraped in parallel, at most `max_parallel` at once, each within
+its timeout.
+
+Fetched rows are measured (`services.quality`) and validated
+(`services.validation`), then delivered to the destinations of the report
+(`services.export.destinations`), e-mailed and stored as a snapshot. Delta
+destinations only receive the rows changed since the previous snapshot.
+Once every report finished, workbook bundles and manifests are written.
+Deliveries run in worker threads, and dry runs fetch but deliver nothing.
+"""
+
+import asyncio
+import time
+from contextlib import asynccontextmanager
+from dataclasses import replace
+from pathlib import Path
+from typing import Any, AsyncIterator, Dict, List, Optional, Sequence, Tuple, Union
+
+from core.config import settings
+from core.errors import error_kind
+from core.logger import logger, mask_secrets
+from core.metrics import record_report_run
+from core.snapshot_store import SnapshotStore
+from core.tracing import span
+from core.utils.parsers import portal_now
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from schemas.runner_schemas import (
+    DestinationConfig,
+    ReportJob,
+    ReportRunStatus,
+    RunConfig,
+    RunSummary,
+    WorkbookBundle,
+)
+from services.export.bundle import write_bundle
+from services.export.delta import delta_dataset
+from services.export.destinations import destination_of
+from services.export.exporter import PageWriter
+from services.export.manifest import Artifact, write_manifests
+from services.export.naming import is_template, render_template
+from services.export.registry import load_plugins
+from services.export.s3_upload import S3Archive
+from services.export.shaping import ShapedPageWriter, shape_dataset
+from services.notify.email import send_report_email
+from services.quality import analyze
+from services.report_registry import (
+    ReportContext,
+    ReportDefinition,
+    fetch_dataset,
+    get_report,
+    report_pages,
+)
+from services.validation import rules_for, validate_rows
+
+
+def _resolve_jobs(config: RunConfig) -> Dict[str, ReportJob]:
+    """
+    Build the full set of jobs to execute, including implicit dependencies.
+
+    Dependencies that are not explicitly configured are added without
+    destinations, inheriting the filters and the timeout of the report that
+    requires them. Jobs without a timeout take the timeout of the run.
+
+    Args:
+        config (RunConfig): Batch run configuration.
+
+    Returns:
+        Dict[str, ReportJob]: Jobs indexed by report name.
+
+    Raises:
+        KeyError: If a configured report is not registered.
+    """
+    jobs = {
+        job.report: job if job.timeout else job.model_copy(update={"timeout": config.timeout})
+        for job in config.reports
+    }
+    pending = list(jobs.values())
+    while pending:
+        job = pending.pop()
+        for dependency in get_report(job.report).depends_on:
+            if dependency not in jobs:
+                implicit_job = ReportJob(
+                    report=dependency, filters=job.filters, timeout=job.timeout
+                )
+                jobs[dependency] = implicit_job
+                pending.append(implicit_job)
+    return jobs
+
+
+@asynccontextmanager
+async def _time_limit(job: ReportJob) -> AsyncIterator[None]:
+    """
+    Cancel the fetch of a job once its timeout elapsed, if it has one.
+
+    Raises:
+        TimeoutError: If the timeout elapsed, naming the report.
+    """
+    limit = asyncio.timeout(job.timeout)
+    try:
+        async with limit:
+            yield
+    except TimeoutError as e:
+        if not limit.expired():
+            raise
+        raise TimeoutError(f"{job.report} timed out after {job.timeout:g}s") from e
+
+
+def _execution_stages(jobs: Dict[str, ReportJob]) -> List[List[str]]:
+    """
+    Group jobs into stages so every report runs after its dependencies.
+
+    Args:
+        jobs (Dict[str, ReportJob]): Jobs indexed by report name.
+
+    Returns:
+        List[List[str]]: Report names grouped by execution stage.
+
+    Raises:
+        ValueError: If the dependencies contain a cycle.
+    """
+    remaining = {name: set(get_report(name).depends_on) for name in jobs}
+    stages: List[List[str]] = []
+    while remaining:
+        ready = sorted(name for name, deps in remaining.items() if not deps)
+        if not ready:
+            raise ValueError(f"Circular dependency between reports: {sorted(remaining)}")
+        stages.append(ready)
+        for name in ready:
+            del remaining[name]
+        for deps in remaining.values():
+            deps.difference_update(ready)
+    return stages
+
+
+def _deliver(
+    dataset: Dataset,
+    destination: Union[str, DestinationConfig],
+    previous: Optional[Sequence[Any]] = None,
+    artifacts: Optional[List[Artifact]] = None,
+    dry_run: bool = False,
+) -> str:
+    """
+    Shape a dataset for a destination and write it.
+
+    Args:
+        dataset (Dataset): Report rows and metadata.
+        destination (Union[str, DestinationConfig]): Target, or destination
+            with its column shape and write mode.
+        previous (Optional[Sequence[Any]], optional): Rows of the previous
+            stored run, for delta destinations; empty when the report was
+            never stored.
+        artifacts (Optional[List[Artifact]], optional): Files written by the
+            run, for its manifests; the file written, if any, is added.
+        dry_run (bool, optional): Log what would be written instead of
+            writing it. Defaults to False.
+
+    Returns:
+        str: The destination written, with the file path as rendered.
+
+    Raises:
+        ValueError: If the destination is a delta without previous rows to
+            compare with.
+    """
+    if isinstance(destination, str):
+        destination = DestinationConfig(target=destination)
+    if destination.delta:
+        if previous is None:
+            raise ValueError("Delta exports require the snapshot store of previous runs")
+        dataset = delta_dataset(dataset, previous, include_removed=destination.delta_removed)
+    if destination.reshapes:
+        dataset = shape_dataset(
+            dataset, destination.columns, destination.rename, destination.header_language
+        )
+    if dry_run:
+        target = destination.target
+        if "://" not in target and is_template(target):
+            target = render_template(target, dataset)
+        logger.info(
+            f"Dry run: would write {len(dataset.rows)} rows of {dataset.metadata.report} "
+            f"to {target}."
+        )
+        return target
+    written = destination_of(destination.target).write(dataset, destination.target, destination)
+    path = Path(written)
+    if (
+        artifacts is not None
+        and "://" not in written
+        and path.suffix not in (".sqlite", ".db")
+        and path.is_file()
+    ):
+        artifacts.append(Artifact(path, dataset.metadata.report, len(dataset.rows)))
+    return written
+
+
+def deliver(dataset: Dataset, destination: Union[str, DestinationConfig]) -> str:
+    """
+    Write a dataset fetched outside a batch run to a destination of a batch run.
+
+    Used by `lanx watch`, which delivers a report only when it changed.
+
+    Args:
+        dataset (Dataset): Report rows and metadata.
+        destination (Union[str, DestinationConfig]): Target, or destination
+            with its column shape and write mode; not a delta destination.
+
+    Returns:
+        str: The destination written, with the file path as rendered.
+    """
+    return _deliver(dataset, destination)
+
+
+def _open_pages(
+    destination: DestinationConfig, metadata: DatasetMetadata
+) -> Tuple[str, PageWriter]:
+    """
+    Start writing a streamed report to a destination.
+
+    Local files and databases accept streamed reports. CSV and NDJSON files
+    and databases receive every page as it arrives; files of the other
+    formats are written once the report is complete.
+
+    Args:
+        destination (DestinationConfig): Destination of the report.
+        metadata (DatasetMetadata): Metadata of the report, updated as the
+            pages arrive.
+
+    Returns:
+        Tuple[str, PageWriter]: The destination, with the file path as
+        rendered, and the writer of its pages.
+
+    Raises:
+        ValueError: If the destination cannot be streamed to.
+    """
+    if destination.delta:
+        raise ValueError("Delta exports cannot be streamed")
+    target, writer = destination_of(destination.target).open_pages(
+        metadata, destination.target, destination
+    )
+    if destination.reshapes:
+        writer = ShapedPageWriter(
+            writer, destination.columns, destination.rename, destination.header_language
+        )
+    return target, writer
+
+
+async def _stream_job(
+    context: ReportContext,
+    job: ReportJob,
+    definition: ReportDefinition,
+    results: Dict[str, Dataset],
+    artifacts: Optional[List[Artifact]] = None,
+    dry_run: bool = False,
+) -> ReportRunStatus:
+    """
+    Execute a streamed job, writing its rows to the destinations as the
+    pages arrive.
+
+    Rows are written in fetch order, without deduplication, sorting,
+    validation or snapshots, and the report is not kept for dependents,
+    bundles or e-mails. A destination that fails stops receiving pages
+    while the others carry on.
+
+    Args:
+        context (ReportContext): Shared scraping context.
+        job (ReportJob): Job to execute.
+        definition (ReportDefinition): Report of the job.
+        results (Dict[str, Dataset]): Datasets of reports already executed.
+        artifacts (Optional[List[Artifact]], optional): Files written by the
+            run, extended with the files of the job.
+        dry_run (bool, optional): Fetch every page, but open no destination.
+            Defaults to False.
+
+    Returns:
+        ReportRunStatus: Outcome of the job.
+    """
+    started = time.perf_counter()
+    status = ReportRunStatus(report=job.report, status="success")
+    writers: List[Tuple[str, PageWriter]] = []
+
+    def discard(target: str, writer: PageWriter) -> None:
+        try:
+            writer.abort()
+        except Exception as e:
+            logger.error(f"Error discarding the export of {job.report} to {target}: {e}")
+
+    def fail(target: str, error: Exception) -> None:
+        logger.error(f"Error writing {job.report} to {target}: {error}")
+        status.status = "failed"
+        status.error = mask_secrets(f"{target}: {error}")
+        status.error_kind = "error"
+
+    try:
+        logger.info(f"Streaming report {job.report}...")
+        if job.email is not None:
+            raise ValueError("Streamed reports cannot be e-mailed")
+        filters = definition.parse_filters(job.filters)
+        deps = {dep: results[dep] for dep in definition.depends_on}
+        metadata = DatasetMetadata(
+            report=definition.name,
+            fetched_at=portal_now(),
+            source_url=definition.source_url,
+            filters=filters.model_dump(mode="json"),
+        )
+        for destination in job.destinations if not dry_run else []:
+            if isinstance(destination, str):
+                destination = DestinationConfig(target=destination)
+            try:
+                writers.append(await asyncio.to_thread(_open_pages, destination, metadata))
+            except Exception as e:
+                fail(destination.target, e)
+        context = replace(context, parse_errors=[])
+        if context.progress is not None:
+            context.progress.start(job.report)
+        if writers or dry_run:
+            async with _time_limit(job):
+                async for rows in report_pages(definition, context, filters, deps):
+                    metadata.page_count += 1
+                    metadata.row_count += len(rows)
+                    if context.progress is not None:
+                        context.progress.advance(job.report, len(rows))
+                    for target, writer in list(writers):
+                        try:
+                            with span(f"write {target}", report=job.report, destination=target):
+                                await asyncio.to_thread(writer.write_page, rows)
+                        except Exception as e:
+                            fail(target, e)
+                            writers.remove((target, writer))
+                            discard(target, writer)
+                    if not writers and not dry_run:
+                        break
+        metadata.elapsed_seconds = time.perf_counter() - started
+        metadata.parse_errors = [error.to_issue() for error in context.parse_errors]
+    except Exception as e:
+        logger.error(f"Error running report {job.report}: {e}")
+        for target, writer in writers:
+            discard(target, writer)
+        if context.progress is not None:
+            context.progress.finish(job.report)
+        return ReportRunStatus(
+            report=job.report,
+            status="failed",
+            duration_seconds=time.perf_counter() - started,
+            error=mask_secrets(str(e)),
+            error_kind=error_kind(e),
+        )
+
+    if context.progress is not None:
+        context.progress.finish(job.report)
+    for target, writer in writers:
+        try:
+            with span(f"close {target}", report=job.report, destination=target):
+                await asyncio.to_thread(writer.close)
+        except Exception as e:
+            fail(target, e)
+            continue
+        status.destinations.append(target)
+        path = Path(target)
+        if (
+            artifacts is not None
+            and "://" not in target
+            and path.suffix not in (".sqlite", ".db")
+            and path.is_file()
+        ):
+            artifacts.append(Artifact(path, job.report, metadata.row_count))
+    if dry_run:
+        for destination in job.destinations:
+            target = destination if isinstance(destination, str) else destination.target
+            logger.info(
+                f"Dry run: would stream {metadata.row_count} rows of {job.report} to {target}."
+            )
+            status.destinations.append(target)
+    status.row_count = metadata.row_count
+    status.parse_errors = len(metadata.parse_errors)
+    status.duration_seconds = time.perf_counter() - started
+    logger.info(
+        f"Report {job.report} streamed {metadata.row_count} rows in {metadata.page_count} pages "
+        f"in {metadata.elapsed_seconds:.2f}s."
+    )
+    return status
+
+
+async def _run_job(
+    context: ReportContext,
+    job: ReportJob,
+    results: Dict[str, Dataset],
+    store: Optional[SnapshotStore],
+    artifacts: Optional[List[Artifact]] = None,
+    dry_run: bool = False,
+) -> ReportRunStatus:
+    """
+    Execute a single job and deliver it to its destinations.
+
+    Args:
+        context (ReportContext): Shared scraping context.
+        job (ReportJob): Job to execute.
+        results (Dict[str, Dataset]): Datasets of reports already executed.
+        store (Optional[SnapshotStore]): Store where the run is persisted, if any.
+        artifacts (Optional[List[Artifact]], optional): Files written by the
+            run, extended with the files of the job.
+        dry_run (bool, optional): Fetch the report, but store, write and
+            e-mail nothing. Defaults to False.
+
+    Returns:
+        ReportRunStatus: Outcome of the job.
+    """
+    definition = get_report(job.report)
+    missing = [dep for dep in definition.depends_on if dep not in results]
+    if missing:
+        logger.warning(f"Skipping {job.report}: dependencies failed {missing}")
+        return ReportRunStatus(
+            report=job.report,
+            status="skipped",
+            error=f"Dependencies not available: {', '.join(missing)}",
+        )
+    if job.stream:
+        return await _stream_job(context, job, definition, results, artifacts, dry_run)
+
+    started = time.perf_counter()
+    progress = context.progress
+    try:
+        logger.info(f"Running report {job.report}...")
+        filters = definition.parse_filters(job.filters)
+        deps = {dep: results[dep] for dep in definition.depends_on}
+        if progress is not None:
+            progress.start(job.report)
+        try:
+            async with _time_limit(job):
+                dataset = await fetch_dataset(
+                    definition, context, filters, deps, job.sort_by, job.dedup
+                )
+            if progress is not None:
+                progress.advance(job.report, len(dataset.rows), dataset.metadata.page_count)
+        finally:
+            if progress is not None:
+                progress.finish(job.report)
+        quality = analyze(dataset)
+        dataset.rows, validation = validate_rows(
+            dataset.rows, rules_for(job.report, job.validation)
+        )
+        dataset.metadata.row_count = len(dataset.rows)
+    except Exception as e:
+        logger.error(f"Error running report {job.report}: {e}")
+        return ReportRunStatus(
+            report=job.report,
+            status="failed",
+            duration_seconds=time.perf_counter() - started,
+            error=mask_secrets(str(e)),
+            error_kind=error_kind(e),
+        )
+
+    results[job.report] = dataset
+    status = ReportRunStatus(
+        report=job.report,
+        status="success",
+        row_count=dataset.metadata.row_count,
+        rejected_rows=len(validation.rejected_rows),
+        validation_warnings=len(validation.warnings),
+        parse_errors=len(dataset.metadata.parse_errors),
+        duration_seconds=time.perf_counter() - started,
+        quality=quality,
+    )
+    previous: Optional[List[Dict[str, Any]]] = None
+    snapshot_error: Optional[str] = None
+    if store is not None and any(
+        isinstance(destination, DestinationConfig) and destination.delta
+        for destination in job.destinations
+    ):
+        try:
+            snapshot = await asyncio.to_thread(store.latest, job.report)
+            previous = snapshot.rows if snapshot else []
+        except Exception as e:
+            logger.error(f"Error loading the previous snapshot of {job.report}: {e}")
+            snapshot_error = f"the previous snapshot could not be loaded: {e}"
+    if store is not None and not dry_run:
+        try:
+            info = await asyncio.to_thread(store.save_dataset, dataset)
+            status.snapshot_id = info.id
+            if settings.S3_ARCHIVE_SNAPSHOTS:
+                await asyncio.to_thread(S3Archive().upload_snapshot, store, info, dataset)
+        except Exception as e:
+            logger.error(f"Error storing snapshot of {job.report}: {e}")
+    for destination in job.destinations:
+        target = destination if isinstance(destination, str) else destination.target
+        delta = isinstance(destination, DestinationConfig) and destination.delta
+        if delta and snapshot_error is not None:
+            logger.error(f"Skipping delta destination {target} of {job.report}: {snapshot_error}")
+            status.status = "failed"
+            status.error = mask_secrets(f"{target}: {snapshot_error}")
+            status.error_kind = "snapshot"
+            continue
+        try:
+            with span(f"write {target}", report=job.report, destination=target):
+                status.destinations.append(
+                    await asyncio.to_thread(
+                        _deliver, dataset, destination, previous, artifacts, dry_run
+                    )
+                )
+        except Exception as e:
+            logger.error(f"Error writing {job.report} to {target}: {e}")
+            status.status = "failed"
+            status.error = mask_secrets(f"{target}: {e}")
+            status.error_kind = "error"
+    if job.email is not None and dry_run:
+        logger.info(f"Dry run: would e-mail {job.report} to {', '.join(job.email.to)}.")
+        status.destinations.append("email")
+    elif job.email is not None:
+        try:
+            await asyncio.to_thread(send_report_email, [dataset], job.email)
+            status.destinations.append("email")
+        except Exception as e:
+            logger.error(f"Error e-mailing {job.report}: {e}")
+            status.status = "failed"
+            status.error = mask_secrets(f"email: {e}")
+            status.error_kind = "error"
+    logger.info(
+        f"Report {job.report} finished with {dataset.metadata.row_count} rows "
+        f"in {dataset.metadata.elapsed_seconds:.2f}s from {dataset.metadata.source_url or 'derived data'}."
+    )
+    return status
+
+
+def _write_bundles(
+    bundles: List[WorkbookBundle],
+    configured: List[str],
+    results: Dict[str, Dataset],
+    statuses: List[ReportRunStatus],
+    artifacts: Optional[List[Artifact]] = None,
+) -> List[str]:
+    """
+    Write the workbook bundles of a run.
+
+    Args:
+        bundles (List[WorkbookBundle]): Bundles to write.
+        configured (List[str]): Reports configured in the run, in order;
+            included by bundles that do not list their reports.
+        results (Dict[str, Dataset]): Datasets of the successful reports.
+        statuses (List[ReportRunStatus]): Outcome of every report.
+        artifacts (Optional[List[Artifact]], optional): Files written by the
+            run, extended with the bundles.
+
+    Returns:
+        List[str]: Paths of the bundles written.
+    """
+    written = []
+    for bundle in bundles:
+        datasets = (
+            results
+            if bundle.reports is not None
+            else {name: results[name] for name in configured if name in results}
+        )
+        try:
+            path = write_bundle(bundle, datasets, statuses)
+        except Exception as e:
+            logger.error(f"Error writing bundle {bundle.name}: {e}")
+            continue
+        written.append(str(path))
+        if artifacts is not None:
+            reports = bundle.reports if bundle.reports is not None else list(datasets)
+            rows = sum(len(datasets[name].rows) for name in reports if name in datasets)
+            artifacts.append(Artifact(path, bundle.name, rows))
+    return written
+
+
+async def run_reports(
+    context: ReportContext,
+    config: RunConfig,
+    store: Optional[SnapshotStore] = None,
+    results: Optional[Dict[str, Dataset]] = None,
+) -> RunSummary:
+    """
+    Execute every report of a batch run configuration.
+
+    Args:
+        context (ReportContext): Shared scraping context.
+        config (RunConfig): Batch run configuration.
+        store (Optional[SnapshotStore], optional): Store where every successful
+            report run is persisted. Snapshots are not stored when omitted.
+        results (Optional[Dict[str, Dataset]], optional): Filled with the
+            dataset of every report fetched, by name, for callers that use
+            the rows themselves (e.g. the `lanx` CLI).
+
+    Returns:
+        RunSummary: Consolidated summary with the status of every report.
+
+    Raises:
+        KeyError: If a configured report is not registered.
+        ValueError: If the report dependencies contain a cycle.
+        ImportError: If an export plugin cannot be imported.
+    """
+    started_at = portal_now()
+    load_plugins()
+    jobs = _resolve_jobs(config)
+    stages = _execution_stages(jobs)
+    logger.info(f"Starting batch run with stages: {stages}")
+
+    if results is None:
+        results = {}
+    statuses: List[ReportRunStatus] = []
+    artifacts: List[Artifact] = []
+    limit = asyncio.Semaphore(config.max_parallel or len(jobs) or 1)
+
+    async def run_job(name: str) -> ReportRunStatus:
+        async with limit:
+            with span(f"report {name}", report=name) as current:
+                status = await _run_job(
+                    context, jobs[name], results, store, artifacts, config.dry_run
+                )
+                current.set_attribute("lanx.status", status.status)
+                current.set_attribute("lanx.rows", status.row_count)
+                return status
+
+    for stage in stages:
+        statuses.extend(await asyncio.gather(*(run_job(name) for name in stage)))
+
+    configured = [job.report for job in config.reports]
+    bundles: List[str] = []
+    manifests: List[str] = []
+    if config.dry_run:
+        for bundle in config.bundles:
+            logger.info(f"Dry run: would write bundle {bundle.name} to {bundle.target}.")
+    else:
+        bundles = await asyncio.to_thread(
+            _write_bundles, config.bundles, configured, results, statuses, artifacts
+        )
+    if config.manifest and not config.dry_run:
+        try:
+            paths = await asyncio.to_thread(
+                write_manifests, artifacts, config.manifest, started_at
+            )
+            manifests = [str(path) for path in paths]
+        except Exception as e:
+            logger.error(f"Error writing the manifests of the run: {e}")
+    summary = RunSummary(
+        started_at=started_at,
+        finished_at=portal_now(),
+        results=statuses,
+        bundles=bundles,
+        manifests=manifests,
+        dry_run=config.dry_run,
+    )
+    for status in statuses:
+        record_report_run(
+            status.report,
+            status.status,
+            status.duration_seconds,
+            status.row_count,
+            status.parse_errors,
+            status.error_kind,
+        )
+    logger.info(
+        f"Batch run finished: {sum(s.status == 'success' for s in statuses)}/{len(statuses)} reports succeeded."
+    )
+    return summary
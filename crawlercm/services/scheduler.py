@@ -0,0 +1,179 @@
+"""
+In-process scheduler of report runs.
+
+Runs jobs at the times of their cron expressions (see `core.utils.cron`),
+evaluated in a fixed time zone, for as long as the process lives; this is
+what `lanx serve-scheduler` runs on the automation server. Jobs run
+concurrently with each other, but never with themselves: a job still
+running when its next time comes is skipped for that time, with a
+warning, so slow portal days never pile up runs.
+
+Every record logged while a job runs, including the logs of the batch
+runner, is also written to the log file of the job
+(`SCHEDULE_LOG_DIR/<job>.log`), so the history of a job can be read on
+its own.
+"""
+
+import asyncio
+import contextvars
+import logging
+import time
+from dataclasses import dataclass, field
+from datetime import datetime
+from logging.handlers import RotatingFileHandler
+from pathlib import Path
+from typing import Any, Awaitable, Callable, List, Optional, Set, Tuple
+from zoneinfo import ZoneInfo
+
+from core.logger import TEXT_FORMAT, RedactingFilter, RedactingFormatter, logger
+from core.utils.cron import CronExpression
+
+# Runs later than this after their time are skipped.
+MISSED_RUN_SECONDS = 60
+
+_current_job: contextvars.ContextVar[Optional[str]] = contextvars.ContextVar(
+    "current_job", default=None
+)
+
+
+class _JobFilter(logging.Filter):
+    def __init__(self, name: str):
+        super().__init__()
+        self.job = name
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        return _current_job.get() == self.job
+
+
+@dataclass
+class ScheduledJob:
+    """
+    A job of the scheduler.
+
+    Args:
+        name (str): Job name, used in logs and as its log file name.
+        cron (CronExpression): When the job runs.
+        run (Callable[[], Awaitable[Any]]): Runs the job once.
+    """
+
+    name: str
+    cron: CronExpression
+    run: Callable[[], Awaitable[Any]]
+
+
+@dataclass
+class Scheduler:
+    """
+    Runs scheduled jobs until cancelled.
+
+    Args:
+        jobs (List[ScheduledJob]): Jobs to run.
+        timezone (str): Time zone of the cron expressions, e.g. "America/Sao_Paulo".
+        log_dir (Optional[Path], optional): Directory of the log files of
+            the jobs. Jobs only log to the application log when omitted.
+    """
+
+    jobs: List[ScheduledJob]
+    timezone: str
+    log_dir: Optional[Path] = None
+    _running: Set[str] = field(default_factory=set, init=False)
+    _tasks: Set["asyncio.Task[None]"] = field(default_factory=set, init=False)
+
+    def now(self) -> datetime:
+        """
+        Current time in the time zone of the scheduler.
+        """
+        return datetime.now(ZoneInfo(self.timezone))
+
+    def next_runs(
+        self, after: Optional[datetime] = None
+    ) -> List[Tuple[datetime, ScheduledJob]]:
+        """
+        Next run of every job, soonest first.
+
+        Args:
+            after (Optional[datetime], optional): Start of the search.
+                Defaults to now.
+        """
+        after = after or self.now()
+        return sorted(
+            ((job.cron.next_after(after), job) for job in self.jobs), key=lambda run: run[0]
+        )
+
+    def _job_handler(self, name: str) -> Optional[logging.Handler]:
+        if self.log_dir is None:
+            return None
+        self.log_dir.mkdir(parents=True, exist_ok=True)
+        handler = RotatingFileHandler(
+            self.log_dir / f"{name}.log", maxBytes=5_000_000, backupCount=5, encoding="utf-8"
+        )
+        handler.setFormatter(RedactingFormatter(TEXT_FORMAT))
+        handler.addFilter(RedactingFilter())
+        handler.addFilter(_JobFilter(name))
+        return handler
+
+    async def run_job(self, job: ScheduledJob) -> None:
+        """
+        Run a job once, unless it is already running.
+
+        Errors of the job are logged; they never stop the scheduler.
+        """
+        if job.name in self._running:
+            logger.warning(
+                f"Skipping scheduled job {job.name}: its previous run is still running"
+            )
+            return
+        self._running.add(job.name)
+        _current_job.set(job.name)
+        handler = self._job_handler(job.name)
+        if handler is not None:
+            logging.getLogger().addHandler(handler)
+        started = time.perf_counter()
+        try:
+            logger.info(f"Scheduled job {job.name} started.")
+            await job.run()
+            logger.info(
+                f"Scheduled job {job.name} finished in {time.perf_counter() - started:.2f}s."
+            )
+        except Exception as e:
+            logger.exception(f"Scheduled job {job.name} failed: {e}")
+        finally:
+            self._running.discard(job.name)
+            if handler is not None:
+                logging.getLogger().removeHandler(handler)
+                handler.close()
+
+    async def serve(self) -> None:
+        """
+        Run the jobs at their times, until cancelled.
+
+        Jobs still running when the scheduler is cancelled are cancelled too.
+        """
+        if not self.jobs:
+            raise ValueError("No scheduled jobs; configure them in SCHEDULES")
+        for when, job in self.next_runs():
+            logger.info(f"Scheduled job {job.name} ({job.cron.expression}) runs next at {when}.")
+        try:
+            after = self.now()
+            while True:
+                runs = self.next_runs(after)
+                when = runs[0][0]
+                delay = (when - self.now()).total_seconds()
+                if delay > 0:
+                    await asyncio.sleep(delay)
+                elif delay < -MISSED_RUN_SECONDS:
+                    # E.g. the server was suspended; missed runs are not caught up.
+                    logger.warning(f"Missed the runs of {when}; resuming from now")
+                    after = self.now()
+                    continue
+                for due, job in runs:
+                    if due != when:
+                        break
+                    task = asyncio.create_task(self.run_job(job), name=job.name)
+                    self._tasks.add(task)
+                    task.add_done_callback(self._tasks.discard)
+                after = when
+        finally:
+            for task in list(self._tasks):
+                task.cancel()
+            await asyncio.gather(*self._tasks, return_exceptions=True)
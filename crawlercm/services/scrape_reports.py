@@ -3,20 +3,26 @@ Service to scrape reports data from CM.
 
 This module contains functions that use an authenticated aiohttp client session
 to scrape various reports (sales pending orders, production pending orders,
-and pending materials) from the CM system. Helper functions are included to
-parse numeric and date strings from the HTML tables.
+and pending materials) from the CM system. Table rows are mapped into the
+report models with `core.utils.table_mapping.map_row`, following the
+columns declared in each schema. Rows that cannot be parsed are logged
+and dropped unless `STRICT_PARSING` is set.
 """
 
 import asyncio
+import math
+import re
 from collections import defaultdict
-from datetime import date, datetime
 from io import BytesIO
 import aiohttp
-from bs4 import BeautifulSoup
-from typing import List, Optional
+from bs4 import BeautifulSoup, Tag
+from typing import AsyncIterator, Callable, List, Optional, Type, TypeVar
 import pandas as pd
 
+from core.config import settings
 from core.logger import logger
+from core.tracing import span
+from core.utils.table_mapping import CellParseError, PageParseError, RowParseError, map_row
 from schemas.reports_schemas import (
     FilteredSalesReportItem,
     PendingMaterialsItem,
@@ -24,81 +30,111 @@ from schemas.reports_schemas import (
     PendingOrdersItem,
 )
 
+M = TypeVar("M")
 
-def _parse_float(value: str) -> float:
-    """
-    Converte uma string para float, lidando com formatos brasileiros (ex: "1.300,00")
-    e aplicando uma heurística para valores ambíguos como "1.300".
-
-    Args:
-        value (str): A string numérica a ser convertida.
+# Rows per page of the grid of the pending materials report.
+PENDING_MATERIALS_PAGE_SIZE = 20
 
-    Returns:
-        float: O valor float convertido. Retorna 0.0 se a conversão falhar.
-    """
-    if not isinstance(value, str):
-        return 0.0
+# Row count of the summary of a grid, e.g. "Exibindo 1-20 de 1.234 resultados."
+GRID_TOTAL_PATTERN = re.compile(r"\bde\s+([\d.]+)\s+(?:resultado|registro|ite)", re.IGNORECASE)
+# Page of a link of the pager of a grid, e.g. "...&Pedido_page=62".
+PAGER_PAGE_PATTERN = re.compile(r"_page=(\d+)")
 
-    cleaned_value = value.strip()
-    if not cleaned_value:
-        return 0.0
 
-    if "," in cleaned_value:
-        cleaned_value = cleaned_value.replace(".", "").replace(",", ".")
-
-    elif "." in cleaned_value:
-        parts = cleaned_value.split(".")
-        if len(parts[-1]) == 3 and len(parts) > 1:
-            cleaned_value = "".join(parts)
-        else:
-            cleaned_value = "".join(parts[:-1]) + "." + parts[-1]
+def _report_rows(html: str, url: str) -> List[Tag]:
+    """
+    Rows of the report table of a page, without its header.
 
-    try:
-        return float(cleaned_value)
-    except (ValueError, TypeError):
-        return 0.0
+    Raises:
+        PageParseError: If the page has no report table, e.g. when CM served
+            its login or error page instead.
+    """
+    table = BeautifulSoup(html, "html.parser").find("table", {"id": "tableExpo"})
+    if table is None:
+        raise PageParseError(f"No report table (tableExpo) in the page of {url}")
+    return table.find_all("tr")[1:]
 
 
-def _parse_int(value: str) -> int:
+def _page_count(html: str, page_size: int) -> Optional[int]:
     """
-    Parse a string into an integer using `_parse_float`.
+    Number of pages of a paged grid, from its summary or its pager.
 
     Args:
-        value (str): Numeric string.
+        html (str): A page of the grid.
+        page_size (int): Rows per page.
 
     Returns:
-        int: Parsed integer value.
+        Optional[int]: The page count, or None if the page shows neither
+        the row count nor a pager.
     """
-    float_value = _parse_float(value)
-    return int(float_value)
+    soup = BeautifulSoup(html, "html.parser")
+    summary = soup.find(class_="summary")
+    if summary is not None:
+        match = GRID_TOTAL_PATTERN.search(summary.get_text(" "))
+        if match:
+            return max(math.ceil(int(match.group(1).replace(".", "")) / page_size), 1)
+    pager = soup.find(class_=re.compile(r"pager|pagination"))
+    if pager is not None:
+        pages = [
+            int(match.group(1))
+            for link in pager.find_all("a", href=True)
+            if (match := PAGER_PAGE_PATTERN.search(link["href"]))
+        ]
+        if pages:
+            return max(pages)
+    return None
 
 
-def _parse_date(date_str: str) -> Optional[date]:
+def _parse_row(
+    model: Type[M],
+    cells: List[str],
+    row: int,
+    url: str,
+    errors: Optional[List[CellParseError]],
+    page: int = 1,
+) -> Optional[M]:
     """
-    Parse a short date string into a date object.
+    Map a table row, recording the cells that could not be parsed.
+
+    Rows with unparsable required fields are dropped, so the scrape goes on
+    with partial results, unless `STRICT_PARSING` is set.
 
     Args:
-        date_str (str): Date string in format "DD/MM/YY".
+        model (Type[M]): Report model.
+        cells (List[str]): Raw text of each cell of the row.
+        row (int): Position of the row in the table.
+        url (str): URL of the report page.
+        errors (Optional[List[CellParseError]]): Collects the parse errors.
+        page (int, optional): Page of the report the row is on. Defaults to 1.
 
     Returns:
-        Optional[date]: Date object, or None if parsing fails.
+        Optional[M]: The parsed item, or None if the row was dropped.
+
+    Raises:
+        RowParseError: If the row cannot be parsed and `STRICT_PARSING` is set.
     """
-    if not date_str or not date_str.strip():
+    try:
+        item = map_row(model, cells, row=row, page=page, url=url)
+    except RowParseError as e:
+        if settings.STRICT_PARSING:
+            raise
+        logger.warning(f"Row dropped: {e}")
+        if errors is not None:
+            errors.extend(e.errors)
         return None
-
-    formats_to_try = ["%d/%m/%Y", "%d/%m/%y"]
-
-    for fmt in formats_to_try:
-        try:
-            return datetime.strptime(date_str, fmt).date()
-        except ValueError:
-            continue
-
-    return None
+    for error in item.parse_errors:
+        logger.warning(str(error))
+    if errors is not None:
+        errors.extend(item.parse_errors)
+    return item
 
 
 async def scrape_sales_pending_orders(
-    client: aiohttp.ClientSession, url: str, init_date: str, end_date: str
+    client: aiohttp.ClientSession,
+    url: str,
+    init_date: str,
+    end_date: str,
+    errors: Optional[List[CellParseError]] = None,
 ) -> List[SalesReportItem]:
     """
     Scrape the sales pending orders report from the CM system.
@@ -108,67 +144,49 @@ async def scrape_sales_pending_orders(
         url (str): URL of the sales pending orders report.
         init_date (str): Start date in "DD/MM/YYYY" format.
         end_date (str): End date in "DD/MM/YYYY" format.
+        errors (Optional[List[CellParseError]]): Collects the cells that
+            could not be parsed.
 
     Returns:
         List[SalesReportItem]: List of parsed sales report items.
 
     Raises:
         aiohttp.ClientError: If the HTTP request fails.
+        PageParseError: If the page has no report table.
+        RowParseError: If a row cannot be parsed and `STRICT_PARSING` is set.
     """
-    try:
-        headers = {
-            "User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-        }
-        params = {
-            "RelatorioPedidosPendentes[dataInicio]": f"{init_date}",
-            "RelatorioPedidosPendentes[dataFim]": f"{end_date}",
-            "RelatorioPedidosPendentes[considerarForecast]": "0",
-            "RelatorioPedidosPendentes[emissor]": "37299632",
-            "RelatorioPedidosPendentes[situacao]": "",
-        }
-        logger.info("Scraping sales pending orders...")
-        async with client.get(
-            url, headers=headers, params=params
-        ) as response:
-            response.raise_for_status()
-            html = await response.text()
-            soup = BeautifulSoup(html, "html.parser")
-            table = soup.find("table", {"id": "tableExpo"})
-            trs = table.find_all("tr")[1:]
+    headers = {
+        "User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+    }
+    params = {
+        "RelatorioPedidosPendentes[dataInicio]": f"{init_date}",
+        "RelatorioPedidosPendentes[dataFim]": f"{end_date}",
+        "RelatorioPedidosPendentes[considerarForecast]": "0",
+        "RelatorioPedidosPendentes[emissor]": "37299632",
+        "RelatorioPedidosPendentes[situacao]": "",
+    }
+    logger.info("Scraping sales pending orders...")
+    async with client.get(
+        url, headers=headers, params=params
+    ) as response:
+        response.raise_for_status()
+        html = await response.text()
+        with span("parse", report="pending_sales"):
+            trs = _report_rows(html, url)
             items_found: List[SalesReportItem] = []
 
-            for tr in trs:
+            for row_index, tr in enumerate(trs, start=1):
                 if not tr.text.strip():
                     break
 
                 tds = tr.find_all("td")
                 if len(tds) >= 14:
-                    item = SalesReportItem(
-                        cliente=tds[0].text.strip(),
-                        negociacao=tds[1].text.strip(),
-                        tipo_servico=tds[2].text.strip(),
-                        pedido_cliente=tds[4].text.strip(),
-                        op=tds[5].text.strip(),
-                        numero_projeto=tds[6].text.strip(),
-                        codigo=tds[7].text.strip(),
-                        produto=tds[8].text.strip(),
-                        condicao_pagamento=tds[18].text.strip(),
-                        emissao_pv=tds[3].text.strip() or None,
-                        previsao=tds[9].text.strip() or None,
-                        qtde_pendente=_parse_int(tds[10].text),
-                        estoque=_parse_int(tds[11].text),
-                        valor_unitario=_parse_float(tds[12].text),
-                        ipi=_parse_float(tds[13].text),
-                        valor_total=_parse_float(tds[14].text),
-                        custo_estrutura=_parse_float(tds[15].text),
-                        lucratividade_rs=_parse_float(tds[16].text),
-                        lucratividade_percentual=_parse_float(tds[17].text),
+                    item = _parse_row(
+                        SalesReportItem, [td.text for td in tds], row_index, url, errors
                     )
-                    items_found.append(item)
+                    if item is not None:
+                        items_found.append(item)
             logger.info(f"Pendind Sales Items found: {len(items_found)}")
-    except aiohttp.ClientError as e:
-        logger.error(f"Error scraping sales pending orders: {e}")
-        return []
 
     return items_found
 
@@ -179,6 +197,7 @@ async def scrape_prod_pending_orders(
     init_date: str,
     end_date: str,
     yii_token: str,
+    errors: Optional[List[CellParseError]] = None,
 ) -> List[PendingOrdersItem]:
     """
     Scrape the production pending orders report from the CM system.
@@ -189,124 +208,158 @@ async def scrape_prod_pending_orders(
         init_date (str): Start date in "DD/MM/YYYY" format.
         end_date (str): End date in "DD/MM/YYYY" format.
         yii_token (str): CSRF token required for POST requests.
+        errors (Optional[List[CellParseError]]): Collects the cells that
+            could not be parsed.
 
     Returns:
         List[PendingOrdersItem]: List of parsed production pending orders.
 
     Raises:
         aiohttp.ClientError: If the HTTP request fails.
+        PageParseError: If the page has no report table.
+        RowParseError: If a row cannot be parsed and `STRICT_PARSING` is set.
     """
-    try:
-        headers = {
-            "User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-        }
-
-        params = {
-            "dataInicio": init_date,
-            "dataFim": end_date,
-            "clienteId": "",
-            "YII_CSRF_TOKEN": yii_token,
-        }
-        logger.info("Scraping production pending orders...")
-        async with client.post(
-            url, headers=headers, params=params
-        ) as response:
-            response.raise_for_status()
-            html = await response.text()
-            soup = BeautifulSoup(html, "html.parser")
-            table = soup.find("table", {"id": "tableExpo"})
-            trs = table.find_all("tr")[1:]
+    headers = {
+        "User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+    }
+
+    params = {
+        "dataInicio": init_date,
+        "dataFim": end_date,
+        "clienteId": "",
+        "YII_CSRF_TOKEN": yii_token,
+    }
+    logger.info("Scraping production pending orders...")
+    async with client.post(
+        url, headers=headers, params=params
+    ) as response:
+        response.raise_for_status()
+        html = await response.text()
+        with span("parse", report="pending_orders"):
+            trs = _report_rows(html, url)
             items_found: List[PendingOrdersItem] = []
-            for tr in trs:
+            for row_index, tr in enumerate(trs, start=1):
                 tds = tr.find_all("td")
                 if tds:
-                    item = PendingOrdersItem(
-                        op=tds[0].text.strip(),
-                        cliente=tds[1].text.strip(),
-                        codigo=tds[2].text.strip(),
-                        produto=tds[3].text.strip(),
-                        criacao=_parse_date(tds[4].text.strip()),
-                        prazo=_parse_date(tds[5].text.strip()),
-                        quantidade=_parse_int(tds[6].text.strip()),
-                        peso=_parse_float(tds[7].text.strip()),
-                        etapa=tds[8].text.strip(),
+                    item = _parse_row(
+                        PendingOrdersItem, [td.text for td in tds], row_index, url, errors
                     )
-                    items_found.append(item)
+                    if item is not None:
+                        items_found.append(item)
             logger.info(f"Pending Orders Items found: {len(items_found)}")
-    except aiohttp.ClientError as e:
-        logger.error(f"Error scraping production pending orders: {e}")
-        return []
 
     return items_found
 
 
-async def scrape_pending_materials(
+async def scrape_pending_materials_pages(
     client: aiohttp.ClientSession,
     url: str,
-) -> List[PendingMaterialsItem]:
+    errors: Optional[List[CellParseError]] = None,
+    on_page_count: Optional[Callable[[int], None]] = None,
+) -> AsyncIterator[List[PendingMaterialsItem]]:
     """
-    Scrape the pending materials report from the CM system.
+    Scrape the pending materials report from the CM system, page by page.
+
+    Pages of `PENDING_MATERIALS_PAGE_SIZE` rows are requested in turn from
+    the grid of the report (`Pedido_page`), until a page comes back short.
+    A page repeating the previous one also ends the report, should CM serve
+    every row at once.
 
     Args:
         client (aiohttp.ClientSession): Authenticated aiohttp client session.
         url (str): URL of the pending materials report.
+        errors (Optional[List[CellParseError]]): Collects the cells that
+            could not be parsed.
+        on_page_count (Optional[Callable[[int], None]]): Receives the number
+            of pages of the report, read from the summary or the pager of
+            the first page, when it shows them.
 
-    Returns:
-        List[PendingMaterialsItem]: List of parsed pending materials items.
+    Yields:
+        List[PendingMaterialsItem]: Parsed pending materials items of every page.
 
     Raises:
         aiohttp.ClientError: If the HTTP request fails.
+        PageParseError: If a page has no report table.
+        RowParseError: If a row cannot be parsed and `STRICT_PARSING` is set.
     """
-    try:
-        headers = {
-            "User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-        }
-
-        params = {
-            "Pedido[_nomeMaterial]": "",
-            "Pedido[_solicitante]": "",
-            "Pedido[status_id]": "",
-            "Pedido[situacao]": "TODAS",
-            "Pedido[_qtdeFornecida]": "Parcialmente",
-            "Pedido[_inicioCriacao]": "01/01/2025",
-            "Pedido[_fimCriacao]": "",
-            "pageSize": "20",
-        }
-        logger.info("Scraping pending materials")
+    headers = {
+        "User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+    }
+
+    params = {
+        "Pedido[_nomeMaterial]": "",
+        "Pedido[_solicitante]": "",
+        "Pedido[status_id]": "",
+        "Pedido[situacao]": "TODAS",
+        "Pedido[_qtdeFornecida]": "Parcialmente",
+        "Pedido[_inicioCriacao]": "01/01/2025",
+        "Pedido[_fimCriacao]": "",
+        "pageSize": str(PENDING_MATERIALS_PAGE_SIZE),
+    }
+    logger.info("Scraping pending materials")
+    previous: Optional[List[List[str]]] = None
+    page = 1
+    while True:
         async with client.get(
-            url, headers=headers, params=params
+            url, headers=headers, params={**params, "Pedido_page": str(page)}
         ) as response:
             response.raise_for_status()
             html = await response.text()
-            soup = BeautifulSoup(html, "html.parser")
-            table = soup.find("table", {"id": "tableExpo"})
-            trs = table.find_all("tr")[1:]
+        with span("parse", report="pending_materials", page=page):
+            if page == 1 and on_page_count is not None:
+                page_count = _page_count(html, PENDING_MATERIALS_PAGE_SIZE)
+                if page_count is not None:
+                    on_page_count(page_count)
+            cells = [
+                [td.text for td in tds]
+                for tds in (tr.find_all("td") for tr in _report_rows(html, url))
+                if tds
+            ]
+            if cells == previous:
+                break
             items_found: List[PendingMaterialsItem] = []
-            for tr in trs:
-                tds = tr.find_all("td")
-                if tds:
-                    item = PendingMaterialsItem(
-                        criacao=_parse_date(tds[0].text.strip()),
-                        servico=tds[1].text.strip(),
-                        codigo=tds[2].text.strip(),
-                        material=tds[3].text.strip(),
-                        op=tds[4].text.strip(),
-                        produto=tds[5].text.strip(),
-                        sub_produto=tds[6].text.strip(),
-                        previsao_op=_parse_date(tds[7].text.strip()),
-                        quantidade=_parse_float(tds[8].text.strip().split(" ")[0]),
-                        pendente=_parse_float(tds[9].text.strip().split(" ")[0]),
-                        unidade=tds[9].text.strip().split(" ")[-1],
-                        situacao=tds[10].text.strip(),
-                        previsao_mp=_parse_date(tds[11].text.strip()),
-                    )
+            for row_index, row in enumerate(cells, start=1):
+                item = _parse_row(PendingMaterialsItem, row, row_index, url, errors, page)
+                if item is not None:
                     items_found.append(item)
-            logger.info(f"Pending Materials Items found: {len(items_found)}")
-    except aiohttp.ClientError as e:
-        logger.error(f"Error scraping pending materials: {e}")
-        return []
+            logger.info(f"Pending Materials Items found on page {page}: {len(items_found)}")
+        yield items_found
+        if len(cells) < PENDING_MATERIALS_PAGE_SIZE:
+            break
+        previous = cells
+        page += 1
 
-    return items_found
+
+async def scrape_pending_materials(
+    client: aiohttp.ClientSession,
+    url: str,
+    errors: Optional[List[CellParseError]] = None,
+    on_page_count: Optional[Callable[[int], None]] = None,
+) -> List[PendingMaterialsItem]:
+    """
+    Scrape every page of the pending materials report from the CM system.
+
+    Args:
+        client (aiohttp.ClientSession): Authenticated aiohttp client session.
+        url (str): URL of the pending materials report.
+        errors (Optional[List[CellParseError]]): Collects the cells that
+            could not be parsed.
+        on_page_count (Optional[Callable[[int], None]]): Receives the number
+            of pages of the report, when CM shows it.
+
+    Returns:
+        List[PendingMaterialsItem]: List of parsed pending materials items.
+
+    Raises:
+        aiohttp.ClientError: If the HTTP request fails.
+        PageParseError: If a page has no report table.
+        RowParseError: If a row cannot be parsed and `STRICT_PARSING` is set.
+    """
+    return [
+        item
+        async for page in scrape_pending_materials_pages(client, url, errors, on_page_count)
+        for item in page
+    ]
 
 
 def combine_data(
@@ -388,13 +441,13 @@ async def get_combined_report_data(
     """
     try:
         logger.info("Starting parallel tasks for scraping of all report sources...")
-        
+
         tasks = [
             scrape_sales_pending_orders(client, urls["sales"], init_date_str, end_date_str),
             scrape_prod_pending_orders(client, urls["prod"], init_date_str, end_date_str, csrf_token),
             scrape_pending_materials(client, urls["materials"]),
         ]
-        
+
         sales_data, orders_data, materials_data = await asyncio.gather(*tasks, return_exceptions=True)
 
         for result in [sales_data, orders_data, materials_data]:
@@ -403,9 +456,9 @@ async def get_combined_report_data(
                 raise result
 
         logger.info("All scraping tasks completed successfully. Combining data...")
-        
+
         combined_list = combine_data(sales_data, orders_data, materials_data)
-        
+
         logger.info("Data combined successfully!")
     except Exception as e:
         logger.error(f"Error combining data: {e}")
@@ -0,0 +1,186 @@
+"""
+Change events of the webhook subscriptions.
+
+After a scheduled refresh stores a new snapshot of a report, the run is
+compared with the previous snapshot of the report (see
+`services.report_diff`) and turned into change events:
+
+- `added` / `removed`: rows only in the new / the old run, such as new
+  materials;
+- `price_changed`: rows whose price field (`ReportDefinition.price_field`)
+  changed, and `changed` for rows where only other fields changed;
+- `late`: rows past their due date (`ReportDefinition.due_field`) in the
+  portal timezone that were not late yet in the previous run, such as
+  orders missing their deadline.
+
+Every subscription of the report (see `core.subscription_store`) receives
+the events of its kinds and rows in one signed POST, sent by the webhook
+destination with its retries (see `services.export.webhook`). The first
+run of a report, with nothing to compare with, notifies nothing.
+"""
+
+import asyncio
+import uuid
+from datetime import date
+from typing import Any, Dict, List, Optional
+
+from core.logger import logger
+from core.snapshot_store import SnapshotStore, snapshot_store
+from core.subscription_store import SubscriptionStore, subscription_store
+from core.utils.parsers import portal_now, portal_today
+from core.utils.records import value_of
+from schemas.runner_schemas import RunSummary
+from schemas.snapshot_schemas import Snapshot
+from schemas.subscription_schemas import ChangeEvent, ChangeNotification
+from services.export.webhook import Webhook
+from services.report_diff import diff
+from services.report_registry import ReportDefinition, get_report
+
+
+def _due_date(row: Dict[str, Any], field: str) -> Optional[date]:
+    value = row.get(field)
+    if not value:
+        return None
+    try:
+        return date.fromisoformat(str(value)[:10])
+    except ValueError:
+        return None
+
+
+def matches(row: Dict[str, Any], filter: Dict[str, Any]) -> bool:
+    """
+    Whether a row has every value of a subscription filter, compared as text ignoring case.
+    """
+    return all(
+        str(value_of(row, name, "")).casefold() == str(expected).casefold()
+        for name, expected in filter.items()
+    )
+
+
+def change_events(
+    definition: ReportDefinition, previous: Snapshot, current: Snapshot, today: date
+) -> List[ChangeEvent]:
+    """
+    Changes of a report between two of its snapshots.
+
+    Args:
+        definition (ReportDefinition): Report of the snapshots; its key
+            identifies the rows.
+        previous (Snapshot): Older run.
+        current (Snapshot): Newer run.
+        today (date): Date rows are late after, in the portal timezone.
+
+    Returns:
+        List[ChangeEvent]: Added, removed, changed and late rows.
+
+    Raises:
+        ValueError: If the report has no key fields.
+    """
+    key_fields = definition.key_fields
+    result = diff(previous.rows, current.rows, key_fields)
+    events = [
+        ChangeEvent(type="added", key={name: row.get(name) for name in key_fields}, row=row)
+        for row in result.added
+    ]
+    events += [
+        ChangeEvent(type="removed", key={name: row.get(name) for name in key_fields}, row=row)
+        for row in result.removed
+    ]
+    for change in result.changed:
+        price_changed = (
+            definition.price_field is not None and definition.price_field in change.fields
+        )
+        events.append(
+            ChangeEvent(
+                type="price_changed" if price_changed else "changed",
+                key=change.key,
+                row=change.new,
+                old=change.old,
+                fields=change.fields,
+            )
+        )
+    if definition.due_field:
+        field = definition.due_field
+        old_rows = {tuple(row.get(name) for name in key_fields): row for row in previous.rows}
+        previous_day = previous.created_at.date()
+        for row in current.rows:
+            due = _due_date(row, field)
+            if due is None or due >= today:
+                continue
+            old = old_rows.get(tuple(row.get(name) for name in key_fields))
+            old_due = _due_date(old, field) if old is not None else None
+            if old_due is not None and old_due < previous_day:
+                continue
+            key = {name: row.get(name) for name in key_fields}
+            events.append(ChangeEvent(type="late", key=key, row=row, fields=[field]))
+    return events
+
+
+async def notify_subscribers(
+    summary: RunSummary,
+    store: SnapshotStore = snapshot_store,
+    subscriptions: SubscriptionStore = subscription_store,
+) -> int:
+    """
+    Send the changes of the reports stored by a run to their subscriptions.
+
+    Failed deliveries are logged and do not affect the others.
+
+    Args:
+        summary (RunSummary): Summary of the run, with the stored snapshots.
+        store (SnapshotStore, optional): Store of the snapshots.
+        subscriptions (SubscriptionStore, optional): Store of the subscriptions.
+
+    Returns:
+        int: Number of notifications delivered.
+    """
+    delivered = 0
+    for status in summary.results:
+        if status.status != "success" or not status.snapshot_id:
+            continue
+        subscribed = subscriptions.list(status.report)
+        if not subscribed:
+            continue
+        ids = [info.id for info in store.list(status.report)]
+        if status.snapshot_id not in ids or ids.index(status.snapshot_id) == 0:
+            continue
+        previous_id = ids[ids.index(status.snapshot_id) - 1]
+        try:
+            events = change_events(
+                get_report(status.report),
+                store.load(status.report, previous_id),
+                store.load(status.report, status.snapshot_id),
+                portal_today(),
+            )
+        except (KeyError, ValueError) as e:
+            logger.error(f"Cannot compare the runs of {status.report} for its subscriptions: {e}")
+            continue
+        for subscription in subscribed:
+            matching = [
+                event
+                for event in events
+                if event.type in subscription.events and matches(event.row, subscription.filter)
+            ]
+            if not matching:
+                continue
+            notification = ChangeNotification(
+                subscription=subscription.id,
+                report=status.report,
+                snapshot=status.snapshot_id,
+                previous_snapshot=previous_id,
+                detected_at=portal_now(),
+                events=matching,
+            )
+            body = notification.model_dump_json().encode("utf-8")
+            webhook = Webhook(subscription.url, secret=subscription.secret, chunk_size=0)
+            try:
+                await asyncio.to_thread(webhook.post, body, str(uuid.uuid4()))
+            except RuntimeError as e:
+                logger.error(f"Error notifying subscription {subscription.id}: {e}")
+                continue
+            delivered += 1
+            logger.info(
+                f"Sent {len(matching)} changes of {status.report} to subscription "
+                f"{subscription.id}."
+            )
+    return delivered
@@ -0,0 +1,243 @@
+"""
+Row-level validation of report data.
+
+Rules check a single row (required fields, non-negative prices, sane
+dates, CNPJ check digits) and have a configurable severity: rows breaking
+an `error` rule are rejected before reaching exports and the ERP, while
+`warning` rules only flag them. Every report has a default rule set in
+`DEFAULT_RULES`, whose severities can be overridden by rule name.
+"""
+
+import re
+from dataclasses import dataclass, replace
+from datetime import date, datetime
+from typing import Any, Callable, Dict, Iterable, List, Optional, Sequence, Tuple, TypeVar, Union
+
+from pydantic import BaseModel
+
+from core.logger import logger
+from core.utils.parsers import portal_today
+from core.utils.table_mapping import ReportRow
+from schemas.validation_schemas import Severity, ValidationIssue, ValidationReport
+
+T = TypeVar("T", bound=Union[BaseModel, Dict[str, Any]])
+
+
+@dataclass(frozen=True)
+class Rule:
+    """
+    A validation rule applied to every row of a dataset.
+
+    Attributes:
+        name (str): Unique rule name, used to override its severity.
+        field (str): Field checked by the rule.
+        check (Callable[[Any], Optional[str]]): Receives the field value and
+            returns an error message, or None when the value is valid.
+        severity (Severity): Severity of the rule.
+    """
+
+    name: str
+    field: str
+    check: Callable[[Any], Optional[str]]
+    severity: Severity = Severity.ERROR
+
+
+def is_valid_cnpj(value: str) -> bool:
+    """
+    Check the format and check digits of a CNPJ.
+
+    Args:
+        value (str): CNPJ, with or without punctuation.
+
+    Returns:
+        bool: Whether the CNPJ is valid.
+    """
+    digits = re.sub(r"\D", "", value or "")
+    if len(digits) != 14 or len(set(digits)) == 1:
+        return False
+
+    def check_digit(base: str) -> str:
+        weights = list(range(len(base) - 7, 1, -1)) + list(range(9, 1, -1))
+        total = sum(int(d) * w for d, w in zip(base, weights))
+        remainder = total % 11
+        return "0" if remainder < 2 else str(11 - remainder)
+
+    first = check_digit(digits[:12])
+    second = check_digit(digits[:12] + first)
+    return digits[12:] == first + second
+
+
+def required(field: str, severity: Severity = Severity.ERROR) -> Rule:
+    """
+    The field must be present and not blank.
+    """
+
+    def check(value: Any) -> Optional[str]:
+        if value is None or (isinstance(value, str) and not value.strip()):
+            return f"{field} is required"
+        return None
+
+    return Rule(f"required:{field}", field, check, severity)
+
+
+def non_negative(field: str, severity: Severity = Severity.ERROR) -> Rule:
+    """
+    The field, when present, must not be negative.
+    """
+
+    def check(value: Any) -> Optional[str]:
+        if value is not None and value < 0:
+            return f"{field} must not be negative"
+        return None
+
+    return Rule(f"non_negative:{field}", field, check, severity)
+
+
+def sane_date(
+    field: str,
+    min_year: int = 2000,
+    max_years_ahead: int = 5,
+    severity: Severity = Severity.WARNING,
+) -> Rule:
+    """
+    The date, when present, must be within a plausible range.
+    """
+
+    def check(value: Any) -> Optional[str]:
+        if value is None:
+            return None
+        value_date = value.date() if isinstance(value, datetime) else value
+        if isinstance(value_date, str):
+            value_date = date.fromisoformat(value_date[:10])
+        today = portal_today()
+        if value_date.year < min_year or value_date.year > today.year + max_years_ahead:
+            return f"{field} {value_date.isoformat()} is out of the expected range"
+        return None
+
+    return Rule(f"sane_date:{field}", field, check, severity)
+
+
+def valid_cnpj(field: str, severity: Severity = Severity.ERROR) -> Rule:
+    """
+    The field, when present, must hold a valid CNPJ.
+    """
+
+    def check(value: Any) -> Optional[str]:
+        if value in (None, ""):
+            return None
+        if not is_valid_cnpj(str(value)):
+            return f"{field} is not a valid CNPJ"
+        return None
+
+    return Rule(f"valid_cnpj:{field}", field, check, severity)
+
+
+DEFAULT_RULES: Dict[str, List[Rule]] = {
+    "pending_sales": [
+        required("op"),
+        required("codigo"),
+        non_negative("valor_unitario"),
+        non_negative("valor_total"),
+        non_negative("qtde_pendente", Severity.WARNING),
+        sane_date("emissao_pv"),
+        sane_date("previsao"),
+    ],
+    "pending_orders": [
+        required("op"),
+        required("codigo"),
+        non_negative("quantidade"),
+        sane_date("criacao"),
+        sane_date("prazo"),
+    ],
+    "pending_materials": [
+        required("codigo"),
+        non_negative("quantidade"),
+        non_negative("pendente"),
+        sane_date("criacao"),
+        sane_date("previsao_op"),
+        sane_date("previsao_mp"),
+    ],
+    "filtered_sales_report": [
+        required("op"),
+        non_negative("valor_unitario"),
+        non_negative("valor_total"),
+        sane_date("previsao"),
+    ],
+}
+
+
+def rules_for(report: str, overrides: Optional[Dict[str, Severity]] = None) -> List[Rule]:
+    """
+    Default rules of a report, with severities overridden by rule name.
+
+    Args:
+        report (str): Report name.
+        overrides (Optional[Dict[str, Severity]]): Severity per rule name
+            (e.g. {"sane_date:previsao": "error"}).
+
+    Returns:
+        List[Rule]: Rules to apply.
+    """
+    overrides = overrides or {}
+    return [
+        replace(rule, severity=Severity(overrides[rule.name])) if rule.name in overrides else rule
+        for rule in DEFAULT_RULES.get(report, [])
+    ]
+
+
+def _cell_source(row: Any, field: str) -> Optional[str]:
+    """
+    Describe the portal cell a field of a row was parsed from, if known.
+    """
+    source = row.source(field) if isinstance(row, ReportRow) else None
+    return str(source) if source else None
+
+
+def validate_rows(rows: Sequence[T], rules: Iterable[Rule]) -> Tuple[List[T], ValidationReport]:
+    """
+    Validate every row of a dataset.
+
+    Args:
+        rows (Sequence[T]): Rows to validate, as models or dictionaries.
+        rules (Iterable[Rule]): Rules to apply.
+
+    Returns:
+        Tuple[List[T], ValidationReport]: Rows that were not rejected, and the
+        report of every issue found.
+    """
+    active_rules = [rule for rule in rules if rule.severity != Severity.IGNORE]
+    report = ValidationReport(total_rows=len(rows))
+    accepted: List[T] = []
+    for index, row in enumerate(rows):
+        values = row if isinstance(row, dict) else row.__dict__
+        rejected = False
+        for rule in active_rules:
+            value = values.get(rule.field)
+            try:
+                message = rule.check(value)
+            except (TypeError, ValueError) as e:
+                message = f"{rule.field} could not be checked: {e}"
+            if message is None:
+                continue
+            report.issues.append(
+                ValidationIssue(
+                    row_index=index,
+                    rule=rule.name,
+                    field=rule.field,
+                    value=value if isinstance(value, (str, int, float, bool)) else str(value),
+                    message=message,
+                    severity=rule.severity,
+                    source=_cell_source(row, rule.field),
+                )
+            )
+            rejected = rejected or rule.severity == Severity.ERROR
+        if rejected:
+            report.rejected_rows.append(index)
+        else:
+            accepted.append(row)
+
+    if report.issues:
+        logger.warning(
+            f"Validation found {len(report.issues)} issues, {len(report.rejected_rows)} rows rejected."
+        )
+    return accepted, report
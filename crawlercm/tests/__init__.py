@@ -0,0 +1,21 @@
+"""
+Unit tests of the scraper, for the logic that runs without CM:
+
+    python -m unittest discover -s tests -t .
+
+Run from `crawlercm`. The settings without default get placeholder values,
+so the modules reading them can be imported without a `.env` file.
+"""
+
+import os
+
+for _name in (
+    "LOGIN_URL",
+    "HOME_URL",
+    "SALES_PENDING_ORDER_URL",
+    "PROD_PENDING_ORDER_URL",
+    "PENDING_MATERIALS_URL",
+):
+    os.environ.setdefault(_name, "https://cm.test/")
+os.environ.setdefault("USERNAME", "test")
+os.environ.setdefault("PASSWORD", "test")
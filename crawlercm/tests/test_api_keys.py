@@ -0,0 +1,82 @@
+import os
+import unittest
+from unittest import mock
+
+from core.api_keys import ANONYMOUS, InvalidApiKey, Principal, authenticate, bearer_key
+from core.config import ApiKey, settings
+
+API_KEYS = {
+    "dashboard": ApiKey(key="dash-key", reports=["filtered_sales_report"]),
+    "purchasing": ApiKey(key_env="LANX_TEST_PURCHASING_KEY", actions=["refresh"]),
+}
+
+
+class AuthenticateTest(unittest.TestCase):
+    def setUp(self):
+        patcher = mock.patch.object(settings, "API_KEYS", API_KEYS)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_open_without_api_keys(self):
+        with mock.patch.object(settings, "API_KEYS", {}):
+            self.assertIs(authenticate(None), ANONYMOUS)
+
+    def test_key_given_in_the_settings(self):
+        principal = authenticate("dash-key")
+        self.assertEqual(principal.name, "dashboard")
+        self.assertEqual(principal.reports, ["filtered_sales_report"])
+        self.assertEqual(principal.actions, ["read"])
+
+    def test_key_read_from_its_variable(self):
+        with mock.patch.dict(os.environ, {"LANX_TEST_PURCHASING_KEY": "buy-key"}):
+            self.assertEqual(authenticate("buy-key").name, "purchasing")
+
+    def test_key_without_its_variable_matches_nothing(self):
+        with mock.patch.dict(os.environ):
+            os.environ.pop("LANX_TEST_PURCHASING_KEY", None)
+            with self.assertRaises(InvalidApiKey):
+                authenticate("")
+            with self.assertRaises(InvalidApiKey):
+                authenticate("buy-key")
+
+    def test_missing_and_unknown_keys(self):
+        with self.assertRaises(InvalidApiKey):
+            authenticate(None)
+        with self.assertRaises(InvalidApiKey):
+            authenticate("other-key")
+
+
+class PrincipalTest(unittest.TestCase):
+    def test_actions_include_the_lower_ones(self):
+        refresh = Principal("purchasing", actions=["refresh"])
+        self.assertTrue(refresh.can("read"))
+        self.assertTrue(refresh.can("refresh"))
+        self.assertFalse(refresh.can("admin"))
+        self.assertTrue(Principal("ops").can("admin"))
+
+    def test_reports(self):
+        dashboard = Principal("dashboard", reports=["filtered_sales_report"])
+        self.assertTrue(dashboard.sees("filtered_sales_report"))
+        self.assertFalse(dashboard.sees("pending_materials"))
+        self.assertTrue(Principal("ops").sees("pending_materials"))
+
+    def test_check(self):
+        dashboard = Principal("dashboard", reports=["filtered_sales_report"], actions=["read"])
+        dashboard.check("read", ["filtered_sales_report"])
+        with self.assertRaisesRegex(PermissionError, "cannot refresh"):
+            dashboard.check("refresh", ["filtered_sales_report"])
+        with self.assertRaisesRegex(PermissionError, "cannot access pending_materials"):
+            dashboard.check("read", ["filtered_sales_report", "pending_materials"])
+
+
+class BearerKeyTest(unittest.TestCase):
+    def test_bearer_key(self):
+        self.assertEqual(bearer_key("Bearer dash-key"), "dash-key")
+        self.assertEqual(bearer_key("bearer  dash-key "), "dash-key")
+        self.assertIsNone(bearer_key("Basic dXNlcjpwYXNz"))
+        self.assertIsNone(bearer_key("Bearer "))
+        self.assertIsNone(bearer_key(None))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,90 @@
+import asyncio
+import unittest
+from datetime import datetime
+from unittest import mock
+
+from core.cache import TTLCache
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+
+
+def dataset(rows):
+    return Dataset(
+        metadata=DatasetMetadata(report="pending_orders", fetched_at=datetime(2025, 1, 31)),
+        rows=rows,
+    )
+
+
+class Fetcher:
+    def __init__(self, value, delay=0.0):
+        self.value = value
+        self.delay = delay
+        self.calls = 0
+
+    async def __call__(self):
+        self.calls += 1
+        await asyncio.sleep(self.delay)
+        return self.value
+
+
+class TTLCacheTest(unittest.IsolatedAsyncioTestCase):
+    async def test_second_request_is_a_hit(self):
+        cache = TTLCache(60)
+        fetch = Fetcher([{"op": "1"}])
+        self.assertEqual(await cache.get_or_fetch(("pending_orders",), fetch), [{"op": "1"}])
+        self.assertEqual(await cache.get_or_fetch(("pending_orders",), fetch), [{"op": "1"}])
+        self.assertEqual(fetch.calls, 1)
+
+    async def test_entries_expire(self):
+        cache = TTLCache(60)
+        with mock.patch("core.cache.time.monotonic", return_value=1000.0):
+            cache.set(("pending_orders",), [{"op": "1"}])
+        with mock.patch("core.cache.time.monotonic", return_value=1059.0):
+            self.assertEqual(cache.get(("pending_orders",)), [{"op": "1"}])
+        with mock.patch("core.cache.time.monotonic", return_value=1060.0):
+            self.assertIsNone(cache.get(("pending_orders",)))
+
+    async def test_report_ttls_override_the_default(self):
+        cache = TTLCache(60, {"pending_orders": 0})
+        fetch = Fetcher([{"op": "1"}])
+        await cache.get_or_fetch(("pending_orders",), fetch)
+        await cache.get_or_fetch(("pending_orders",), fetch)
+        self.assertEqual(fetch.calls, 2)
+        self.assertEqual(cache.ttl_of(("pending_sales", "2025-01-01")), 60)
+
+    async def test_empty_results_are_not_cached(self):
+        cache = TTLCache(60)
+        for empty in ([], {}, None, dataset([])):
+            fetch = Fetcher(empty)
+            await cache.get_or_fetch(("pending_orders",), fetch)
+            await cache.get_or_fetch(("pending_orders",), fetch)
+            self.assertEqual(fetch.calls, 2, empty)
+
+    async def test_datasets_with_rows_are_cached(self):
+        cache = TTLCache(60)
+        fetch = Fetcher(dataset([{"op": "1"}]))
+        await cache.get_or_fetch(("pending_orders",), fetch)
+        await cache.get_or_fetch(("pending_orders",), fetch)
+        self.assertEqual(fetch.calls, 1)
+
+    async def test_concurrent_requests_share_one_fetch(self):
+        cache = TTLCache(60)
+        fetch = Fetcher([{"op": "1"}], delay=0.01)
+        results = await asyncio.gather(
+            *(cache.get_or_fetch(("pending_orders",), fetch) for _ in range(5))
+        )
+        self.assertEqual(results, [[{"op": "1"}]] * 5)
+        self.assertEqual(fetch.calls, 1)
+        self.assertEqual(cache._locks, {})
+        self.assertEqual(cache._lock_users, {})
+
+    async def test_invalidate_reports(self):
+        cache = TTLCache(60)
+        cache.set(("pending_orders", "a"), [1])
+        cache.set(("pending_orders", "b"), [2])
+        cache.set(("pending_sales", "a"), [3])
+        self.assertEqual(cache.invalidate_reports(["pending_orders"]), 2)
+        self.assertEqual(cache.get(("pending_sales", "a")), [3])
+
+
+if __name__ == "__main__":
+    unittest.main()
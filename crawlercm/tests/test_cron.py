@@ -0,0 +1,82 @@
+import unittest
+from datetime import datetime
+from zoneinfo import ZoneInfo
+
+from core.utils.cron import CronExpression
+
+
+class CronExpressionTest(unittest.TestCase):
+    def test_parses_ranges_steps_lists_and_names(self):
+        cron = CronExpression.parse("*/15 8-18/2 1,15 jan-mar mon-fri")
+        self.assertEqual(cron.minutes, {0, 15, 30, 45})
+        self.assertEqual(cron.hours, {8, 10, 12, 14, 16, 18})
+        self.assertEqual(cron.days, {1, 15})
+        self.assertEqual(cron.months, {1, 2, 3})
+        self.assertEqual(cron.weekdays, {1, 2, 3, 4, 5})
+
+    def test_sunday_is_0_or_7(self):
+        self.assertEqual(CronExpression.parse("0 0 * * 7").weekdays, {0})
+        self.assertEqual(CronExpression.parse("0 0 * * sun").weekdays, {0})
+
+    def test_shortcuts(self):
+        daily = CronExpression.parse("@daily")
+        self.assertEqual((daily.minutes, daily.hours), ({0}, {0}))
+        self.assertEqual(
+            daily.next_after(datetime(2025, 1, 31, 12, 0)), datetime(2025, 2, 1, 0, 0)
+        )
+
+    def test_rejects_invalid_expressions(self):
+        invalid = [
+            "",
+            "* * * *",
+            "60 * * * *",
+            "0 24 * * *",
+            "0 0 * * funday",
+            "*/0 * * * *",
+            "5-1 * * * *",
+        ]
+        for expression in invalid:
+            with self.subTest(expression=expression):
+                with self.assertRaises(ValueError):
+                    CronExpression.parse(expression)
+
+    def test_next_after_skips_to_the_next_weekday(self):
+        cron = CronExpression.parse("0 7 * * mon-fri")
+        # Friday at 07:00 exactly: the run of that moment is not returned.
+        self.assertEqual(
+            cron.next_after(datetime(2025, 1, 3, 7, 0)), datetime(2025, 1, 6, 7, 0)
+        )
+        self.assertEqual(
+            cron.next_after(datetime(2025, 1, 6, 6, 59, 30)), datetime(2025, 1, 6, 7, 0)
+        )
+
+    def test_next_after_crosses_the_year(self):
+        cron = CronExpression.parse("30 6 1 jan *")
+        self.assertEqual(
+            cron.next_after(datetime(2025, 3, 1, 0, 0)), datetime(2026, 1, 1, 6, 30)
+        )
+
+    def test_day_of_month_or_day_of_week_when_both_restricted(self):
+        cron = CronExpression.parse("0 0 13 * fri")
+        # 2025-06-06 is a Friday, before the 13th.
+        self.assertEqual(
+            cron.next_after(datetime(2025, 6, 1, 0, 0)), datetime(2025, 6, 6, 0, 0)
+        )
+        self.assertEqual(
+            cron.next_after(datetime(2025, 6, 12, 0, 0)), datetime(2025, 6, 13, 0, 0)
+        )
+
+    def test_keeps_the_time_zone_of_the_moment(self):
+        zone = ZoneInfo("America/Sao_Paulo")
+        cron = CronExpression.parse("0 7 * * *")
+        run = cron.next_after(datetime(2025, 1, 1, 8, 0, tzinfo=zone))
+        self.assertEqual(run, datetime(2025, 1, 2, 7, 0, tzinfo=zone))
+        self.assertIs(run.tzinfo, zone)
+
+    def test_never_running_expression(self):
+        with self.assertRaises(ValueError):
+            CronExpression.parse("0 0 30 feb *").next_after(datetime(2025, 1, 1))
+
+
+if __name__ == "__main__":
+    unittest.main()
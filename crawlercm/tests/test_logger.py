@@ -0,0 +1,69 @@
+import json
+import logging
+import sys
+import unittest
+
+from core.logger import (
+    TEXT_FORMAT,
+    JsonFormatter,
+    RedactingFilter,
+    RedactingFormatter,
+    mask_secrets,
+)
+
+URL = "https://cm.test/grid?r=pedidos&YII_CSRF_TOKEN=s3cr3t&page=2"
+
+
+def record(message, *args, exc_info=None):
+    return logging.LogRecord("app", logging.ERROR, __file__, 1, message, args, exc_info)
+
+
+def failure():
+    try:
+        raise RuntimeError(f"GET {URL} failed")
+    except RuntimeError:
+        return sys.exc_info()
+
+
+class MaskSecretsTest(unittest.TestCase):
+    def test_masks_tokens_in_urls(self):
+        self.assertEqual(
+            mask_secrets(URL), "https://cm.test/grid?r=pedidos&YII_CSRF_TOKEN=***&page=2"
+        )
+
+    def test_masks_cookies_passwords_and_headers(self):
+        self.assertEqual(mask_secrets("PHPSESSID=abc; lang=pt"), "PHPSESSID=***; lang=pt")
+        self.assertEqual(mask_secrets("{'password': 'hunter2'}"), "{'password': '***'}")
+        self.assertEqual(mask_secrets('"csrf_token": "xyz"'), '"csrf_token": "***"')
+        self.assertEqual(
+            mask_secrets("Authorization: Bearer abc.def"), "Authorization: Bearer ***"
+        )
+
+    def test_keeps_other_text(self):
+        message = "Fetched 10 rows of pending_orders"
+        self.assertEqual(mask_secrets(message), message)
+
+
+class RedactingFilterTest(unittest.TestCase):
+    def test_masks_the_formatted_message(self):
+        entry = record("Error fetching %s", URL)
+        self.assertTrue(RedactingFilter().filter(entry))
+        self.assertNotIn("s3cr3t", entry.getMessage())
+        self.assertIn("YII_CSRF_TOKEN=***", entry.getMessage())
+
+
+class FormatterTest(unittest.TestCase):
+    def test_text_tracebacks_are_masked(self):
+        text = RedactingFormatter(TEXT_FORMAT).format(record("boom", exc_info=failure()))
+        self.assertIn("Traceback", text)
+        self.assertIn("YII_CSRF_TOKEN=***", text)
+        self.assertNotIn("s3cr3t", text)
+
+    def test_json_exceptions_are_masked(self):
+        entry = json.loads(JsonFormatter().format(record("boom", exc_info=failure())))
+        self.assertIn("YII_CSRF_TOKEN=***", entry["exception"])
+        self.assertNotIn("s3cr3t", entry["exception"])
+
+
+if __name__ == "__main__":
+    unittest.main()
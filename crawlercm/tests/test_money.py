@@ -0,0 +1,37 @@
+import unittest
+from decimal import Decimal
+
+from pydantic import BaseModel
+
+from core.utils.money import Money, round_money, sum_money, to_cents
+
+
+class Price(BaseModel):
+    valor: Money
+
+
+class MoneyTest(unittest.TestCase):
+    def test_validates_pt_br_text_and_numbers_exactly(self):
+        self.assertEqual(Price(valor="R$ 1.234,56").valor, Decimal("1234.56"))
+        self.assertEqual(Price(valor=0.1).valor, Decimal("0.1"))
+        self.assertEqual(Price(valor=3).valor, Decimal("3"))
+
+    def test_serializes_json_numbers(self):
+        price = Price(valor="R$ 10,50")
+        self.assertEqual(price.model_dump(mode="json"), {"valor": 10.5})
+        self.assertEqual(price.model_dump(), {"valor": Decimal("10.50")})
+
+    def test_arithmetic_is_exact(self):
+        total = sum_money(Price(valor=value).valor for value in [0.1, 0.2])
+        self.assertEqual(total, Decimal("0.3"))
+        self.assertEqual(sum_money([]), Decimal("0"))
+
+    def test_rounds_half_up_to_cents(self):
+        self.assertEqual(round_money(Decimal("2.345")), Decimal("2.35"))
+        self.assertEqual(round_money(Decimal("2.344")), Decimal("2.34"))
+        self.assertEqual(to_cents(Decimal("10.005")), 1001)
+        self.assertEqual(to_cents(Decimal("-1.5")), -150)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,109 @@
+import unittest
+from datetime import date, datetime
+from decimal import Decimal
+from enum import Enum
+
+from core.utils.parsers import (
+    PORTAL_TZ,
+    parse_bool,
+    parse_currency,
+    parse_date,
+    parse_datetime,
+    parse_decimal,
+    parse_enum,
+    parse_int,
+    parse_money,
+    parse_percent,
+    parse_quantity,
+)
+
+
+class Situacao(Enum):
+    ABERTO = "Aberto"
+    FECHADO = "Fechado"
+
+
+class NumberParsersTest(unittest.TestCase):
+    def test_decimal(self):
+        cases = {
+            "1.234,56": 1234.56,
+            "1234,5": 1234.5,
+            "1.300": 1300.0,
+            "1.300.000": 1300000.0,
+            "12.5": 12.5,
+            "-3,25": -3.25,
+            " 7 ": 7.0,
+        }
+        for text, expected in cases.items():
+            with self.subTest(text=text):
+                self.assertEqual(parse_decimal(text), expected)
+
+    def test_decimal_of_blank_or_invalid_text_is_zero(self):
+        for text in ["", "   ", "abc", None]:
+            with self.subTest(text=text):
+                self.assertEqual(parse_decimal(text), 0.0)
+
+    def test_int(self):
+        self.assertEqual(parse_int("1.500"), 1500)
+        self.assertEqual(parse_int("12,9"), 12)
+
+    def test_currency(self):
+        self.assertEqual(parse_currency("R$ 1.234,56"), 1234.56)
+        self.assertEqual(parse_currency("R$\xa010,00"), 10.0)
+        self.assertEqual(parse_currency("-R$ 10,00"), -10.0)
+        self.assertEqual(parse_currency(None), 0.0)
+
+    def test_money_is_exact(self):
+        self.assertEqual(parse_money("R$ 1.234,56"), Decimal("1234.56"))
+        self.assertEqual(parse_money("0,10"), Decimal("0.10"))
+        self.assertEqual(parse_money(""), Decimal("0"))
+        self.assertEqual(parse_money("R$ -"), Decimal("0"))
+
+    def test_percent_and_quantity(self):
+        self.assertEqual(parse_percent("12,5%"), 12.5)
+        self.assertEqual(parse_quantity("1.500,00 M"), 1500.0)
+        self.assertEqual(parse_quantity(""), 0.0)
+
+
+class TextParsersTest(unittest.TestCase):
+    def test_bool(self):
+        for text in ["Sim", "S", " x ", "✓"]:
+            with self.subTest(text=text):
+                self.assertTrue(parse_bool(text))
+        for text in ["Não", "N", "", None]:
+            with self.subTest(text=text):
+                self.assertFalse(parse_bool(text))
+
+    def test_enum_matches_values_and_names(self):
+        parser = parse_enum(Situacao)
+        self.assertIs(parser(" aberto "), Situacao.ABERTO)
+        self.assertIs(parser("FECHADO"), Situacao.FECHADO)
+        self.assertEqual(parser(" Cancelado "), "Cancelado")
+
+
+class DateParsersTest(unittest.TestCase):
+    def test_date(self):
+        self.assertEqual(parse_date("31/01/2025"), date(2025, 1, 31))
+        self.assertEqual(parse_date(" 31/01/25 "), date(2025, 1, 31))
+        self.assertIsNone(parse_date("2025-01-31"))
+        self.assertIsNone(parse_date(""))
+
+    def test_datetime_is_aware_in_the_portal_timezone(self):
+        self.assertEqual(
+            parse_datetime("02/01/2006 15:04"), datetime(2006, 1, 2, 15, 4, tzinfo=PORTAL_TZ)
+        )
+        self.assertEqual(
+            parse_datetime("02/01/2006  15:04:05"),
+            datetime(2006, 1, 2, 15, 4, 5, tzinfo=PORTAL_TZ),
+        )
+
+    def test_datetime_of_a_date_is_midnight(self):
+        self.assertEqual(parse_datetime("02/01/2006"), datetime(2006, 1, 2, tzinfo=PORTAL_TZ))
+
+    def test_invalid_datetime(self):
+        self.assertIsNone(parse_datetime("ontem"))
+        self.assertIsNone(parse_datetime(" "))
+
+
+if __name__ == "__main__":
+    unittest.main()
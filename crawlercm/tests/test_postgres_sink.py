@@ -0,0 +1,36 @@
+import unittest
+
+from services.export.postgres_sink import PostgresSink
+
+COLUMNS = ["codigo", "op", "valor_unitario", "run_id"]
+KEYS = ["codigo", "op"]
+
+
+class PostgresSinkTest(unittest.TestCase):
+    def setUp(self):
+        self.sink = PostgresSink(dsn="postgresql://lanx@db.test/lanx", schema="staging")
+
+    def test_upsert_updates_the_columns_outside_the_key(self):
+        self.assertEqual(
+            self.sink.upsert_sql("pending_orders", COLUMNS, KEYS),
+            'INSERT INTO "staging"."pending_orders" '
+            '("codigo", "op", "valor_unitario", "run_id") VALUES (%s, %s, %s, %s) '
+            'ON CONFLICT ("codigo", "op") DO UPDATE SET '
+            '"valor_unitario" = EXCLUDED."valor_unitario", "run_id" = EXCLUDED."run_id"',
+        )
+
+    def test_insert(self):
+        self.assertEqual(
+            self.sink.insert_sql("run_log", ["run_id", "report"]),
+            'INSERT INTO "staging"."run_log" ("run_id", "report") VALUES (%s, %s)',
+        )
+
+    def test_rejects_unsafe_identifiers(self):
+        with self.assertRaises(ValueError):
+            self.sink.upsert_sql('orders"; DROP TABLE x; --', COLUMNS, KEYS)
+        with self.assertRaises(ValueError):
+            PostgresSink(dsn="postgresql://lanx@db.test/lanx", schema="staging.x")
+
+
+if __name__ == "__main__":
+    unittest.main()
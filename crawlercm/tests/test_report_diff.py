@@ -0,0 +1,84 @@
+import unittest
+from decimal import Decimal
+
+from pydantic import BaseModel
+
+from services.report_diff import diff
+
+
+class Item(BaseModel):
+    codigo: str
+    fornecedor: str
+    valor_unitario: Decimal
+
+
+class DiffTest(unittest.TestCase):
+    def test_classifies_added_removed_and_changed_rows(self):
+        old = [
+            {"codigo": "A", "valor": 1},
+            {"codigo": "B", "valor": 2},
+            {"codigo": "C", "valor": 3},
+        ]
+        new = [
+            {"codigo": "B", "valor": 2},
+            {"codigo": "C", "valor": 4},
+            {"codigo": "D", "valor": 5},
+        ]
+        result = diff(old, new, ["codigo"])
+        self.assertEqual(result.added, [{"codigo": "D", "valor": 5}])
+        self.assertEqual(result.removed, [{"codigo": "A", "valor": 1}])
+        self.assertEqual(len(result.changed), 1)
+        change = result.changed[0]
+        self.assertEqual(change.key, {"codigo": "C"})
+        self.assertEqual(change.fields, ["valor"])
+        self.assertEqual((change.old["valor"], change.new["valor"]), (3, 4))
+        self.assertEqual(result.duplicated, [])
+        self.assertFalse(result.is_empty)
+
+    def test_identical_runs_are_empty(self):
+        rows = [{"codigo": "A", "valor": 1}]
+        self.assertTrue(diff(rows, list(rows), ["codigo"]).is_empty)
+
+    def test_composite_key_and_models(self):
+        old = [Item(codigo="A", fornecedor="X", valor_unitario=Decimal("1.50"))]
+        new = [
+            Item(codigo="A", fornecedor="X", valor_unitario=Decimal("1.75")),
+            Item(codigo="A", fornecedor="Y", valor_unitario=Decimal("1.60")),
+        ]
+        result = diff(old, new, ["codigo", "fornecedor"])
+        self.assertEqual([row["fornecedor"] for row in result.added], ["Y"])
+        self.assertEqual(result.changed[0].key, {"codigo": "A", "fornecedor": "X"})
+        self.assertEqual(result.changed[0].fields, ["valor_unitario"])
+
+    def test_ignored_fields_are_not_changes(self):
+        old = [{"codigo": "A", "valor": 1, "lido_em": "10:00"}]
+        new = [{"codigo": "A", "valor": 1, "lido_em": "11:00"}]
+        self.assertTrue(diff(old, new, ["codigo"], ignore_fields=["lido_em"]).is_empty)
+
+    def test_fields_missing_from_one_run_are_changes(self):
+        result = diff([{"codigo": "A"}], [{"codigo": "A", "valor": 1}], ["codigo"])
+        self.assertEqual(result.changed[0].fields, ["valor"])
+
+    def test_duplicated_keys_are_listed_and_compared_by_their_last_row(self):
+        old = [{"codigo": "A", "valor": 1}, {"codigo": "A", "valor": 2}]
+        new = [
+            {"codigo": "A", "valor": 2},
+            {"codigo": "B", "valor": 1},
+            {"codigo": "B", "valor": 1},
+        ]
+        result = diff(old, new, ["codigo"])
+        self.assertEqual(result.duplicated, [{"codigo": "A"}, {"codigo": "B"}])
+        self.assertEqual(result.changed, [])
+        self.assertEqual(result.added, [{"codigo": "B", "valor": 1}])
+
+    def test_requires_a_key(self):
+        with self.assertRaises(ValueError):
+            diff([], [], [])
+
+    def test_rows_without_the_key_field(self):
+        with self.assertRaises(KeyError):
+            diff([{"valor": 1}], [], ["codigo"])
+
+
+if __name__ == "__main__":
+    unittest.main()
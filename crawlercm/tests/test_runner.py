@@ -0,0 +1,104 @@
+import asyncio
+import os
+import unittest
+from unittest import mock
+
+from schemas.runner_schemas import ReportJob, RunConfig
+from services.report_registry import REPORTS, ReportContext, ReportDefinition
+from services.runner import run_reports
+
+
+async def _fetch_stock(context, filters, deps):
+    return [{"codigo": "A", "estoque": 3}, {"codigo": "B", "estoque": 0}]
+
+
+async def _fetch_in_stock(context, filters, deps):
+    return [row for row in deps["stock"] if row["estoque"] > 0]
+
+
+async def _fetch_slow(context, filters, deps):
+    await asyncio.sleep(1)
+    return [{"codigo": "A"}]
+
+
+async def _fetch_failing(context, filters, deps):
+    raise RuntimeError("GET https://cm.test/grid?YII_CSRF_TOKEN=s3cr3t failed")
+
+
+TEST_REPORTS = {
+    definition.name: definition
+    for definition in [
+        ReportDefinition(name="stock", description="Stock.", fetch=_fetch_stock),
+        ReportDefinition(
+            name="in_stock",
+            description="Stock above zero.",
+            fetch=_fetch_in_stock,
+            depends_on=["stock"],
+        ),
+        ReportDefinition(name="slow", description="Slow.", fetch=_fetch_slow),
+        ReportDefinition(name="failing", description="Failing.", fetch=_fetch_failing),
+        ReportDefinition(
+            name="after_failing",
+            description="Depends on a failing report.",
+            fetch=_fetch_stock,
+            depends_on=["failing"],
+        ),
+    ]
+}
+
+
+class RunReportsTest(unittest.IsolatedAsyncioTestCase):
+    def setUp(self):
+        patcher = mock.patch.dict(REPORTS, TEST_REPORTS)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.context = ReportContext(client=None, csrf_token="s3cr3t")
+
+    async def run_config(self, *jobs, **options):
+        results = {}
+        config = RunConfig(reports=list(jobs), **options)
+        summary = await run_reports(self.context, config, results=results)
+        return summary, results, {status.report: status for status in summary.results}
+
+    async def test_dependencies_run_first_even_when_not_configured(self):
+        summary, results, statuses = await self.run_config(ReportJob(report="in_stock"))
+        self.assertTrue(summary.succeeded)
+        self.assertEqual([status.report for status in summary.results], ["stock", "in_stock"])
+        self.assertEqual(results["in_stock"].rows, [{"codigo": "A", "estoque": 3}])
+        self.assertEqual(statuses["in_stock"].row_count, 1)
+
+    async def test_times_are_aware(self):
+        summary, _, _ = await self.run_config(ReportJob(report="stock"))
+        self.assertIsNotNone(summary.started_at.tzinfo)
+        self.assertIsNotNone(summary.finished_at.tzinfo)
+
+    async def test_timeout_fails_only_its_report(self):
+        summary, results, statuses = await self.run_config(
+            ReportJob(report="slow", timeout=0.01), ReportJob(report="stock")
+        )
+        self.assertFalse(summary.succeeded)
+        self.assertEqual(statuses["slow"].status, "failed")
+        self.assertIn("slow timed out after 0.01s", statuses["slow"].error)
+        self.assertEqual(statuses["stock"].status, "success")
+        self.assertNotIn("slow", results)
+
+    async def test_reports_depending_on_a_failed_report_are_skipped(self):
+        _, _, statuses = await self.run_config(ReportJob(report="after_failing"))
+        self.assertEqual(statuses["failing"].status, "failed")
+        self.assertEqual(statuses["after_failing"].status, "skipped")
+
+    async def test_errors_are_stored_without_session_data(self):
+        _, _, statuses = await self.run_config(ReportJob(report="failing"))
+        self.assertIn("YII_CSRF_TOKEN=***", statuses["failing"].error)
+        self.assertNotIn("s3cr3t", statuses["failing"].error)
+
+    async def test_dry_runs_deliver_nothing(self):
+        job = ReportJob(report="stock", destinations=["tmp/tests/stock.csv"])
+        summary, _, statuses = await self.run_config(job, dry_run=True)
+        self.assertTrue(summary.dry_run)
+        self.assertEqual(statuses["stock"].destinations, ["tmp/tests/stock.csv"])
+        self.assertFalse(os.path.exists("tmp/tests/stock.csv"))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,88 @@
+import hashlib
+import hmac
+import io
+import json
+import unittest
+from datetime import datetime
+from unittest import mock
+from urllib.error import HTTPError
+
+from core.config import settings
+from schemas.dataset_schemas import Dataset, DatasetMetadata
+from services.export.webhook import Webhook, signature
+
+URL = "https://erp.test/hooks/lanx?token=abc"
+
+
+def dataset(count):
+    return Dataset(
+        metadata=DatasetMetadata(report="pending_orders", fetched_at=datetime(2025, 1, 31)),
+        rows=[{"op": str(number)} for number in range(count)],
+    )
+
+
+def http_error(code):
+    return HTTPError(URL, code, "error", {}, io.BytesIO(b"try later"))
+
+
+class SignatureTest(unittest.TestCase):
+    def test_signs_the_timestamp_and_the_body(self):
+        body = b'{"rows": []}'
+        expected = hmac.new(b"s3cr3t", b'1738332000.{"rows": []}', hashlib.sha256).hexdigest()
+        self.assertEqual(signature("s3cr3t", "1738332000", body), f"sha256={expected}")
+
+    def test_depends_on_the_secret(self):
+        self.assertNotEqual(
+            signature("s3cr3t", "1738332000", b"{}"), signature("other", "1738332000", b"{}")
+        )
+
+
+class WebhookTest(unittest.TestCase):
+    def setUp(self):
+        patcher = mock.patch("services.export.webhook.urlopen")
+        self.urlopen = patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def requests(self):
+        return [call.args[0] for call in self.urlopen.call_args_list]
+
+    def test_signed_requests_can_be_verified(self):
+        Webhook(URL, secret="s3cr3t", chunk_size=0).send(dataset(2))
+        (request,) = self.requests()
+        timestamp = request.get_header("X-lanx-timestamp")
+        self.assertEqual(
+            request.get_header("X-lanx-signature"), signature("s3cr3t", timestamp, request.data)
+        )
+        self.assertEqual(json.loads(request.data)["rows"], [{"op": "0"}, {"op": "1"}])
+
+    def test_unsigned_without_secret(self):
+        with mock.patch.object(settings, "WEBHOOK_SECRET", None):
+            Webhook(URL, chunk_size=0).send(dataset(1))
+        (request,) = self.requests()
+        self.assertIsNone(request.get_header("X-lanx-signature"))
+        self.assertIsNone(request.get_header("X-lanx-timestamp"))
+
+    def test_chunks_share_the_delivery_id(self):
+        sent = Webhook(URL, secret="s3cr3t", chunk_size=2).send(dataset(5))
+        self.assertEqual(sent, 3)
+        requests = self.requests()
+        chunks = [json.loads(request.data)["chunk"] for request in requests]
+        self.assertEqual(chunks[-1], {"index": 3, "count": 3, "rows": 1})
+        self.assertEqual(len({request.get_header("X-lanx-delivery") for request in requests}), 1)
+
+    def test_retries_transient_failures(self):
+        self.urlopen.side_effect = [http_error(503), mock.MagicMock()]
+        with mock.patch("services.export.webhook.time.sleep"):
+            Webhook(URL, secret="s3cr3t", chunk_size=0, retries=2, retry_delay=0).send(dataset(1))
+        self.assertEqual(self.urlopen.call_count, 2)
+
+    def test_rejected_requests_are_not_retried(self):
+        self.urlopen.side_effect = http_error(400)
+        webhook = Webhook(URL, secret="s3cr3t", chunk_size=0, retries=2, retry_delay=0)
+        with self.assertRaisesRegex(RuntimeError, "erp.test/hooks/lanx failed: 400"):
+            webhook.send(dataset(1))
+        self.assertEqual(self.urlopen.call_count, 1)
+
+
+if __name__ == "__main__":
+    unittest.main()